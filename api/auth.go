@@ -51,7 +51,8 @@ func (auth Auth) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 	}
 
 	encode(res, data.Auth{
-		Token: token,
-		Email: email,
+		Token:      token,
+		Email:      email,
+		PassChange: user.PassChange,
 	})
 }
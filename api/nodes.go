@@ -2,8 +2,11 @@ package api
 
 import (
 	"encoding/json"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/simpleiot/simpleiot/data"
@@ -68,6 +71,27 @@ func (h *Nodes) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 		}
 	}
 
+	// users that must change their password can only read, and set their
+	// own password
+	passOnly := false
+
+	if validUser && userID != "" && req.Method != http.MethodGet {
+		passChange, err := h.passChangeRequired(userID)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if passChange {
+			if id != userID || head != "points" {
+				http.Error(res, "password change required", http.StatusForbidden)
+				return
+			}
+
+			passOnly = true
+		}
+	}
+
 	if id == "" {
 		switch req.Method {
 		case http.MethodGet:
@@ -84,6 +108,8 @@ func (h *Nodes) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 				http.Error(res, err.Error(), http.StatusNotFound)
 				return
 			}
+
+			data.Nodes(nodes).Redact()
 			if len(nodes) > 0 {
 				en := json.NewEncoder(res)
 				en.Encode(nodes)
@@ -115,6 +141,7 @@ func (h *Nodes) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 			if err != nil {
 				http.Error(res, err.Error(), http.StatusNotFound)
 			} else {
+				node.Redact()
 				en := json.NewEncoder(res)
 				en.Encode(node)
 			}
@@ -144,7 +171,52 @@ func (h *Nodes) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 
 	case "samples", "points":
 		if req.Method == http.MethodPost {
-			h.processPoints(res, req, id)
+			h.processPoints(res, req, id, passOnly)
+			return
+		}
+
+		http.Error(res, "only POST allowed", http.StatusMethodNotAllowed)
+		return
+
+	case "history":
+		if req.Method == http.MethodGet {
+			h.getHistory(res, req, id)
+			return
+		}
+
+		http.Error(res, "only GET allowed", http.StatusMethodNotAllowed)
+		return
+
+	case "events":
+		if req.Method == http.MethodGet {
+			h.getEvents(res, req, id)
+			return
+		}
+
+		http.Error(res, "only GET allowed", http.StatusMethodNotAllowed)
+		return
+
+	case "deliveries":
+		if req.Method == http.MethodGet {
+			h.getDeliveries(res, req, id)
+			return
+		}
+
+		http.Error(res, "only GET allowed", http.StatusMethodNotAllowed)
+		return
+
+	case "ack":
+		if req.Method == http.MethodPost {
+			h.alarmAck(res, req, id, userID)
+			return
+		}
+
+		http.Error(res, "only POST allowed", http.StatusMethodNotAllowed)
+		return
+
+	case "eval":
+		if req.Method == http.MethodPost {
+			h.evalRule(res, req, id)
 			return
 		}
 
@@ -290,7 +362,21 @@ func (h *Nodes) insertNode(res http.ResponseWriter, req *http.Request) {
 	encode(res, data.StandardResponse{Success: true, ID: node.ID})
 }
 
-func (h *Nodes) processPoints(res http.ResponseWriter, req *http.Request, id string) {
+// passChangeRequired returns true if a user must change their password
+func (h *Nodes) passChangeRequired(userID string) (bool, error) {
+	user, err := nats.GetNode(h.nc, userID, "skip")
+	if err != nil {
+		return false, err
+	}
+
+	passChange, _ := user.Points.ValueBool("", data.PointTypePassChange, 0)
+
+	return passChange, nil
+}
+
+// processPoints writes points to a node. If passOnly is set, only the
+// password may be written.
+func (h *Nodes) processPoints(res http.ResponseWriter, req *http.Request, id string, passOnly bool) {
 	decoder := json.NewDecoder(req.Body)
 	var points data.Points
 	err := decoder.Decode(&points)
@@ -299,6 +385,15 @@ func (h *Nodes) processPoints(res http.ResponseWriter, req *http.Request, id str
 		return
 	}
 
+	if passOnly {
+		for _, p := range points {
+			if p.Type != data.PointTypePass {
+				http.Error(res, "password change required", http.StatusForbidden)
+				return
+			}
+		}
+	}
+
 	err = nats.SendNodePointsCreate(h.nc, id, points, true)
 
 	if err != nil {
@@ -309,3 +404,214 @@ func (h *Nodes) processPoints(res http.ResponseWriter, req *http.Request, id str
 	en := json.NewEncoder(res)
 	en.Encode(data.StandardResponse{Success: true, ID: id})
 }
+
+// alarmAck acknowledges, shelves, or unshelves the alarm of a rule node. The
+// user is taken from the JWT. Requests that use the auth token must specify
+// the user in the body.
+func (h *Nodes) alarmAck(res http.ResponseWriter, req *http.Request, id, userID string) {
+	var ack data.AlarmAck
+	if err := decode(req.Body, &ack); err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if userID != "" {
+		ack.UserID = userID
+	}
+
+	if ack.Action == "" {
+		ack.Action = data.AlarmActionAck
+	}
+
+	err := nats.SendAlarmAck(h.nc, id, ack)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	encode(res, data.StandardResponse{Success: true, ID: id})
+}
+
+// evalRule evaluates a rule without side effects, with optional hypothetical
+// points, and returns the result of each condition, the rule state, and the
+// actions that would run
+func (h *Nodes) evalRule(res http.ResponseWriter, req *http.Request, id string) {
+	// the body is optional
+	var evalReq data.RuleEvalRequest
+	if err := decode(req.Body, &evalReq); err != nil && err != io.EOF {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	eval, err := nats.EvalRule(h.nc, id, evalReq)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	encode(res, eval)
+}
+
+// getHistory returns points from the local history store. The following
+// query parameters may be used to filter points: id, type, index, start,
+// end (RFC3339 times), and limit.
+func (h *Nodes) getHistory(res http.ResponseWriter, req *http.Request, id string) {
+	params := req.URL.Query()
+
+	query := data.HistoryQuery{
+		ID:    params.Get("id"),
+		Type:  params.Get("type"),
+		Index: -1,
+	}
+
+	var err error
+
+	if index := params.Get("index"); index != "" {
+		query.Index, err = strconv.Atoi(index)
+		if err != nil {
+			http.Error(res, "invalid index: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if start := params.Get("start"); start != "" {
+		query.Start, err = time.Parse(time.RFC3339, start)
+		if err != nil {
+			http.Error(res, "invalid start: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if end := params.Get("end"); end != "" {
+		query.End, err = time.Parse(time.RFC3339, end)
+		if err != nil {
+			http.Error(res, "invalid end: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if limit := params.Get("limit"); limit != "" {
+		query.Limit, err = strconv.Atoi(limit)
+		if err != nil {
+			http.Error(res, "invalid limit: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if rollup := params.Get("rollup"); rollup != "" {
+		query.Rollup, err = time.ParseDuration(rollup)
+		if err != nil {
+			http.Error(res, "invalid rollup: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	points, err := nats.GetNodeHistory(h.nc, id, query)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if len(points) > 0 {
+		encode(res, points)
+	} else {
+		res.Write([]byte("[]"))
+	}
+}
+
+// getDeliveries returns the message deliveries to a user node, or to all
+// users if the ID is "root". The following query parameters may be used to
+// filter deliveries: notificationId, state, and limit.
+func (h *Nodes) getDeliveries(res http.ResponseWriter, req *http.Request, id string) {
+	params := req.URL.Query()
+
+	query := data.DeliveryQuery{
+		NotificationID: params.Get("notificationId"),
+		State:          params.Get("state"),
+	}
+
+	if limit := params.Get("limit"); limit != "" {
+		var err error
+		query.Limit, err = strconv.Atoi(limit)
+		if err != nil {
+			http.Error(res, "invalid limit: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	deliveries, err := nats.GetDeliveries(h.nc, id, query)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if len(deliveries) > 0 {
+		encode(res, deliveries)
+	} else {
+		res.Write([]byte("[]"))
+	}
+}
+
+// getEvents returns events for a node and all of its descendants from the
+// event log. The following query parameters may be used to filter events:
+// type, level, start, end (RFC3339 times), and limit.
+func (h *Nodes) getEvents(res http.ResponseWriter, req *http.Request, id string) {
+	params := req.URL.Query()
+
+	var query data.EventQuery
+	var err error
+
+	if typ := params.Get("type"); typ != "" {
+		t, err := strconv.Atoi(typ)
+		if err != nil {
+			http.Error(res, "invalid type: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		query.Type = data.EventType(t)
+	}
+
+	if level := params.Get("level"); level != "" {
+		l, err := strconv.Atoi(level)
+		if err != nil {
+			http.Error(res, "invalid level: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		query.Level = data.EventLevel(l)
+	}
+
+	if start := params.Get("start"); start != "" {
+		query.Start, err = time.Parse(time.RFC3339, start)
+		if err != nil {
+			http.Error(res, "invalid start: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if end := params.Get("end"); end != "" {
+		query.End, err = time.Parse(time.RFC3339, end)
+		if err != nil {
+			http.Error(res, "invalid end: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if limit := params.Get("limit"); limit != "" {
+		query.Limit, err = strconv.Atoi(limit)
+		if err != nil {
+			http.Error(res, "invalid limit: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	events, err := nats.GetEvents(h.nc, id, query)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if len(events) > 0 {
+		encode(res, events)
+	} else {
+		res.Write([]byte("[]"))
+	}
+}
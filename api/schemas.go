@@ -0,0 +1,60 @@
+package api
+
+import (
+	"net/http"
+
+	natsgo "github.com/nats-io/nats.go"
+	"github.com/simpleiot/simpleiot/nats"
+)
+
+// Schemas handles node schema requests
+type Schemas struct {
+	check     RequestValidator
+	nc        *natsgo.Conn
+	authToken string
+}
+
+// NewSchemasHandler returns a new node schema handler
+func NewSchemasHandler(v RequestValidator, authToken string,
+	nc *natsgo.Conn) http.Handler {
+	return &Schemas{v, nc, authToken}
+}
+
+// ServeHTTP returns all node schemas, or the schema for a single node type
+// if one is given in the path (/v1/schemas/:type)
+func (h *Schemas) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	if req.Header.Get("Authorization") != h.authToken {
+		if valid, _ := h.check.Valid(req); !valid {
+			http.Error(res, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	if req.Method != http.MethodGet {
+		http.Error(res, "only GET allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var nodeType string
+	nodeType, req.URL.Path = ShiftPath(req.URL.Path)
+
+	schemas, err := nats.GetNodeSchemas(h.nc)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if nodeType == "" {
+		encode(res, schemas)
+		return
+	}
+
+	for _, s := range schemas {
+		if s.Type == nodeType {
+			encode(res, s)
+			return
+		}
+	}
+
+	http.Error(res, "unknown node type: "+nodeType, http.StatusNotFound)
+}
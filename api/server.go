@@ -84,6 +84,8 @@ type ServerArgs struct {
 	JwtAuth    Authorizer
 	AuthToken  string
 	Nc         *nats.Conn
+	// URL clients use to reach the server, if behind a reverse proxy
+	PublicURL string
 }
 
 // Server starts a API server instance
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	natsgo "github.com/nats-io/nats.go"
+	"github.com/simpleiot/simpleiot/data"
+	"github.com/simpleiot/simpleiot/msg"
+	"github.com/simpleiot/simpleiot/nats"
+)
+
+// emptyTwiML is the response to Twilio webhooks. Replies are sent through
+// the message service so that they are tracked like other messages.
+const emptyTwiML = `<?xml version="1.0" encoding="UTF-8"?><Response></Response>`
+
+// SMS handles inbound SMS webhooks from message services at
+// /v1/sms/<message service ID>. Requests are authenticated by the signature
+// of the message service, as the service can't log in.
+type SMS struct {
+	nc        *natsgo.Conn
+	publicURL string
+}
+
+// NewSMSHandler returns a new inbound SMS handler. publicURL is the URL
+// clients use to reach the server, and must be set if the server is behind
+// a reverse proxy.
+func NewSMSHandler(nc *natsgo.Conn, publicURL string) http.Handler {
+	return &SMS{nc: nc, publicURL: strings.TrimSuffix(publicURL, "/")}
+}
+
+// requestURL returns the URL the client requested, which is signed by
+// Twilio. Proxy headers are not used as clients can set them, so the
+// public URL is used if it is configured.
+func (h *SMS) requestURL(req *http.Request) string {
+	if h.publicURL != "" {
+		return h.publicURL + req.RequestURI
+	}
+
+	scheme := "http"
+	if req.TLS != nil {
+		scheme = "https"
+	}
+
+	return scheme + "://" + req.Host + req.RequestURI
+}
+
+func (h *SMS) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(res, "only POST allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var id string
+	id, req.URL.Path = ShiftPath(req.URL.Path)
+
+	node, err := nats.GetNode(h.nc, id, "skip")
+	if err != nil || node.Type != data.NodeTypeMsgService {
+		http.Error(res, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	svc, err := data.NodeToMsgService(node.ToNode())
+	if err != nil || svc.Service != data.PointValueTwilio {
+		http.Error(res, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	err = req.ParseForm()
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	twilio := msg.NewTwilio(svc.SID, svc.AuthToken, svc.From)
+
+	err = twilio.VerifySignature(h.requestURL(req), req.PostForm,
+		req.Header.Get("X-Twilio-Signature"))
+	if err != nil {
+		log.Printf("Inbound SMS for %v rejected: %v\n", id, err)
+		http.Error(res, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	cmd, err := nats.SendSMSCommand(h.nc, id, data.SMSCommand{
+		From: req.PostForm.Get("From"),
+		Body: req.PostForm.Get("Body"),
+	})
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if cmd.Error != "" {
+		log.Printf("SMS command from %v: %v\n", cmd.From, cmd.Error)
+	}
+
+	res.Header().Set("Content-Type", "text/xml")
+	res.Write([]byte(emptyTwiML))
+}
@@ -6,11 +6,13 @@ import (
 
 // V1 handles v1 api requests
 type V1 struct {
-	GroupsHandler http.Handler
-	UsersHandler  http.Handler
-	NodesHandler  http.Handler
-	AuthHandler   http.Handler
-	MsgHandler    http.Handler
+	GroupsHandler  http.Handler
+	UsersHandler   http.Handler
+	NodesHandler   http.Handler
+	SchemasHandler http.Handler
+	AuthHandler    http.Handler
+	MsgHandler     http.Handler
+	SMSHandler     http.Handler
 }
 
 // Top level handler for http requests in the coap-server process
@@ -22,8 +24,12 @@ func (h *V1) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 		h.NodesHandler.ServeHTTP(res, req)
 	case "devices":
 		h.NodesHandler.ServeHTTP(res, req)
+	case "schemas":
+		h.SchemasHandler.ServeHTTP(res, req)
 	case "auth":
 		h.AuthHandler.ServeHTTP(res, req)
+	case "sms":
+		h.SMSHandler.ServeHTTP(res, req)
 	default:
 		http.Error(res, "Not Found", http.StatusNotFound)
 	}
@@ -35,6 +41,8 @@ func NewV1Handler(args ServerArgs) http.Handler {
 	return &V1{
 		NodesHandler: NewNodesHandler(args.DbInst, args.JwtAuth,
 			args.AuthToken, args.Nc),
-		AuthHandler: NewAuthHandler(args.DbInst, args.JwtAuth),
+		SchemasHandler: NewSchemasHandler(args.JwtAuth, args.AuthToken, args.Nc),
+		AuthHandler:    NewAuthHandler(args.DbInst, args.JwtAuth),
+		SMSHandler:     NewSMSHandler(args.Nc, args.PublicURL),
 	}
 }
@@ -10,6 +10,10 @@ import (
 	"strings"
 	"time"
 
+	// embed the timezone database as many embedded devices do not have
+	// one, and it is needed to evaluate schedule conditions in local time
+	_ "time/tzdata"
+
 	"github.com/simpleiot/simpleiot/api"
 	"github.com/simpleiot/simpleiot/assets/files"
 	"github.com/simpleiot/simpleiot/assets/frontend"
@@ -303,6 +307,13 @@ func main() {
 			}
 		})
 
+		_, err = nc.Subscribe("node.*.trace", func(msg *natsgo.Msg) {
+			err := nats.Dump(nc, msg)
+			if err != nil {
+				log.Println("Error dumping nats msg: ", err)
+			}
+		})
+
 		_, err = nc.Subscribe("node.*.*.points", func(msg *natsgo.Msg) {
 			err := nats.Dump(nc, msg)
 			if err != nil {
@@ -511,6 +522,7 @@ func main() {
 		JwtAuth:    auth,
 		AuthToken:  authToken,
 		Nc:         nc,
+		PublicURL:  os.Getenv("SIOT_PUBLIC_URL"),
 	})
 
 	if err != nil {
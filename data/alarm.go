@@ -0,0 +1,99 @@
+package data
+
+import (
+	"errors"
+	"time"
+
+	"github.com/simpleiot/simpleiot/internal/pb"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// alarm acknowledgement actions
+const (
+	AlarmActionAck      = "ack"
+	AlarmActionShelve   = "shelve"
+	AlarmActionUnshelve = "unshelve"
+)
+
+// AlarmAck is used to acknowledge, shelve, or unshelve the alarm of a rule.
+// ShelveUntil is required when shelving.
+type AlarmAck struct {
+	UserID      string    `json:"userId"`
+	Action      string    `json:"action"`
+	ShelveUntil time.Time `json:"shelveUntil"`
+}
+
+// ToPb encodes an alarm ack to protobuf
+func (a AlarmAck) ToPb() ([]byte, error) {
+	pbAck := pb.AlarmAck{
+		UserId: a.UserID,
+		Action: a.Action,
+	}
+
+	if !a.ShelveUntil.IsZero() {
+		pbAck.ShelveUntil = timestamppb.New(a.ShelveUntil)
+	}
+
+	return proto.Marshal(&pbAck)
+}
+
+// PbDecodeAlarmAck decodes a protobuf alarm ack
+func PbDecodeAlarmAck(data []byte) (AlarmAck, error) {
+	pbAck := &pb.AlarmAck{}
+
+	err := proto.Unmarshal(data, pbAck)
+	if err != nil {
+		return AlarmAck{}, err
+	}
+
+	ret := AlarmAck{
+		UserID: pbAck.UserId,
+		Action: pbAck.Action,
+	}
+
+	if pbAck.ShelveUntil != nil {
+		ret.ShelveUntil = pbAck.ShelveUntil.AsTime()
+	}
+
+	return ret, nil
+}
+
+// AlarmTransition returns the alarm state after a rule goes active or
+// inactive. Shelved alarms stay shelved.
+func AlarmTransition(state string, active bool) string {
+	if state == PointValueAlarmShelved {
+		return state
+	}
+
+	if active {
+		return PointValueAlarmActiveUnacked
+	}
+
+	switch state {
+	case PointValueAlarmActiveUnacked:
+		return PointValueAlarmClearedUnacked
+	case PointValueAlarmActiveAcked, "":
+		return PointValueAlarmNormal
+	}
+
+	return state
+}
+
+// AlarmAckState returns the alarm state after an alarm is acknowledged. Rules
+// that went active before alarm states were tracked do not have a state, so
+// active is used to determine their state.
+func AlarmAckState(state string, active bool) (string, error) {
+	if state == "" && active {
+		state = PointValueAlarmActiveUnacked
+	}
+
+	switch state {
+	case PointValueAlarmActiveUnacked:
+		return PointValueAlarmActiveAcked, nil
+	case PointValueAlarmClearedUnacked:
+		return PointValueAlarmNormal, nil
+	}
+
+	return state, errors.New("alarm is not unacknowledged")
+}
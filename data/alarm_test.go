@@ -0,0 +1,97 @@
+package data
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAlarmAckPb(t *testing.T) {
+	a := AlarmAck{
+		UserID:      "u1",
+		Action:      AlarmActionShelve,
+		ShelveUntil: time.Date(2021, time.September, 1, 0, 0, 0, 5, time.UTC),
+	}
+
+	buf, err := a.ToPb()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a2, err := PbDecodeAlarmAck(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if a2.UserID != a.UserID || a2.Action != a.Action ||
+		!a2.ShelveUntil.Equal(a.ShelveUntil) {
+		t.Errorf("alarm ack not preserved, exp %+v, got %+v", a, a2)
+	}
+
+	buf, err = AlarmAck{UserID: "u1", Action: AlarmActionAck}.ToPb()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a2, err = PbDecodeAlarmAck(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !a2.ShelveUntil.IsZero() {
+		t.Error("expected zero shelve time: ", a2.ShelveUntil)
+	}
+}
+
+func TestAlarmTransition(t *testing.T) {
+	tests := []struct {
+		state  string
+		active bool
+		exp    string
+	}{
+		{"", true, PointValueAlarmActiveUnacked},
+		{"", false, PointValueAlarmNormal},
+		{PointValueAlarmNormal, true, PointValueAlarmActiveUnacked},
+		{PointValueAlarmActiveUnacked, false, PointValueAlarmClearedUnacked},
+		{PointValueAlarmActiveAcked, false, PointValueAlarmNormal},
+		{PointValueAlarmClearedUnacked, true, PointValueAlarmActiveUnacked},
+		{PointValueAlarmShelved, true, PointValueAlarmShelved},
+		{PointValueAlarmShelved, false, PointValueAlarmShelved},
+	}
+
+	for _, test := range tests {
+		state := AlarmTransition(test.state, test.active)
+		if state != test.exp {
+			t.Errorf("%v, active %v: expected %v, got %v", test.state,
+				test.active, test.exp, state)
+		}
+	}
+}
+
+func TestAlarmAckState(t *testing.T) {
+	tests := []struct {
+		state  string
+		active bool
+		exp    string
+		err    bool
+	}{
+		{"", true, PointValueAlarmActiveAcked, false},
+		{"", false, "", true},
+		{PointValueAlarmActiveUnacked, true, PointValueAlarmActiveAcked, false},
+		{PointValueAlarmClearedUnacked, false, PointValueAlarmNormal, false},
+		{PointValueAlarmActiveAcked, true, PointValueAlarmActiveAcked, true},
+		{PointValueAlarmNormal, false, PointValueAlarmNormal, true},
+		{PointValueAlarmShelved, true, PointValueAlarmShelved, true},
+	}
+
+	for _, test := range tests {
+		state, err := AlarmAckState(test.state, test.active)
+		if (err != nil) != test.err {
+			t.Errorf("%v: unexpected error result: %v", test.state, err)
+		}
+
+		if state != test.exp {
+			t.Errorf("%v, active %v: expected %v, got %v", test.state,
+				test.active, test.exp, state)
+		}
+	}
+}
@@ -1,7 +1,9 @@
 package data
 
-// Auth is an authentication response.
+// Auth is an authentication response. PassChange is set if the user must
+// change their password before making other changes.
 type Auth struct {
-	Token string `json:"token"`
-	Email string `json:"email"`
+	Token      string `json:"token"`
+	Email      string `json:"email"`
+	PassChange bool   `json:"passChange"`
 }
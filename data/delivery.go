@@ -0,0 +1,178 @@
+package data
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/simpleiot/simpleiot/internal/pb"
+	"google.golang.org/protobuf/proto"
+)
+
+// define valid delivery states
+const (
+	DeliveryQueued = "queued"
+	DeliverySent   = "sent"
+	DeliveryFailed = "failed"
+)
+
+// Delivery records the delivery of a message through a message service.
+// A delivery is queued until it is sent, or until it fails after all retries
+// are used. Address is where the message service delivered the message, for
+// example the phone number or email address of the user. Error is the error
+// of the last attempt.
+type Delivery struct {
+	ID        string    `json:"id"`
+	Message   Message   `json:"message"`
+	ServiceID string    `json:"serviceId"`
+	Service   string    `json:"service"`
+	Address   string    `json:"address"`
+	State     string    `json:"state"`
+	Attempts  int       `json:"attempts"`
+	Error     string    `json:"error"`
+	Created   time.Time `json:"created"`
+	Updated   time.Time `json:"updated"`
+}
+
+// ToPb converts a delivery to a protobuf delivery
+func (d Delivery) ToPb() (*pb.Delivery, error) {
+	created, err := ptypes.TimestampProto(d.Created)
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := ptypes.TimestampProto(d.Updated)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.Delivery{
+		Id:        d.ID,
+		Message:   d.Message.toPb(),
+		ServiceId: d.ServiceID,
+		Service:   d.Service,
+		Address:   d.Address,
+		State:     d.State,
+		Attempts:  int32(d.Attempts),
+		Error:     d.Error,
+		Created:   created,
+		Updated:   updated,
+	}, nil
+}
+
+// PbToDelivery converts a protobuf delivery to a delivery
+func PbToDelivery(d *pb.Delivery) (Delivery, error) {
+	ret := Delivery{
+		ID:        d.Id,
+		ServiceID: d.ServiceId,
+		Service:   d.Service,
+		Address:   d.Address,
+		State:     d.State,
+		Attempts:  int(d.Attempts),
+		Error:     d.Error,
+	}
+
+	if d.Message != nil {
+		ret.Message = pbToMessage(d.Message)
+	}
+
+	var err error
+
+	if d.Created != nil {
+		ret.Created, err = ptypes.Timestamp(d.Created)
+		if err != nil {
+			return Delivery{}, err
+		}
+	}
+
+	if d.Updated != nil {
+		ret.Updated, err = ptypes.Timestamp(d.Updated)
+		if err != nil {
+			return Delivery{}, err
+		}
+	}
+
+	return ret, nil
+}
+
+// DeliveriesToPb encodes deliveries and an error in a protobuf deliveries
+// request
+func DeliveriesToPb(deliveries []Delivery, err error) ([]byte, error) {
+	req := pb.DeliveriesRequest{}
+
+	if err != nil {
+		req.Error = err.Error()
+	}
+
+	for _, d := range deliveries {
+		pbDelivery, err := d.ToPb()
+		if err != nil {
+			return nil, err
+		}
+		req.Deliveries = append(req.Deliveries, pbDelivery)
+	}
+
+	return proto.Marshal(&req)
+}
+
+// PbDecodeDeliveriesRequest decodes a protobuf encoded deliveries request
+func PbDecodeDeliveriesRequest(data []byte) ([]Delivery, error) {
+	req := &pb.DeliveriesRequest{}
+
+	err := proto.Unmarshal(data, req)
+	if err != nil {
+		return []Delivery{}, err
+	}
+
+	if req.Error != "" {
+		return []Delivery{}, errors.New(req.Error)
+	}
+
+	ret := make([]Delivery, len(req.Deliveries))
+
+	for i, pbDelivery := range req.Deliveries {
+		ret[i], err = PbToDelivery(pbDelivery)
+		if err != nil {
+			return []Delivery{}, err
+		}
+	}
+
+	return ret, nil
+}
+
+// DeliveryQuery describes a request for message deliveries. UserID,
+// NotificationID, and State are ignored if blank. Limit caps the number of
+// deliveries returned (0 is no limit).
+type DeliveryQuery struct {
+	UserID         string `json:"userId"`
+	NotificationID string `json:"notificationId"`
+	State          string `json:"state"`
+	Limit          int    `json:"limit"`
+}
+
+// ToPb converts a delivery query to protobuf
+func (dq *DeliveryQuery) ToPb() ([]byte, error) {
+	return proto.Marshal(&pb.DeliveryQuery{
+		UserId:         dq.UserID,
+		NotificationId: dq.NotificationID,
+		State:          dq.State,
+		Limit:          int32(dq.Limit),
+	})
+}
+
+// PbDecodeDeliveryQuery converts a protobuf to a delivery query
+func PbDecodeDeliveryQuery(data []byte) (DeliveryQuery, error) {
+	pbReq := &pb.DeliveryQuery{}
+
+	err := proto.Unmarshal(data, pbReq)
+	if err != nil {
+		return DeliveryQuery{}, err
+	}
+
+	return DeliveryQuery{
+		UserID:         pbReq.UserId,
+		NotificationID: pbReq.NotificationId,
+		State:          pbReq.State,
+		Limit:          int(pbReq.Limit),
+	}, nil
+}
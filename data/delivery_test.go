@@ -0,0 +1,97 @@
+package data
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDeliveriesPb(t *testing.T) {
+	deliveries := []Delivery{
+		{
+			ID: "123",
+			Message: Message{
+				ID:             "m1",
+				UserID:         "u1",
+				NotificationID: "n1",
+				Email:          "bob@example.com",
+				Subject:        "Tank low",
+				Message:        "Tank 3 is low",
+				HTML:           "<p>Tank 3 is low</p>",
+				RuleID:         "r1",
+			},
+			ServiceID: "s1",
+			Service:   PointValueSMTP,
+			Address:   "bob@example.com",
+			State:     DeliveryFailed,
+			Attempts:  4,
+			Error:     "connection refused",
+			Created:   time.Date(2021, time.September, 1, 0, 0, 0, 5, time.UTC),
+			Updated:   time.Date(2021, time.September, 1, 0, 1, 0, 0, time.UTC),
+		},
+	}
+
+	buf, err := DeliveriesToPb(deliveries, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d2, err := PbDecodeDeliveriesRequest(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(d2) != 1 || !d2[0].Created.Equal(deliveries[0].Created) ||
+		!d2[0].Updated.Equal(deliveries[0].Updated) {
+		t.Fatalf("times not preserved: %+v", d2)
+	}
+
+	d2[0].Created = deliveries[0].Created
+	d2[0].Updated = deliveries[0].Updated
+
+	if !reflect.DeepEqual(deliveries, d2) {
+		t.Errorf("deliveries not preserved, exp %+v, got %+v", deliveries, d2)
+	}
+
+	buf, err = DeliveriesToPb(nil, errors.New("db error"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = PbDecodeDeliveriesRequest(buf)
+	if err == nil || err.Error() != "db error" {
+		t.Error("expected error, got: ", err)
+	}
+}
+
+func TestNodeToMsgServiceRetries(t *testing.T) {
+	svc, err := NodeToMsgService(Node{Points: Points{
+		{Type: PointTypeService, Text: PointValueWebhook},
+		{Type: PointTypeURI, Text: "http://localhost/msg"},
+		{Type: PointTypeHeader, Text: "Authorization: Bearer abc"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if svc.Retries != DefaultMsgRetries {
+		t.Error("default retries not set: ", svc.Retries)
+	}
+
+	if svc.Headers["Authorization"] != "Bearer abc" {
+		t.Error("header not parsed: ", svc.Headers)
+	}
+
+	svc, err = NodeToMsgService(Node{Points: Points{
+		{Type: PointTypeService, Text: PointValueMock},
+		{Type: PointTypeRetries, Value: 0},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if svc.Retries != 0 {
+		t.Error("retries should be disabled: ", svc.Retries)
+	}
+}
@@ -1,6 +1,13 @@
 package data
 
-import "time"
+import (
+	"errors"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/simpleiot/simpleiot/internal/pb"
+	"google.golang.org/protobuf/proto"
+)
 
 // EventType describes an event. Custom applications that build on top of Simple IoT
 // should custom event types at high number above 10,000 to ensure there is not a collision
@@ -9,10 +16,17 @@ type EventType int
 
 // define valid events
 const (
-	EventTypeStartSystem EventType = 10
+	EventTypeStartSystem EventType = iota + 10
 	EventTypeStartApp
 	EventTypeSystemUpdate
 	EventTypeAppUpdate
+	EventTypeRuleActive
+	EventTypeRuleInactive
+	EventTypeDeviceOffline
+	EventTypeDeviceOnline
+	EventTypeAlarmAck
+	EventTypeAlarmShelve
+	EventTypeAlarmUnshelve
 )
 
 // EventLevel is used to describe the "severity" of the event and can be used to
@@ -21,7 +35,7 @@ type EventLevel int
 
 // define valid events
 const (
-	EventLevelFault EventLevel = 3
+	EventLevelFault EventLevel = iota + 3
 	EventLevelInfo
 	EventLevelDebug
 )
@@ -29,8 +43,186 @@ const (
 // Event describes something that happened and might be displayed to user in a
 // a sequential log format.
 type Event struct {
-	Time    time.Time
-	Type    EventType
-	Level   EventLevel
-	Message string
+	ID      string     `json:"id"`
+	NodeID  string     `json:"nodeId"`
+	Time    time.Time  `json:"time"`
+	Type    EventType  `json:"type"`
+	Level   EventLevel `json:"level"`
+	Message string     `json:"message"`
+}
+
+// ToPb converts an event to a protobuf event
+func (e Event) ToPb() (*pb.Event, error) {
+	ts, err := ptypes.TimestampProto(e.Time)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.Event{
+		Id:      e.ID,
+		NodeId:  e.NodeID,
+		Time:    ts,
+		Type:    int32(e.Type),
+		Level:   int32(e.Level),
+		Message: e.Message,
+	}, nil
+}
+
+// PbToEvent converts a protobuf event to an event
+func PbToEvent(e *pb.Event) (Event, error) {
+	ret := Event{
+		ID:      e.Id,
+		NodeID:  e.NodeId,
+		Type:    EventType(e.Type),
+		Level:   EventLevel(e.Level),
+		Message: e.Message,
+	}
+
+	if e.Time != nil {
+		var err error
+		ret.Time, err = ptypes.Timestamp(e.Time)
+		if err != nil {
+			return Event{}, err
+		}
+	}
+
+	return ret, nil
+}
+
+// PbEncode encodes an event to a protobuf
+func (e Event) PbEncode() ([]byte, error) {
+	pbEvent, err := e.ToPb()
+	if err != nil {
+		return nil, err
+	}
+
+	return proto.Marshal(pbEvent)
+}
+
+// PbDecodeEvent decodes a protobuf encoded event
+func PbDecodeEvent(data []byte) (Event, error) {
+	pbEvent := &pb.Event{}
+
+	err := proto.Unmarshal(data, pbEvent)
+	if err != nil {
+		return Event{}, err
+	}
+
+	return PbToEvent(pbEvent)
+}
+
+// EventsToPb encodes events and an error in a protobuf events request
+func EventsToPb(events []Event, err error) ([]byte, error) {
+	req := pb.EventsRequest{}
+
+	if err != nil {
+		req.Error = err.Error()
+	}
+
+	for _, e := range events {
+		pbEvent, err := e.ToPb()
+		if err != nil {
+			return nil, err
+		}
+		req.Events = append(req.Events, pbEvent)
+	}
+
+	return proto.Marshal(&req)
+}
+
+// PbDecodeEventsRequest decodes a protobuf encoded events request
+func PbDecodeEventsRequest(data []byte) ([]Event, error) {
+	req := &pb.EventsRequest{}
+
+	err := proto.Unmarshal(data, req)
+	if err != nil {
+		return []Event{}, err
+	}
+
+	if req.Error != "" {
+		return []Event{}, errors.New(req.Error)
+	}
+
+	ret := make([]Event, len(req.Events))
+
+	for i, pbEvent := range req.Events {
+		ret[i], err = PbToEvent(pbEvent)
+		if err != nil {
+			return []Event{}, err
+		}
+	}
+
+	return ret, nil
+}
+
+// EventQuery describes a request for events recorded against a node and
+// all of its descendants. Type is ignored if 0. Level selects events
+// at that level or more severe (0 matches all levels). A zero Start or End
+// leaves that end of the time range open. Limit caps the number of events
+// returned (0 is no limit).
+type EventQuery struct {
+	Type  EventType  `json:"type"`
+	Level EventLevel `json:"level"`
+	Start time.Time  `json:"start"`
+	End   time.Time  `json:"end"`
+	Limit int        `json:"limit"`
+}
+
+// ToPb converts an event query to protobuf
+func (eq *EventQuery) ToPb() ([]byte, error) {
+	pbReq := pb.EventQuery{
+		Type:  int32(eq.Type),
+		Level: int32(eq.Level),
+		Limit: int32(eq.Limit),
+	}
+
+	var err error
+
+	if !eq.Start.IsZero() {
+		pbReq.Start, err = ptypes.TimestampProto(eq.Start)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !eq.End.IsZero() {
+		pbReq.End, err = ptypes.TimestampProto(eq.End)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return proto.Marshal(&pbReq)
+}
+
+// PbDecodeEventQuery converts a protobuf to an event query
+func PbDecodeEventQuery(data []byte) (EventQuery, error) {
+	pbReq := &pb.EventQuery{}
+
+	err := proto.Unmarshal(data, pbReq)
+	if err != nil {
+		return EventQuery{}, err
+	}
+
+	ret := EventQuery{
+		Type:  EventType(pbReq.Type),
+		Level: EventLevel(pbReq.Level),
+		Limit: int(pbReq.Limit),
+	}
+
+	if pbReq.Start != nil {
+		ret.Start, err = ptypes.Timestamp(pbReq.Start)
+		if err != nil {
+			return EventQuery{}, err
+		}
+	}
+
+	if pbReq.End != nil {
+		ret.End, err = ptypes.Timestamp(pbReq.End)
+		if err != nil {
+			return EventQuery{}, err
+		}
+	}
+
+	return ret, nil
 }
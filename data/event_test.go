@@ -0,0 +1,50 @@
+package data
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEventPb(t *testing.T) {
+	e := Event{
+		ID:      "123",
+		NodeID:  "456",
+		Time:    time.Date(2021, time.September, 1, 0, 0, 0, 5, time.UTC),
+		Type:    EventTypeDeviceOffline,
+		Level:   EventLevelFault,
+		Message: "device offline",
+	}
+
+	buf, err := e.PbEncode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e2, err := PbDecodeEvent(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !e2.Time.Equal(e.Time) {
+		t.Error("time not preserved: ", e2.Time)
+	}
+
+	e2.Time = e.Time
+
+	if !reflect.DeepEqual(e, e2) {
+		t.Errorf("event not preserved, exp %+v, got %+v", e, e2)
+	}
+}
+
+func TestEventTypeValues(t *testing.T) {
+	// event types and levels are stored, so must never change
+	if EventTypeStartSystem != 10 || EventTypeAppUpdate != 13 ||
+		EventTypeDeviceOnline != 17 {
+		t.Error("event type values changed")
+	}
+
+	if EventLevelFault != 3 || EventLevelInfo != 4 || EventLevelDebug != 5 {
+		t.Error("event level values changed")
+	}
+}
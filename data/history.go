@@ -0,0 +1,93 @@
+package data
+
+import (
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/simpleiot/simpleiot/internal/pb"
+	"google.golang.org/protobuf/proto"
+)
+
+// HistoryQuery describes a request for points in the local history store.
+// ID and Type are ignored if blank, and an Index of -1 matches all indexes.
+// A zero Start or End leaves that end of the time range open. Limit
+// caps the number of points returned (0 is no limit). Rollup selects
+// downsampled points with the given period (0 returns raw points).
+type HistoryQuery struct {
+	ID     string        `json:"id"`
+	Type   string        `json:"type"`
+	Index  int           `json:"index"`
+	Start  time.Time     `json:"start"`
+	End    time.Time     `json:"end"`
+	Limit  int           `json:"limit"`
+	Rollup time.Duration `json:"rollup"`
+}
+
+// ToPb converts a history query to protobuf
+func (hq *HistoryQuery) ToPb() ([]byte, error) {
+	pbReq := pb.HistoryRequest{
+		Id:     hq.ID,
+		Type:   hq.Type,
+		Index:  int32(hq.Index),
+		Limit:  int32(hq.Limit),
+		Rollup: ptypes.DurationProto(hq.Rollup),
+	}
+
+	var err error
+
+	if !hq.Start.IsZero() {
+		pbReq.Start, err = ptypes.TimestampProto(hq.Start)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !hq.End.IsZero() {
+		pbReq.End, err = ptypes.TimestampProto(hq.End)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return proto.Marshal(&pbReq)
+}
+
+// PbDecodeHistoryQuery converts a protobuf to a history query
+func PbDecodeHistoryQuery(data []byte) (HistoryQuery, error) {
+	pbReq := &pb.HistoryRequest{}
+
+	err := proto.Unmarshal(data, pbReq)
+	if err != nil {
+		return HistoryQuery{}, err
+	}
+
+	ret := HistoryQuery{
+		ID:    pbReq.Id,
+		Type:  pbReq.Type,
+		Index: int(pbReq.Index),
+		Limit: int(pbReq.Limit),
+	}
+
+	if pbReq.Rollup != nil {
+		ret.Rollup, err = ptypes.Duration(pbReq.Rollup)
+		if err != nil {
+			return HistoryQuery{}, err
+		}
+	}
+
+	if pbReq.Start != nil {
+		ret.Start, err = ptypes.Timestamp(pbReq.Start)
+		if err != nil {
+			return HistoryQuery{}, err
+		}
+	}
+
+	if pbReq.End != nil {
+		ret.End, err = ptypes.Timestamp(pbReq.End)
+		if err != nil {
+			return HistoryQuery{}, err
+		}
+	}
+
+	return ret, nil
+}
@@ -1,5 +1,11 @@
 package data
 
+import "strings"
+
+// DefaultMsgRetries is the number of times a failed delivery is retried if a
+// message service does not have a retries point
+const DefaultMsgRetries = 3
+
 // MsgService is used to represent message services such as Twilio, SMTP, etc
 type MsgService struct {
 	ID        string
@@ -7,11 +13,36 @@ type MsgService struct {
 	SID       string
 	AuthToken string
 	From      string
+	// SMTP parameters
+	Host          string
+	Port          int
+	TLSMode       string
+	Username      string
+	Password      string
+	SubjectPrefix string
+	// webhook parameters
+	URI     string
+	Method  string
+	Headers map[string]string
+	// modem parameters
+	ModemPort string
+	SMSMode   string
+	// Timeout is in seconds
+	Timeout float64
+	Retries int
+	// Error is the last delivery error
+	Error string
 }
 
 // NodeToMsgService converts a node to message service
 func NodeToMsgService(node Node) (MsgService, error) {
-	ret := MsgService{}
+	ret := MsgService{Retries: DefaultMsgRetries}
+
+	err := ValidateNode(NodeTypeMsgService, node.Points)
+	if err != nil {
+		return ret, err
+	}
+
 	ret.ID = node.ID
 	for _, p := range node.Points {
 		switch p.Type {
@@ -23,6 +54,41 @@ func NodeToMsgService(node Node) (MsgService, error) {
 			ret.AuthToken = p.Text
 		case PointTypeFrom:
 			ret.From = p.Text
+		case PointTypeHost:
+			ret.Host = p.Text
+		case PointTypePort:
+			ret.Port = int(p.Value)
+		case PointTypeTLSMode:
+			ret.TLSMode = p.Text
+		case PointTypeUsername:
+			ret.Username = p.Text
+		case PointTypePass:
+			ret.Password = p.Text
+		case PointTypeSubjectPrefix:
+			ret.SubjectPrefix = p.Text
+		case PointTypeURI:
+			ret.URI = p.Text
+		case PointTypeMethod:
+			ret.Method = p.Text
+		case PointTypeHeader:
+			parts := strings.SplitN(p.Text, ":", 2)
+			if len(parts) == 2 {
+				if ret.Headers == nil {
+					ret.Headers = make(map[string]string)
+				}
+				ret.Headers[strings.TrimSpace(parts[0])] =
+					strings.TrimSpace(parts[1])
+			}
+		case PointTypeModemPort:
+			ret.ModemPort = p.Text
+		case PointTypeSMSMode:
+			ret.SMSMode = p.Text
+		case PointTypeTimeout:
+			ret.Timeout = p.Value
+		case PointTypeRetries:
+			ret.Retries = int(p.Value)
+		case PointTypeError:
+			ret.Error = p.Text
 		}
 	}
 
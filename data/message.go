@@ -7,19 +7,45 @@ import (
 
 // Message describes a notification that is sent to a particular user
 type Message struct {
-	ID             string
-	UserID         string
-	ParentID       string
-	NotificationID string
-	Email          string
-	Phone          string
-	Subject        string
-	Message        string
+	ID             string `json:"id"`
+	UserID         string `json:"userId"`
+	ParentID       string `json:"parentId"`
+	NotificationID string `json:"notificationId"`
+	Email          string `json:"email"`
+	Phone          string `json:"phone"`
+	Subject        string `json:"subject"`
+	Message        string `json:"message"`
+	// HTML is an optional HTML version of the message for email
+	HTML string `json:"html"`
+	// Channels limits the channels (sms, email, webhook) the message is
+	// sent on. All channels are used if blank.
+	Channels []string `json:"channels"`
+	// RuleID is the rule that sent the notification, if any
+	RuleID string `json:"ruleId"`
+}
+
+// ChannelAllowed returns true if the message may be sent on a channel
+func (m *Message) ChannelAllowed(channel string) bool {
+	if len(m.Channels) <= 0 {
+		return true
+	}
+
+	for _, c := range m.Channels {
+		if c == channel {
+			return true
+		}
+	}
+
+	return false
 }
 
 // ToPb converts to protobuf data
 func (m *Message) ToPb() ([]byte, error) {
-	pbMsg := pb.Message{
+	return proto.Marshal(m.toPb())
+}
+
+func (m *Message) toPb() *pb.Message {
+	return &pb.Message{
 		Id:             m.ID,
 		UserId:         m.UserID,
 		ParentId:       m.ParentID,
@@ -28,9 +54,10 @@ func (m *Message) ToPb() ([]byte, error) {
 		Phone:          m.Phone,
 		Subject:        m.Subject,
 		Message:        m.Message,
+		Html:           m.HTML,
+		Channels:       m.Channels,
+		RuleId:         m.RuleID,
 	}
-
-	return proto.Marshal(&pbMsg)
 }
 
 // PbDecodeMessage converts a protobuf to a message data structure
@@ -42,6 +69,10 @@ func PbDecodeMessage(data []byte) (Message, error) {
 		return Message{}, err
 	}
 
+	return pbToMessage(pbMsg), nil
+}
+
+func pbToMessage(pbMsg *pb.Message) Message {
 	return Message{
 		ID:             pbMsg.Id,
 		UserID:         pbMsg.UserId,
@@ -51,5 +82,8 @@ func PbDecodeMessage(data []byte) (Message, error) {
 		Phone:          pbMsg.Phone,
 		Subject:        pbMsg.Subject,
 		Message:        pbMsg.Message,
-	}, nil
+		HTML:           pbMsg.Html,
+		Channels:       pbMsg.Channels,
+		RuleID:         pbMsg.RuleId,
+	}
 }
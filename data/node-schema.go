@@ -0,0 +1,698 @@
+package data
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/simpleiot/simpleiot/internal/pb"
+	"google.golang.org/protobuf/proto"
+)
+
+// PointSchema describes a point that can be set on a node.
+//
+// ValueType is one of PointValueNumber, PointValueOnOff, or
+// PointValueText, and determines if Value or Text holds the data. Text
+// points are checked against Allowed (if not empty), and number points are
+// checked against Min if HasMin is set and Max if HasMax is set. Required
+// points must be present
+// in the node, and RequiredWhen makes a point required only when any one of
+// the listed matches is satisfied. A match maps point types to the text
+// values they must have, for example {"protocol": "RTU"}. Indexed points
+// may be set at multiple indexes.
+type PointSchema struct {
+	Type         string              `json:"type"`
+	Description  string              `json:"description"`
+	ValueType    string              `json:"valueType"`
+	Required     bool                `json:"required,omitempty"`
+	RequiredWhen []map[string]string `json:"requiredWhen,omitempty"`
+	Allowed      []string            `json:"allowed,omitempty"`
+	HasMin       bool                `json:"hasMin,omitempty"`
+	Min          float64             `json:"min,omitempty"`
+	HasMax       bool                `json:"hasMax,omitempty"`
+	Max          float64             `json:"max,omitempty"`
+	Units        string              `json:"units,omitempty"`
+	Indexed      bool                `json:"indexed,omitempty"`
+}
+
+// NodeSchema describes the points a node type supports
+type NodeSchema struct {
+	Type        string        `json:"type"`
+	Description string        `json:"description"`
+	Points      []PointSchema `json:"points"`
+}
+
+// Point returns the schema for a point type in the node
+func (ns *NodeSchema) Point(typ string) (PointSchema, bool) {
+	for _, ps := range ns.Points {
+		if ps.Type == typ {
+			return ps, true
+		}
+	}
+
+	return PointSchema{}, false
+}
+
+// ValidatePoint checks if a point value is valid for this schema
+func (ps *PointSchema) ValidatePoint(p Point) error {
+	switch ps.ValueType {
+	case PointValueNumber:
+		if math.IsNaN(p.Value) || math.IsInf(p.Value, 0) {
+			return fmt.Errorf("%v must be a number", ps.Type)
+		}
+
+		if ps.HasMin && ps.HasMax && (p.Value < ps.Min || p.Value > ps.Max) {
+			return fmt.Errorf("%v must be between %v and %v", ps.Type, ps.Min, ps.Max)
+		}
+
+		if ps.HasMin && p.Value < ps.Min {
+			return fmt.Errorf("%v must be at least %v", ps.Type, ps.Min)
+		}
+
+		if ps.HasMax && p.Value > ps.Max {
+			return fmt.Errorf("%v must be at most %v", ps.Type, ps.Max)
+		}
+	case PointValueOnOff:
+		if p.Value != 0 && p.Value != 1 {
+			return fmt.Errorf("%v must be 0 or 1", ps.Type)
+		}
+	case PointValueText:
+		if p.Text == "" || len(ps.Allowed) <= 0 {
+			return nil
+		}
+
+		for _, a := range ps.Allowed {
+			if p.Text == a {
+				return nil
+			}
+		}
+
+		return fmt.Errorf("%v must be one of: %v", ps.Type,
+			strings.Join(ps.Allowed, ", "))
+	}
+
+	return nil
+}
+
+// required returns true if the point must be present in a node with
+// the given points
+func (ps *PointSchema) required(points Points) bool {
+	if ps.Required {
+		return true
+	}
+
+	for _, match := range ps.RequiredWhen {
+		matched := true
+		for typ, value := range match {
+			text, _ := points.Text("", typ, 0)
+			if text != value {
+				matched = false
+				break
+			}
+		}
+
+		if matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ValidatePoints checks point values against the schema. Point types that
+// are not in the schema are allowed. Required points are not checked, see
+// ValidateRequired.
+func (ns *NodeSchema) ValidatePoints(points Points) error {
+	for _, p := range points {
+		ps, ok := ns.Point(p.Type)
+		if !ok {
+			continue
+		}
+
+		err := ps.ValidatePoint(p)
+		if err != nil {
+			return fmt.Errorf("%v: %w", ns.Type, err)
+		}
+	}
+
+	return nil
+}
+
+// ValidateRequired makes sure all required points are present in the
+// points of a node.
+func (ns *NodeSchema) ValidateRequired(points Points) error {
+	for _, ps := range ns.Points {
+		if !ps.required(points) {
+			continue
+		}
+
+		found := false
+		for _, p := range points {
+			if p.Type != ps.Type {
+				continue
+			}
+
+			if ps.ValueType == PointValueText && p.Text == "" {
+				continue
+			}
+
+			found = true
+			break
+		}
+
+		if !found {
+			return fmt.Errorf("%v: %v must be set", ns.Type, ps.Type)
+		}
+	}
+
+	return nil
+}
+
+// Validate checks all points in a node against the schema, and also makes
+// sure all required points are present.
+func (ns *NodeSchema) Validate(points Points) error {
+	err := ns.ValidatePoints(points)
+	if err != nil {
+		return err
+	}
+
+	return ns.ValidateRequired(points)
+}
+
+// ToPb converts a node schema to a protobuf type
+func (ns *NodeSchema) ToPb() *pb.NodeSchema {
+	ret := &pb.NodeSchema{
+		Type:        ns.Type,
+		Description: ns.Description,
+	}
+
+	for _, ps := range ns.Points {
+		pbPs := &pb.PointSchema{
+			Type:        ps.Type,
+			Description: ps.Description,
+			ValueType:   ps.ValueType,
+			Required:    ps.Required,
+			Allowed:     ps.Allowed,
+			HasMin:      ps.HasMin,
+			Min:         ps.Min,
+			HasMax:      ps.HasMax,
+			Max:         ps.Max,
+			Units:       ps.Units,
+			Indexed:     ps.Indexed,
+		}
+
+		for _, m := range ps.RequiredWhen {
+			pbPs.RequiredWhen = append(pbPs.RequiredWhen, &pb.SchemaMatch{Points: m})
+		}
+
+		ret.Points = append(ret.Points, pbPs)
+	}
+
+	return ret
+}
+
+// PbToNodeSchema converts a protobuf node schema to a node schema
+func PbToNodeSchema(pbNs *pb.NodeSchema) NodeSchema {
+	ret := NodeSchema{
+		Type:        pbNs.Type,
+		Description: pbNs.Description,
+	}
+
+	for _, pbPs := range pbNs.Points {
+		ps := PointSchema{
+			Type:        pbPs.Type,
+			Description: pbPs.Description,
+			ValueType:   pbPs.ValueType,
+			Required:    pbPs.Required,
+			Allowed:     pbPs.Allowed,
+			HasMin:      pbPs.HasMin,
+			Min:         pbPs.Min,
+			HasMax:      pbPs.HasMax,
+			Max:         pbPs.Max,
+			Units:       pbPs.Units,
+			Indexed:     pbPs.Indexed,
+		}
+
+		for _, m := range pbPs.RequiredWhen {
+			ps.RequiredWhen = append(ps.RequiredWhen, m.Points)
+		}
+
+		ret.Points = append(ret.Points, ps)
+	}
+
+	return ret
+}
+
+// NodeSchemasToPb encodes node schemas as a protobuf NodeSchemasRequest
+func NodeSchemasToPb(schemas []NodeSchema, err error) ([]byte, error) {
+	req := pb.NodeSchemasRequest{}
+
+	if err != nil {
+		req.Error = err.Error()
+	}
+
+	for i := range schemas {
+		req.Schemas = append(req.Schemas, schemas[i].ToPb())
+	}
+
+	return proto.Marshal(&req)
+}
+
+// PbDecodeNodeSchemasRequest decodes a protobuf NodeSchemasRequest
+func PbDecodeNodeSchemasRequest(buf []byte) ([]NodeSchema, error) {
+	req := pb.NodeSchemasRequest{}
+
+	err := proto.Unmarshal(buf, &req)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Error != "" {
+		return nil, fmt.Errorf("%v", req.Error)
+	}
+
+	ret := make([]NodeSchema, len(req.Schemas))
+	for i, s := range req.Schemas {
+		ret[i] = PbToNodeSchema(s)
+	}
+
+	return ret, nil
+}
+
+// GetNodeSchema returns the schema for a node type
+func GetNodeSchema(nodeType string) (NodeSchema, bool) {
+	for _, ns := range nodeSchemas {
+		if ns.Type == nodeType {
+			return ns, true
+		}
+	}
+
+	return NodeSchema{}, false
+}
+
+// NodeSchemas returns the schemas for all known node types
+func NodeSchemas() []NodeSchema {
+	ret := make([]NodeSchema, len(nodeSchemas))
+	copy(ret, nodeSchemas)
+	return ret
+}
+
+// ValidateNodePoints checks point values for a node type. Node types
+// without a schema are not checked.
+func ValidateNodePoints(nodeType string, points Points) error {
+	ns, ok := GetNodeSchema(nodeType)
+	if !ok {
+		return nil
+	}
+
+	return ns.ValidatePoints(points)
+}
+
+// ValidateRequired makes sure all required points are present in the points
+// of a node. Node types without a schema are not checked.
+func ValidateRequired(nodeType string, points Points) error {
+	ns, ok := GetNodeSchema(nodeType)
+	if !ok {
+		return nil
+	}
+
+	return ns.ValidateRequired(points)
+}
+
+// ValidateNode checks all points in a node, including required points.
+// Node types without a schema are not checked.
+func ValidateNode(nodeType string, points Points) error {
+	ns, ok := GetNodeSchema(nodeType)
+	if !ok {
+		return nil
+	}
+
+	return ns.Validate(points)
+}
+
+var descriptionPoint = PointSchema{
+	Type: PointTypeDescription, Description: "description", ValueType: PointValueText,
+}
+
+var logicPoint = PointSchema{
+	Type: PointTypeLogic, Description: "condition logic", ValueType: PointValueText,
+	Allowed: []string{PointValueAnd, PointValueOr, PointValueNot},
+}
+
+// severities are the notification severities, in order of increasing
+// severity
+var severities = []string{PointValueInfo, PointValueWarning, PointValueCritical}
+
+var modbusErrorCountPoints = []PointSchema{
+	{Type: PointTypeErrorCount, Description: "error count", ValueType: PointValueNumber},
+	{Type: PointTypeErrorCountEOF, Description: "EOF error count", ValueType: PointValueNumber},
+	{Type: PointTypeErrorCountCRC, Description: "CRC error count", ValueType: PointValueNumber},
+	{Type: PointTypeErrorCountReset, Description: "reset error count", ValueType: PointValueOnOff},
+	{Type: PointTypeErrorCountEOFReset, Description: "reset EOF error count", ValueType: PointValueOnOff},
+	{Type: PointTypeErrorCountCRCReset, Description: "reset CRC error count", ValueType: PointValueOnOff},
+}
+
+var modbusRegisterMatch = []map[string]string{
+	{PointTypeModbusIOType: PointValueModbusInputRegister},
+	{PointTypeModbusIOType: PointValueModbusHoldingRegister},
+}
+
+// nodeSchemas is the registry of all known node types. When adding a node
+// type or point to the system, describe it here.
+var nodeSchemas = []NodeSchema{
+	{
+		Type:        NodeTypeDevice,
+		Description: "device",
+		Points: []PointSchema{
+			descriptionPoint,
+			{Type: PointTypeSysState, Description: "system state", ValueType: PointValueText,
+				Allowed: []string{PointValueSysStateUnknown, PointValueSysStatePowerOff,
+					PointValueSysStateOffline, PointValueSysStateOnline}},
+			{Type: PointTypeCmdPending, Description: "command pending", ValueType: PointValueOnOff},
+			{Type: PointTypeBootID, Description: "boot ID of the last system start",
+				ValueType: PointValueText},
+		},
+	},
+	{
+		Type:        NodeTypeUser,
+		Description: "user",
+		Points: []PointSchema{
+			{Type: PointTypeFirstName, Description: "first name", ValueType: PointValueText},
+			{Type: PointTypeLastName, Description: "last name", ValueType: PointValueText},
+			{Type: PointTypePhone, Description: "phone", ValueType: PointValueText},
+			{Type: PointTypeEmail, Description: "email", ValueType: PointValueText},
+			{Type: PointTypePass, Description: "password", ValueType: PointValueText},
+			{Type: PointTypePassChange, Description: "password change required",
+				ValueType: PointValueOnOff},
+			{Type: PointTypeNotifyChannel, Description: "notification channel",
+				ValueType: PointValueText, Indexed: true,
+				Allowed: []string{PointValueSMS, PointValueEmail, PointValueWebhook}},
+			{Type: PointTypeMinSeverity, Description: "minimum notification severity",
+				ValueType: PointValueText, Allowed: severities},
+			{Type: PointTypeQuietStart, Description: "quiet hours start (HH:MM)",
+				ValueType: PointValueText},
+			{Type: PointTypeQuietEnd, Description: "quiet hours end (HH:MM)",
+				ValueType: PointValueText},
+			{Type: PointTypeTimezone, Description: "timezone", ValueType: PointValueText},
+			{Type: PointTypeQuietSeverity, Description: "minimum severity in quiet hours",
+				ValueType: PointValueText, Allowed: severities},
+			{Type: PointTypeOptOut, Description: "opt out of notifications",
+				ValueType: PointValueOnOff},
+		},
+	},
+	{
+		Type:        NodeTypeGroup,
+		Description: "group",
+		Points: []PointSchema{
+			descriptionPoint,
+		},
+	},
+	{
+		Type:        NodeTypeModbus,
+		Description: "modbus bus",
+		Points: append([]PointSchema{
+			descriptionPoint,
+			{Type: PointTypeClientServer, Description: "client/server", ValueType: PointValueText,
+				Required: true, Allowed: []string{PointValueClient, PointValueServer}},
+			{Type: PointTypeProtocol, Description: "protocol", ValueType: PointValueText,
+				Required: true, Allowed: []string{PointValueRTU, PointValueTCP}},
+			{Type: PointTypePort, Description: "port", ValueType: PointValueText,
+				RequiredWhen: []map[string]string{
+					{PointTypeProtocol: PointValueRTU},
+					{PointTypeProtocol: PointValueTCP, PointTypeClientServer: PointValueServer},
+				}},
+			{Type: PointTypeBaud, Description: "baud", ValueType: PointValueText,
+				RequiredWhen: []map[string]string{{PointTypeProtocol: PointValueRTU}}},
+			{Type: PointTypeURI, Description: "URI", ValueType: PointValueText,
+				RequiredWhen: []map[string]string{
+					{PointTypeProtocol: PointValueTCP, PointTypeClientServer: PointValueClient},
+				}},
+			{Type: PointTypeID, Description: "device ID", ValueType: PointValueNumber,
+				HasMin: true, Min: 0, HasMax: true, Max: 255,
+				RequiredWhen: []map[string]string{{PointTypeClientServer: PointValueServer}}},
+			{Type: PointTypePollPeriod, Description: "poll period", ValueType: PointValueNumber,
+				Units:        "ms",
+				RequiredWhen: []map[string]string{{PointTypeClientServer: PointValueClient}}},
+			{Type: PointTypeDebug, Description: "debug level", ValueType: PointValueNumber,
+				HasMin: true, Min: 0, HasMax: true, Max: 9},
+		}, modbusErrorCountPoints...),
+	},
+	{
+		Type:        NodeTypeModbusIO,
+		Description: "modbus IO",
+		Points: append([]PointSchema{
+			descriptionPoint,
+			{Type: PointTypeID, Description: "device ID", ValueType: PointValueNumber,
+				HasMin: true, Min: 0, HasMax: true, Max: 255},
+			{Type: PointTypeAddress, Description: "address", ValueType: PointValueNumber,
+				Required: true, HasMin: true, Min: 0, HasMax: true, Max: 65535},
+			{Type: PointTypeModbusIOType, Description: "IO type", ValueType: PointValueText,
+				Required: true, Allowed: []string{PointValueModbusDiscreteInput,
+					PointValueModbusCoil, PointValueModbusInputRegister,
+					PointValueModbusHoldingRegister}},
+			{Type: PointTypeDataFormat, Description: "data format", ValueType: PointValueText,
+				RequiredWhen: modbusRegisterMatch,
+				Allowed: []string{PointValueUINT16, PointValueINT16, PointValueUINT32,
+					PointValueINT32, PointValueFLOAT32}},
+			{Type: PointTypeScale, Description: "scale", ValueType: PointValueNumber,
+				RequiredWhen: modbusRegisterMatch},
+			{Type: PointTypeOffset, Description: "offset", ValueType: PointValueNumber,
+				RequiredWhen: modbusRegisterMatch},
+			{Type: PointTypeUnits, Description: "units", ValueType: PointValueText},
+			{Type: PointTypeReadOnly, Description: "read only", ValueType: PointValueOnOff},
+			{Type: PointTypeValue, Description: "value", ValueType: PointValueNumber},
+			{Type: PointTypeValueSet, Description: "set value", ValueType: PointValueNumber},
+		}, modbusErrorCountPoints...),
+	},
+	{
+		Type:        NodeTypeDb,
+		Description: "InfluxDB database",
+		Points: []PointSchema{
+			descriptionPoint,
+			{Type: PointTypeURI, Description: "URL", ValueType: PointValueText, Required: true},
+			{Type: PointTypeBucket, Description: "bucket", ValueType: PointValueText, Required: true},
+			{Type: PointTypeOrg, Description: "organization", ValueType: PointValueText, Required: true},
+			{Type: PointTypeAuthToken, Description: "API token", ValueType: PointValueText, Required: true},
+		},
+	},
+	{
+		Type:        NodeTypeRule,
+		Description: "rule",
+		Points: []PointSchema{
+			descriptionPoint,
+			{Type: PointTypeActive, Description: "active", ValueType: PointValueOnOff},
+			logicPoint,
+			{Type: PointTypeAlarmState, Description: "alarm state", ValueType: PointValueText,
+				Allowed: []string{PointValueAlarmNormal, PointValueAlarmActiveUnacked,
+					PointValueAlarmActiveAcked, PointValueAlarmClearedUnacked,
+					PointValueAlarmShelved}},
+			{Type: PointTypeAckUser, Description: "acknowledged by", ValueType: PointValueText},
+			{Type: PointTypeShelveUntil, Description: "shelved until", ValueType: PointValueText},
+			{Type: PointTypeTrace, Description: "trace evaluation", ValueType: PointValueOnOff},
+			{Type: PointTypeSeverity, Description: "notification severity",
+				ValueType: PointValueText, Allowed: severities},
+		},
+	},
+	{
+		Type:        NodeTypeConditionGroup,
+		Description: "rule condition group",
+		Points: []PointSchema{
+			descriptionPoint,
+			{Type: PointTypeActive, Description: "active", ValueType: PointValueOnOff},
+			logicPoint,
+		},
+	},
+	{
+		Type:        NodeTypeCondition,
+		Description: "rule condition",
+		Points: []PointSchema{
+			descriptionPoint,
+			{Type: PointTypeConditionType, Description: "condition type", ValueType: PointValueText,
+				Allowed: []string{PointValuePointValue, PointValueSchedule, PointValueNoUpdate,
+					PointValueWindow}},
+			{Type: PointTypeID, Description: "node ID", ValueType: PointValueText},
+			{Type: PointTypePointID, Description: "point ID", ValueType: PointValueText},
+			{Type: PointTypePointType, Description: "point type", ValueType: PointValueText},
+			{Type: PointTypePointIndex, Description: "point index", ValueType: PointValueNumber},
+			{Type: PointTypeValueType, Description: "point value type", ValueType: PointValueText,
+				Allowed: []string{PointValueNumber, PointValueOnOff, PointValueText}},
+			{Type: PointTypeOperator, Description: "operator", ValueType: PointValueText,
+				Allowed: []string{PointValueGreaterThan, PointValueLessThan, PointValueEqual,
+					PointValueNotEqual, PointValueOn, PointValueOff, PointValueContains,
+					PointValueStartsWith, PointValueEndsWith, PointValueRegex}},
+			{Type: PointTypeValue, Description: "value", ValueType: PointValueNumber},
+			{Type: PointTypeCaseInsensitive, Description: "ignore case of text values",
+				ValueType: PointValueOnOff},
+			{Type: PointTypeMinActive, Description: "minimum active time", ValueType: PointValueNumber,
+				Units: "m", HasMin: true, Min: 0},
+			{Type: PointTypeMinInactive, Description: "minimum inactive time", ValueType: PointValueNumber,
+				Units: "m", HasMin: true, Min: 0},
+			{Type: PointTypeHysteresis, Description: "hysteresis", ValueType: PointValueNumber,
+				HasMin: true, Min: 0},
+			{Type: PointTypeStaleTime, Description: "stale time", ValueType: PointValueNumber,
+				Units: "m", HasMin: true, Min: 0},
+			{Type: PointTypeInputStatus, Description: "input status", ValueType: PointValueText,
+				Allowed: []string{PointValueInputOK, PointValueInputMissing, PointValueInputStale}},
+			{Type: PointTypeWindow, Description: "window", ValueType: PointValueNumber,
+				Units: "m", HasMin: true, Min: 0},
+			{Type: PointTypeWindowStat, Description: "window statistic", ValueType: PointValueText,
+				Allowed: []string{PointValueAverage, PointValueMin, PointValueMax, PointValueDelta,
+					PointValueDeltaPercent, PointValueRate}},
+			{Type: PointTypeNoUpdateTime, Description: "time without updates", ValueType: PointValueNumber,
+				Units: "m", HasMin: true, Min: 0},
+			{Type: PointTypeDescendants, Description: "include descendant nodes",
+				ValueType: PointValueOnOff},
+			{Type: PointTypePending, Description: "pending", ValueType: PointValueOnOff},
+			{Type: PointTypeActive, Description: "active", ValueType: PointValueOnOff},
+			{Type: PointTypeStart, Description: "start time", ValueType: PointValueText},
+			{Type: PointTypeEnd, Description: "end time", ValueType: PointValueText},
+			{Type: PointTypeWeekday, Description: "weekday", ValueType: PointValueOnOff,
+				Indexed: true},
+			{Type: PointTypeTimezone, Description: "timezone", ValueType: PointValueText},
+			{Type: PointTypeDate, Description: "date", ValueType: PointValueText, Indexed: true},
+			{Type: PointTypeExcludeDate, Description: "exclude date", ValueType: PointValueText,
+				Indexed: true},
+			{Type: PointTypeCalendar, Description: "calendar node ID", ValueType: PointValueText},
+			{Type: PointTypeCalendarMode, Description: "calendar mode", ValueType: PointValueText,
+				Allowed: []string{PointValueExclude, PointValueOnly}},
+		},
+	},
+	actionSchema(NodeTypeAction, "rule action"),
+	actionSchema(NodeTypeActionInactive, "rule inactive action"),
+	{
+		Type:        NodeTypeCalendar,
+		Description: "calendar",
+		Points: []PointSchema{
+			descriptionPoint,
+			{Type: PointTypeDate, Description: "date", ValueType: PointValueText, Indexed: true},
+		},
+	},
+	{
+		Type:        NodeTypeMsgService,
+		Description: "messaging service",
+		Points: []PointSchema{
+			descriptionPoint,
+			{Type: PointTypeService, Description: "service", ValueType: PointValueText,
+				Required: true, Allowed: []string{PointValueTwilio, PointValueSMTP,
+					PointValueWebhook, PointValueModem, PointValueMock}},
+			{Type: PointTypeSID, Description: "SID", ValueType: PointValueText},
+			{Type: PointTypeAuthToken, Description: "auth token", ValueType: PointValueText},
+			{Type: PointTypeFrom, Description: "from", ValueType: PointValueText},
+			{Type: PointTypeHost, Description: "SMTP host", ValueType: PointValueText},
+			{Type: PointTypePort, Description: "SMTP port", ValueType: PointValueNumber,
+				HasMin: true, Min: 0, HasMax: true, Max: 65535},
+			{Type: PointTypeTLSMode, Description: "SMTP TLS mode", ValueType: PointValueText,
+				Allowed: []string{PointValueTLSNone, PointValueStartTLS, PointValueTLS}},
+			{Type: PointTypeUsername, Description: "SMTP username", ValueType: PointValueText},
+			{Type: PointTypePass, Description: "SMTP password", ValueType: PointValueText},
+			{Type: PointTypeSubjectPrefix, Description: "email subject prefix",
+				ValueType: PointValueText},
+			{Type: PointTypeURI, Description: "webhook URL", ValueType: PointValueText},
+			{Type: PointTypeMethod, Description: "webhook method", ValueType: PointValueText,
+				Allowed: []string{"POST", "PUT"}},
+			{Type: PointTypeHeader, Description: "webhook header", ValueType: PointValueText,
+				Indexed: true},
+			{Type: PointTypeModemPort, Description: "modem AT command port",
+				ValueType: PointValueText},
+			{Type: PointTypeSMSMode, Description: "modem SMS mode", ValueType: PointValueText,
+				Allowed: []string{PointValueText, PointValuePDU}},
+			{Type: PointTypeTimeout, Description: "delivery timeout", ValueType: PointValueNumber,
+				Units: "s", HasMin: true, Min: 0},
+			{Type: PointTypeRetries, Description: "delivery retries", ValueType: PointValueNumber,
+				HasMin: true, Min: 0},
+			{Type: PointTypeError, Description: "delivery error", ValueType: PointValueText},
+		},
+	},
+	{
+		Type:        NodeTypeVariable,
+		Description: "variable",
+		Points: []PointSchema{
+			descriptionPoint,
+			{Type: PointTypeVariableType, Description: "variable type", ValueType: PointValueText,
+				Allowed: []string{PointValueOnOff, PointValueNumber}},
+			{Type: PointTypeValue, Description: "value", ValueType: PointValueNumber},
+			{Type: PointTypeUnits, Description: "units", ValueType: PointValueText},
+			{Type: PointTypeExpression, Description: "expression", ValueType: PointValueText},
+			{Type: PointTypeInputName, Description: "input name", ValueType: PointValueText,
+				Indexed: true},
+			{Type: PointTypeID, Description: "input node ID", ValueType: PointValueText,
+				Indexed: true},
+			{Type: PointTypePointType, Description: "input point type", ValueType: PointValueText,
+				Indexed: true},
+			{Type: PointTypePointIndex, Description: "input point index", ValueType: PointValueNumber,
+				Indexed: true},
+			{Type: PointTypeError, Description: "variable error", ValueType: PointValueText},
+		},
+	},
+	{
+		Type:        NodeTypeUpstream,
+		Description: "upstream connection",
+		Points: []PointSchema{
+			descriptionPoint,
+			{Type: PointTypeURI, Description: "URI", ValueType: PointValueText, Required: true},
+			{Type: PointTypeAuthToken, Description: "auth token", ValueType: PointValueText},
+		},
+	},
+	{
+		Type:        NodeTypeRetention,
+		Description: "history retention",
+		Points: []PointSchema{
+			descriptionPoint,
+			{Type: PointTypePointType, Description: "point type", ValueType: PointValueText},
+			{Type: PointTypeRawRetention, Description: "raw retention", ValueType: PointValueNumber,
+				Units: "days", HasMin: true, Min: 0, HasMax: true, Max: 36500},
+			{Type: PointTypeRollupPeriod, Description: "rollup period", ValueType: PointValueNumber,
+				Units: "minutes", HasMin: true, Min: 0, HasMax: true, Max: 525600, Indexed: true},
+			{Type: PointTypeRollupRetention, Description: "rollup retention", ValueType: PointValueNumber,
+				Units: "days", HasMin: true, Min: 0, HasMax: true, Max: 36500, Indexed: true},
+			{Type: PointTypeEventRetention, Description: "event retention", ValueType: PointValueNumber,
+				Units: "days", HasMin: true, Min: 0, HasMax: true, Max: 36500},
+		},
+	},
+}
+
+func actionSchema(nodeType, desc string) NodeSchema {
+	return NodeSchema{
+		Type:        nodeType,
+		Description: desc,
+		Points: []PointSchema{
+			descriptionPoint,
+			{Type: PointTypeActionType, Description: "action type", ValueType: PointValueText,
+				Allowed: []string{PointValueActionNotify, PointValueActionSetValue,
+					PointValueActionPlayAudio, PointValueActionWebhook}},
+			{Type: PointTypeID, Description: "node ID", ValueType: PointValueText},
+			{Type: PointTypePointType, Description: "point type", ValueType: PointValueText},
+			{Type: PointTypeValueType, Description: "point value type", ValueType: PointValueText,
+				Allowed: []string{PointValueNumber, PointValueOnOff, PointValueText}},
+			{Type: PointTypeValue, Description: "value", ValueType: PointValueNumber},
+			{Type: PointTypeChannel, Description: "audio channel", ValueType: PointValueNumber},
+			{Type: PointTypeDevice, Description: "audio device", ValueType: PointValueText},
+			{Type: PointTypeFilePath, Description: "audio file path", ValueType: PointValueText},
+			{Type: PointTypeDelay, Description: "delay", ValueType: PointValueNumber,
+				Units: "m", HasMin: true, Min: 0},
+			{Type: PointTypeRepeat, Description: "repeat interval", ValueType: PointValueNumber,
+				Units: "m", HasMin: true, Min: 0},
+			{Type: PointTypeRepeatMax, Description: "maximum repeats", ValueType: PointValueNumber,
+				HasMin: true, Min: 0},
+			{Type: PointTypeRunCount, Description: "run count", ValueType: PointValueNumber},
+			{Type: PointTypeSubjectTemplate, Description: "notification subject template",
+				ValueType: PointValueText},
+			{Type: PointTypeTemplate, Description: "notification message or webhook body template",
+				ValueType: PointValueText},
+			{Type: PointTypeHTMLTemplate, Description: "notification HTML email template",
+				ValueType: PointValueText},
+			{Type: PointTypeURI, Description: "webhook URL", ValueType: PointValueText},
+			{Type: PointTypeMethod, Description: "webhook method", ValueType: PointValueText,
+				Allowed: []string{"POST", "PUT"}},
+			{Type: PointTypeHeader, Description: "webhook header", ValueType: PointValueText,
+				Indexed: true},
+			{Type: PointTypeTimeout, Description: "webhook timeout", ValueType: PointValueNumber,
+				Units: "s", HasMin: true, Min: 0},
+			{Type: PointTypeRetries, Description: "webhook retries", ValueType: PointValueNumber,
+				HasMin: true, Min: 0},
+			{Type: PointTypeStatusCode, Description: "webhook status code", ValueType: PointValueNumber},
+			{Type: PointTypeAttempts, Description: "webhook attempts", ValueType: PointValueNumber},
+			{Type: PointTypeError, Description: "webhook error", ValueType: PointValueText},
+		},
+	}
+}
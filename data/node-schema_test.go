@@ -0,0 +1,92 @@
+package data
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidateNodePoints(t *testing.T) {
+	tests := []struct {
+		desc     string
+		nodeType string
+		points   Points
+		valid    bool
+	}{
+		{"allowed text", NodeTypeModbus,
+			Points{{Type: PointTypeProtocol, Text: PointValueRTU}}, true},
+		{"invalid text", NodeTypeModbus,
+			Points{{Type: PointTypeProtocol, Text: "serial"}}, false},
+		{"blank text", NodeTypeModbus,
+			Points{{Type: PointTypeProtocol, Text: ""}}, true},
+		{"out of range", NodeTypeModbusIO,
+			Points{{Type: PointTypeAddress, Value: 70000}}, false},
+		{"in range", NodeTypeModbusIO,
+			Points{{Type: PointTypeAddress, Value: 100}}, true},
+		{"below min without max", NodeTypeCondition,
+			Points{{Type: PointTypeMinActive, Value: -1}}, false},
+		{"min without max", NodeTypeCondition,
+			Points{{Type: PointTypeMinActive, Value: 1000}}, true},
+		{"invalid on/off", NodeTypeRule,
+			Points{{Type: PointTypeActive, Value: 2}}, false},
+		{"unknown point", NodeTypeModbus,
+			Points{{Type: "custom", Value: 2}}, true},
+		{"unknown node type", "custom",
+			Points{{Type: PointTypeProtocol, Text: "serial"}}, true},
+	}
+
+	for _, test := range tests {
+		err := ValidateNodePoints(test.nodeType, test.points)
+		if test.valid && err != nil {
+			t.Errorf("%v: expected valid, got: %v", test.desc, err)
+		} else if !test.valid && err == nil {
+			t.Errorf("%v: expected error", test.desc)
+		}
+	}
+}
+
+func TestValidateNode(t *testing.T) {
+	points := Points{
+		{Type: PointTypeClientServer, Text: PointValueClient},
+		{Type: PointTypeProtocol, Text: PointValueTCP},
+		{Type: PointTypePollPeriod, Value: 500},
+	}
+
+	err := ValidateNode(NodeTypeModbus, points)
+	if err == nil {
+		t.Error("expected error for missing URI")
+	}
+
+	points = append(points, Point{Type: PointTypeURI, Text: "localhost:502"})
+
+	err = ValidateNode(NodeTypeModbus, points)
+	if err != nil {
+		t.Error("expected valid node, got: ", err)
+	}
+
+	// port is only required for RTU or TCP servers
+	points[1].Text = PointValueRTU
+	points = append(points, Point{Type: PointTypeBaud, Text: "9600"})
+
+	err = ValidateNode(NodeTypeModbus, points)
+	if err == nil {
+		t.Error("expected error for missing port")
+	}
+}
+
+func TestNodeSchemasPb(t *testing.T) {
+	schemas := NodeSchemas()
+
+	buf, err := NodeSchemasToPb(schemas, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := PbDecodeNodeSchemasRequest(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(schemas, decoded) {
+		t.Error("decoded schemas do not match")
+	}
+}
@@ -79,15 +79,36 @@ func (n *Node) Desc() string {
 // offline to be when we did not receive data from a remote device
 // for X minutes. However, with points that could represent a config
 // change as well. Eventually we may want to improve this to look
-// at point types (perhaps Sample).
+// at point types (perhaps Sample). The sysState point itself is not
+// considered, and a node that is offline goes back online as soon as
+// it sends points again.
 func (n *Node) GetState() (string, bool) {
 	sysState := n.State()
+
+	var latest time.Time
+	for _, p := range n.Points {
+		if p.Type != PointTypeSysState && p.Time.After(latest) {
+			latest = p.Time
+		}
+	}
+
+	offline := time.Since(latest) > 15*time.Minute
+
 	switch sysState {
-	case PointValueSysStateUnknown, PointValueSysStateOnline:
-		if time.Since(n.Points.LatestTime()) > 15*time.Minute {
+	case PointValueSysStatePowerOff:
+	case PointValueSysStateOffline:
+		if !offline {
+			return PointValueSysStateOnline, true
+		}
+	default:
+		if offline {
 			// mark device as offline
 			return PointValueSysStateOffline, true
 		}
+
+		if sysState != PointValueSysStateOnline {
+			return PointValueSysStateOnline, true
+		}
 	}
 
 	return sysState, false
@@ -106,14 +127,16 @@ func (n *Node) ToUser() User {
 	phone, _ := n.Points.Text("", PointTypePhone, 0)
 	email, _ := n.Points.Text("", PointTypeEmail, 0)
 	pass, _ := n.Points.Text("", PointTypePass, 0)
+	passChange, _ := n.Points.ValueBool("", PointTypePassChange, 0)
 
 	return User{
-		ID:        n.ID,
-		FirstName: first,
-		LastName:  last,
-		Phone:     phone,
-		Email:     email,
-		Pass:      pass,
+		ID:         n.ID,
+		FirstName:  first,
+		LastName:   last,
+		Phone:      phone,
+		Email:      email,
+		Pass:       pass,
+		PassChange: passChange,
 	}
 }
 
@@ -304,6 +327,33 @@ func (n *NodeEdge) ProcessPoint(pIn Point) {
 	}
 }
 
+// PointRedacted returns true for point types that are written but never
+// returned to clients by the API or NATS, like passwords. These points are
+// only read when nodes are synchronized between instances.
+func PointRedacted(typ string) bool {
+	return typ == PointTypePass
+}
+
+// Redact removes the points that are never returned to clients
+func (n *NodeEdge) Redact() {
+	var points Points
+
+	for _, p := range n.Points {
+		if !PointRedacted(p.Type) {
+			points = append(points, p)
+		}
+	}
+
+	n.Points = points
+}
+
+// Redact removes the points that are never returned to clients
+func (nodes Nodes) Redact() {
+	for i := range nodes {
+		nodes[i].Redact()
+	}
+}
+
 // bytesLess compares two slices of bytes and returns true if a is less than b
 func bytesLess(a, b []byte) bool {
 	return bytes.Compare(a, b) < 0
@@ -0,0 +1,75 @@
+package data
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNodeGetState(t *testing.T) {
+	now := time.Now()
+	old := now.Add(-time.Hour)
+
+	tests := []struct {
+		name    string
+		points  Points
+		state   string
+		changed bool
+	}{
+		{"new node", Points{{Type: PointTypeValue, Time: now}},
+			PointValueSysStateOnline, true},
+		{"online", Points{
+			{Type: PointTypeValue, Time: now},
+			{Type: PointTypeSysState, Text: PointValueSysStateOnline, Time: old},
+		}, PointValueSysStateOnline, false},
+		{"stale", Points{
+			{Type: PointTypeValue, Time: old},
+			{Type: PointTypeSysState, Text: PointValueSysStateOnline, Time: old},
+		}, PointValueSysStateOffline, true},
+		{"offline", Points{
+			{Type: PointTypeValue, Time: old},
+			{Type: PointTypeSysState, Text: PointValueSysStateOffline, Time: now},
+		}, PointValueSysStateOffline, false},
+		{"back online", Points{
+			{Type: PointTypeValue, Time: now},
+			{Type: PointTypeSysState, Text: PointValueSysStateOffline, Time: old},
+		}, PointValueSysStateOnline, true},
+		{"power off", Points{
+			{Type: PointTypeValue, Time: old},
+			{Type: PointTypeSysState, Text: PointValueSysStatePowerOff, Time: old},
+		}, PointValueSysStatePowerOff, false},
+	}
+
+	for _, test := range tests {
+		n := Node{Points: test.points}
+		state, changed := n.GetState()
+		if state != test.state || changed != test.changed {
+			t.Errorf("%v: expected %v %v, got %v %v", test.name,
+				test.state, test.changed, state, changed)
+		}
+	}
+}
+
+func TestNodeRedact(t *testing.T) {
+	points := Points{
+		{Type: PointTypeEmail, Text: "bob@example.com"},
+		{Type: PointTypePass, Text: "hash"},
+		{Type: PointTypePassChange, Value: 1},
+	}
+
+	nodes := Nodes{{ID: "u1", Type: NodeTypeUser, Points: points}}
+	nodes.Redact()
+
+	if len(nodes[0].Points) != 2 {
+		t.Fatalf("wrong points: %+v", nodes[0].Points)
+	}
+
+	for _, p := range nodes[0].Points {
+		if p.Type == PointTypePass {
+			t.Error("password not redacted")
+		}
+	}
+
+	if len(points) != 3 || points[1].Type != PointTypePass {
+		t.Error("redact changed the points of the node")
+	}
+}
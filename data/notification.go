@@ -12,6 +12,13 @@ type Notification struct {
 	SourceNode string `json:"sourceNode"`
 	Subject    string `json:"subject"`
 	Message    string `json:"message"`
+	// HTML is an optional HTML version of the message for email
+	HTML string `json:"html"`
+	// Severity is info, warning, or critical. Blank is warning.
+	Severity string `json:"severity"`
+	// RuleID is the rule that sent the notification, if any. It is used
+	// to acknowledge the alarm of the rule from a reply to the message.
+	RuleID string `json:"ruleId"`
 }
 
 // ToPb converts to protobuf data
@@ -22,6 +29,9 @@ func (n *Notification) ToPb() ([]byte, error) {
 		SourceNode: n.SourceNode,
 		Subject:    n.Subject,
 		Msg:        n.Message,
+		Html:       n.HTML,
+		Severity:   n.Severity,
+		RuleId:     n.RuleID,
 	}
 
 	return proto.Marshal(&pbNot)
@@ -42,5 +52,21 @@ func PbDecodeNotification(data []byte) (Notification, error) {
 		SourceNode: pbNot.SourceNode,
 		Subject:    pbNot.Subject,
 		Message:    pbNot.Msg,
+		HTML:       pbNot.Html,
+		Severity:   pbNot.Severity,
+		RuleID:     pbNot.RuleId,
 	}, nil
 }
+
+// SeverityLevel returns a number for a notification severity that increases
+// with severity. A blank or unknown severity is warning.
+func SeverityLevel(severity string) int {
+	switch severity {
+	case PointValueInfo:
+		return 1
+	case PointValueCritical:
+		return 3
+	}
+
+	return 2
+}
@@ -3,7 +3,9 @@ package data
 import (
 	"crypto/md5"
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"io"
 	"time"
 
 	"github.com/golang/protobuf/ptypes"
@@ -45,6 +47,15 @@ type Point struct {
 	// statistical values that may be calculated over the duration of the point
 	Min float64 `json:"min,omitempty"`
 	Max float64 `json:"max,omitempty"`
+
+	// Time and Logical form a hybrid logical clock that is used to decide
+	// which point wins when the same point is changed on several instances.
+	// Logical orders points that have the same Time.
+	Logical int `json:"logical,omitempty"`
+
+	// Origin is the ID of the instance (root node ID) that created the
+	// point. It is used to break ties between points with the same clock.
+	Origin string `json:"origin,omitempty"`
 }
 
 func (p Point) String() string {
@@ -81,6 +92,54 @@ func (p Point) String() string {
 	return t
 }
 
+// After returns true if point p is newer than point o. Points are ordered
+// by their hybrid logical clock (Time, then Logical), and Origin is used as a
+// deterministic tie breaker so that all instances pick the same winner.
+func (p Point) After(o Point) bool {
+	if !p.Time.Equal(o.Time) {
+		return p.Time.After(o.Time)
+	}
+
+	if p.Logical != o.Logical {
+		return p.Logical > o.Logical
+	}
+
+	return p.Origin > o.Origin
+}
+
+// Stamp sets the hybrid logical clock for a point that was created on the
+// instance identified by origin. prev is the current value of the point on
+// this instance, if any. The time of p is advanced to the time of prev if
+// prev is newer (typically due to clock drift), and the logical counter is
+// incremented if the times are equal, so that a local change always
+// supersedes the value this instance has already seen.
+func (p *Point) Stamp(origin string, prev *Point) {
+	p.Origin = origin
+	p.Logical = 0
+
+	if prev == nil || p.Time.After(prev.Time) {
+		return
+	}
+
+	p.Time = prev.Time
+	p.Logical = prev.Logical + 1
+}
+
+// WriteClock writes the hybrid logical clock of the point to w. This is
+// used to calculate hashes of points. Logical and Origin are only written
+// if set so that hashes of points without them do not change.
+func (p Point) WriteClock(w io.Writer) {
+	d := make([]byte, 8)
+	binary.LittleEndian.PutUint64(d, uint64(p.Time.UnixNano()))
+	w.Write(d)
+
+	if p.Logical != 0 || p.Origin != "" {
+		binary.LittleEndian.PutUint64(d, uint64(p.Logical))
+		w.Write(d)
+		w.Write([]byte(p.Origin))
+	}
+}
+
 // IsMatch returns true if the point matches the params passed in
 func (p Point) IsMatch(id, typ string, index int) bool {
 	if id != "" && id != p.ID {
@@ -115,6 +174,8 @@ func (p Point) ToPb() (pb.Point, error) {
 		Duration: ptypes.DurationProto(p.Duration),
 		Min:      float32(p.Min),
 		Max:      float32(p.Max),
+		Logical:  int32(p.Logical),
+		Origin:   p.Origin,
 	}, nil
 }
 
@@ -203,17 +264,27 @@ func (ps *Points) LatestTime() time.Time {
 
 // ToPb encodes an array of points into protobuf
 func (ps *Points) ToPb() ([]byte, error) {
+	pbPoints, err := ps.ToPbPoints()
+	if err != nil {
+		return []byte{}, err
+	}
+
+	return proto.Marshal(pbPoints)
+}
+
+// ToPbPoints converts an array of points to protobuf points
+func (ps *Points) ToPbPoints() (*pb.Points, error) {
 	pbPoints := make([]*pb.Point, len(*ps))
 	for i, s := range *ps {
 		sPb, err := s.ToPb()
 		if err != nil {
-			return []byte{}, err
+			return nil, err
 		}
 
 		pbPoints[i] = &sPb
 	}
 
-	return proto.Marshal(&pb.Points{Points: pbPoints})
+	return &pb.Points{Points: pbPoints}, nil
 }
 
 // question -- should be using []*Point instead of []Point?
@@ -223,29 +294,34 @@ func (ps *Points) Hash() []byte {
 	h := md5.New()
 
 	for _, p := range *ps {
-		d := make([]byte, 8)
-		binary.LittleEndian.PutUint64(d, uint64(p.Time.UnixNano()))
-		h.Write(d)
+		p.WriteClock(h)
 	}
 
 	return h.Sum(nil)
 }
 
-// ProcessPoint takes a point and updates an existing array of points
-func (ps *Points) ProcessPoint(pIn Point) {
+// ProcessPoint takes a point and updates an existing array of points.
+// Existing points are only replaced by newer points (see Point.After).
+// Returns true if the point was applied.
+func (ps *Points) ProcessPoint(pIn Point) bool {
+	applied := false
 	pFound := false
 	for i, p := range *ps {
 		if p.ID == pIn.ID && p.Type == pIn.Type && p.Index == pIn.Index {
 			pFound = true
-			if pIn.Time.After(p.Time) {
+			if pIn.After(p) {
 				(*ps)[i] = pIn
+				applied = true
 			}
 		}
 	}
 
 	if !pFound {
 		*ps = append(*ps, pIn)
+		applied = true
 	}
+
+	return applied
 }
 
 // Implement methods needed by sort.Interface
@@ -288,6 +364,8 @@ func PbToPoint(sPb *pb.Point) (Point, error) {
 		Duration: dur,
 		Min:      float64(sPb.Min),
 		Max:      float64(sPb.Max),
+		Logical:  int(sPb.Logical),
+		Origin:   sPb.Origin,
 	}
 
 	return ret, nil
@@ -314,6 +392,35 @@ func PbDecodePoints(data []byte) ([]Point, error) {
 	return ret, nil
 }
 
+// PbDecodePointsRequest decodes a protobuf encoded points request
+func PbDecodePointsRequest(data []byte) ([]Point, error) {
+	pbPointsRequest := &pb.PointsRequest{}
+	err := proto.Unmarshal(data, pbPointsRequest)
+	if err != nil {
+		return []Point{}, err
+	}
+
+	if pbPointsRequest.Error != "" {
+		return []Point{}, errors.New(pbPointsRequest.Error)
+	}
+
+	if pbPointsRequest.Points == nil {
+		return []Point{}, nil
+	}
+
+	ret := make([]Point, len(pbPointsRequest.Points.Points))
+
+	for i, sPb := range pbPointsRequest.Points.Points {
+		s, err := PbToPoint(sPb)
+		if err != nil {
+			return []Point{}, err
+		}
+		ret[i] = s
+	}
+
+	return ret, nil
+}
+
 // PointFilter is used to send points upstream. It only sends
 // the data has changed, and at a max frequency
 type PointFilter struct {
@@ -29,19 +29,18 @@ func (pa *PointAverager) AddPoint(s Point) {
 		pa.pointTime = s.Time
 	}
 
-	// update statistical values.
-	pa.total += s.Value
-	pa.count++
+	// update statistical values. The first point seeds min/max
+	// so that negative values are handled correctly.
 	// min
-	if pa.min == 0 {
-		pa.min = s.Min
-	} else if s.Min < pa.min {
+	if pa.count == 0 || s.Min < pa.min {
 		pa.min = s.Min
 	}
 	// max
-	if s.Max > pa.max {
+	if pa.count == 0 || s.Max > pa.max {
 		pa.max = s.Max
 	}
+	pa.total += s.Value
+	pa.count++
 }
 
 // ResetAverage sets the accumulated total to zero
@@ -72,6 +72,25 @@ func TestPointAverager(t *testing.T) {
 	if avgPoint.Max != max {
 		t.Error("point max is not correct")
 	}
+
+	// Round 4, negative values
+	min = -20
+	max = -5
+	avg = -10
+
+	pointAverager = NewPointAverager("testPoint")
+	feedPoints(pointAverager, avg, min, max)
+
+	avgPoint = pointAverager.GetAverage()
+	if avgPoint.Value != avg {
+		t.Error("point avg is not correct")
+	}
+	if avgPoint.Min != min {
+		t.Error("point min is not correct: ", avgPoint.Min)
+	}
+	if avgPoint.Max != max {
+		t.Error("point max is not correct: ", avgPoint.Max)
+	}
 }
 
 func feedPoints(pointAverager *PointAverager, avg, min, max float64) {
@@ -37,3 +37,94 @@ func TestPointsSort(t *testing.T) {
 		t.Errorf("t3 failed, t3: %v, exp: %v", t3, exp)
 	}
 }
+
+func TestPointAfter(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		desc string
+		p, o Point
+		exp  bool
+	}{
+		{"newer time", Point{Time: now.Add(time.Second)}, Point{Time: now, Logical: 5}, true},
+		{"older time", Point{Time: now, Logical: 5}, Point{Time: now.Add(time.Second)}, false},
+		{"logical", Point{Time: now, Logical: 1}, Point{Time: now}, true},
+		{"origin", Point{Time: now, Origin: "b"}, Point{Time: now, Origin: "a"}, true},
+		{"equal", Point{Time: now, Origin: "a"}, Point{Time: now, Origin: "a"}, false},
+	}
+
+	for _, test := range tests {
+		if test.p.After(test.o) != test.exp {
+			t.Errorf("%v: expected %v", test.desc, test.exp)
+		}
+	}
+}
+
+func TestPointStamp(t *testing.T) {
+	now := time.Now()
+
+	// point from another instance with a clock that is ahead
+	prev := Point{Type: PointTypeValue, Time: now.Add(time.Hour), Logical: 2, Origin: "cloud"}
+
+	p := Point{Type: PointTypeValue, Time: now, Value: 1}
+	p.Stamp("edge", &prev)
+
+	if !p.After(prev) {
+		t.Error("local point should supersede point from other instance")
+	}
+
+	if !p.Time.Equal(prev.Time) || p.Logical != 3 || p.Origin != "edge" {
+		t.Error("point not stamped correctly: ", p)
+	}
+
+	// the clock of this instance went backwards, so a local write has an
+	// earlier time than the value it wrote before
+	prev = Point{Type: PointTypeValue, Time: now, Logical: 1, Origin: "edge"}
+	p = Point{Type: PointTypeValue, Time: now.Add(-time.Minute), Value: 2}
+	p.Stamp("edge", &prev)
+
+	if !p.Time.Equal(now) || p.Logical != 2 {
+		t.Error("point should have been advanced: ", p)
+	}
+
+	ps := Points{prev}
+	ps.ProcessPoint(p)
+
+	if ps[0].Value != 2 {
+		t.Error("local write was dropped")
+	}
+
+	// equal times increment the logical counter
+	p = Point{Type: PointTypeValue, Time: now, Value: 3}
+	p.Stamp("edge", &ps[0])
+
+	if !p.After(ps[0]) || p.Logical != 3 {
+		t.Error("point with equal time not advanced: ", p)
+	}
+
+	// newer points are not changed
+	p = Point{Type: PointTypeValue, Time: now.Add(time.Minute)}
+	p.Stamp("edge", &ps[0])
+
+	if !p.Time.Equal(now.Add(time.Minute)) || p.Logical != 0 {
+		t.Error("point should not have been advanced: ", p)
+	}
+}
+
+func TestPointPbClock(t *testing.T) {
+	p := Point{Type: PointTypeValue, Time: time.Now(), Logical: 3, Origin: "abc"}
+
+	pPb, err := p.ToPb()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p2, err := PbToPoint(&pPb)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p2.Logical != p.Logical || p2.Origin != p.Origin {
+		t.Error("clock not preserved in protobuf: ", p2)
+	}
+}
@@ -0,0 +1,136 @@
+package data
+
+import (
+	"sort"
+	"time"
+)
+
+// RetentionTier describes one level of downsampled history. Points are
+// averaged over Period windows and the resulting rollups are kept for
+// Retention (0 keeps them forever).
+type RetentionTier struct {
+	Period    time.Duration
+	Retention time.Duration
+}
+
+// Retention describes how point history is kept for the parent node
+// of a retention node and all of its descendants. Raw points are kept for
+// Raw (0 keeps them forever). If PointType is set, only points of that
+// type are affected. Events are kept for Events (0 keeps them forever),
+// and only policies without a PointType expire events.
+type Retention struct {
+	ID          string
+	Description string
+	PointType   string
+	Raw         time.Duration
+	Events      time.Duration
+	Tiers       []RetentionTier
+}
+
+// NodeToRetention converts a node to a retention policy. Raw, rollup, and
+// event retention points are specified in days, and rollup periods in minutes.
+// Rollup tiers are specified with indexed points, and tiers without a
+// period are ignored.
+func NodeToRetention(node NodeEdge) (*Retention, error) {
+	ret := &Retention{ID: node.ID}
+
+	tiers := make(map[int]*RetentionTier)
+
+	tier := func(index int) *RetentionTier {
+		t, ok := tiers[index]
+		if !ok {
+			t = &RetentionTier{}
+			tiers[index] = t
+		}
+		return t
+	}
+
+	for _, p := range node.Points {
+		switch p.Type {
+		case PointTypeDescription:
+			ret.Description = p.Text
+		case PointTypePointType:
+			ret.PointType = p.Text
+		case PointTypeRawRetention:
+			ret.Raw = daysToDuration(p.Value)
+		case PointTypeEventRetention:
+			ret.Events = daysToDuration(p.Value)
+		case PointTypeRollupPeriod:
+			tier(p.Index).Period = time.Duration(p.Value * float64(time.Minute))
+		case PointTypeRollupRetention:
+			tier(p.Index).Retention = daysToDuration(p.Value)
+		}
+	}
+
+	indexes := make([]int, 0, len(tiers))
+	for i := range tiers {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+
+	for _, i := range indexes {
+		if tiers[i].Period > 0 {
+			ret.Tiers = append(ret.Tiers, *tiers[i])
+		}
+	}
+
+	return ret, nil
+}
+
+func daysToDuration(days float64) time.Duration {
+	return time.Duration(days * float64(24*time.Hour))
+}
+
+// Rollup downsamples points into windows of the given period. Points are
+// grouped by ID, Type, and Index, and each window is averaged into one
+// point with the window start time, Duration set to the period, and Min/Max
+// set to the extremes seen in the window. Points that do not already carry
+// Min/Max contribute their value. Text points are skipped. The returned
+// points are sorted by time.
+func Rollup(points Points, period time.Duration) Points {
+	type key struct {
+		id    string
+		typ   string
+		index int
+		start int64
+	}
+
+	windows := make(map[key]*PointAverager)
+	var keys []key
+
+	for _, p := range points {
+		if p.Text != "" {
+			continue
+		}
+
+		k := key{p.ID, p.Type, p.Index, p.Time.Truncate(period).UnixNano()}
+
+		pa, ok := windows[k]
+		if !ok {
+			pa = NewPointAverager(p.Type)
+			windows[k] = pa
+			keys = append(keys, k)
+		}
+
+		if p.Min == 0 && p.Max == 0 {
+			p.Min, p.Max = p.Value, p.Value
+		}
+
+		pa.AddPoint(p)
+	}
+
+	ret := make(Points, 0, len(keys))
+
+	for _, k := range keys {
+		p := windows[k].GetAverage()
+		p.ID = k.id
+		p.Index = k.index
+		p.Time = time.Unix(0, k.start)
+		p.Duration = period
+		ret = append(ret, p)
+	}
+
+	sort.Stable(ret)
+
+	return ret
+}
@@ -0,0 +1,87 @@
+package data
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNodeToRetention(t *testing.T) {
+	node := NodeEdge{
+		ID:   "1",
+		Type: NodeTypeRetention,
+		Points: Points{
+			{Type: PointTypeRawRetention, Value: 7},
+			{Type: PointTypeEventRetention, Value: 90},
+			{Type: PointTypeRollupPeriod, Index: 1, Value: 60},
+			{Type: PointTypeRollupRetention, Index: 1, Value: 365},
+			{Type: PointTypeRollupPeriod, Index: 0, Value: 5},
+			{Type: PointTypeRollupRetention, Index: 0, Value: 30},
+			{Type: PointTypeRollupRetention, Index: 2, Value: 10},
+		},
+	}
+
+	r, err := NodeToRetention(node)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.Raw != 7*24*time.Hour {
+		t.Error("wrong raw retention: ", r.Raw)
+	}
+
+	if r.Events != 90*24*time.Hour {
+		t.Error("wrong event retention: ", r.Events)
+	}
+
+	exp := []RetentionTier{
+		{Period: 5 * time.Minute, Retention: 30 * 24 * time.Hour},
+		{Period: time.Hour, Retention: 365 * 24 * time.Hour},
+	}
+
+	if len(r.Tiers) != len(exp) {
+		t.Fatalf("expected %v tiers, got %v", len(exp), len(r.Tiers))
+	}
+
+	for i := range exp {
+		if r.Tiers[i] != exp[i] {
+			t.Errorf("tier %v: expected %+v, got %+v", i, exp[i], r.Tiers[i])
+		}
+	}
+}
+
+func TestRollup(t *testing.T) {
+	start := time.Date(2021, time.September, 1, 0, 0, 0, 0, time.UTC)
+
+	points := Points{
+		{Type: PointTypeValue, Time: start, Value: -2},
+		{Type: PointTypeValue, Time: start.Add(time.Minute), Value: 4},
+		{Type: PointTypeValue, Index: 1, Time: start.Add(time.Minute), Value: 10},
+		{Type: PointTypeDescription, Time: start, Text: "ignored"},
+		{Type: PointTypeValue, Time: start.Add(6 * time.Minute), Value: 8, Min: 5, Max: 12},
+	}
+
+	r := Rollup(points, 5*time.Minute)
+
+	exp := Points{
+		{Type: PointTypeValue, Time: start, Value: 1, Min: -2, Max: 4},
+		{Type: PointTypeValue, Index: 1, Time: start, Value: 10, Min: 10, Max: 10},
+		{Type: PointTypeValue, Time: start.Add(5 * time.Minute), Value: 8, Min: 5, Max: 12},
+	}
+
+	if len(r) != len(exp) {
+		t.Fatalf("expected %v points, got %v", len(exp), len(r))
+	}
+
+	for i := range exp {
+		e := exp[i]
+		p := r[i]
+		if p.Type != e.Type || p.Index != e.Index || !p.Time.Equal(e.Time) ||
+			p.Value != e.Value || p.Min != e.Min || p.Max != e.Max {
+			t.Errorf("point %v: expected %v, got %v", i, e, p)
+		}
+
+		if p.Duration != 5*time.Minute {
+			t.Errorf("point %v: wrong duration: %v", i, p.Duration)
+		}
+	}
+}
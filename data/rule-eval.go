@@ -0,0 +1,300 @@
+package data
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/simpleiot/simpleiot/internal/pb"
+	"google.golang.org/protobuf/proto"
+)
+
+// RuleEvalRequest is used to evaluate a rule without side effects (a dry
+// run). Points are hypothetical points for NodeID, which defaults to the
+// parent of the rule. If the rule has more than one parent, Parent selects
+// which one is used, otherwise the first parent is used.
+type RuleEvalRequest struct {
+	Parent string `json:"parent"`
+	NodeID string `json:"nodeId"`
+	Points Points `json:"points"`
+}
+
+// ConditionEval is the result of evaluating a condition. Met is the result
+// of the last evaluation before the condition is debounced, and Point is the
+// value the condition was evaluated against: the point or input for point
+// value conditions, the statistic (Value) for window conditions, the last
+// update (Time) for no update conditions, and the time for schedule
+// conditions. Evaluated is false if the condition was not evaluated, in which
+// case only Active and Pending are valid. GroupID is the condition group the
+// condition is in, or blank.
+type ConditionEval struct {
+	ID            string `json:"id"`
+	GroupID       string `json:"groupId"`
+	Description   string `json:"description"`
+	ConditionType string `json:"conditionType"`
+	Evaluated     bool   `json:"evaluated"`
+	Met           bool   `json:"met"`
+	Active        bool   `json:"active"`
+	Pending       bool   `json:"pending"`
+	Point         Point  `json:"point"`
+	InputStatus   string `json:"inputStatus"`
+	Error         string `json:"error"`
+}
+
+// GroupEval is the state of a condition group after a rule is evaluated.
+// ParentID is the group the group is nested in, or blank.
+type GroupEval struct {
+	ID          string `json:"id"`
+	ParentID    string `json:"parentId"`
+	Description string `json:"description"`
+	Logic       string `json:"logic"`
+	Active      bool   `json:"active"`
+}
+
+// ActionEval is an action that ran (or would run in a dry run) when a rule
+// was evaluated. Inactive is set for actions that run when the rule goes
+// inactive.
+type ActionEval struct {
+	ID          string `json:"id"`
+	Description string `json:"description"`
+	Action      string `json:"action"`
+	Inactive    bool   `json:"inactive"`
+	TriggerNode string `json:"triggerNode"`
+}
+
+// RuleEval is the result of evaluating a rule, either in a dry run, or
+// when a rule with trace enabled processes points. NodeID and Points are
+// the node and points that were processed, and Changed is set if the rule
+// active state changed.
+type RuleEval struct {
+	RuleID      string          `json:"ruleId"`
+	Parent      string          `json:"parent"`
+	Description string          `json:"description"`
+	Time        time.Time       `json:"time"`
+	DryRun      bool            `json:"dryRun"`
+	NodeID      string          `json:"nodeId"`
+	Points      Points          `json:"points"`
+	Active      bool            `json:"active"`
+	Changed     bool            `json:"changed"`
+	AlarmState  string          `json:"alarmState"`
+	Conditions  []ConditionEval `json:"conditions"`
+	Groups      []GroupEval     `json:"groups"`
+	Actions     []ActionEval    `json:"actions"`
+}
+
+func pointsToPb(points Points) ([]*pb.Point, error) {
+	ret := make([]*pb.Point, len(points))
+
+	for i, p := range points {
+		pbPoint, err := p.ToPb()
+		if err != nil {
+			return nil, err
+		}
+		ret[i] = &pbPoint
+	}
+
+	return ret, nil
+}
+
+func pbToPoints(pbPoints []*pb.Point) (Points, error) {
+	ret := make(Points, len(pbPoints))
+
+	for i, pbPoint := range pbPoints {
+		var err error
+		ret[i], err = PbToPoint(pbPoint)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return ret, nil
+}
+
+// ToPb encodes a rule eval request to protobuf
+func (r RuleEvalRequest) ToPb() ([]byte, error) {
+	points, err := pointsToPb(r.Points)
+	if err != nil {
+		return nil, err
+	}
+
+	return proto.Marshal(&pb.RuleEvalRequest{
+		Parent: r.Parent,
+		NodeId: r.NodeID,
+		Points: points,
+	})
+}
+
+// PbDecodeRuleEvalRequest decodes a protobuf rule eval request
+func PbDecodeRuleEvalRequest(data []byte) (RuleEvalRequest, error) {
+	pbReq := &pb.RuleEvalRequest{}
+
+	err := proto.Unmarshal(data, pbReq)
+	if err != nil {
+		return RuleEvalRequest{}, err
+	}
+
+	points, err := pbToPoints(pbReq.Points)
+	if err != nil {
+		return RuleEvalRequest{}, err
+	}
+
+	return RuleEvalRequest{
+		Parent: pbReq.Parent,
+		NodeID: pbReq.NodeId,
+		Points: points,
+	}, nil
+}
+
+// RuleEvalToPb encodes a rule evaluation and an error to protobuf
+func RuleEvalToPb(e RuleEval, err error) ([]byte, error) {
+	ret := pb.RuleEval{
+		RuleId:      e.RuleID,
+		Parent:      e.Parent,
+		Description: e.Description,
+		DryRun:      e.DryRun,
+		NodeId:      e.NodeID,
+		Active:      e.Active,
+		Changed:     e.Changed,
+		AlarmState:  e.AlarmState,
+	}
+
+	if err != nil {
+		ret.Error = err.Error()
+	}
+
+	ret.Time, err = ptypes.TimestampProto(e.Time)
+	if err != nil {
+		return nil, err
+	}
+
+	ret.Points, err = pointsToPb(e.Points)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, c := range e.Conditions {
+		p, err := c.Point.ToPb()
+		if err != nil {
+			return nil, err
+		}
+
+		ret.Conditions = append(ret.Conditions, &pb.ConditionEval{
+			Id:            c.ID,
+			GroupId:       c.GroupID,
+			Description:   c.Description,
+			ConditionType: c.ConditionType,
+			Evaluated:     c.Evaluated,
+			Met:           c.Met,
+			Active:        c.Active,
+			Pending:       c.Pending,
+			Point:         &p,
+			InputStatus:   c.InputStatus,
+			Error:         c.Error,
+		})
+	}
+
+	for _, g := range e.Groups {
+		ret.Groups = append(ret.Groups, &pb.GroupEval{
+			Id:          g.ID,
+			ParentId:    g.ParentID,
+			Description: g.Description,
+			Logic:       g.Logic,
+			Active:      g.Active,
+		})
+	}
+
+	for _, a := range e.Actions {
+		ret.Actions = append(ret.Actions, &pb.ActionEval{
+			Id:          a.ID,
+			Description: a.Description,
+			Action:      a.Action,
+			Inactive:    a.Inactive,
+			TriggerNode: a.TriggerNode,
+		})
+	}
+
+	return proto.Marshal(&ret)
+}
+
+// PbDecodeRuleEval decodes a protobuf rule evaluation
+func PbDecodeRuleEval(data []byte) (RuleEval, error) {
+	pbEval := &pb.RuleEval{}
+
+	err := proto.Unmarshal(data, pbEval)
+	if err != nil {
+		return RuleEval{}, err
+	}
+
+	if pbEval.Error != "" {
+		return RuleEval{}, errors.New(pbEval.Error)
+	}
+
+	ret := RuleEval{
+		RuleID:      pbEval.RuleId,
+		Parent:      pbEval.Parent,
+		Description: pbEval.Description,
+		DryRun:      pbEval.DryRun,
+		NodeID:      pbEval.NodeId,
+		Active:      pbEval.Active,
+		Changed:     pbEval.Changed,
+		AlarmState:  pbEval.AlarmState,
+	}
+
+	if pbEval.Time != nil {
+		ret.Time, err = ptypes.Timestamp(pbEval.Time)
+		if err != nil {
+			return RuleEval{}, err
+		}
+	}
+
+	ret.Points, err = pbToPoints(pbEval.Points)
+	if err != nil {
+		return RuleEval{}, err
+	}
+
+	for _, c := range pbEval.Conditions {
+		var p Point
+		if c.Point != nil {
+			p, err = PbToPoint(c.Point)
+			if err != nil {
+				return RuleEval{}, err
+			}
+		}
+
+		ret.Conditions = append(ret.Conditions, ConditionEval{
+			ID:            c.Id,
+			GroupID:       c.GroupId,
+			Description:   c.Description,
+			ConditionType: c.ConditionType,
+			Evaluated:     c.Evaluated,
+			Met:           c.Met,
+			Active:        c.Active,
+			Pending:       c.Pending,
+			Point:         p,
+			InputStatus:   c.InputStatus,
+			Error:         c.Error,
+		})
+	}
+
+	for _, g := range pbEval.Groups {
+		ret.Groups = append(ret.Groups, GroupEval{
+			ID:          g.Id,
+			ParentID:    g.ParentId,
+			Description: g.Description,
+			Logic:       g.Logic,
+			Active:      g.Active,
+		})
+	}
+
+	for _, a := range pbEval.Actions {
+		ret.Actions = append(ret.Actions, ActionEval{
+			ID:          a.Id,
+			Description: a.Description,
+			Action:      a.Action,
+			Inactive:    a.Inactive,
+			TriggerNode: a.TriggerNode,
+		})
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,82 @@
+package data
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRuleEvalPb(t *testing.T) {
+	now := time.Date(2021, time.September, 1, 0, 0, 0, 0, time.UTC)
+
+	e := RuleEval{
+		RuleID:      "r1",
+		Parent:      "dev1",
+		Description: "low level",
+		Time:        now,
+		DryRun:      true,
+		NodeID:      "dev1",
+		Points:      Points{{Type: PointTypeValue, Value: 12, Time: now}},
+		Active:      true,
+		Changed:     true,
+		AlarmState:  PointValueAlarmActiveUnacked,
+		Conditions: []ConditionEval{
+			{ID: "c1", GroupID: "g1", ConditionType: PointValuePointValue,
+				Evaluated: true, Met: true, Active: true,
+				Point:       Point{Type: PointTypeValue, Value: 12, Time: now},
+				InputStatus: PointValueInputOK},
+			{ID: "c2", ConditionType: PointValueSchedule, Pending: true,
+				Point: Point{Time: now}, Error: "invalid"},
+		},
+		Groups:  []GroupEval{{ID: "g1", Logic: PointValueOr, Active: true}},
+		Actions: []ActionEval{{ID: "a1", Action: PointValueActionNotify, TriggerNode: "dev1"}},
+	}
+
+	buf, err := RuleEvalToPb(e, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e2, err := PbDecodeRuleEval(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(e, e2) {
+		t.Errorf("rule eval not preserved, exp %+v, got %+v", e, e2)
+	}
+
+	buf, err = RuleEvalToPb(RuleEval{}, errors.New("node is not a rule"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = PbDecodeRuleEval(buf)
+	if err == nil || err.Error() != "node is not a rule" {
+		t.Error("expected error, got: ", err)
+	}
+}
+
+func TestRuleEvalRequestPb(t *testing.T) {
+	r := RuleEvalRequest{
+		Parent: "dev1",
+		NodeID: "pump",
+		Points: Points{{Type: "on", Value: 1}},
+	}
+
+	buf, err := r.ToPb()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r2, err := PbDecodeRuleEvalRequest(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r2.Parent != r.Parent || r2.NodeID != r.NodeID || len(r2.Points) != 1 ||
+		r2.Points[0].Type != "on" || r2.Points[0].Value != 1 {
+		t.Errorf("rule eval request not preserved, exp %+v, got %+v", r, r2)
+	}
+}
@@ -2,17 +2,26 @@ package data
 
 import (
 	"fmt"
+	"math"
+	"regexp"
+	"strings"
 	"time"
 )
 
 // Condition defines parameters to look for in a point or a schedule.
+// MinTimeActive and MinTimeInactive are in minutes, and specify how long
+// the condition must be met (or not met) before Active changes. Pending is
+// set while waiting for this time to elapse.
 type Condition struct {
 	// general parameters
-	ID            string
-	Description   string
-	ConditionType string
-	MinTimeActive float64
-	Active        bool
+	ID              string
+	Description     string
+	ConditionType   string
+	MinTimeActive   float64
+	MinTimeInactive float64
+	Active          bool
+	Pending         bool
+	PendingStart    time.Time
 
 	// used with point value rules
 	NodeID         string
@@ -23,11 +32,50 @@ type Condition struct {
 	Operator       string
 	PointValue     float64
 	PointTextValue string
+	// text operators ignore case if set
+	CaseInsensitive bool
+	// regular expression of the regex operator, compiled when the
+	// condition is built from a node
+	regex *regexp.Regexp
+	// numeric > and < conditions do not go inactive until the value is
+	// this far past PointValue
+	Hysteresis float64
+
+	// point value conditions that specify a node ID are evaluated against
+	// Input, the current point stored in the node, which is filled in by
+	// the rule engine. InputStatus reports if Input is missing, or stale
+	// (older than StaleTime minutes).
+	StaleTime   float64
+	InputStatus string
+	Input       *Point
 
-	// used with shedule rules
-	StartTime string
-	EndTime   string
-	Weekdays  []time.Weekday
+	// used with window rules. The number operators are applied to the
+	// WindowStat statistic of the points received over the last Window
+	// minutes. WindowValue is filled in by the rule engine, and is only
+	// valid if WindowValid is set.
+	Window      float64
+	WindowStat  string
+	WindowValue float64
+	WindowValid bool
+
+	// used with no update rules. NodeID, PointType, and PointIndex select
+	// the points that are watched. LastUpdate is the time of the latest of
+	// these points and is filled in by the rule scheduler.
+	NoUpdateTime float64
+	Descendants  bool
+	LastUpdate   time.Time
+
+	// used with shedule rules. CalendarDates are the dates in the Calendar
+	// node, and are filled in by the rule scheduler.
+	StartTime     string
+	EndTime       string
+	Weekdays      []time.Weekday
+	Timezone      string
+	Dates         []string
+	ExcludeDates  []string
+	Calendar      string
+	CalendarMode  string
+	CalendarDates []string
 }
 
 func (c Condition) String() string {
@@ -35,6 +83,262 @@ func (c Condition) String() string {
 	return ret
 }
 
+// NumberActive checks if a numeric point value satisfies the condition
+// operator. Once active, > and < conditions stay active until the value
+// crosses PointValue by more than Hysteresis.
+func (c Condition) NumberActive(value float64) bool {
+	switch c.Operator {
+	case PointValueGreaterThan:
+		if c.Active {
+			return value > c.PointValue-c.Hysteresis
+		}
+		return value > c.PointValue
+	case PointValueLessThan:
+		if c.Active {
+			return value < c.PointValue+c.Hysteresis
+		}
+		return value < c.PointValue
+	case PointValueEqual:
+		return value == c.PointValue
+	case PointValueNotEqual:
+		return value != c.PointValue
+	}
+
+	return false
+}
+
+// Debounce returns the condition state after the condition is evaluated
+// as met (or not) at time now. Active only changes once the condition has
+// been met (or not met) continuously for MinTimeActive (or MinTimeInactive).
+func (c Condition) Debounce(met bool, now time.Time) Condition {
+	if met == c.Active {
+		c.Pending = false
+		c.PendingStart = time.Time{}
+		return c
+	}
+
+	delay := c.MinTimeActive
+	if !met {
+		delay = c.MinTimeInactive
+	}
+
+	if !c.Pending {
+		c.Pending = true
+		c.PendingStart = now
+	}
+
+	if now.Sub(c.PendingStart) >= time.Duration(delay*float64(time.Minute)) {
+		c.Active = met
+		c.Pending = false
+		c.PendingStart = time.Time{}
+	}
+
+	return c
+}
+
+// PointActive checks if a point satisfies a point value condition
+func (c Condition) PointActive(p Point) (bool, error) {
+	switch c.PointValueType {
+	case PointValueNumber:
+		return c.NumberActive(p.Value), nil
+	case PointValueText:
+		return c.TextActive(p.Text)
+	case PointValueOnOff:
+		condValue := c.PointValue != 0
+		pointValue := p.Value != 0
+		return condValue == pointValue, nil
+	}
+
+	return false, fmt.Errorf("invalid point value type: %v", c.PointValueType)
+}
+
+// InputState returns the input status of p, the point stored in the node
+// the condition references, at time now. p is nil if the point does not
+// exist.
+func (c Condition) InputState(p *Point, now time.Time) string {
+	switch {
+	case p == nil:
+		return PointValueInputMissing
+	case c.StaleTime > 0 &&
+		now.Sub(p.Time) > time.Duration(c.StaleTime*float64(time.Minute)):
+		return PointValueInputStale
+	}
+
+	return PointValueInputOK
+}
+
+// PointMatch returns true if a point in a node matches the node and point
+// qualifiers of the condition. Blank qualifiers (or a PointIndex of -1)
+// match any value.
+func (c Condition) PointMatch(nodeID string, p Point) bool {
+	if c.NodeID != "" && c.NodeID != nodeID {
+		return false
+	}
+
+	if c.PointID != "" && c.PointID != p.ID {
+		return false
+	}
+
+	if c.PointType != "" && c.PointType != p.Type {
+		return false
+	}
+
+	if c.PointIndex != -1 && c.PointIndex != int(p.Index) {
+		return false
+	}
+
+	return true
+}
+
+// Watches returns true if a point in a node may change the state of the
+// condition. Schedule conditions only change over time, and inactive no
+// update conditions only change when they time out. Active no update
+// conditions watch points of their type in any node, as they may watch
+// descendant nodes.
+func (c Condition) Watches(nodeID string, p Point) bool {
+	switch c.ConditionType {
+	case PointValuePointValue, PointValueWindow:
+		return c.PointMatch(nodeID, p)
+	case PointValueNoUpdate:
+		if !c.Active {
+			return false
+		}
+
+		if c.PointType == "" {
+			return p.Type != PointTypeSysState
+		}
+
+		return p.Type == c.PointType
+	}
+
+	return false
+}
+
+// PendingEnd returns when a pending condition changes state if it is still
+// met (or not met) at that time, or a zero time if the condition is not
+// pending
+func (c Condition) PendingEnd() time.Time {
+	if !c.Pending {
+		return time.Time{}
+	}
+
+	delay := c.MinTimeActive
+	if c.Active {
+		delay = c.MinTimeInactive
+	}
+
+	return c.PendingStart.Add(time.Duration(delay * float64(time.Minute)))
+}
+
+// WindowStats computes the WindowStat statistic of points, which must be
+// sorted by time. Returns false if there are not enough points to compute
+// the statistic: delta, deltaPercent, and rate need at least two points, and
+// deltaPercent is not valid if the first value is zero.
+func (c Condition) WindowStats(points Points) (float64, bool) {
+	if len(points) <= 0 {
+		return 0, false
+	}
+
+	pa := NewPointAverager("")
+
+	for _, p := range points {
+		if p.Min == 0 && p.Max == 0 {
+			p.Min, p.Max = p.Value, p.Value
+		}
+		pa.AddPoint(p)
+	}
+
+	avg := pa.GetAverage()
+
+	first := points[0]
+	last := points[len(points)-1]
+	delta := last.Value - first.Value
+
+	switch c.WindowStat {
+	case PointValueAverage:
+		return avg.Value, true
+	case PointValueMin:
+		return avg.Min, true
+	case PointValueMax:
+		return avg.Max, true
+	case PointValueDelta:
+		return delta, len(points) > 1
+	case PointValueDeltaPercent:
+		if len(points) < 2 || first.Value == 0 {
+			return 0, false
+		}
+		return delta / math.Abs(first.Value) * 100, true
+	case PointValueRate:
+		minutes := last.Time.Sub(first.Time).Minutes()
+		if minutes <= 0 {
+			return 0, false
+		}
+		return delta / minutes, true
+	}
+
+	return 0, false
+}
+
+// NoUpdateActive checks if the watched points have not been updated for
+// NoUpdateTime minutes at time now
+func (c Condition) NoUpdateActive(now time.Time) bool {
+	return now.Sub(c.LastUpdate) >= time.Duration(c.NoUpdateTime*float64(time.Minute))
+}
+
+// TextActive checks if a text point value satisfies the condition operator.
+// For the regex operator, the condition value is a regular expression that
+// must match some part of the text.
+func (c Condition) TextActive(text string) (bool, error) {
+	value := c.PointTextValue
+
+	if c.CaseInsensitive && c.Operator != PointValueRegex {
+		text = strings.ToLower(text)
+		value = strings.ToLower(value)
+	}
+
+	switch c.Operator {
+	case PointValueEqual:
+		return text == value, nil
+	case PointValueNotEqual:
+		return text != value, nil
+	case PointValueContains:
+		return strings.Contains(text, value), nil
+	case PointValueStartsWith:
+		return strings.HasPrefix(text, value), nil
+	case PointValueEndsWith:
+		return strings.HasSuffix(text, value), nil
+	case PointValueRegex:
+		re := c.regex
+		if re == nil {
+			var err error
+			re, err = c.Regex()
+			if err != nil {
+				return false, err
+			}
+		}
+
+		return re.MatchString(text), nil
+	}
+
+	return false, fmt.Errorf("invalid text operator: %v", c.Operator)
+}
+
+// Regex compiles the regular expression of a condition with the regex
+// operator
+func (c Condition) Regex() (*regexp.Regexp, error) {
+	value := c.PointTextValue
+	if c.CaseInsensitive {
+		value = "(?i)" + value
+	}
+
+	re, err := regexp.Compile(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid condition regex: %w", err)
+	}
+
+	return re, nil
+}
+
 // Action defines actions that can be taken if a rule is active.
 // Template can optionally be used to customize the message that is sent and
 // uses Io Type or IDs to fill in the values. Example might be:
@@ -51,6 +355,70 @@ type Action struct {
 	PointChannel   int
 	PointDevice    string
 	PointFilePath  string
+	// notification templates. Template is also used for the webhook
+	// body, and HTMLTemplate is the optional HTML version of the email.
+	SubjectTemplate string
+	Template        string
+	HTMLTemplate    string
+	// webhook parameters, Timeout is in seconds
+	URI     string
+	Method  string
+	Headers map[string]string
+	Timeout float64
+	Retries int
+	// Delay and Repeat are in minutes. RunCount is the number of times
+	// the action has run since the rule went active, as of LastRun, and
+	// LastTriggerNode is the node that triggered the rule at that time.
+	Delay           float64
+	Repeat          float64
+	RepeatMax       int
+	RunCount        int
+	LastRun         time.Time
+	LastTriggerNode string
+}
+
+// Due returns true if an action should run at time now for a rule that went
+// active at activeTime. An action first runs Delay minutes after the rule
+// goes active, and then every Repeat minutes until it has been repeated
+// RepeatMax times. Runs from before the rule went active are ignored.
+func (a Action) Due(activeTime, now time.Time) bool {
+	runCount := a.RunCountSince(activeTime)
+
+	if runCount == 0 {
+		return now.Sub(activeTime) >= time.Duration(a.Delay*float64(time.Minute))
+	}
+
+	if a.Repeat <= 0 || (a.RepeatMax > 0 && runCount > a.RepeatMax) {
+		return false
+	}
+
+	return now.Sub(a.LastRun) >= time.Duration(a.Repeat*float64(time.Minute))
+}
+
+// NextRun returns when an action is next due (see Due) for a rule that went
+// active at activeTime, or a zero time if the action will not run again
+func (a Action) NextRun(activeTime time.Time) time.Time {
+	runCount := a.RunCountSince(activeTime)
+
+	if runCount == 0 {
+		return activeTime.Add(time.Duration(a.Delay * float64(time.Minute)))
+	}
+
+	if a.Repeat <= 0 || (a.RepeatMax > 0 && runCount > a.RepeatMax) {
+		return time.Time{}
+	}
+
+	return a.LastRun.Add(time.Duration(a.Repeat * float64(time.Minute)))
+}
+
+// RunCountSince returns the number of times the action has run since the rule
+// went active at activeTime
+func (a Action) RunCountSince(activeTime time.Time) int {
+	if a.LastRun.Before(activeTime) {
+		return 0
+	}
+
+	return a.RunCount
 }
 
 func (a Action) String() string {
@@ -74,13 +442,227 @@ type RuleState struct {
 // done by two different entities.
 type Rule struct {
 	ID              string
+	Parent          string
 	Description     string
 	Active          bool
+	ActiveTime      time.Time
+	TriggerNode     string
+	AlarmState      string
+	AckUser         string
+	AckTime         time.Time
+	ShelveUntil     time.Time
+	Logic           string
+	Trace           bool
+	Severity        string
 	Conditions      []Condition
+	Groups          []ConditionGroup
 	Actions         []Action
 	ActionsInactive []Action
 }
 
+// AllConditions returns the conditions of the rule and all of its
+// condition groups
+func (r *Rule) AllConditions() []Condition {
+	var ret []Condition
+
+	var add func(conditions []Condition, groups []ConditionGroup)
+
+	add = func(conditions []Condition, groups []ConditionGroup) {
+		ret = append(ret, conditions...)
+		for _, g := range groups {
+			add(g.Conditions, g.Groups)
+		}
+	}
+
+	add(r.Conditions, r.Groups)
+
+	return ret
+}
+
+// Watches returns true if any of the points in a node may change the state
+// of the rule (see Condition.Watches)
+func (r *Rule) Watches(nodeID string, points Points) bool {
+	for _, c := range r.AllConditions() {
+		for _, p := range points {
+			if c.Watches(nodeID, p) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Copy returns a deep copy of the rule, so that a copy can be modified while
+// the rule is processed without changing the original
+func (r *Rule) Copy() *Rule {
+	ret := *r
+	ret.Conditions = append([]Condition(nil), r.Conditions...)
+	ret.Groups = copyConditionGroups(r.Groups)
+	ret.Actions = append([]Action(nil), r.Actions...)
+	ret.ActionsInactive = append([]Action(nil), r.ActionsInactive...)
+	return &ret
+}
+
+// RuleStatePoint returns true for point types that the rule engine writes to
+// rule, condition, condition group, and action nodes to record the state of
+// a rule. All other points of these nodes configure the rule.
+func RuleStatePoint(typ string) bool {
+	switch typ {
+	case PointTypeActive, PointTypePending, PointTypeInputStatus,
+		PointTypeRunCount, PointTypeAlarmState, PointTypeAckUser,
+		PointTypeShelveUntil, PointTypeStatusCode, PointTypeAttempts,
+		PointTypeError:
+		return true
+	}
+
+	return false
+}
+
+// ProcessStatePoints updates the state of the rule, or of the condition,
+// condition group, or action with the ID nodeID, from state points written
+// to that node (see RuleStatePoint). Other points are ignored, as the rule
+// must be built from its nodes again when its configuration changes.
+func (r *Rule) ProcessStatePoints(nodeID string, points Points) {
+	var process func(conditions []Condition, groups []ConditionGroup, p Point)
+
+	process = func(conditions []Condition, groups []ConditionGroup, p Point) {
+		for i := range conditions {
+			if conditions[i].ID == nodeID {
+				conditions[i].processPoint(p)
+			}
+		}
+
+		for i := range groups {
+			if groups[i].ID == nodeID {
+				groups[i].processPoint(p)
+			}
+
+			process(groups[i].Conditions, groups[i].Groups, p)
+		}
+	}
+
+	for _, p := range points {
+		if !RuleStatePoint(p.Type) {
+			continue
+		}
+
+		if r.ID == nodeID {
+			r.processPoint(p)
+		}
+
+		process(r.Conditions, r.Groups, p)
+
+		for i := range r.Actions {
+			if r.Actions[i].ID == nodeID {
+				r.Actions[i].processPoint(p)
+			}
+		}
+
+		for i := range r.ActionsInactive {
+			if r.ActionsInactive[i].ID == nodeID {
+				r.ActionsInactive[i].processPoint(p)
+			}
+		}
+	}
+}
+
+func copyConditionGroups(groups []ConditionGroup) []ConditionGroup {
+	if groups == nil {
+		return nil
+	}
+
+	ret := make([]ConditionGroup, len(groups))
+	for i, g := range groups {
+		ret[i] = g
+		ret[i].Conditions = append([]Condition(nil), g.Conditions...)
+		ret[i].Groups = copyConditionGroups(g.Groups)
+	}
+
+	return ret
+}
+
+// TriggerPoint returns the point from a node that is matched by a point
+// value or window condition of the rule, or the first point if no point
+// matches. This is used to report which point caused a rule to fire.
+func (r *Rule) TriggerPoint(nodeID string, points Points) Point {
+	var match func(conditions []Condition, groups []ConditionGroup, p Point) bool
+
+	match = func(conditions []Condition, groups []ConditionGroup, p Point) bool {
+		for _, c := range conditions {
+			switch c.ConditionType {
+			case PointValuePointValue, PointValueWindow:
+				if c.PointMatch(nodeID, p) {
+					return true
+				}
+			}
+		}
+
+		for _, g := range groups {
+			if match(g.Conditions, g.Groups, p) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	for _, p := range points {
+		if match(r.Conditions, r.Groups, p) {
+			return p
+		}
+	}
+
+	if len(points) > 0 {
+		return points[0]
+	}
+
+	return Point{}
+}
+
+// ConditionGroup combines conditions and nested condition groups in a rule
+// with Logic (and, or, not).
+type ConditionGroup struct {
+	ID          string
+	Description string
+	Logic       string
+	Active      bool
+	Conditions  []Condition
+	Groups      []ConditionGroup
+}
+
+// LogicActive combines the active state of conditions and groups. For and
+// logic (the default), all must be active; for or, any must be active;
+// and for not, none may be active.
+func LogicActive(logic string, conditions []Condition, groups []ConditionGroup) bool {
+	var active []bool
+
+	for _, c := range conditions {
+		active = append(active, c.Active)
+	}
+
+	for _, g := range groups {
+		active = append(active, g.Active)
+	}
+
+	anyActive := false
+	allActive := true
+
+	for _, a := range active {
+		anyActive = anyActive || a
+		allActive = allActive && a
+	}
+
+	switch logic {
+	case PointValueOr:
+		return anyActive
+	case PointValueNot:
+		return !anyActive
+	}
+
+	return allActive
+}
+
 func (r Rule) String() string {
 	ret := fmt.Sprintf("Rule: %v\n", r.Description)
 	ret += fmt.Sprintf("  active: %v\n", r.Active)
@@ -94,85 +676,159 @@ func (r Rule) String() string {
 	return ret
 }
 
+// nodeToCondition converts a condition node to a condition
+func nodeToCondition(cond NodeEdge) Condition {
+	var newCond Condition
+	newCond.ID = cond.ID
+	newCond.PointIndex = -1
+	for _, p := range cond.Points {
+		newCond.processPoint(p)
+	}
+
+	if newCond.Operator == PointValueRegex {
+		// invalid expressions are rejected when the condition is
+		// validated, and reported by TextActive
+		newCond.regex, _ = newCond.Regex()
+	}
+
+	return newCond
+}
+
+// processPoint updates the condition from a point of the condition node
+func (c *Condition) processPoint(p Point) {
+	switch p.Type {
+	case PointTypeDescription:
+		c.Description = p.Text
+	case PointTypeConditionType:
+		c.ConditionType = p.Text
+	case PointTypeID:
+		c.NodeID = p.Text
+	case PointTypePointType:
+		c.PointType = p.Text
+	case PointTypePointID:
+		c.PointID = p.Text
+	case PointTypePointIndex:
+		c.PointIndex = int(p.Value)
+	case PointTypeValueType:
+		c.PointValueType = p.Text
+	case PointTypeOperator:
+		c.Operator = p.Text
+	case PointTypeValue:
+		c.PointValue = p.Value
+		c.PointTextValue = p.Text
+	case PointTypeCaseInsensitive:
+		c.CaseInsensitive = FloatToBool(p.Value)
+	case PointTypeMinActive:
+		c.MinTimeActive = p.Value
+	case PointTypeMinInactive:
+		c.MinTimeInactive = p.Value
+	case PointTypeHysteresis:
+		c.Hysteresis = p.Value
+	case PointTypeStaleTime:
+		c.StaleTime = p.Value
+	case PointTypeInputStatus:
+		c.InputStatus = p.Text
+	case PointTypeWindow:
+		c.Window = p.Value
+	case PointTypeWindowStat:
+		c.WindowStat = p.Text
+	case PointTypeNoUpdateTime:
+		c.NoUpdateTime = p.Value
+	case PointTypeDescendants:
+		c.Descendants = FloatToBool(p.Value)
+	case PointTypeActive:
+		c.Active = FloatToBool(p.Value)
+	case PointTypePending:
+		c.Pending = FloatToBool(p.Value)
+		if c.Pending {
+			c.PendingStart = p.Time
+		}
+	case PointTypeStart:
+		c.StartTime = p.Text
+	case PointTypeEnd:
+		c.EndTime = p.Text
+	case PointTypeWeekday:
+		if p.Value > 0 {
+			c.Weekdays = append(c.Weekdays, time.Weekday(p.Index))
+		}
+	case PointTypeTimezone:
+		c.Timezone = p.Text
+	case PointTypeDate:
+		if p.Text != "" {
+			c.Dates = append(c.Dates, p.Text)
+		}
+	case PointTypeExcludeDate:
+		if p.Text != "" {
+			c.ExcludeDates = append(c.ExcludeDates, p.Text)
+		}
+	case PointTypeCalendar:
+		c.Calendar = p.Text
+	case PointTypeCalendarMode:
+		c.CalendarMode = p.Text
+	}
+}
+
+// ValidateCondition checks the points of a condition node that can't be
+// checked by the node schema, such as the regular expression of the regex
+// operator
+func ValidateCondition(node NodeEdge) error {
+	c := nodeToCondition(node)
+
+	if c.Operator == PointValueRegex {
+		_, err := c.Regex()
+		return err
+	}
+
+	return nil
+}
+
+// NodeToConditionGroup converts a condition group node and the condition
+// nodes under it to a condition group. Nested groups must be added by the
+// caller.
+func NodeToConditionGroup(groupNode NodeEdge, conditionNodes []NodeEdge) ConditionGroup {
+	ret := ConditionGroup{ID: groupNode.ID}
+
+	for _, p := range groupNode.Points {
+		ret.processPoint(p)
+	}
+
+	for _, cond := range conditionNodes {
+		ret.Conditions = append(ret.Conditions, nodeToCondition(cond))
+	}
+
+	return ret
+}
+
+// processPoint updates the group from a point of the condition group node
+func (g *ConditionGroup) processPoint(p Point) {
+	switch p.Type {
+	case PointTypeDescription:
+		g.Description = p.Text
+	case PointTypeActive:
+		g.Active = FloatToBool(p.Value)
+	case PointTypeLogic:
+		g.Logic = p.Text
+	}
+}
+
 // NodeToRule converts nodes that make up a rule to a node
 func NodeToRule(ruleNode NodeEdge, conditionNodes, actionNodes, actionInactiveNodes []NodeEdge) (*Rule, error) {
 	ret := &Rule{}
 	ret.ID = ruleNode.ID
+	ret.Parent = ruleNode.Parent
 	for _, p := range ruleNode.Points {
-		switch p.Type {
-		case PointTypeDescription:
-			ret.Description = p.Text
-		case PointTypeActive:
-			ret.Active = FloatToBool(p.Value)
-		}
+		ret.processPoint(p)
 	}
 
 	for _, cond := range conditionNodes {
-		var newCond Condition
-		newCond.ID = cond.ID
-		newCond.PointIndex = -1
-		for _, p := range cond.Points {
-			switch p.Type {
-			case PointTypeDescription:
-				newCond.Description = p.Text
-			case PointTypeConditionType:
-				newCond.ConditionType = p.Text
-			case PointTypeID:
-				newCond.NodeID = p.Text
-			case PointTypePointType:
-				newCond.PointType = p.Text
-			case PointTypePointID:
-				newCond.PointID = p.Text
-			case PointTypePointIndex:
-				newCond.PointIndex = int(p.Value)
-			case PointTypeValueType:
-				newCond.PointValueType = p.Text
-			case PointTypeOperator:
-				newCond.Operator = p.Text
-			case PointTypeValue:
-				newCond.PointValue = p.Value
-			case PointTypeMinActive:
-				newCond.MinTimeActive = p.Value
-			case PointTypeActive:
-				newCond.Active = FloatToBool(p.Value)
-			case PointTypeStart:
-				newCond.StartTime = p.Text
-			case PointTypeEnd:
-				newCond.EndTime = p.Text
-			case PointTypeWeekday:
-				if p.Value > 0 {
-					newCond.Weekdays = append(newCond.Weekdays, time.Weekday(p.Index))
-				}
-			}
-		}
-		ret.Conditions = append(ret.Conditions, newCond)
+		ret.Conditions = append(ret.Conditions, nodeToCondition(cond))
 	}
 
 	nodeToAction := func(n NodeEdge) Action {
 		var newAct Action
 		newAct.ID = n.ID
 		for _, p := range n.Points {
-			switch p.Type {
-			case PointTypeDescription:
-				newAct.Description = p.Text
-			case PointTypeActionType:
-				newAct.Action = p.Text
-			case PointTypeID:
-				newAct.NodeID = p.Text
-			case PointTypePointType:
-				newAct.PointType = p.Text
-			case PointTypeValueType:
-				newAct.PointValueType = p.Text
-			case PointTypeValue:
-				newAct.PointValue = p.Value
-				newAct.PointTextValue = p.Text
-			case PointTypeChannel:
-				newAct.PointChannel = int(p.Value)
-			case PointTypeDevice:
-				newAct.PointDevice = p.Text
-			case PointTypeFilePath:
-				newAct.PointFilePath = p.Text
-			}
+			newAct.processPoint(p)
 		}
 
 		return newAct
@@ -188,3 +844,87 @@ func NodeToRule(ruleNode NodeEdge, conditionNodes, actionNodes, actionInactiveNo
 
 	return ret, nil
 }
+
+// processPoint updates the rule from a point of the rule node
+func (r *Rule) processPoint(p Point) {
+	switch p.Type {
+	case PointTypeDescription:
+		r.Description = p.Text
+	case PointTypeActive:
+		r.Active = FloatToBool(p.Value)
+		r.ActiveTime = p.Time
+		r.TriggerNode = p.Text
+	case PointTypeAlarmState:
+		r.AlarmState = p.Text
+	case PointTypeAckUser:
+		r.AckUser = p.Text
+		r.AckTime = p.Time
+	case PointTypeShelveUntil:
+		// a blank or invalid time leaves the alarm unshelved
+		r.ShelveUntil, _ = time.Parse(time.RFC3339, p.Text)
+	case PointTypeLogic:
+		r.Logic = p.Text
+	case PointTypeTrace:
+		r.Trace = FloatToBool(p.Value)
+	case PointTypeSeverity:
+		r.Severity = p.Text
+	}
+}
+
+// processPoint updates the action from a point of the action node
+func (a *Action) processPoint(p Point) {
+	switch p.Type {
+	case PointTypeDescription:
+		a.Description = p.Text
+	case PointTypeActionType:
+		a.Action = p.Text
+	case PointTypeID:
+		a.NodeID = p.Text
+	case PointTypePointType:
+		a.PointType = p.Text
+	case PointTypeValueType:
+		a.PointValueType = p.Text
+	case PointTypeValue:
+		a.PointValue = p.Value
+		a.PointTextValue = p.Text
+	case PointTypeChannel:
+		a.PointChannel = int(p.Value)
+	case PointTypeDevice:
+		a.PointDevice = p.Text
+	case PointTypeFilePath:
+		a.PointFilePath = p.Text
+	case PointTypeSubjectTemplate:
+		a.SubjectTemplate = p.Text
+	case PointTypeTemplate:
+		a.Template = p.Text
+	case PointTypeHTMLTemplate:
+		a.HTMLTemplate = p.Text
+	case PointTypeURI:
+		a.URI = p.Text
+	case PointTypeMethod:
+		a.Method = p.Text
+	case PointTypeHeader:
+		parts := strings.SplitN(p.Text, ":", 2)
+		if len(parts) == 2 {
+			if a.Headers == nil {
+				a.Headers = make(map[string]string)
+			}
+			a.Headers[strings.TrimSpace(parts[0])] =
+				strings.TrimSpace(parts[1])
+		}
+	case PointTypeTimeout:
+		a.Timeout = p.Value
+	case PointTypeRetries:
+		a.Retries = int(p.Value)
+	case PointTypeDelay:
+		a.Delay = p.Value
+	case PointTypeRepeat:
+		a.Repeat = p.Value
+	case PointTypeRepeatMax:
+		a.RepeatMax = int(p.Value)
+	case PointTypeRunCount:
+		a.RunCount = int(p.Value)
+		a.LastRun = p.Time
+		a.LastTriggerNode = p.Text
+	}
+}
@@ -0,0 +1,769 @@
+package data
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestConditionTextActive(t *testing.T) {
+	tests := []struct {
+		op              string
+		value           string
+		caseInsensitive bool
+		text            string
+		exp             bool
+	}{
+		{PointValueEqual, "offline", false, "offline", true},
+		{PointValueEqual, "offline", false, "Offline", false},
+		{PointValueEqual, "offline", true, "Offline", true},
+		{PointValueNotEqual, "online", false, "offline", true},
+		{PointValueNotEqual, "online", true, "ONLINE", false},
+		{PointValueContains, "T-Mobile", false, "T-Mobile USA", true},
+		{PointValueContains, "t-mobile", false, "T-Mobile USA", false},
+		{PointValueContains, "t-mobile", true, "T-Mobile USA", true},
+		{PointValueStartsWith, "err", false, "error: timeout", true},
+		{PointValueStartsWith, "ERR", true, "error: timeout", true},
+		{PointValueStartsWith, "timeout", false, "error: timeout", false},
+		{PointValueEndsWith, "timeout", false, "error: timeout", true},
+		{PointValueEndsWith, "TIMEOUT", true, "error: timeout", true},
+		{PointValueEndsWith, "error", false, "error: timeout", false},
+		{PointValueRegex, `^fault \d+$`, false, "fault 12", true},
+		{PointValueRegex, `^fault \d+$`, false, "FAULT 12", false},
+		{PointValueRegex, `^fault \d+$`, true, "FAULT 12", true},
+		{PointValueRegex, `verizon|at&t`, false, "carrier: at&t", true},
+	}
+
+	for _, test := range tests {
+		c := Condition{
+			Operator:        test.op,
+			PointTextValue:  test.value,
+			CaseInsensitive: test.caseInsensitive,
+		}
+
+		active, err := c.TextActive(test.text)
+		if err != nil {
+			t.Errorf("%v %v: error: %v", test.op, test.value, err)
+			continue
+		}
+
+		if active != test.exp {
+			t.Errorf("%v %v (case insensitive: %v) on %v: expected %v",
+				test.op, test.value, test.caseInsensitive, test.text, test.exp)
+		}
+	}
+}
+
+func TestConditionTextActiveErrors(t *testing.T) {
+	for _, c := range []Condition{
+		{Operator: PointValueRegex, PointTextValue: "fault ("},
+		{Operator: PointValueGreaterThan, PointTextValue: "a"},
+	} {
+		_, err := c.TextActive("fault")
+		if err == nil {
+			t.Errorf("%v %v: expected error", c.Operator, c.PointTextValue)
+		}
+	}
+}
+
+func TestValidateCondition(t *testing.T) {
+	cond := func(op, value string) NodeEdge {
+		return NodeEdge{
+			ID:   "c1",
+			Type: NodeTypeCondition,
+			Points: Points{
+				{Type: PointTypeOperator, Text: op},
+				{Type: PointTypeValue, Text: value},
+			},
+		}
+	}
+
+	if err := ValidateCondition(cond(PointValueRegex, `^fault \d+$`)); err != nil {
+		t.Error("valid regex: ", err)
+	}
+
+	if err := ValidateCondition(cond(PointValueRegex, "fault (")); err == nil {
+		t.Error("invalid regex should be rejected")
+	}
+
+	if err := ValidateCondition(cond(PointValueContains, "fault (")); err != nil {
+		t.Error("text is only a regex for the regex operator: ", err)
+	}
+
+	// the regex is compiled when the condition is built
+	c := nodeToCondition(cond(PointValueRegex, "fault"))
+	if c.regex == nil {
+		t.Fatal("regex not compiled")
+	}
+
+	c.PointTextValue = "fault ("
+	if active, err := c.TextActive("fault"); err != nil || !active {
+		t.Error("compiled regex not used: ", active, err)
+	}
+}
+
+func TestNodeToRuleTextCondition(t *testing.T) {
+	cond := NodeEdge{
+		ID:   "c1",
+		Type: NodeTypeCondition,
+		Points: Points{
+			{Type: PointTypeConditionType, Text: PointValuePointValue},
+			{Type: PointTypeValueType, Text: PointValueText},
+			{Type: PointTypeOperator, Text: PointValueContains},
+			{Type: PointTypeValue, Text: "fault"},
+			{Type: PointTypeCaseInsensitive, Value: 1},
+		},
+	}
+
+	r, err := NodeToRule(NodeEdge{ID: "r1"}, []NodeEdge{cond}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := r.Conditions[0]
+
+	if c.PointTextValue != "fault" || !c.CaseInsensitive {
+		t.Errorf("text condition not converted: %+v", c)
+	}
+}
+
+func TestConditionNumberActive(t *testing.T) {
+	c := Condition{Operator: PointValueGreaterThan, PointValue: 10, Hysteresis: 2}
+
+	tests := []struct {
+		value  float64
+		active bool
+		exp    bool
+	}{
+		{10.5, false, true},
+		{9, false, false},
+		{9, true, true},
+		{8.5, true, true},
+		{7.9, true, false},
+	}
+
+	for _, test := range tests {
+		c.Active = test.active
+		if c.NumberActive(test.value) != test.exp {
+			t.Errorf("> %v active: %v, expected %v", test.value, test.active, test.exp)
+		}
+	}
+
+	c = Condition{Operator: PointValueLessThan, PointValue: 10, Hysteresis: 2}
+
+	tests = []struct {
+		value  float64
+		active bool
+		exp    bool
+	}{
+		{9.5, false, true},
+		{11, false, false},
+		{11, true, true},
+		{12.1, true, false},
+	}
+
+	for _, test := range tests {
+		c.Active = test.active
+		if c.NumberActive(test.value) != test.exp {
+			t.Errorf("< %v active: %v, expected %v", test.value, test.active, test.exp)
+		}
+	}
+}
+
+func TestConditionDebounce(t *testing.T) {
+	start := time.Date(2021, time.September, 1, 0, 0, 0, 0, time.UTC)
+
+	c := Condition{MinTimeActive: 2, MinTimeInactive: 1}
+
+	steps := []struct {
+		met     bool
+		t       time.Duration
+		active  bool
+		pending bool
+	}{
+		{true, 0, false, true},
+		{true, time.Minute, false, true},
+		// condition flaps, so timer restarts
+		{false, 90 * time.Second, false, false},
+		{true, 2 * time.Minute, false, true},
+		{true, 3 * time.Minute, false, true},
+		{true, 4 * time.Minute, true, false},
+		{false, 5 * time.Minute, true, true},
+		{true, 5*time.Minute + 30*time.Second, true, false},
+		{false, 6 * time.Minute, true, true},
+		{false, 7 * time.Minute, false, false},
+	}
+
+	for i, s := range steps {
+		c = c.Debounce(s.met, start.Add(s.t))
+		if c.Active != s.active || c.Pending != s.pending {
+			t.Errorf("step %v: expected active %v pending %v, got %v %v",
+				i, s.active, s.pending, c.Active, c.Pending)
+		}
+	}
+
+	// no delay changes state immediately
+	c = Condition{}.Debounce(true, start)
+	if !c.Active || c.Pending {
+		t.Error("condition without delay did not go active")
+	}
+}
+
+func TestNodeToRulePending(t *testing.T) {
+	pendingStart := time.Date(2021, time.September, 1, 0, 0, 0, 0, time.UTC)
+
+	cond := NodeEdge{
+		ID:   "c1",
+		Type: NodeTypeCondition,
+		Points: Points{
+			{Type: PointTypeMinActive, Value: 5},
+			{Type: PointTypeMinInactive, Value: 1},
+			{Type: PointTypeHysteresis, Value: 0.5},
+			{Type: PointTypePending, Value: 1, Time: pendingStart},
+		},
+	}
+
+	r, err := NodeToRule(NodeEdge{ID: "r1"}, []NodeEdge{cond}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := r.Conditions[0]
+
+	if c.MinTimeActive != 5 || c.MinTimeInactive != 1 || c.Hysteresis != 0.5 ||
+		!c.Pending || !c.PendingStart.Equal(pendingStart) {
+		t.Errorf("condition not converted: %+v", c)
+	}
+}
+
+func TestLogicActive(t *testing.T) {
+	cond := func(active ...bool) []Condition {
+		var ret []Condition
+		for _, a := range active {
+			ret = append(ret, Condition{Active: a})
+		}
+		return ret
+	}
+
+	tests := []struct {
+		logic      string
+		conditions []Condition
+		groups     []ConditionGroup
+		exp        bool
+	}{
+		{"", cond(true, true), nil, true},
+		{PointValueAnd, cond(true, false), nil, false},
+		{PointValueAnd, nil, nil, true},
+		{PointValueOr, cond(false, true), nil, true},
+		{PointValueOr, cond(false, false), nil, false},
+		{PointValueOr, nil, nil, false},
+		{PointValueNot, cond(false), nil, true},
+		{PointValueNot, cond(false, true), nil, false},
+		{PointValueAnd, cond(true), []ConditionGroup{{Active: false}}, false},
+		{PointValueOr, cond(false), []ConditionGroup{{Active: true}}, true},
+	}
+
+	for i, test := range tests {
+		if LogicActive(test.logic, test.conditions, test.groups) != test.exp {
+			t.Errorf("test %v (%v): expected %v", i, test.logic, test.exp)
+		}
+	}
+}
+
+func TestNodeToConditionGroup(t *testing.T) {
+	group := NodeEdge{
+		ID:   "g1",
+		Type: NodeTypeConditionGroup,
+		Points: Points{
+			{Type: PointTypeDescription, Text: "faults"},
+			{Type: PointTypeLogic, Text: PointValueOr},
+			{Type: PointTypeActive, Value: 1},
+		},
+	}
+
+	cond := NodeEdge{ID: "c1", Type: NodeTypeCondition}
+
+	g := NodeToConditionGroup(group, []NodeEdge{cond})
+
+	if g.ID != "g1" || g.Description != "faults" || g.Logic != PointValueOr ||
+		!g.Active {
+		t.Errorf("group not converted: %+v", g)
+	}
+
+	if len(g.Conditions) != 1 || g.Conditions[0].ID != "c1" {
+		t.Errorf("group conditions not converted: %+v", g.Conditions)
+	}
+}
+
+func TestConditionNoUpdate(t *testing.T) {
+	lastUpdate := time.Date(2021, time.September, 1, 0, 0, 0, 0, time.UTC)
+
+	cond := NodeEdge{
+		ID:   "c1",
+		Type: NodeTypeCondition,
+		Points: Points{
+			{Type: PointTypeConditionType, Text: PointValueNoUpdate},
+			{Type: PointTypePointType, Text: PointTypeValue},
+			{Type: PointTypeNoUpdateTime, Value: 15},
+			{Type: PointTypeDescendants, Value: 1},
+		},
+	}
+
+	r, err := NodeToRule(NodeEdge{ID: "r1"}, []NodeEdge{cond}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := r.Conditions[0]
+
+	if c.NoUpdateTime != 15 || !c.Descendants || c.PointType != PointTypeValue {
+		t.Fatalf("condition not converted: %+v", c)
+	}
+
+	c.LastUpdate = lastUpdate
+
+	if c.NoUpdateActive(lastUpdate.Add(14 * time.Minute)) {
+		t.Error("condition active before no update time")
+	}
+
+	if !c.NoUpdateActive(lastUpdate.Add(15 * time.Minute)) {
+		t.Error("condition not active after no update time")
+	}
+
+	// nodes that never sent points have not been updated
+	c.LastUpdate = time.Time{}
+	if !c.NoUpdateActive(lastUpdate) {
+		t.Error("condition not active for node without points")
+	}
+}
+
+func TestConditionWindowStats(t *testing.T) {
+	start := time.Date(2021, time.September, 1, 0, 0, 0, 0, time.UTC)
+
+	points := Points{
+		{Type: PointTypeValue, Time: start, Value: 80},
+		{Type: PointTypeValue, Time: start.Add(time.Minute), Value: -10},
+		{Type: PointTypeValue, Time: start.Add(2 * time.Minute), Value: 100},
+		{Type: PointTypeValue, Time: start.Add(4 * time.Minute), Value: 70},
+	}
+
+	tests := []struct {
+		stat  string
+		exp   float64
+		valid bool
+	}{
+		{PointValueAverage, 60, true},
+		{PointValueMin, -10, true},
+		{PointValueMax, 100, true},
+		{PointValueDelta, -10, true},
+		{PointValueDeltaPercent, -12.5, true},
+		{PointValueRate, -2.5, true},
+		{"bogus", 0, false},
+	}
+
+	for _, test := range tests {
+		c := Condition{WindowStat: test.stat}
+		v, valid := c.WindowStats(points)
+		if v != test.exp || valid != test.valid {
+			t.Errorf("%v: expected %v %v, got %v %v", test.stat, test.exp,
+				test.valid, v, valid)
+		}
+	}
+
+	// a single point is not enough to compute changes
+	for _, stat := range []string{PointValueDelta, PointValueDeltaPercent, PointValueRate} {
+		c := Condition{WindowStat: stat}
+		if _, valid := c.WindowStats(points[:1]); valid {
+			t.Errorf("%v: single point should not be valid", stat)
+		}
+	}
+
+	c := Condition{WindowStat: PointValueAverage}
+	if _, valid := c.WindowStats(nil); valid {
+		t.Error("average of no points should not be valid")
+	}
+}
+
+func TestConditionPointActive(t *testing.T) {
+	tests := []struct {
+		c   Condition
+		p   Point
+		exp bool
+	}{
+		{Condition{PointValueType: PointValueNumber, Operator: PointValueGreaterThan,
+			PointValue: 10}, Point{Value: 11}, true},
+		{Condition{PointValueType: PointValueOnOff, PointValue: 1},
+			Point{Value: 0}, false},
+		{Condition{PointValueType: PointValueText, Operator: PointValueEqual,
+			PointTextValue: "online"}, Point{Text: "online"}, true},
+	}
+
+	for i, test := range tests {
+		active, err := test.c.PointActive(test.p)
+		if err != nil {
+			t.Errorf("test %v: error: %v", i, err)
+		}
+
+		if active != test.exp {
+			t.Errorf("test %v: expected %v, got %v", i, test.exp, active)
+		}
+	}
+
+	_, err := Condition{}.PointActive(Point{})
+	if err == nil {
+		t.Error("expected error for missing value type")
+	}
+}
+
+func TestConditionInputState(t *testing.T) {
+	now := time.Date(2021, time.September, 1, 0, 0, 0, 0, time.UTC)
+
+	c := Condition{StaleTime: 5}
+
+	tests := []struct {
+		p   *Point
+		exp string
+	}{
+		{nil, PointValueInputMissing},
+		{&Point{Time: now.Add(-time.Minute)}, PointValueInputOK},
+		{&Point{Time: now.Add(-10 * time.Minute)}, PointValueInputStale},
+	}
+
+	for i, test := range tests {
+		state := c.InputState(test.p, now)
+		if state != test.exp {
+			t.Errorf("test %v: expected %v, got %v", i, test.exp, state)
+		}
+	}
+
+	// inputs never go stale if stale time is not set
+	state := Condition{}.InputState(&Point{}, now)
+	if state != PointValueInputOK {
+		t.Errorf("expected ok without stale time, got %v", state)
+	}
+}
+
+func TestRuleTriggerPoint(t *testing.T) {
+	r := Rule{
+		Groups: []ConditionGroup{
+			{Conditions: []Condition{
+				{ConditionType: PointValuePointValue, PointType: PointTypeValue,
+					PointIndex: -1},
+			}},
+		},
+	}
+
+	points := Points{
+		{Type: PointTypeDescription, Text: "tank"},
+		{Type: PointTypeValue, Value: 12},
+	}
+
+	p := r.TriggerPoint("node", points)
+	if p.Type != PointTypeValue || p.Value != 12 {
+		t.Errorf("wrong trigger point: %+v", p)
+	}
+
+	p = r.TriggerPoint("node", points[:1])
+	if p.Type != PointTypeDescription {
+		t.Errorf("expected first point if none match, got %+v", p)
+	}
+}
+
+func TestNodeToRuleWebhook(t *testing.T) {
+	act := NodeEdge{
+		ID:   "a1",
+		Type: NodeTypeAction,
+		Points: Points{
+			{Type: PointTypeActionType, Text: PointValueActionWebhook},
+			{Type: PointTypeURI, Text: "http://localhost/hook"},
+			{Type: PointTypeMethod, Text: "PUT"},
+			{Type: PointTypeHeader, Index: 0, Text: "Authorization: Bearer a:b"},
+			{Type: PointTypeHeader, Index: 1, Text: "invalid"},
+			{Type: PointTypeTimeout, Value: 5},
+			{Type: PointTypeRetries, Value: 3},
+		},
+	}
+
+	r, err := NodeToRule(NodeEdge{ID: "r1"}, nil, []NodeEdge{act}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := r.Actions[0]
+
+	if a.Action != PointValueActionWebhook || a.URI != "http://localhost/hook" ||
+		a.Method != "PUT" || a.Timeout != 5 || a.Retries != 3 {
+		t.Errorf("action not converted: %+v", a)
+	}
+
+	if len(a.Headers) != 1 || a.Headers["Authorization"] != "Bearer a:b" {
+		t.Errorf("wrong headers: %v", a.Headers)
+	}
+}
+
+func TestActionDue(t *testing.T) {
+	active := time.Date(2021, time.September, 1, 0, 0, 0, 0, time.UTC)
+
+	at := func(m float64) time.Time {
+		return active.Add(time.Duration(m * float64(time.Minute)))
+	}
+
+	tests := []struct {
+		name string
+		a    Action
+		now  time.Time
+		exp  bool
+	}{
+		{"first run", Action{}, active, true},
+		{"no repeat", Action{RunCount: 1, LastRun: active}, at(60), false},
+		{"delay not expired", Action{Delay: 10}, at(9), false},
+		{"delay expired", Action{Delay: 10}, at(10), true},
+		{"repeat not expired", Action{Repeat: 5, RunCount: 1, LastRun: active},
+			at(4), false},
+		{"repeat expired", Action{Repeat: 5, RunCount: 1, LastRun: active},
+			at(5), true},
+		{"repeat max", Action{Repeat: 5, RepeatMax: 2, RunCount: 2, LastRun: at(5)},
+			at(10), true},
+		{"repeat max reached", Action{Repeat: 5, RepeatMax: 2, RunCount: 3,
+			LastRun: at(10)}, at(15), false},
+		// runs from a previous activation do not count
+		{"previous activation", Action{Delay: 10, RunCount: 3,
+			LastRun: active.Add(-time.Hour)}, at(5), false},
+		{"previous activation delay", Action{Delay: 10, RunCount: 3,
+			LastRun: active.Add(-time.Hour)}, at(10), true},
+	}
+
+	for _, test := range tests {
+		if test.a.Due(active, test.now) != test.exp {
+			t.Errorf("%v: expected %v", test.name, test.exp)
+		}
+	}
+}
+
+func TestNodeToRuleRepeat(t *testing.T) {
+	lastRun := time.Date(2021, time.September, 1, 0, 0, 0, 0, time.UTC)
+
+	act := NodeEdge{
+		ID:   "a1",
+		Type: NodeTypeAction,
+		Points: Points{
+			{Type: PointTypeDelay, Value: 15},
+			{Type: PointTypeRepeat, Value: 5},
+			{Type: PointTypeRepeatMax, Value: 3},
+			{Type: PointTypeRunCount, Value: 2, Time: lastRun, Text: "n1"},
+		},
+	}
+
+	ruleNode := NodeEdge{
+		ID: "r1",
+		Points: Points{
+			{Type: PointTypeActive, Value: 1, Time: lastRun.Add(-time.Hour)},
+		},
+	}
+
+	r, err := NodeToRule(ruleNode, nil, []NodeEdge{act}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !r.ActiveTime.Equal(lastRun.Add(-time.Hour)) {
+		t.Error("wrong rule active time: ", r.ActiveTime)
+	}
+
+	a := r.Actions[0]
+
+	if a.Delay != 15 || a.Repeat != 5 || a.RepeatMax != 3 || a.RunCount != 2 ||
+		!a.LastRun.Equal(lastRun) || a.LastTriggerNode != "n1" {
+		t.Errorf("action not converted: %+v", a)
+	}
+}
+
+func TestNodeToRuleAlarm(t *testing.T) {
+	ackTime := time.Date(2021, time.September, 1, 0, 0, 0, 0, time.UTC)
+
+	ruleNode := NodeEdge{
+		ID: "r1",
+		Points: Points{
+			{Type: PointTypeActive, Value: 1, Text: "n1"},
+			{Type: PointTypeAlarmState, Text: PointValueAlarmShelved},
+			{Type: PointTypeAckUser, Text: "u1", Time: ackTime},
+			{Type: PointTypeShelveUntil, Text: "2021-09-01T02:00:00Z"},
+		},
+	}
+
+	r, err := NodeToRule(ruleNode, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.TriggerNode != "n1" || r.AlarmState != PointValueAlarmShelved || r.AckUser != "u1" ||
+		!r.AckTime.Equal(ackTime) ||
+		!r.ShelveUntil.Equal(ackTime.Add(2*time.Hour)) {
+		t.Errorf("alarm not converted: %+v", r)
+	}
+}
+
+func TestNodeToConditionSchedule(t *testing.T) {
+	c := nodeToCondition(NodeEdge{
+		ID: "c1",
+		Points: Points{
+			{Type: PointTypeConditionType, Text: PointValueSchedule},
+			{Type: PointTypeTimezone, Text: "America/Chicago"},
+			{Type: PointTypeDate, Index: 0, Text: "2021-12-24"},
+			{Type: PointTypeDate, Index: 1, Text: ""},
+			{Type: PointTypeExcludeDate, Index: 0, Text: "2021-12-25"},
+			{Type: PointTypeCalendar, Text: "cal"},
+			{Type: PointTypeCalendarMode, Text: PointValueOnly},
+		},
+	})
+
+	if c.Timezone != "America/Chicago" || c.Calendar != "cal" ||
+		c.CalendarMode != PointValueOnly {
+		t.Errorf("schedule not converted: %+v", c)
+	}
+
+	if !reflect.DeepEqual(c.Dates, []string{"2021-12-24"}) ||
+		!reflect.DeepEqual(c.ExcludeDates, []string{"2021-12-25"}) {
+		t.Errorf("dates not converted: %v, %v", c.Dates, c.ExcludeDates)
+	}
+}
+
+func TestActionNextRun(t *testing.T) {
+	active := time.Date(2021, time.September, 1, 0, 0, 0, 0, time.UTC)
+
+	at := func(m float64) time.Time {
+		return active.Add(time.Duration(m * float64(time.Minute)))
+	}
+
+	tests := []struct {
+		name string
+		a    Action
+		exp  time.Time
+	}{
+		{"first run", Action{}, active},
+		{"delay", Action{Delay: 10}, at(10)},
+		{"no repeat", Action{RunCount: 1, LastRun: active}, time.Time{}},
+		{"repeat", Action{Repeat: 5, RunCount: 1, LastRun: at(2)}, at(7)},
+		{"repeat max reached", Action{Repeat: 5, RepeatMax: 2, RunCount: 3,
+			LastRun: at(10)}, time.Time{}},
+		{"previous activation", Action{Delay: 10, RunCount: 3,
+			LastRun: active.Add(-time.Hour)}, at(10)},
+	}
+
+	for _, test := range tests {
+		next := test.a.NextRun(active)
+		if !next.Equal(test.exp) {
+			t.Errorf("%v: expected %v, got %v", test.name, test.exp, next)
+		}
+	}
+}
+
+func TestRuleWatches(t *testing.T) {
+	r := Rule{
+		Conditions: []Condition{
+			{ConditionType: PointValuePointValue, NodeID: "n1",
+				PointType: "temp", PointIndex: -1},
+			{ConditionType: PointValueSchedule},
+		},
+		Groups: []ConditionGroup{
+			{Conditions: []Condition{
+				{ConditionType: PointValueNoUpdate, Active: true,
+					PointType: "level"},
+			}},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		nodeID string
+		p      Point
+		exp    bool
+	}{
+		{"point value", "n1", Point{Type: "temp"}, true},
+		{"other node", "n2", Point{Type: "temp"}, false},
+		{"other type", "n1", Point{Type: "humidity"}, false},
+		{"active no update in group", "n2", Point{Type: "level"}, true},
+	}
+
+	for _, test := range tests {
+		if r.Watches(test.nodeID, Points{test.p}) != test.exp {
+			t.Errorf("%v: expected %v", test.name, test.exp)
+		}
+	}
+
+	r.Groups[0].Conditions[0].Active = false
+	if r.Watches("n2", Points{{Type: "level"}}) {
+		t.Error("inactive no update condition should only change on timeout")
+	}
+}
+
+func TestRuleCopy(t *testing.T) {
+	r := &Rule{
+		Conditions: []Condition{{ID: "c1"}},
+		Groups: []ConditionGroup{
+			{Conditions: []Condition{{ID: "c2"}}},
+		},
+		Actions: []Action{{ID: "a1"}},
+	}
+
+	c := r.Copy()
+	c.Conditions[0].Active = true
+	c.Groups[0].Conditions[0].Active = true
+	c.Actions[0].RunCount = 1
+
+	if r.Conditions[0].Active || r.Groups[0].Conditions[0].Active ||
+		r.Actions[0].RunCount != 0 {
+		t.Error("modifying copy changed the original rule")
+	}
+}
+
+func TestRuleProcessStatePoints(t *testing.T) {
+	now := time.Now()
+
+	r := &Rule{
+		ID:         "rule",
+		Conditions: []Condition{{ID: "c1"}},
+		Groups: []ConditionGroup{
+			{ID: "g1", Groups: []ConditionGroup{
+				{ID: "g2", Conditions: []Condition{{ID: "c2"}}},
+			}},
+		},
+		Actions: []Action{{ID: "a1"}},
+	}
+
+	r.ProcessStatePoints("rule", Points{
+		{Type: PointTypeActive, Time: now, Value: 1, Text: "dev"},
+		{Type: PointTypeDescription, Text: "config"},
+	})
+
+	if !r.Active || !r.ActiveTime.Equal(now) || r.TriggerNode != "dev" {
+		t.Error("rule state not updated: ", r)
+	}
+
+	if r.Description != "" {
+		t.Error("config points should be ignored")
+	}
+
+	r.ProcessStatePoints("c2", Points{{Type: PointTypePending, Time: now, Value: 1}})
+
+	c := r.Groups[0].Groups[0].Conditions[0]
+	if !c.Pending || !c.PendingStart.Equal(now) {
+		t.Error("nested condition state not updated: ", c)
+	}
+
+	if r.Conditions[0].Pending {
+		t.Error("other condition updated")
+	}
+
+	r.ProcessStatePoints("g1", Points{{Type: PointTypeActive, Value: 1}})
+
+	if !r.Groups[0].Active || r.Groups[0].Groups[0].Active {
+		t.Error("group state not updated: ", r.Groups)
+	}
+
+	r.ProcessStatePoints("a1", Points{{Type: PointTypeRunCount, Time: now, Value: 2}})
+
+	if r.Actions[0].RunCount != 2 || !r.Actions[0].LastRun.Equal(now) {
+		t.Error("action state not updated: ", r.Actions[0])
+	}
+}
@@ -40,6 +40,7 @@ const (
 	PointTypeUpdateOS      = "updateOS"
 	PointTypeUpdateApp     = "updateApp"
 	PointTypeSysState      = "sysState"
+	PointTypeBootID        = "bootId"
 
 	PointValueSysStateUnknown  = "unknown"
 	PointValueSysStatePowerOff = "powerOff"
@@ -60,7 +61,29 @@ const (
 	PointTypeLastName  = "lastName"
 	PointTypePhone     = "phone"
 	PointTypeEmail     = "email"
-	PointTypePass      = "pass"
+	// pass points are never returned by the API or NATS, and user
+	// passwords are stored as bcrypt hashes. passChange is set when the
+	// user must change their password before making other changes, like
+	// the default admin.
+	PointTypePass       = "pass"
+	PointTypePassChange = "passChange"
+
+	// notification preferences may be set on a user node, or on the edge
+	// between a user and a group, which overrides the user node for
+	// notifications through that group. notifyChannel is an indexed point
+	// that limits the channels messages are sent on (all channels if not
+	// set). Notifications below minSeverity are not sent, and during quiet
+	// hours (quietStart to quietEnd, HH:MM in timezone) notifications below
+	// quietSeverity (critical if not set) are not sent. optOut stops all
+	// notifications.
+	PointTypeNotifyChannel = "notifyChannel"
+	PointValueSMS          = "sms"
+	PointValueEmail        = "email"
+	PointTypeMinSeverity   = "minSeverity"
+	PointTypeQuietStart    = "quietStart"
+	PointTypeQuietEnd      = "quietEnd"
+	PointTypeQuietSeverity = "quietSeverity"
+	PointTypeOptOut        = "optOut"
 
 	// modbus nodes
 	// in modbus land, terminology is a big backwards, client is master,
@@ -105,13 +128,61 @@ const (
 	// a rule node describes a rule that may run on the system
 	NodeTypeRule = "rule"
 
+	// the text of the active point of a rule is the node that made the
+	// rule active
 	PointTypeActive = "active"
 
+	// alarmState tracks the acknowledgement of a rule that has gone
+	// active. ackUser is the ID of the user that acknowledged the alarm,
+	// and its time is when the alarm was acknowledged. A shelved alarm
+	// does not run actions until the shelveUntil time (RFC3339 text).
+	PointTypeAlarmState           = "alarmState"
+	PointValueAlarmNormal         = "normal"
+	PointValueAlarmActiveUnacked  = "activeUnacked"
+	PointValueAlarmActiveAcked    = "activeAcked"
+	PointValueAlarmClearedUnacked = "clearedUnacked"
+	PointValueAlarmShelved        = "shelved"
+	PointTypeAckUser              = "ackUser"
+	PointTypeShelveUntil          = "shelveUntil"
+
+	// if trace is set, the evaluation of a rule is published on the
+	// node trace subject every time the rule processes points
+	PointTypeTrace = "trace"
+
+	// severity of the notifications sent by a rule (warning if not set)
+	PointTypeSeverity  = "severity"
+	PointValueInfo     = "info"
+	PointValueWarning  = "warning"
+	PointValueCritical = "critical"
+
+	// logic combines the conditions and condition groups of a rule or
+	// condition group
+	PointTypeLogic = "logic"
+	PointValueAnd  = "and"
+	PointValueOr   = "or"
+	PointValueNot  = "not"
+
 	NodeTypeCondition = "condition"
 
+	// a condition group is a child of a rule or another condition group
+	// and combines the conditions and groups under it
+	NodeTypeConditionGroup = "conditionGroup"
+
 	PointTypeConditionType = "conditionType"
 	PointValuePointValue   = "pointValue"
 	PointValueSchedule     = "schedule"
+	// noUpdate conditions are active when a node has not received
+	// points for the noUpdateTime (in minutes)
+	PointValueNoUpdate = "noUpdate"
+
+	// window conditions compare a statistic of the points received over
+	// the last window minutes instead of the latest point value
+	PointValueWindow = "window"
+
+	PointTypeNoUpdateTime = "noUpdateTime"
+	// descendants extends a noUpdate condition to all nodes below the
+	// condition node
+	PointTypeDescendants = "descendants"
 
 	PointTypeTrigger = "trigger"
 
@@ -119,6 +190,24 @@ const (
 	PointTypeEnd     = "end"
 	PointTypeWeekday = "weekday"
 
+	// schedule conditions are evaluated in the local time of timezone (an
+	// IANA name such as America/Chicago, UTC if blank). date and
+	// excludeDate are indexed points with a YYYY-MM-DD date or a
+	// YYYY-MM-DD/YYYY-MM-DD range. calendar is the ID of a calendar node
+	// whose dates are excluded from the schedule, or with calendarMode
+	// only, the only dates the schedule is active.
+	PointTypeTimezone     = "timezone"
+	PointTypeDate         = "date"
+	PointTypeExcludeDate  = "excludeDate"
+	PointTypeCalendar     = "calendar"
+	PointTypeCalendarMode = "calendarMode"
+	PointValueExclude     = "exclude"
+	PointValueOnly        = "only"
+
+	// a calendar node holds a shared list of dates, such as holidays, in
+	// indexed date points
+	NodeTypeCalendar = "calendar"
+
 	PointTypePointID    = "pointID"
 	PointTypePointType  = "pointType"
 	PointTypePointIndex = "pointIndex"
@@ -135,8 +224,40 @@ const (
 	PointValueOn          = "on"
 	PointValueOff         = "off"
 	PointValueContains    = "contains"
-
-	PointTypeMinActive = "minActive"
+	PointValueStartsWith  = "startsWith"
+	PointValueEndsWith    = "endsWith"
+	PointValueRegex       = "regex"
+
+	// text conditions ignore case if caseInsensitive is set
+	PointTypeCaseInsensitive = "caseInsensitive"
+
+	PointTypeWindow        = "window"
+	PointTypeWindowStat    = "windowStat"
+	PointValueAverage      = "average"
+	PointValueMin          = "min"
+	PointValueMax          = "max"
+	PointValueDelta        = "delta"
+	PointValueDeltaPercent = "deltaPercent"
+	// rate is the change in value per minute
+	PointValueRate = "rate"
+
+	// conditions that specify a node ID are evaluated against the point
+	// stored in that node. inputStatus reports if this point is missing,
+	// or older than staleTime (in minutes).
+	PointTypeStaleTime     = "staleTime"
+	PointTypeInputStatus   = "inputStatus"
+	PointValueInputOK      = "ok"
+	PointValueInputMissing = "missing"
+	PointValueInputStale   = "stale"
+
+	PointTypeMinActive   = "minActive"
+	PointTypeMinInactive = "minInactive"
+	PointTypeHysteresis  = "hysteresis"
+
+	// pending is set on a condition while it is waiting for the min
+	// active/inactive time to elapse. The point time is when the
+	// condition started changing.
+	PointTypePending = "pending"
 
 	NodeTypeAction         = "action"
 	NodeTypeActionInactive = "actionInactive"
@@ -146,6 +267,38 @@ const (
 	PointValueActionNotify    = "notify"
 	PointValueActionSetValue  = "setValue"
 	PointValueActionPlayAudio = "playAudio"
+	PointValueActionWebhook   = "webhook"
+
+	// actions run delay minutes after the rule goes active, and are
+	// repeated every repeat minutes up to repeatMax times (0 is no limit)
+	// while the rule stays active. runCount records how many times the
+	// action has run since the rule went active. Its time is when the
+	// action last ran, and its text is the node that triggered the rule.
+	PointTypeDelay     = "delay"
+	PointTypeRepeat    = "repeat"
+	PointTypeRepeatMax = "repeatMax"
+	PointTypeRunCount  = "runCount"
+
+	// notify actions may specify Go templates for the notification subject
+	// and message. template is also used for the webhook body.
+	PointTypeSubjectTemplate = "subjectTemplate"
+	PointTypeTemplate        = "template"
+	// htmlTemplate is an optional HTML version of the message that is
+	// sent to email recipients with the plain text message
+	PointTypeHTMLTemplate = "htmlTemplate"
+
+	// webhook actions send a request to the uri point. Headers are
+	// specified as indexed "Name: value" text points. timeout is in
+	// seconds.
+	PointTypeMethod  = "method"
+	PointTypeHeader  = "header"
+	PointTypeTimeout = "timeout"
+	PointTypeRetries = "retries"
+
+	// webhook delivery results are recorded in the action node
+	PointTypeStatusCode = "statusCode"
+	PointTypeAttempts   = "attempts"
+	PointTypeError      = "error"
 
 	// Transient points that are used for notifications, etc.
 	// These points are not stored in the state of any node,
@@ -159,16 +312,58 @@ const (
 
 	PointValueTwilio = "twilio"
 	PointValueSMTP   = "smtp"
+	// webhook message services post each message as JSON to the uri
+	// point, with the same method, header, and timeout points as webhook
+	// actions. Failed deliveries of all message services are retried the
+	// number of times in the retries point.
+	PointValueWebhook = "webhook"
+	// mock message services record messages instead of sending them,
+	// which is useful for testing
+	PointValueMock = "mock"
+	// modem message services send and receive SMS through the AT
+	// command port of a cellular modem (modemPort). smsMode selects
+	// text or PDU mode, as some modems only support one of them.
+	// Received messages are polled and run as SMS commands.
+	PointValueModem    = "modem"
+	PointTypeModemPort = "modemPort"
+	PointTypeSMSMode   = "smsMode"
+	PointValuePDU      = "pdu"
 
 	PointTypeSID       = "sid"
 	PointTypeAuthToken = "authToken"
 	PointTypeFrom      = "from"
 
+	// SMTP message services send email through a server at host and port
+	// (the port defaults to the standard port for the TLS mode). username
+	// and pass are optional credentials. subjectPrefix is added to the
+	// subject of every email. Delivery errors are recorded in the error
+	// point of the service node.
+	PointTypeHost          = "host"
+	PointTypeTLSMode       = "tlsMode"
+	PointValueTLSNone      = "none"
+	PointValueStartTLS     = "starttls"
+	PointValueTLS          = "tls"
+	PointTypeUsername      = "username"
+	PointTypeSubjectPrefix = "subjectPrefix"
+
+	// a variable node computes its value from an expression over
+	// points in other nodes. Inputs are specified with indexed points.
 	NodeTypeVariable      = "variable"
 	PointTypeVariableType = "variableType"
+	PointTypeExpression   = "expression"
+	PointTypeInputName    = "inputName"
 
 	NodeTypeUpstream = "upstream"
 
+	// a retention node configures how long point history is kept
+	// for its parent node and all descendants, and how it is downsampled.
+	NodeTypeRetention = "retention"
+
+	PointTypeRawRetention    = "rawRetention"
+	PointTypeRollupPeriod    = "rollupPeriod"
+	PointTypeRollupRetention = "rollupRetention"
+	PointTypeEventRetention  = "eventRetention"
+
 	PointTypeMetricNatsNodePoint     = "metricNatsNodePoint"
 	PointTypeMetricNatsNodeEdgePoint = "metricNatsNodeEdgePoint"
 	PointTypeMetricNatsNode          = "metricNatsNode"
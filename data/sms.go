@@ -0,0 +1,78 @@
+package data
+
+import (
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/simpleiot/simpleiot/internal/pb"
+	"google.golang.org/protobuf/proto"
+)
+
+// define commands that users can send by SMS
+const (
+	SMSCommandAck    = "ACK"
+	SMSCommandStatus = "STATUS"
+	SMSCommandSet    = "SET"
+	SMSCommandHelp   = "HELP"
+)
+
+// SMSCommand is a command a user sends by SMS, for example to acknowledge
+// an alarm. ServiceID is the message service that received the SMS, and
+// From is the phone number of the sender. UserID, Reply, and Error are set
+// once the command is run. The reply is sent back to the user by SMS, and
+// Error is set if the sender is unknown or the command failed.
+type SMSCommand struct {
+	ServiceID string    `json:"serviceId"`
+	From      string    `json:"from"`
+	Body      string    `json:"body"`
+	Time      time.Time `json:"time"`
+	UserID    string    `json:"userId"`
+	Reply     string    `json:"reply"`
+	Error     string    `json:"error"`
+}
+
+// ToPb converts a SMS command to protobuf
+func (c SMSCommand) ToPb() ([]byte, error) {
+	t, err := ptypes.TimestampProto(c.Time)
+	if err != nil {
+		return nil, err
+	}
+
+	return proto.Marshal(&pb.SMSCommand{
+		ServiceId: c.ServiceID,
+		From:      c.From,
+		Body:      c.Body,
+		Time:      t,
+		UserId:    c.UserID,
+		Reply:     c.Reply,
+		Error:     c.Error,
+	})
+}
+
+// PbDecodeSMSCommand converts a protobuf to a SMS command
+func PbDecodeSMSCommand(data []byte) (SMSCommand, error) {
+	pbCmd := &pb.SMSCommand{}
+
+	err := proto.Unmarshal(data, pbCmd)
+	if err != nil {
+		return SMSCommand{}, err
+	}
+
+	ret := SMSCommand{
+		ServiceID: pbCmd.ServiceId,
+		From:      pbCmd.From,
+		Body:      pbCmd.Body,
+		UserID:    pbCmd.UserId,
+		Reply:     pbCmd.Reply,
+		Error:     pbCmd.Error,
+	}
+
+	if pbCmd.Time != nil {
+		ret.Time, err = ptypes.Timestamp(pbCmd.Time)
+		if err != nil {
+			return SMSCommand{}, err
+		}
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,38 @@
+package data
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSMSCommandPb(t *testing.T) {
+	c := SMSCommand{
+		ServiceID: "s1",
+		From:      "+15555550100",
+		Body:      "STATUS Tank 3",
+		Time:      time.Date(2021, time.September, 1, 0, 0, 0, 5, time.UTC),
+		UserID:    "u1",
+		Reply:     "Tank 3: level 2.5",
+	}
+
+	buf, err := c.ToPb()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c2, err := PbDecodeSMSCommand(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !c2.Time.Equal(c.Time) {
+		t.Errorf("time not preserved: %v", c2.Time)
+	}
+
+	c2.Time = c.Time
+
+	if !reflect.DeepEqual(c, c2) {
+		t.Errorf("expected %+v, got %+v", c, c2)
+	}
+}
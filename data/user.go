@@ -1,15 +1,43 @@
 package data
 
-import "time"
+import (
+	"time"
 
-// User represents a user of the system
+	"golang.org/x/crypto/bcrypt"
+)
+
+// User represents a user of the system. Pass is the bcrypt hash of the
+// password once the user is stored.
 type User struct {
-	ID        string `json:"id" boltholdKey:"ID"`
-	FirstName string `json:"firstName"`
-	LastName  string `json:"lastName"`
-	Phone     string `json:"phone"`
-	Email     string `json:"email"`
-	Pass      string `json:"pass"`
+	ID         string `json:"id" boltholdKey:"ID"`
+	FirstName  string `json:"firstName"`
+	LastName   string `json:"lastName"`
+	Phone      string `json:"phone"`
+	Email      string `json:"email"`
+	Pass       string `json:"pass"`
+	PassChange bool   `json:"passChange"`
+}
+
+// HashPassword returns the salted bcrypt hash of a password
+func HashPassword(pass string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(pass), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(hash), nil
+}
+
+// PasswordHashed returns true if a password is already a bcrypt hash
+func PasswordHashed(pass string) bool {
+	_, err := bcrypt.Cost([]byte(pass))
+	return err == nil
+}
+
+// CheckPassword returns true if a password matches a hash from
+// HashPassword
+func CheckPassword(hash, pass string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(pass)) == nil
 }
 
 // ToPoints converts a user structure into points
@@ -21,6 +49,7 @@ func (u *User) ToPoints() Points {
 		{Type: PointTypePhone, Time: now, Text: u.Phone},
 		{Type: PointTypeEmail, Time: now, Text: u.Email},
 		{Type: PointTypePass, Time: now, Text: u.Pass},
+		{Type: PointTypePassChange, Time: now, Value: BoolToFloat(u.PassChange)},
 		{Type: PointTypeNodeType, Time: now, Text: NodeTypeUser},
 	}
 }
@@ -49,8 +78,74 @@ func NodeToUser(node Node) (User, error) {
 			ret.Phone = p.Text
 		case PointTypePass:
 			ret.Pass = p.Text
+		case PointTypePassChange:
+			ret.PassChange = FloatToBool(p.Value)
 		}
 	}
 
 	return ret, nil
 }
+
+// NotifyPrefs are the notification preferences of a user. Channels limits
+// the channels messages are sent on (all channels if empty). Severities are
+// blank if not set.
+type NotifyPrefs struct {
+	Channels      []string
+	MinSeverity   string
+	QuietStart    string
+	QuietEnd      string
+	Timezone      string
+	QuietSeverity string
+	OptOut        bool
+}
+
+// NodeEdgeToNotifyPrefs returns the notification preferences of a user node.
+// Preferences set in the edge points (between the user and a group) override
+// those in the user node points. Blank text points are not set.
+func NodeEdgeToNotifyPrefs(ne NodeEdge) NotifyPrefs {
+	var ret NotifyPrefs
+
+	apply := func(points Points) {
+		var channels []string
+
+		for _, p := range points {
+			switch p.Type {
+			case PointTypeNotifyChannel:
+				if p.Text != "" {
+					channels = append(channels, p.Text)
+				}
+			case PointTypeMinSeverity:
+				if p.Text != "" {
+					ret.MinSeverity = p.Text
+				}
+			case PointTypeQuietStart:
+				if p.Text != "" {
+					ret.QuietStart = p.Text
+				}
+			case PointTypeQuietEnd:
+				if p.Text != "" {
+					ret.QuietEnd = p.Text
+				}
+			case PointTypeTimezone:
+				if p.Text != "" {
+					ret.Timezone = p.Text
+				}
+			case PointTypeQuietSeverity:
+				if p.Text != "" {
+					ret.QuietSeverity = p.Text
+				}
+			case PointTypeOptOut:
+				ret.OptOut = FloatToBool(p.Value)
+			}
+		}
+
+		if len(channels) > 0 {
+			ret.Channels = channels
+		}
+	}
+
+	apply(ne.Points)
+	apply(ne.EdgePoints)
+
+	return ret
+}
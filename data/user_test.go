@@ -0,0 +1,100 @@
+package data
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNodeEdgeToNotifyPrefs(t *testing.T) {
+	ne := NodeEdge{
+		Points: Points{
+			{Type: PointTypeNotifyChannel, Index: 0, Text: PointValueSMS},
+			{Type: PointTypeNotifyChannel, Index: 1, Text: PointValueEmail},
+			{Type: PointTypeMinSeverity, Text: PointValueWarning},
+			{Type: PointTypeQuietStart, Text: "22:00"},
+			{Type: PointTypeQuietEnd, Text: "7:00"},
+			{Type: PointTypeTimezone, Text: "America/Chicago"},
+		},
+	}
+
+	exp := NotifyPrefs{
+		Channels:    []string{PointValueSMS, PointValueEmail},
+		MinSeverity: PointValueWarning,
+		QuietStart:  "22:00",
+		QuietEnd:    "7:00",
+		Timezone:    "America/Chicago",
+	}
+
+	prefs := NodeEdgeToNotifyPrefs(ne)
+	if !reflect.DeepEqual(prefs, exp) {
+		t.Errorf("expected %+v, got %+v", exp, prefs)
+	}
+
+	// edge points override the user node, blank text is not set
+	ne.EdgePoints = Points{
+		{Type: PointTypeNotifyChannel, Text: PointValueEmail},
+		{Type: PointTypeMinSeverity, Text: PointValueCritical},
+		{Type: PointTypeQuietStart, Text: ""},
+		{Type: PointTypeOptOut, Value: 1},
+	}
+
+	exp.Channels = []string{PointValueEmail}
+	exp.MinSeverity = PointValueCritical
+	exp.OptOut = true
+
+	prefs = NodeEdgeToNotifyPrefs(ne)
+	if !reflect.DeepEqual(prefs, exp) {
+		t.Errorf("expected %+v, got %+v", exp, prefs)
+	}
+}
+
+func TestSeverityLevel(t *testing.T) {
+	if !(SeverityLevel(PointValueInfo) < SeverityLevel(PointValueWarning) &&
+		SeverityLevel(PointValueWarning) < SeverityLevel(PointValueCritical)) {
+		t.Error("severities are not ordered")
+	}
+
+	if SeverityLevel("") != SeverityLevel(PointValueWarning) {
+		t.Error("blank severity should be warning")
+	}
+}
+
+func TestPassword(t *testing.T) {
+	hash, err := HashPassword("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if hash == "secret" || !PasswordHashed(hash) || PasswordHashed("secret") {
+		t.Error("password not hashed")
+	}
+
+	if !CheckPassword(hash, "secret") || CheckPassword(hash, "Secret") {
+		t.Error("wrong password check")
+	}
+
+	// hashes are salted
+	hash2, _ := HashPassword("secret")
+	if hash2 == hash {
+		t.Error("hashes are not salted")
+	}
+
+	if CheckPassword("secret", "secret") {
+		t.Error("plain text should not match")
+	}
+}
+
+func TestUserPassChange(t *testing.T) {
+	u := User{ID: "u1", Email: "admin@admin.com", Pass: "hash", PassChange: true}
+
+	n := u.ToNode()
+	n.ID = u.ID
+
+	if u2, _ := NodeToUser(n); u2 != u {
+		t.Errorf("expected %+v, got %+v", u, u2)
+	}
+
+	if u2 := n.ToUser(); u2 != u {
+		t.Errorf("expected %+v, got %+v", u, u2)
+	}
+}
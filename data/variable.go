@@ -0,0 +1,126 @@
+package data
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/simpleiot/simpleiot/expr"
+)
+
+// VariableInput maps a point in another node to a name that can be used in
+// a variable expression
+type VariableInput struct {
+	Name       string
+	NodeID     string
+	PointType  string
+	PointIndex int
+}
+
+// Variable is a node whose value is computed from an expression over points
+// in other nodes. The result is stored in the value point of the variable
+// node so that it can be used like any other sensor value.
+type Variable struct {
+	ID           string
+	Description  string
+	VariableType string
+	Expression   string
+	Value        float64
+	Inputs       []VariableInput
+	// expression parsed when the variable is built from a node
+	compiled *expr.Expr
+}
+
+// NodeToVariable converts a node to a variable. Inputs are specified with
+// indexed points, and inputs without a name are ignored.
+func NodeToVariable(node NodeEdge) (*Variable, error) {
+	ret := &Variable{ID: node.ID}
+
+	inputs := make(map[int]*VariableInput)
+
+	input := func(index int) *VariableInput {
+		in, ok := inputs[index]
+		if !ok {
+			in = &VariableInput{}
+			inputs[index] = in
+		}
+		return in
+	}
+
+	for _, p := range node.Points {
+		switch p.Type {
+		case PointTypeDescription:
+			ret.Description = p.Text
+		case PointTypeVariableType:
+			ret.VariableType = p.Text
+		case PointTypeExpression:
+			ret.Expression = p.Text
+		case PointTypeValue:
+			ret.Value = p.Value
+		case PointTypeInputName:
+			input(p.Index).Name = p.Text
+		case PointTypeID:
+			input(p.Index).NodeID = p.Text
+		case PointTypePointType:
+			input(p.Index).PointType = p.Text
+		case PointTypePointIndex:
+			input(p.Index).PointIndex = int(p.Value)
+		}
+	}
+
+	indexes := make([]int, 0, len(inputs))
+	for i := range inputs {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+
+	for _, i := range indexes {
+		if inputs[i].Name != "" {
+			ret.Inputs = append(ret.Inputs, *inputs[i])
+		}
+	}
+
+	// invalid expressions are reported by Eval
+	ret.compiled, _ = expr.Parse(ret.Expression)
+
+	return ret, nil
+}
+
+// IsInput returns true if a point in a node is an input to the variable
+func (v *Variable) IsInput(nodeID string, p Point) bool {
+	for _, in := range v.Inputs {
+		if in.NodeID == nodeID && in.PointType == p.Type &&
+			in.PointIndex == p.Index {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Eval computes the variable value from input values keyed by input name.
+// The result of onOff variables is 1 if the expression is non-zero.
+func (v *Variable) Eval(values map[string]float64) (float64, error) {
+	if v.Expression == "" {
+		return 0, fmt.Errorf("variable %v does not have an expression", v.ID)
+	}
+
+	e := v.compiled
+	if e == nil {
+		var err error
+		e, err = expr.Parse(v.Expression)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	ret, err := e.Eval(values)
+	if err != nil {
+		return 0, err
+	}
+
+	if v.VariableType == PointValueOnOff {
+		ret = BoolToFloat(ret != 0)
+	}
+
+	return ret, nil
+}
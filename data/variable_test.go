@@ -0,0 +1,85 @@
+package data
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNodeToVariable(t *testing.T) {
+	node := NodeEdge{
+		ID:   "1",
+		Type: NodeTypeVariable,
+		Points: Points{
+			{Type: PointTypeExpression, Text: "a + b"},
+			{Type: PointTypeValue, Value: 5},
+			{Type: PointTypeInputName, Index: 1, Text: "b"},
+			{Type: PointTypeID, Index: 1, Text: "node2"},
+			{Type: PointTypePointType, Index: 1, Text: PointTypeValue},
+			{Type: PointTypePointIndex, Index: 1, Value: 2},
+			{Type: PointTypeInputName, Index: 0, Text: "a"},
+			{Type: PointTypeID, Index: 0, Text: "node1"},
+			{Type: PointTypePointType, Index: 0, Text: PointTypeValue},
+			// inputs without a name are ignored
+			{Type: PointTypeID, Index: 2, Text: "node3"},
+		},
+	}
+
+	v, err := NodeToVariable(node)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v.Expression != "a + b" || v.Value != 5 {
+		t.Errorf("wrong variable: %+v", v)
+	}
+
+	if v.compiled == nil || v.compiled.String() != "a + b" {
+		t.Error("expression not compiled")
+	}
+
+	exp := []VariableInput{
+		{Name: "a", NodeID: "node1", PointType: PointTypeValue},
+		{Name: "b", NodeID: "node2", PointType: PointTypeValue, PointIndex: 2},
+	}
+
+	if !reflect.DeepEqual(v.Inputs, exp) {
+		t.Errorf("expected inputs %+v, got %+v", exp, v.Inputs)
+	}
+
+	if !v.IsInput("node2", Point{Type: PointTypeValue, Index: 2}) {
+		t.Error("expected point to be an input")
+	}
+
+	if v.IsInput("node2", Point{Type: PointTypeValue}) {
+		t.Error("point with wrong index should not be an input")
+	}
+}
+
+func TestVariableEval(t *testing.T) {
+	v := Variable{Expression: "a * 2"}
+
+	val, err := v.Eval(map[string]float64{"a": 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if val != 6 {
+		t.Error("expected 6, got ", val)
+	}
+
+	v.VariableType = PointValueOnOff
+
+	val, err = v.Eval(map[string]float64{"a": 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if val != 1 {
+		t.Error("expected onOff value of 1, got ", val)
+	}
+
+	_, err = v.Eval(map[string]float64{})
+	if err == nil {
+		t.Error("expected error for missing input")
+	}
+}
@@ -0,0 +1,109 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/simpleiot/simpleiot/data"
+	"github.com/simpleiot/simpleiot/nats"
+)
+
+// alarmAck acknowledges, shelves, or unshelves the alarm of a rule node. The
+// user is recorded in the ackUser point of the rule, and an event is logged.
+func (nh *NatsHandler) alarmAck(nodeID string, ack data.AlarmAck) error {
+	if ack.UserID == "" {
+		return errors.New("user ID must be set")
+	}
+
+	node, err := nh.db.node(nodeID)
+	if err != nil {
+		return err
+	}
+
+	if node.Type != data.NodeTypeRule {
+		return errors.New("node is not a rule")
+	}
+
+	rule, err := data.NodeToRule(data.NodeEdge{ID: node.ID, Points: node.Points},
+		nil, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	userDesc := ack.UserID
+	user, err := nh.db.node(ack.UserID)
+	if err == nil {
+		userDesc = user.Desc()
+	}
+
+	now := time.Now()
+	desc := node.Desc()
+
+	var points data.Points
+	var e data.Event
+
+	switch ack.Action {
+	case data.AlarmActionAck:
+		state, err := data.AlarmAckState(rule.AlarmState, rule.Active)
+		if err != nil {
+			return err
+		}
+
+		points = data.Points{
+			{Time: now, Type: data.PointTypeAlarmState, Text: state},
+			{Time: now, Type: data.PointTypeAckUser, Text: ack.UserID},
+		}
+
+		e = data.Event{
+			Type:    data.EventTypeAlarmAck,
+			Message: fmt.Sprintf("%v acknowledged by %v", desc, userDesc),
+		}
+
+	case data.AlarmActionShelve:
+		if !ack.ShelveUntil.After(now) {
+			return errors.New("shelve time must be in the future")
+		}
+
+		until := ack.ShelveUntil.Format(time.RFC3339)
+
+		points = data.Points{
+			{Time: now, Type: data.PointTypeAlarmState, Text: data.PointValueAlarmShelved},
+			{Time: now, Type: data.PointTypeAckUser, Text: ack.UserID},
+			{Time: now, Type: data.PointTypeShelveUntil, Text: until},
+		}
+
+		e = data.Event{
+			Type:    data.EventTypeAlarmShelve,
+			Message: fmt.Sprintf("%v shelved by %v until %v", desc, userDesc, until),
+		}
+
+	case data.AlarmActionUnshelve:
+		if rule.AlarmState != data.PointValueAlarmShelved {
+			return errors.New("alarm is not shelved")
+		}
+
+		// the rule scheduler moves the alarm out of the shelved state
+		// once the shelve time has expired
+		points = data.Points{
+			{Time: now, Type: data.PointTypeShelveUntil, Text: now.Format(time.RFC3339)},
+		}
+
+		e = data.Event{
+			Type:    data.EventTypeAlarmUnshelve,
+			Message: fmt.Sprintf("%v unshelved by %v", desc, userDesc),
+		}
+
+	default:
+		return fmt.Errorf("unknown alarm action: %v", ack.Action)
+	}
+
+	err = nats.SendNodePoints(nh.Nc, nodeID, points, true)
+	if err != nil {
+		return err
+	}
+
+	e.Level = data.EventLevelInfo
+
+	return nats.SendEvent(nh.Nc, nodeID, e, false)
+}
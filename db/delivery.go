@@ -0,0 +1,388 @@
+package db
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/genjidb/genji/document"
+	"github.com/genjidb/genji/types"
+	"github.com/google/uuid"
+	natsgo "github.com/nats-io/nats.go"
+	"github.com/simpleiot/simpleiot/data"
+	"github.com/simpleiot/simpleiot/msg"
+	"github.com/simpleiot/simpleiot/nats"
+)
+
+// deliveryRecord is the record stored in the deliveries table. The message
+// is flattened so that deliveries can be queried by user and notification.
+// Times are stored as Unix nanoseconds.
+type deliveryRecord struct {
+	ID             string
+	MessageID      string
+	UserID         string
+	ParentID       string
+	NotificationID string
+	Email          string
+	Phone          string
+	Subject        string
+	Message        string
+	HTML           string
+	RuleID         string
+	ServiceID      string
+	Service        string
+	Address        string
+	State          string
+	Attempts       int
+	Error          string
+	Created        int64
+	Updated        int64
+}
+
+func newDeliveryRecord(d data.Delivery) deliveryRecord {
+	return deliveryRecord{
+		ID:             d.ID,
+		MessageID:      d.Message.ID,
+		UserID:         d.Message.UserID,
+		ParentID:       d.Message.ParentID,
+		NotificationID: d.Message.NotificationID,
+		Email:          d.Message.Email,
+		Phone:          d.Message.Phone,
+		Subject:        d.Message.Subject,
+		Message:        d.Message.Message,
+		HTML:           d.Message.HTML,
+		RuleID:         d.Message.RuleID,
+		ServiceID:      d.ServiceID,
+		Service:        d.Service,
+		Address:        d.Address,
+		State:          d.State,
+		Attempts:       d.Attempts,
+		Error:          d.Error,
+		Created:        d.Created.UnixNano(),
+		Updated:        d.Updated.UnixNano(),
+	}
+}
+
+func (dr deliveryRecord) toDelivery() data.Delivery {
+	return data.Delivery{
+		ID: dr.ID,
+		Message: data.Message{
+			ID:             dr.MessageID,
+			UserID:         dr.UserID,
+			ParentID:       dr.ParentID,
+			NotificationID: dr.NotificationID,
+			Email:          dr.Email,
+			Phone:          dr.Phone,
+			Subject:        dr.Subject,
+			Message:        dr.Message,
+			HTML:           dr.HTML,
+			RuleID:         dr.RuleID,
+		},
+		ServiceID: dr.ServiceID,
+		Service:   dr.Service,
+		Address:   dr.Address,
+		State:     dr.State,
+		Attempts:  dr.Attempts,
+		Error:     dr.Error,
+		Created:   time.Unix(0, dr.Created),
+		Updated:   time.Unix(0, dr.Updated),
+	}
+}
+
+// deliveryWrite inserts or replaces a delivery record
+func (gen *Db) deliveryWrite(d data.Delivery) error {
+	err := gen.store.Exec(`insert into deliveries values ? on conflict do replace`,
+		newDeliveryRecord(d))
+
+	if err != nil {
+		return fmt.Errorf("Error writing delivery: %w", err)
+	}
+
+	return nil
+}
+
+// deliveries returns message deliveries sorted by the time they were created
+func (gen *Db) deliveries(q data.DeliveryQuery) ([]data.Delivery, error) {
+	var where []string
+	var args []interface{}
+
+	if q.UserID != "" {
+		where = append(where, `userid = ?`)
+		args = append(args, q.UserID)
+	}
+
+	if q.NotificationID != "" {
+		where = append(where, `notificationid = ?`)
+		args = append(args, q.NotificationID)
+	}
+
+	if q.State != "" {
+		where = append(where, `state = ?`)
+		args = append(args, q.State)
+	}
+
+	query := `select * from deliveries`
+
+	if len(where) > 0 {
+		query += ` where ` + strings.Join(where, ` and `)
+	}
+
+	query += ` order by created`
+
+	if q.Limit > 0 {
+		query += fmt.Sprintf(` limit %v`, q.Limit)
+	}
+
+	var ret []data.Delivery
+
+	res, err := gen.store.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer res.Close()
+
+	err = res.Iterate(func(d types.Document) error {
+		var dr deliveryRecord
+		err := document.StructScan(d, &dr)
+		if err != nil {
+			return err
+		}
+
+		ret = append(ret, dr.toDelivery())
+		return nil
+	})
+
+	return ret, err
+}
+
+// deliveryMaxBackoff is the maximum delay between delivery retries
+var deliveryMaxBackoff = 5 * time.Minute
+
+// newMsgService creates message service backends. It is replaced in tests.
+var newMsgService = msg.NewService
+
+// msgServiceEntry is a message service backend and the configuration it was
+// created with
+type msgServiceEntry struct {
+	svc     data.MsgService
+	service msg.Service
+}
+
+// msgService returns the backend for a message service node. Backends are
+// reused until the configuration of the node changes.
+func (nh *NatsHandler) msgService(svc data.MsgService) (msg.Service, error) {
+	nh.msgServiceLock.Lock()
+	defer nh.msgServiceLock.Unlock()
+
+	// the error point changes with deliveries and is not configuration
+	svc.Error = ""
+
+	e, ok := nh.msgServices[svc.ID]
+	if ok && reflect.DeepEqual(e.svc, svc) {
+		return e.service, nil
+	}
+
+	service, err := newMsgService(svc)
+	if err != nil {
+		return nil, err
+	}
+
+	nh.msgServices[svc.ID] = msgServiceEntry{svc: svc, service: service}
+
+	return service, nil
+}
+
+// queueDelivery records a message delivery as queued and starts delivering
+// it. The message is not queued if the service can't deliver it.
+func (nh *NatsHandler) queueDelivery(svc data.MsgService, message data.Message) {
+	service, err := nh.msgService(svc)
+	if err != nil {
+		log.Printf("Error creating msg service %v: %v\n", svc.ID, err)
+		return
+	}
+
+	address := service.Address(message)
+	if address == "" {
+		return
+	}
+
+	now := time.Now()
+
+	d := data.Delivery{
+		ID:        uuid.New().String(),
+		Message:   message,
+		ServiceID: svc.ID,
+		Service:   svc.Service,
+		Address:   address,
+		State:     data.DeliveryQueued,
+		Created:   now,
+		Updated:   now,
+	}
+
+	err = nh.db.deliveryWrite(d)
+	if err != nil {
+		log.Println("Error recording delivery: ", err)
+	}
+
+	go nh.deliver(service, svc, d)
+}
+
+// deliver sends a message and retries with backoff until it is sent or the
+// retries of the service are used. The delivery record is updated after
+// every attempt, and the result is recorded in the error point of the
+// service.
+func (nh *NatsHandler) deliver(service msg.Service, svc data.MsgService, d data.Delivery) {
+	var err error
+
+	for {
+		d.Attempts++
+		err = service.Deliver(d.Message)
+		d.Updated = time.Now()
+
+		if err == nil {
+			d.State = data.DeliverySent
+			d.Error = ""
+		} else {
+			log.Printf("Error delivering message to %v (attempt %v): %v\n",
+				d.Address, d.Attempts, err)
+			d.Error = err.Error()
+			if d.Attempts > svc.Retries {
+				d.State = data.DeliveryFailed
+			}
+		}
+
+		errWrite := nh.db.deliveryWrite(d)
+		if errWrite != nil {
+			log.Println("Error recording delivery: ", errWrite)
+		}
+
+		if d.State != data.DeliveryQueued {
+			break
+		}
+
+		time.Sleep(nats.ExpBackoff(d.Attempts-1, deliveryMaxBackoff))
+	}
+
+	nh.msgServiceError(svc, err)
+}
+
+// resumeDeliveries continues deliveries that were queued when SIOT stopped.
+// If the message service no longer exists, the delivery fails.
+func (nh *NatsHandler) resumeDeliveries() error {
+	deliveries, err := nh.db.deliveries(data.DeliveryQuery{State: data.DeliveryQueued})
+	if err != nil {
+		return err
+	}
+
+	for _, d := range deliveries {
+		svc, service, err := nh.deliveryService(d)
+		if err != nil {
+			d.State = data.DeliveryFailed
+			d.Error = err.Error()
+			d.Updated = time.Now()
+
+			err := nh.db.deliveryWrite(d)
+			if err != nil {
+				log.Println("Error recording delivery: ", err)
+			}
+			continue
+		}
+
+		go nh.deliver(service, svc, d)
+	}
+
+	return nil
+}
+
+// deliveryService returns the message service of a delivery
+func (nh *NatsHandler) deliveryService(d data.Delivery) (data.MsgService, msg.Service, error) {
+	node, err := nh.db.node(d.ServiceID)
+	if err != nil {
+		return data.MsgService{}, nil, fmt.Errorf("msg service %v not found: %w",
+			d.ServiceID, err)
+	}
+
+	svc, err := data.NodeToMsgService(*node)
+	if err != nil {
+		return data.MsgService{}, nil, err
+	}
+
+	service, err := nh.msgService(svc)
+	if err != nil {
+		return data.MsgService{}, nil, err
+	}
+
+	return svc, service, nil
+}
+
+// msgServiceError records the result of the last delivery by a message
+// service in its error point. The point is only sent if the error changes.
+func (nh *NatsHandler) msgServiceError(svc data.MsgService, err error) {
+	errText := ""
+	if err != nil {
+		errText = err.Error()
+	}
+
+	if errText == svc.Error {
+		return
+	}
+
+	err = nats.SendNodePoint(nh.Nc, svc.ID, data.Point{
+		Type: data.PointTypeError,
+		Time: time.Now(),
+		Text: errText,
+	}, false)
+	if err != nil {
+		log.Println("Error sending msg service error point: ", err)
+	}
+}
+
+// handleNodeDeliveries returns the message deliveries to a user. If the
+// node ID is "root", deliveries to all users are returned.
+func (nh *NatsHandler) handleNodeDeliveries(msg *natsgo.Msg) {
+	var query data.DeliveryQuery
+	var err error
+	var deliveries []data.Delivery
+	var resp []byte
+
+	chunks := strings.Split(msg.Subject, ".")
+	if len(chunks) < 3 {
+		err = fmt.Errorf("Error in message subject: %v", msg.Subject)
+		goto handleNodeDeliveriesDone
+	}
+
+	query, err = data.PbDecodeDeliveryQuery(msg.Data)
+	if err != nil {
+		err = fmt.Errorf("Error decoding delivery request params: %v", err)
+		goto handleNodeDeliveriesDone
+	}
+
+	if chunks[1] != "root" {
+		query.UserID = chunks[1]
+	}
+
+	deliveries, err = nh.db.deliveries(query)
+	if err != nil {
+		err = fmt.Errorf("NATS: Error getting deliveries for node %v: %v", chunks[1], err)
+	}
+
+handleNodeDeliveriesDone:
+	resp, err = data.DeliveriesToPb(deliveries, err)
+	if err != nil {
+		// reply with the error so the requester does not wait for a timeout
+		resp, err = data.DeliveriesToPb(nil,
+			fmt.Errorf("Error encoding deliveries: %v", err))
+		if err != nil {
+			log.Println("Error encoding deliveries error: ", err)
+			return
+		}
+	}
+
+	err = nh.Nc.Publish(msg.Reply, resp)
+	if err != nil {
+		log.Println("NATS: Error publishing response to node deliveries request: ", err)
+	}
+}
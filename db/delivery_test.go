@@ -0,0 +1,184 @@
+package db
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/simpleiot/simpleiot/data"
+	"github.com/simpleiot/simpleiot/msg"
+)
+
+func TestDeliveries(t *testing.T) {
+	db, err := NewDb(StoreTypeMemory, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2021, time.September, 1, 0, 0, 0, 0, time.UTC)
+
+	for i, d := range []data.Delivery{
+		{ID: "1", Message: data.Message{UserID: "bob", NotificationID: "n1"},
+			State: data.DeliverySent},
+		{ID: "2", Message: data.Message{UserID: "amy", NotificationID: "n1"},
+			State: data.DeliveryQueued},
+		{ID: "3", Message: data.Message{UserID: "bob", NotificationID: "n2"},
+			State: data.DeliveryQueued},
+	} {
+		d.Created = start.Add(time.Duration(i) * time.Minute)
+		d.Updated = d.Created
+		err := db.deliveryWrite(d)
+		if err != nil {
+			t.Fatal("Error writing delivery: ", err)
+		}
+	}
+
+	// update a delivery after an attempt
+	err = db.deliveryWrite(data.Delivery{ID: "3",
+		Message: data.Message{UserID: "bob", NotificationID: "n2", Subject: "low"},
+		State:   data.DeliveryFailed, Attempts: 4, Error: "timeout",
+		Created: start.Add(2 * time.Minute), Updated: start.Add(time.Hour)})
+	if err != nil {
+		t.Fatal("Error updating delivery: ", err)
+	}
+
+	ids := func(deliveries []data.Delivery) string {
+		ret := ""
+		for _, d := range deliveries {
+			ret += d.ID
+		}
+		return ret
+	}
+
+	tests := []struct {
+		q   data.DeliveryQuery
+		exp string
+	}{
+		{data.DeliveryQuery{}, "123"},
+		{data.DeliveryQuery{UserID: "bob"}, "13"},
+		{data.DeliveryQuery{NotificationID: "n1"}, "12"},
+		{data.DeliveryQuery{UserID: "bob", NotificationID: "n1"}, "1"},
+		{data.DeliveryQuery{State: data.DeliveryQueued}, "2"},
+		{data.DeliveryQuery{Limit: 2}, "12"},
+	}
+
+	for _, test := range tests {
+		deliveries, err := db.deliveries(test.q)
+		if err != nil {
+			t.Fatal("Error getting deliveries: ", err)
+		}
+
+		if ids(deliveries) != test.exp {
+			t.Errorf("query %+v: expected %v, got %v", test.q, test.exp, ids(deliveries))
+		}
+	}
+
+	deliveries, err := db.deliveries(data.DeliveryQuery{UserID: "bob", NotificationID: "n2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := deliveries[0]
+	if d.State != data.DeliveryFailed || d.Attempts != 4 || d.Error != "timeout" ||
+		d.Message.Subject != "low" || !d.Updated.Equal(start.Add(time.Hour)) {
+		t.Errorf("delivery not updated: %+v", d)
+	}
+}
+
+func TestDeliver(t *testing.T) {
+	db, err := NewDb(StoreTypeMemory, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock := msg.NewMock()
+	created := 0
+
+	newMsgServiceSave := newMsgService
+	maxBackoffSave := deliveryMaxBackoff
+	defer func() {
+		newMsgService = newMsgServiceSave
+		deliveryMaxBackoff = maxBackoffSave
+	}()
+
+	newMsgService = func(svc data.MsgService) (msg.Service, error) {
+		created++
+		return mock, nil
+	}
+
+	deliveryMaxBackoff = 0
+
+	// the error point is not sent without a NATS connection, which is
+	// logged and ignored
+	nh := &NatsHandler{db: db, msgServices: make(map[string]msgServiceEntry)}
+
+	svc := data.MsgService{ID: "svc", Service: data.PointValueMock, Retries: 1}
+	m := data.Message{ID: "m1", UserID: "bob", NotificationID: "n1",
+		Email: "bob@example.com", Message: "Tank 3 is low"}
+
+	mock.SetError(errors.New("service down"))
+	nh.queueDelivery(svc, m)
+
+	get := func(state string) data.Delivery {
+		start := time.Now()
+		for time.Since(start) < 5*time.Second {
+			deliveries, err := db.deliveries(data.DeliveryQuery{UserID: "bob", State: state})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(deliveries) > 0 {
+				return deliveries[len(deliveries)-1]
+			}
+
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		t.Fatalf("no %v delivery", state)
+		return data.Delivery{}
+	}
+
+	d := get(data.DeliveryFailed)
+	if d.Attempts != 2 || d.Error != "service down" || d.Address != "bob@example.com" ||
+		d.ServiceID != "svc" || !reflect.DeepEqual(d.Message, m) {
+		t.Errorf("wrong failed delivery: %+v", d)
+	}
+
+	mock.SetError(nil)
+	svc.Error = "service down"
+	nh.queueDelivery(svc, m)
+
+	d = get(data.DeliverySent)
+	if d.Attempts != 1 || d.Error != "" {
+		t.Errorf("wrong sent delivery: %+v", d)
+	}
+
+	if len(mock.Messages()) != 1 {
+		t.Error("message not delivered")
+	}
+
+	// the backend is reused until the configuration changes
+	if created != 1 {
+		t.Error("backend should be reused, created: ", created)
+	}
+
+	svc.Retries = 5
+	nh.queueDelivery(svc, m)
+
+	if created != 2 {
+		t.Error("backend should be created after config change, created: ", created)
+	}
+
+	// messages the service can't deliver are not queued
+	nh.queueDelivery(svc, data.Message{ID: "m2", UserID: "amy"})
+
+	deliveries, err := db.deliveries(data.DeliveryQuery{UserID: "amy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(deliveries) != 0 {
+		t.Errorf("message without address should not be queued: %+v", deliveries)
+	}
+}
@@ -0,0 +1,164 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/genjidb/genji/document"
+	"github.com/genjidb/genji/types"
+	"github.com/google/uuid"
+	"github.com/simpleiot/simpleiot/data"
+)
+
+// eventRecord is the record stored in the events table. Time is stored
+// as Unix nanoseconds in an INTEGER field so that it can be range queried.
+type eventRecord struct {
+	ID      string
+	NodeID  string
+	Time    int64
+	Type    int
+	Level   int
+	Message string
+}
+
+func (er eventRecord) toEvent() data.Event {
+	return data.Event{
+		ID:      er.ID,
+		NodeID:  er.NodeID,
+		Time:    time.Unix(0, er.Time),
+		Type:    data.EventType(er.Type),
+		Level:   data.EventLevel(er.Level),
+		Message: er.Message,
+	}
+}
+
+// eventWrite records an event in the events table. An ID and time are
+// assigned if not set.
+func (gen *Db) eventWrite(e data.Event) (data.Event, error) {
+	if e.ID == "" {
+		e.ID = uuid.New().String()
+	}
+
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	err := gen.store.Exec(`insert into events values ?`, eventRecord{
+		ID:      e.ID,
+		NodeID:  e.NodeID,
+		Time:    e.Time.UnixNano(),
+		Type:    int(e.Type),
+		Level:   int(e.Level),
+		Message: e.Message,
+	})
+
+	if err != nil {
+		return e, fmt.Errorf("Error inserting event: %w", err)
+	}
+
+	return e, nil
+}
+
+// events returns events for a node and all of its descendants sorted by
+// time
+func (gen *Db) events(nodeID string, q data.EventQuery) ([]data.Event, error) {
+	desc, err := gen.nodeDescendents(nodeID, "", true, false)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []string{nodeID}
+	seen := map[string]bool{nodeID: true}
+
+	for _, d := range desc {
+		if !seen[d.ID] {
+			seen[d.ID] = true
+			ids = append(ids, d.ID)
+		}
+	}
+
+	var ret []data.Event
+
+	for _, id := range ids {
+		events, err := gen.nodeEvents(id, q)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, events...)
+	}
+
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].Time.Before(ret[j].Time)
+	})
+
+	if q.Limit > 0 && len(ret) > q.Limit {
+		ret = ret[:q.Limit]
+	}
+
+	return ret, nil
+}
+
+// nodeEvents returns events recorded against a single node
+func (gen *Db) nodeEvents(nodeID string, q data.EventQuery) ([]data.Event, error) {
+	query := `select * from events where nodeid = ?`
+	args := []interface{}{nodeID}
+
+	if q.Type != 0 {
+		query += ` and type = ?`
+		args = append(args, int(q.Type))
+	}
+
+	if q.Level != 0 {
+		query += ` and level <= ?`
+		args = append(args, int(q.Level))
+	}
+
+	if !q.Start.IsZero() {
+		query += ` and time >= ?`
+		args = append(args, q.Start.UnixNano())
+	}
+
+	if !q.End.IsZero() {
+		query += ` and time < ?`
+		args = append(args, q.End.UnixNano())
+	}
+
+	query += ` order by time`
+
+	if q.Limit > 0 {
+		query += fmt.Sprintf(` limit %v`, q.Limit)
+	}
+
+	var ret []data.Event
+
+	res, err := gen.store.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer res.Close()
+
+	err = res.Iterate(func(d types.Document) error {
+		var er eventRecord
+		err := document.StructScan(d, &er)
+		if err != nil {
+			return err
+		}
+
+		ret = append(ret, er.toEvent())
+		return nil
+	})
+
+	return ret, err
+}
+
+// eventExpire deletes events for a node older than the retention time
+func (gen *Db) eventExpire(nodeID string, retention time.Duration, now time.Time) error {
+	if retention <= 0 {
+		return nil
+	}
+
+	return gen.store.Exec(`delete from events where nodeid = ? and time < ?`,
+		nodeID, now.Add(-retention).UnixNano())
+}
@@ -0,0 +1,140 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/simpleiot/simpleiot/data"
+	"github.com/simpleiot/simpleiot/nats"
+	"github.com/simpleiot/simpleiot/node"
+	"github.com/simpleiot/simpleiot/system"
+)
+
+func TestEvents(t *testing.T) {
+	db, err := NewDb(StoreTypeMemory, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newNode := func(id, parent string) {
+		_, err := db.nodePoints(id, data.Points{
+			{Type: data.PointTypeNodeType, Text: data.NodeTypeDevice}})
+		if err != nil {
+			t.Fatal("Error creating node: ", err)
+		}
+
+		_, err = db.edgePoints(id, parent,
+			data.Points{{Type: data.PointTypeTombstone, Value: 0}})
+		if err != nil {
+			t.Fatal("Error creating edge: ", err)
+		}
+	}
+
+	newNode("root", "")
+	newNode("site", "root")
+	newNode("dev", "site")
+	newNode("other", "root")
+
+	start := time.Date(2021, time.September, 1, 0, 0, 0, 0, time.UTC)
+
+	for i, e := range []data.Event{
+		{NodeID: "site", Type: data.EventTypeStartApp, Level: data.EventLevelInfo},
+		{NodeID: "dev", Type: data.EventTypeDeviceOffline, Level: data.EventLevelFault},
+		{NodeID: "other", Type: data.EventTypeDeviceOffline, Level: data.EventLevelFault},
+		{NodeID: "dev", Type: data.EventTypeDeviceOnline, Level: data.EventLevelInfo},
+		{NodeID: "site", Type: data.EventTypeRuleActive, Level: data.EventLevelDebug},
+	} {
+		e.Time = start.Add(time.Duration(i) * time.Minute)
+		_, err := db.eventWrite(e)
+		if err != nil {
+			t.Fatal("Error writing event: ", err)
+		}
+	}
+
+	tests := []struct {
+		desc   string
+		nodeID string
+		query  data.EventQuery
+		exp    []data.EventType
+	}{
+		{"subtree", "site", data.EventQuery{}, []data.EventType{
+			data.EventTypeStartApp, data.EventTypeDeviceOffline,
+			data.EventTypeDeviceOnline, data.EventTypeRuleActive}},
+		{"leaf", "dev", data.EventQuery{}, []data.EventType{
+			data.EventTypeDeviceOffline, data.EventTypeDeviceOnline}},
+		{"level", "site", data.EventQuery{Level: data.EventLevelInfo}, []data.EventType{
+			data.EventTypeStartApp, data.EventTypeDeviceOffline,
+			data.EventTypeDeviceOnline}},
+		{"type", "root", data.EventQuery{Type: data.EventTypeDeviceOffline},
+			[]data.EventType{data.EventTypeDeviceOffline, data.EventTypeDeviceOffline}},
+		{"range", "site", data.EventQuery{
+			Start: start.Add(time.Minute),
+			End:   start.Add(4 * time.Minute),
+		}, []data.EventType{data.EventTypeDeviceOffline, data.EventTypeDeviceOnline}},
+		{"start", "dev", data.EventQuery{Start: start.Add(2 * time.Minute)},
+			[]data.EventType{data.EventTypeDeviceOnline}},
+		{"limit", "site", data.EventQuery{Limit: 2}, []data.EventType{
+			data.EventTypeStartApp, data.EventTypeDeviceOffline}},
+	}
+
+	for _, test := range tests {
+		events, err := db.events(test.nodeID, test.query)
+		if err != nil {
+			t.Errorf("%v: error getting events: %v", test.desc, err)
+			continue
+		}
+
+		if len(events) != len(test.exp) {
+			t.Errorf("%v: expected %v events, got %v", test.desc, len(test.exp), len(events))
+			continue
+		}
+
+		for i := range events {
+			if events[i].Type != test.exp[i] {
+				t.Errorf("%v: expected type %v, got %v", test.desc, test.exp[i], events[i].Type)
+			}
+		}
+	}
+
+	err = db.eventExpire("dev", time.Minute, start.Add(3*time.Minute))
+	if err != nil {
+		t.Fatal("Error expiring events: ", err)
+	}
+
+	events, err := db.events("dev", data.EventQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) != 1 || !events[0].Time.Equal(start.Add(3*time.Minute)) {
+		t.Error("events not expired correctly: ", events)
+	}
+}
+
+func TestStartEvents(t *testing.T) {
+	if _, err := system.BootID(); err != nil {
+		t.Skip("boot ID not supported: ", err)
+	}
+
+	ti := newTestInstance(t)
+
+	// the app is started again without restarting the system
+	err := node.NewManger(ti.nc).Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for typ, exp := range map[data.EventType]int{
+		data.EventTypeStartSystem: 1,
+		data.EventTypeStartApp:    2,
+	} {
+		events, err := nats.GetEvents(ti.nc, ti.root, data.EventQuery{Type: typ})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(events) != exp {
+			t.Errorf("expected %v events of type %v, got %v", exp, typ, len(events))
+		}
+	}
+}
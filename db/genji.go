@@ -116,12 +116,62 @@ func NewDb(storeType StoreType, dataDir string) (*Db, error) {
 		return nil, fmt.Errorf("Error creating idx_edge_down: %w", err)
 	}
 
+	// time and rollup must be typed as genji stores untyped numbers as
+	// doubles, which loses precision for nanosecond values
+	err = store.Exec(`CREATE TABLE IF NOT EXISTS history (time INTEGER, rollup INTEGER)`)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating history table: %w", err)
+	}
+
+	// genji range scans on composite indexes such as (nodeid, time) can
+	// return documents for other nodes, so only nodeid is indexed
+	err = store.Exec(`CREATE INDEX IF NOT EXISTS idx_history_nodeid ON history(nodeid)`)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating idx_history_nodeid: %w", err)
+	}
+
+	err = store.Exec(`CREATE TABLE IF NOT EXISTS rollups (id TEXT PRIMARY KEY, time INTEGER)`)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating rollups table: %w", err)
+	}
+
+	err = store.Exec(`CREATE TABLE IF NOT EXISTS events (time INTEGER, type INTEGER, level INTEGER)`)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating events table: %w", err)
+	}
+
+	err = store.Exec(`CREATE INDEX IF NOT EXISTS idx_events_nodeid ON events(nodeid)`)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating idx_events_nodeid: %w", err)
+	}
+
+	err = store.Exec(`CREATE TABLE IF NOT EXISTS deliveries (id TEXT PRIMARY KEY, attempts INTEGER, created INTEGER, updated INTEGER)`)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating deliveries table: %w", err)
+	}
+
+	err = store.Exec(`CREATE INDEX IF NOT EXISTS idx_deliveries_userid ON deliveries(userid)`)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating idx_deliveries_userid: %w", err)
+	}
+
+	err = store.Exec(`CREATE INDEX IF NOT EXISTS idx_deliveries_notificationid ON deliveries(notificationid)`)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating idx_deliveries_notificationid: %w", err)
+	}
+
+	err = store.Exec(`CREATE INDEX IF NOT EXISTS idx_deliveries_state ON deliveries(state)`)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating idx_deliveries_state: %w", err)
+	}
+
 	db := &Db{store: store}
 	return db, db.initialize()
 }
 
-// DBVersion for this version of siot
-var DBVersion = 1
+// DBVersion for this version of siot. Version 2 stores user passwords as
+// bcrypt hashes.
+var DBVersion = 2
 
 // initialize initializes the database with one user (admin)
 func (gen *Db) initialize() error {
@@ -135,6 +185,20 @@ func (gen *Db) initialize() error {
 			return fmt.Errorf("Error getting db meta data: %w", err)
 		}
 
+		if gen.meta.Version < 2 {
+			err := gen.migratePasswords()
+			if err != nil {
+				return fmt.Errorf("Error migrating passwords: %w", err)
+			}
+
+			gen.meta.Version = DBVersion
+
+			err = gen.store.Exec(`update meta set version = ?`, DBVersion)
+			if err != nil {
+				return fmt.Errorf("Error updating db version: %w", err)
+			}
+		}
+
 		return nil
 	}
 
@@ -376,14 +440,18 @@ func (gen *Db) txCalcHash(tx *genji.Tx, node *data.Node, upEdge data.Edge) ([]by
 	return upEdge.Hash, nil
 }
 
-func (gen *Db) edgePoints(nodeID, parentID string, points data.Points) error {
-	for _, p := range points {
-		if p.Time.IsZero() {
-			p.Time = time.Now()
+func (gen *Db) edgePoints(nodeID, parentID string, points data.Points) (data.Points, error) {
+	for i := range points {
+		if points[i].Time.IsZero() {
+			points[i].Time = time.Now()
 		}
 	}
 
-	return gen.store.Update(func(tx *genji.Tx) error {
+	origin := gen.rootNodeID()
+
+	var applied data.Points
+
+	err := gen.store.Update(func(tx *genji.Tx) error {
 		if parentID == "none" && gen.meta.RootID != "" && nodeID != gen.meta.RootID {
 			// a downstream node its root node edges, set up to rootID
 			parentID = gen.meta.RootID
@@ -424,8 +492,12 @@ func (gen *Db) edgePoints(nodeID, parentID string, points data.Points) error {
 			ne.up = append(ne.up, &edge)
 		}
 
+		stampPoints(origin, points, edge.Points)
+
 		for _, point := range points {
-			edge.Points.ProcessPoint(point)
+			if edge.Points.ProcessPoint(point) {
+				applied = append(applied, point)
+			}
 		}
 
 		sort.Sort(edge.Points)
@@ -442,20 +514,34 @@ func (gen *Db) edgePoints(nodeID, parentID string, points data.Points) error {
 
 		return nil
 	})
+
+	return applied, err
 }
 
 // nodePoints processes Points for a particular node
 // this function does the following:
+//   - stamps points created on this instance (points are modified in place)
 //   - updates the points in the node
 //   - updates hash in all upstream edges
-func (gen *Db) nodePoints(id string, points data.Points) error {
-	for _, p := range points {
-		if p.Time.IsZero() {
-			p.Time = time.Now()
+//
+// The points that were applied are returned. Points that are not newer
+// than the stored points are dropped.
+func (gen *Db) nodePoints(id string, points data.Points) (data.Points, error) {
+	for i := range points {
+		if points[i].Time.IsZero() {
+			points[i].Time = time.Now()
 		}
 	}
 
-	return gen.store.Update(func(tx *genji.Tx) error {
+	origin := gen.rootNodeID()
+	if origin == "" {
+		// this is the first node, so will become the root node
+		origin = id
+	}
+
+	var applied data.Points
+
+	err := gen.store.Update(func(tx *genji.Tx) error {
 		nec := newNodeEdgeCache(tx)
 
 		ne, err := nec.getNodeAndEdges(id)
@@ -484,29 +570,22 @@ func (gen *Db) nodePoints(id string, points data.Points) error {
 			}
 		}
 
+		stampPoints(origin, points, ne.node.Points)
+
 		for _, point := range points {
 			if point.Type == data.PointTypeNodeType {
-				ne.node.Type = point.Text
+				if ne.node.Type != point.Text {
+					ne.node.Type = point.Text
+					applied = append(applied, point)
+				}
 				// we don't encode type in points as this has its own field
 				continue
 			}
 
-			ne.node.Points.ProcessPoint(point)
-		}
-
-		/*
-			 * FIXME: need to clean up offline processing
-			state := node.State()
-			if state != data.PointValueSysStateOnline {
-				node.Points.ProcessPoint(
-					data.Point{
-						Time: time.Now(),
-						Type: data.PointTypeSysState,
-						Text: data.PointValueSysStateOnline,
-					},
-				)
+			if ne.node.Points.ProcessPoint(point) {
+				applied = append(applied, point)
 			}
-		*/
+		}
 
 		sort.Sort(ne.node.Points)
 
@@ -528,6 +607,30 @@ func (gen *Db) nodePoints(id string, points data.Points) error {
 
 		return nil
 	})
+
+	return applied, err
+}
+
+// stampPoints sets the hybrid logical clock for points that do not have an
+// origin, which are points created on this instance. existing are the
+// current points of the node or edge.
+func stampPoints(origin string, points, existing data.Points) {
+	for i := range points {
+		if points[i].Origin != "" || points[i].Type == data.PointTypeNodeType {
+			continue
+		}
+
+		var prev *data.Point
+		for j, e := range existing {
+			if e.ID == points[i].ID && e.Type == points[i].Type &&
+				e.Index == points[i].Index {
+				prev = &existing[j]
+				break
+			}
+		}
+
+		points[i].Stamp(origin, prev)
+	}
 }
 
 // NodesForUser returns all nodes for a particular user
@@ -786,7 +889,8 @@ func (gen *Db) UserCheck(email, password string) (*data.User, error) {
 
 		return nil, err
 	}
-	defer res.Close()
+
+	var matches []data.User
 
 	err = res.Iterate(func(d types.Document) error {
 		var node data.Node
@@ -797,17 +901,25 @@ func (gen *Db) UserCheck(email, password string) (*data.User, error) {
 
 		u := node.ToUser()
 
-		if u.Email == email && u.Pass == password {
-			distRoot, err := gen.minDistToRoot(u.ID)
-			if err != nil {
-				log.Println("Error getting dist to root: ", err)
-			}
-			users = append(users, userDistRoot{distRoot, u})
+		if u.Email == email && data.CheckPassword(u.Pass, password) {
+			matches = append(matches, u)
 		}
 
 		return nil
 	})
 
+	// the query must be closed before other transactions are started, or
+	// a write waiting for the query can deadlock them
+	res.Close()
+
+	for _, u := range matches {
+		distRoot, err := gen.minDistToRoot(u.ID)
+		if err != nil {
+			log.Println("Error getting dist to root: ", err)
+		}
+		users = append(users, userDistRoot{distRoot, u})
+	}
+
 	if len(users) > 0 {
 		sort.Sort(byDistRoot(users))
 		return &users[0].user, err
@@ -822,7 +934,8 @@ type genImport struct {
 	Meta  Meta        `json:"meta"`
 }
 
-// ImportDb imports contents of file into database
+// ImportDb imports contents of file into database. Passwords of users
+// are hashed if the file is from a version that stored them as plain text.
 func ImportDb(gen *Db, in io.Reader) error {
 	decoder := json.NewDecoder(in)
 	dump := genImport{}
@@ -833,7 +946,7 @@ func ImportDb(gen *Db, in io.Reader) error {
 	}
 
 	// FIXME, re-import meta?
-	return gen.store.Update(func(tx *genji.Tx) error {
+	err = gen.store.Update(func(tx *genji.Tx) error {
 		for _, n := range dump.Nodes {
 			err := tx.Exec(`insert into nodes values ? on conflict do replace`, n)
 			if err != nil {
@@ -857,6 +970,11 @@ func ImportDb(gen *Db, in io.Reader) error {
 
 		return nil
 	})
+	if err != nil {
+		return err
+	}
+
+	return gen.migratePasswords()
 }
 
 type genDump struct {
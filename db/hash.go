@@ -2,7 +2,6 @@ package db
 
 import (
 	"crypto/md5"
-	"encoding/binary"
 	"sort"
 
 	"github.com/simpleiot/simpleiot/data"
@@ -17,15 +16,11 @@ func updateHash(node *data.Node, upEdges []*data.Edge, downEdges []*data.Edge) {
 		h := md5.New()
 
 		for _, p := range up.Points {
-			d := make([]byte, 8)
-			binary.LittleEndian.PutUint64(d, uint64(p.Time.UnixNano()))
-			h.Write(d)
+			p.WriteClock(h)
 		}
 
 		for _, p := range node.Points {
-			d := make([]byte, 8)
-			binary.LittleEndian.PutUint64(d, uint64(p.Time.UnixNano()))
-			h.Write(d)
+			p.WriteClock(h)
 		}
 
 		for _, downEdge := range downEdges {
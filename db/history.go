@@ -0,0 +1,151 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/genjidb/genji"
+	"github.com/genjidb/genji/document"
+	"github.com/genjidb/genji/types"
+	"github.com/simpleiot/simpleiot/data"
+)
+
+// historyPoint is the record stored in the history table. Time is stored
+// as Unix nanoseconds in an INTEGER field so that it can be range queried
+// without losing precision. Rollup is the downsampling period in
+// nanoseconds, or 0 for raw points.
+type historyPoint struct {
+	NodeID   string
+	ID       string
+	Type     string
+	Index    int
+	Time     int64
+	Duration int64
+	Value    float64
+	Text     string
+	Min      float64
+	Max      float64
+	Rollup   int64
+}
+
+func newHistoryPoint(nodeID string, p data.Point) historyPoint {
+	return historyPoint{
+		NodeID:   nodeID,
+		ID:       p.ID,
+		Type:     p.Type,
+		Index:    p.Index,
+		Time:     p.Time.UnixNano(),
+		Duration: int64(p.Duration),
+		Value:    p.Value,
+		Text:     p.Text,
+		Min:      p.Min,
+		Max:      p.Max,
+	}
+}
+
+func (hp historyPoint) toPoint() data.Point {
+	return data.Point{
+		ID:       hp.ID,
+		Type:     hp.Type,
+		Index:    hp.Index,
+		Time:     time.Unix(0, hp.Time),
+		Duration: time.Duration(hp.Duration),
+		Value:    hp.Value,
+		Text:     hp.Text,
+		Min:      hp.Min,
+		Max:      hp.Max,
+	}
+}
+
+// historyWrite records points for a node in the history table
+func (gen *Db) historyWrite(nodeID string, points data.Points) error {
+	return gen.store.Update(func(tx *genji.Tx) error {
+		for _, p := range points {
+			if p.Type == data.PointTypeNodeType {
+				// node type is not a point in the node, so skip it
+				continue
+			}
+
+			if data.PointRedacted(p.Type) {
+				// passwords are not recorded
+				continue
+			}
+
+			if p.Time.IsZero() {
+				p.Time = time.Now()
+			}
+
+			err := tx.Exec(`insert into history values ?`, newHistoryPoint(nodeID, p))
+			if err != nil {
+				return fmt.Errorf("Error inserting history point: %w", err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// history returns points for a node from the history table sorted by time
+func (gen *Db) history(nodeID string, q data.HistoryQuery) (data.Points, error) {
+	query := `select * from history where nodeid = ? and rollup = ?`
+	args := []interface{}{nodeID, int64(q.Rollup)}
+
+	if q.ID != "" {
+		query += ` and id = ?`
+		args = append(args, q.ID)
+	}
+
+	if q.Type != "" {
+		query += ` and type = ?`
+		args = append(args, q.Type)
+	}
+
+	if q.Index >= 0 {
+		query += " and `index` = ?"
+		args = append(args, q.Index)
+	}
+
+	if !q.Start.IsZero() {
+		query += ` and time >= ?`
+		args = append(args, q.Start.UnixNano())
+	}
+
+	if !q.End.IsZero() {
+		query += ` and time < ?`
+		args = append(args, q.End.UnixNano())
+	}
+
+	query += ` order by time`
+
+	if q.Limit > 0 {
+		query += fmt.Sprintf(` limit %v`, q.Limit)
+	}
+
+	return gen.historyQuery(query, args...)
+}
+
+// historyQuery runs a query against the history table and returns the
+// resulting points
+func (gen *Db) historyQuery(query string, args ...interface{}) (data.Points, error) {
+	var ret data.Points
+
+	res, err := gen.store.Query(query, args...)
+	if err != nil {
+		return ret, err
+	}
+
+	defer res.Close()
+
+	err = res.Iterate(func(d types.Document) error {
+		var hp historyPoint
+		err := document.StructScan(d, &hp)
+		if err != nil {
+			return err
+		}
+
+		ret = append(ret, hp.toPoint())
+		return nil
+	})
+
+	return ret, err
+}
@@ -0,0 +1,93 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/simpleiot/simpleiot/data"
+)
+
+func TestHistory(t *testing.T) {
+	db, err := NewDb(StoreTypeMemory, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2021, time.September, 1, 0, 0, 0, 0, time.UTC)
+
+	var points data.Points
+
+	for i := 0; i < 10; i++ {
+		points = append(points, data.Point{
+			Type:  data.PointTypeValue,
+			Index: i % 2,
+			Time:  start.Add(time.Duration(i) * time.Minute),
+			Value: float64(i),
+		})
+	}
+
+	err = db.historyWrite("node1", points)
+	if err != nil {
+		t.Fatal("Error writing history: ", err)
+	}
+
+	err = db.historyWrite("node2", data.Points{
+		{Type: data.PointTypeValue, Time: start, Value: 100},
+	})
+	if err != nil {
+		t.Fatal("Error writing history: ", err)
+	}
+
+	tests := []struct {
+		desc  string
+		query data.HistoryQuery
+		exp   []float64
+	}{
+		{"all", data.HistoryQuery{Index: -1}, []float64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}},
+		{"index", data.HistoryQuery{Index: 1}, []float64{1, 3, 5, 7, 9}},
+		{"type", data.HistoryQuery{Type: data.PointTypeDescription, Index: -1}, nil},
+		{"range", data.HistoryQuery{
+			Index: -1,
+			Start: start.Add(2 * time.Minute),
+			End:   start.Add(5 * time.Minute),
+		}, []float64{2, 3, 4}},
+		{"start", data.HistoryQuery{Index: -1, Start: start.Add(8 * time.Minute)},
+			[]float64{8, 9}},
+		{"end", data.HistoryQuery{Index: -1, End: start.Add(2 * time.Minute)},
+			[]float64{0, 1}},
+		{"empty range", data.HistoryQuery{
+			Index: -1,
+			Start: start.Add(time.Hour),
+			End:   start.Add(2 * time.Hour),
+		}, nil},
+		{"limit", data.HistoryQuery{Index: 0, Limit: 2}, []float64{0, 2}},
+	}
+
+	for _, test := range tests {
+		p, err := db.history("node1", test.query)
+		if err != nil {
+			t.Errorf("%v: error getting history: %v", test.desc, err)
+			continue
+		}
+
+		if len(p) != len(test.exp) {
+			t.Errorf("%v: expected %v points, got %v", test.desc, len(test.exp), len(p))
+			continue
+		}
+
+		for i := range p {
+			if p[i].Value != test.exp[i] {
+				t.Errorf("%v: expected value %v, got %v", test.desc, test.exp[i], p[i].Value)
+			}
+		}
+	}
+
+	p, err := db.history("node1", data.HistoryQuery{Index: -1, Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !p[0].Time.Equal(start) {
+		t.Error("point time was not preserved: ", p[0].Time)
+	}
+}
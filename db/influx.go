@@ -2,7 +2,6 @@ package db
 
 import (
 	"context"
-	"errors"
 	"strconv"
 
 	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
@@ -21,26 +20,16 @@ type InfluxConfig struct {
 // NodeToInfluxConfig converts a node to an influx config
 func NodeToInfluxConfig(node data.NodeEdge) (*InfluxConfig, error) {
 	ret := &InfluxConfig{}
-	var ok bool
-	ret.Token, ok = node.Points.Text("", data.PointTypeAuthToken, 0)
-	if !ok || ret.Token == "" {
-		return ret, errors.New("Auth token must be set for InfluxDb")
-	}
-
-	ret.URL, ok = node.Points.Text("", data.PointTypeURI, 0)
-	if !ok || ret.URL == "" {
-		return ret, errors.New("URL must be set for InfluxDb")
-	}
 
-	ret.Bucket, ok = node.Points.Text("", data.PointTypeBucket, 0)
-	if !ok || ret.Bucket == "" {
-		return ret, errors.New("Bucket must be set for InfluxDb")
+	err := data.ValidateNode(data.NodeTypeDb, node.Points)
+	if err != nil {
+		return ret, err
 	}
 
-	ret.Org, ok = node.Points.Text("", data.PointTypeOrg, 0)
-	if !ok || ret.Org == "" {
-		return ret, errors.New("Org must be set for InfluxDb")
-	}
+	ret.Token, _ = node.Points.Text("", data.PointTypeAuthToken, 0)
+	ret.URL, _ = node.Points.Text("", data.PointTypeURI, 0)
+	ret.Bucket, _ = node.Points.Text("", data.PointTypeBucket, 0)
+	ret.Org, _ = node.Points.Text("", data.PointTypeOrg, 0)
 
 	return ret, nil
 }
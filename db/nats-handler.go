@@ -1,6 +1,7 @@
 package db
 
 import (
+	"crypto/subtle"
 	"errors"
 	"fmt"
 	"log"
@@ -14,11 +15,14 @@ import (
 	natsgo "github.com/nats-io/nats.go"
 	"github.com/simpleiot/simpleiot/data"
 	"github.com/simpleiot/simpleiot/internal/pb"
-	"github.com/simpleiot/simpleiot/msg"
 	"github.com/simpleiot/simpleiot/nats"
 	"google.golang.org/protobuf/proto"
 )
 
+// deviceStateInterval is how often devices are checked to see if they are
+// offline
+const deviceStateInterval = time.Minute
+
 // NatsHandler implements the SIOT NATS api
 type NatsHandler struct {
 	server              string
@@ -28,6 +32,11 @@ type NatsHandler struct {
 	lock                sync.Mutex
 	nodeUpdateLock      sync.Mutex
 	updates             map[string]time.Time
+	actionLock          sync.Mutex
+	actionRuns          map[string]data.Point
+	rules               *ruleCache
+	msgServiceLock      sync.Mutex
+	msgServices         map[string]msgServiceEntry
 	metricNodePoint     *nats.Metric
 	metricNodeEdgePoint *nats.Metric
 	metricNode          *nats.Metric
@@ -38,10 +47,13 @@ type NatsHandler struct {
 func NewNatsHandler(db *Db, authToken, server string) *NatsHandler {
 	log.Println("NATS handler connecting to: ", server)
 	return &NatsHandler{
-		db:        db,
-		authToken: authToken,
-		updates:   make(map[string]time.Time),
-		server:    server,
+		db:          db,
+		authToken:   authToken,
+		updates:     make(map[string]time.Time),
+		actionRuns:  make(map[string]data.Point),
+		rules:       newRuleCache(),
+		msgServices: make(map[string]msgServiceEntry),
+		server:      server,
 	}
 }
 
@@ -89,6 +101,14 @@ func (nh *NatsHandler) Connect() (*natsgo.Conn, error) {
 		return nil, fmt.Errorf("Subscribe node error: %w", err)
 	}
 
+	if _, err := nc.Subscribe("node.*.sync", nh.handleNode); err != nil {
+		return nil, fmt.Errorf("Subscribe node sync error: %w", err)
+	}
+
+	if _, err := nc.Subscribe("node.*.syncChildren", nh.handleNodeChildren); err != nil {
+		return nil, fmt.Errorf("Subscribe node sync children error: %w", err)
+	}
+
 	if _, err := nc.Subscribe("node.*.not", nh.handleNotification); err != nil {
 		return nil, fmt.Errorf("Subscribe notification error: %w", err)
 	}
@@ -97,51 +117,113 @@ func (nh *NatsHandler) Connect() (*natsgo.Conn, error) {
 		return nil, fmt.Errorf("Subscribe message error: %w", err)
 	}
 
+	if _, err := nc.Subscribe("node.*.history", nh.handleNodeHistory); err != nil {
+		return nil, fmt.Errorf("Subscribe node history error: %w", err)
+	}
+
+	if _, err := nc.Subscribe(nats.SubjectNodeAllEvents(), nh.handleNodeEvent); err != nil {
+		return nil, fmt.Errorf("Subscribe node event error: %w", err)
+	}
+
+	if _, err := nc.Subscribe("node.*.events", nh.handleNodeEvents); err != nil {
+		return nil, fmt.Errorf("Subscribe node events error: %w", err)
+	}
+
+	if _, err := nc.Subscribe("node.*.ack", nh.handleNodeAck); err != nil {
+		return nil, fmt.Errorf("Subscribe node ack error: %w", err)
+	}
+
+	if _, err := nc.Subscribe("node.*.eval", nh.handleNodeEval); err != nil {
+		return nil, fmt.Errorf("Subscribe node eval error: %w", err)
+	}
+
+	if _, err := nc.Subscribe("node.*.deliveries", nh.handleNodeDeliveries); err != nil {
+		return nil, fmt.Errorf("Subscribe node deliveries error: %w", err)
+	}
+
+	if _, err := nc.Subscribe("node.*.sms", nh.handleNodeSMS); err != nil {
+		return nil, fmt.Errorf("Subscribe node SMS error: %w", err)
+	}
+
+	if _, err := nc.Subscribe(nats.SubjectSchemas(), nh.handleSchemas); err != nil {
+		return nil, fmt.Errorf("Subscribe schemas error: %w", err)
+	}
+
+	go nh.runRuleScheduler()
+
+	err = nh.resumeDeliveries()
+	if err != nil {
+		log.Println("Error resuming message deliveries: ", err)
+	}
+
+	go func() {
+		for {
+			err := nh.updateStates()
+			if err != nil {
+				log.Println("Error updating node states: ", err)
+			}
+			time.Sleep(deviceStateInterval)
+		}
+	}()
+
 	go func() {
 		for {
-			childNodes, err := nh.db.nodeDescendents(nh.db.rootNodeID(), "", false, false)
+			time.Sleep(smsPollInterval)
+			err := nh.receiveSMS()
 			if err != nil {
-				log.Println("Error getting child nodes to run schedule: ", err)
-			} else {
-				for _, c := range childNodes {
-					err := nh.runSchedule(c)
-					if err != nil {
-						log.Println("Error running schedule: ", err)
-					}
-				}
+				log.Println("Error receiving SMS: ", err)
 			}
-			time.Sleep(time.Second * 5)
 		}
 	}()
 
+	go nh.db.runHistoryCompaction(time.Minute * 10)
+
 	return nc, nil
 }
 
-func (nh *NatsHandler) runSchedule(node data.NodeEdge) error {
-	switch node.Type {
-	case data.NodeTypeRule:
-		p := data.Point{Time: time.Now(), Type: data.PointTypeTrigger}
-		err := nh.processRuleNode(node, "", []data.Point{p})
-		if err != nil {
-			return err
+// updateStates updates the state of all devices in the tree
+func (nh *NatsHandler) updateStates() error {
+	devices, err := nh.db.nodeDescendents(nh.db.rootNodeID(), data.NodeTypeDevice,
+		true, false)
+	if err != nil {
+		return err
+	}
+
+	done := make(map[string]bool)
+
+	for _, d := range devices {
+		// a device may have more than one parent
+		if done[d.ID] {
+			continue
 		}
+		done[d.ID] = true
 
-	case data.NodeTypeGroup:
-		childNodes, err := nh.db.nodeDescendents(node.ID, "", false, false)
+		err := nh.updateState(d)
 		if err != nil {
-			return err
-		}
-		for _, c := range childNodes {
-			err := nh.runSchedule(c)
-			if err != nil {
-				return err
-			}
+			log.Println("Error updating node state: ", err)
 		}
 	}
 
 	return nil
 }
 
+// updateState marks a device offline if it has not sent points for a while,
+// and back online when points arrive again
+func (nh *NatsHandler) updateState(node data.NodeEdge) error {
+	n := data.Node{ID: node.ID, Type: node.Type, Points: node.Points}
+
+	state, changed := n.GetState()
+	if !changed {
+		return nil
+	}
+
+	return nats.SendNodePoint(nh.Nc, node.ID, data.Point{
+		Time: time.Now(),
+		Type: data.PointTypeSysState,
+		Text: state,
+	}, false)
+}
+
 func (nh *NatsHandler) setSwUpdateState(id string, state data.SwUpdateState) error {
 	p := state.Points()
 
@@ -221,8 +303,22 @@ func (nh *NatsHandler) handleNodePoints(msg *natsgo.Msg) {
 		return
 	}
 
+	err = nh.validateNodePoints(nodeID, points)
+	if err != nil {
+		nh.reply(msg.Reply, err)
+		return
+	}
+
+	points, err = nh.hashPasswords(nodeID, points)
+	if err != nil {
+		nh.reply(msg.Reply, err)
+		return
+	}
+
+	events := nh.pointEvents(nodeID, points)
+
 	// write points to database
-	err = nh.db.nodePoints(nodeID, points)
+	applied, err := nh.db.nodePoints(nodeID, points)
 
 	if err != nil {
 		// TODO track error stats
@@ -232,6 +328,23 @@ func (nh *NatsHandler) handleNodePoints(msg *natsgo.Msg) {
 		return
 	}
 
+	err = nh.db.historyWrite(nodeID, points)
+	if err != nil {
+		log.Printf("Error writing nodeID (%v) history: %v", nodeID, err)
+	}
+
+	nh.publishStored(nats.SubjectNodeStored(nodeID), applied)
+
+	for _, e := range events {
+		err := nats.SendEvent(nh.Nc, nodeID, e, false)
+		if err != nil {
+			log.Printf("Error sending event for nodeID (%v): %v", nodeID, err)
+		}
+	}
+
+	// update the rules that the node is part of
+	nh.rules.update(nodeID, applied, time.Now())
+
 	node, err := nh.db.node(nodeID)
 	if err != nil {
 		log.Println("handleNodePoints, error getting node for id: ", nodeID)
@@ -239,16 +352,217 @@ func (nh *NatsHandler) handleNodePoints(msg *natsgo.Msg) {
 
 	desc := node.Desc()
 
+	err = nh.ruleLoad()
+	if err != nil {
+		log.Println("Error loading rules: ", err)
+	}
+
+	// rules that have processed the points
+	done := make(map[ruleKey]bool)
+
 	// process point in upstream nodes
-	err = nh.processPointsUpstream(nodeID, nodeID, desc, points)
+	err = nh.processPointsUpstream(nodeID, nodeID, desc, points, done)
 	if err != nil {
 		// TODO track error stats
 		log.Println("Error processing point in upstream nodes: ", err)
 	}
 
+	// process point in rules elsewhere in the tree that reference the node
+	for _, key := range nh.rules.watching(nh.rules.watcherRules(nodeID), points) {
+		if done[key] {
+			continue
+		}
+
+		err := nh.processRuleNode(key, nodeID, points)
+		if err != nil {
+			log.Println("Error processing point in rule: ", err)
+		}
+	}
+
+	err = nh.processVariables(nodeID, points)
+	if err != nil {
+		log.Println("Error processing variables: ", err)
+	}
+
 	nh.reply(msg.Reply, nil)
 }
 
+// pointEvents returns events for state transitions caused by writing
+// points to a node, such as a device going offline or a rule becoming
+// active. It must be called before the points are written.
+func (nh *NatsHandler) pointEvents(nodeID string, points data.Points) []data.Event {
+	var ret []data.Event
+	var node *data.Node
+
+	for _, p := range points {
+		switch p.Type {
+		case data.PointTypeSysState, data.PointTypeAppVersion,
+			data.PointTypeOSVersion, data.PointTypeActive:
+		default:
+			continue
+		}
+
+		if node == nil {
+			var err error
+			node, err = nh.db.node(nodeID)
+			if err != nil {
+				// new nodes have no previous state
+				return nil
+			}
+		}
+
+		desc := node.Desc()
+
+		switch p.Type {
+		case data.PointTypeSysState:
+			prev := node.State()
+			if p.Text == prev {
+				continue
+			}
+
+			switch {
+			case p.Text == data.PointValueSysStateOffline:
+				ret = append(ret, data.Event{
+					Type:    data.EventTypeDeviceOffline,
+					Level:   data.EventLevelFault,
+					Message: fmt.Sprintf("%v offline", desc),
+				})
+			case p.Text == data.PointValueSysStateOnline &&
+				prev == data.PointValueSysStateOffline:
+				ret = append(ret, data.Event{
+					Type:    data.EventTypeDeviceOnline,
+					Level:   data.EventLevelInfo,
+					Message: fmt.Sprintf("%v online", desc),
+				})
+			}
+
+		case data.PointTypeAppVersion, data.PointTypeOSVersion:
+			prev, ok := node.Points.Text("", p.Type, 0)
+			if !ok || prev == "" || prev == p.Text {
+				continue
+			}
+
+			e := data.Event{
+				Type:    data.EventTypeAppUpdate,
+				Level:   data.EventLevelInfo,
+				Message: fmt.Sprintf("%v app updated from %v to %v", desc, prev, p.Text),
+			}
+
+			if p.Type == data.PointTypeOSVersion {
+				e.Type = data.EventTypeSystemUpdate
+				e.Message = fmt.Sprintf("%v OS updated from %v to %v", desc, prev, p.Text)
+			}
+
+			ret = append(ret, e)
+
+		case data.PointTypeActive:
+			if node.Type != data.NodeTypeRule {
+				continue
+			}
+
+			prev, _ := node.Points.ValueBool("", p.Type, 0)
+			active := data.FloatToBool(p.Value)
+			if prev == active {
+				continue
+			}
+
+			e := data.Event{
+				Type:    data.EventTypeRuleActive,
+				Level:   data.EventLevelInfo,
+				Message: fmt.Sprintf("rule %v active", desc),
+			}
+
+			if !active {
+				e.Type = data.EventTypeRuleInactive
+				e.Message = fmt.Sprintf("rule %v inactive", desc)
+			}
+
+			ret = append(ret, e)
+		}
+	}
+
+	return ret
+}
+
+// validateNodePoints checks points against the schema for the node type.
+// The type is taken from the points if they set it, otherwise from the
+// existing node. Required points are checked against the node as it will be
+// after the points are written.
+func (nh *NatsHandler) validateNodePoints(nodeID string, points data.Points) error {
+	nodeType := ""
+
+	for _, p := range points {
+		if p.Type == data.PointTypeNodeType {
+			nodeType = p.Text
+		}
+	}
+
+	if nodeType == "" {
+		node, err := nh.db.node(nodeID)
+		if err != nil {
+			if err != genjierrors.ErrDocumentNotFound {
+				return err
+			}
+			// new nodes default to device type
+			nodeType = data.NodeTypeDevice
+		} else {
+			nodeType = node.Type
+		}
+	}
+
+	err := data.ValidateNodePoints(nodeType, points)
+	if err != nil {
+		return err
+	}
+
+	after := nh.pointsAfter(nodeID, points)
+
+	err = data.ValidateRequired(nodeType, after)
+	if err != nil {
+		return err
+	}
+
+	switch nodeType {
+	case data.NodeTypeVariable:
+		return nh.validateVariable(nodeID, points)
+	case data.NodeTypeCondition:
+		return data.ValidateCondition(data.NodeEdge{
+			ID:     nodeID,
+			Points: after,
+		})
+	}
+
+	return nil
+}
+
+// pointsAfter returns the points a node will have after points are written
+// to it
+func (nh *NatsHandler) pointsAfter(nodeID string, points data.Points) data.Points {
+	var ret data.Points
+
+	node, err := nh.db.node(nodeID)
+	if err == nil {
+		ret = append(ret, node.Points...)
+	}
+
+	for _, p := range points {
+		found := false
+		for i, c := range ret {
+			if c.IsMatch(p.ID, p.Type, p.Index) {
+				ret[i] = p
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			ret = append(ret, p)
+		}
+	}
+
+	return ret
+}
+
 func (nh *NatsHandler) handleEdgePoints(msg *natsgo.Msg) {
 	start := time.Now()
 	defer func() {
@@ -268,18 +582,66 @@ func (nh *NatsHandler) handleEdgePoints(msg *natsgo.Msg) {
 	}
 
 	// write points to database
-	err = nh.db.edgePoints(nodeID, parentID, points)
+	applied, err := nh.db.edgePoints(nodeID, parentID, points)
 
 	if err != nil {
 		// TODO track error stats
 		log.Printf("Error writing edge points (%v:%v) to Db: %v", nodeID, parentID, err)
 		log.Println("msg subject: ", msg.Subject)
 		nh.reply(msg.Reply, err)
+		return
+	}
+
+	nh.publishStored(nats.SubjectEdgeStored(nodeID, parentID), applied)
+
+	// nodes may have been added, moved, or deleted, so rules must be
+	// reloaded
+	nh.rules.treeChanged()
+
+	// a variable may have been created or restored
+	err = nh.processVariables(nodeID, points)
+	if err != nil {
+		log.Println("Error processing variables: ", err)
 	}
 
 	nh.reply(msg.Reply, nil)
 }
 
+// publishStored publishes points after they have been written to the
+// database. The points are stamped with the clock and origin they were
+// stored with, which is what must be synchronized with other instances.
+// Only points that were applied are published, so points that have
+// already been seen are not forwarded between instances again.
+func (nh *NatsHandler) publishStored(subject string, points data.Points) {
+	if len(points) <= 0 {
+		return
+	}
+
+	d, err := points.ToPb()
+	if err != nil {
+		log.Println("Error encoding stored points: ", err)
+		return
+	}
+
+	err = nh.Nc.Publish(subject, d)
+	if err != nil {
+		log.Println("Error publishing stored points: ", err)
+	}
+}
+
+// syncAuthorized returns true if a sync request carries the auth token of
+// this instance. If the instance does not use an auth token, any client can
+// connect, so nodes are always redacted.
+func (nh *NatsHandler) syncAuthorized(msg *natsgo.Msg) bool {
+	if nh.authToken == "" || msg.Header == nil {
+		return false
+	}
+
+	token := msg.Header.Get("Authorization")
+
+	return subtle.ConstantTimeCompare([]byte(token), []byte(nh.authToken)) == 1
+}
+
 func (nh *NatsHandler) handleNode(msg *natsgo.Msg) {
 	start := time.Now()
 	defer func() {
@@ -309,6 +671,11 @@ func (nh *NatsHandler) handleNode(msg *natsgo.Msg) {
 
 	node, err = nh.db.nodeEdge(nodeID, parent)
 
+	// nodes are only sent with all points to other instances
+	if len(chunks) < 3 || chunks[2] != "sync" || !nh.syncAuthorized(msg) {
+		node.Redact()
+	}
+
 	if err != nil {
 		if err != genjierrors.ErrDocumentNotFound {
 			resp.Error = fmt.Sprintf("NATS handler: Error getting node %v from db: %v\n", nodeID, err)
@@ -363,6 +730,11 @@ func (nh *NatsHandler) handleNodeChildren(msg *natsgo.Msg) {
 
 	nodes, err = nh.db.nodeDescendents(nodeID, params.Type, false, params.IncludeDel)
 
+	// nodes are only sent with all points to other instances
+	if chunks[2] != "syncChildren" || !nh.syncAuthorized(msg) {
+		nodes.Redact()
+	}
+
 	if err != nil {
 		resp.Error = fmt.Sprintf("NATS: Error getting node %v from db: %v\n", nodeID, err)
 		goto handleNodeChildrenDone
@@ -386,6 +758,166 @@ handleNodeChildrenDone:
 	}
 }
 
+func (nh *NatsHandler) handleSchemas(msg *natsgo.Msg) {
+	resp, err := data.NodeSchemasToPb(data.NodeSchemas(), nil)
+	if err != nil {
+		// reply with the error so the requester does not wait for a timeout
+		resp, err = data.NodeSchemasToPb(nil,
+			fmt.Errorf("Error encoding node schemas: %v", err))
+		if err != nil {
+			log.Println("Error encoding node schemas error: ", err)
+			return
+		}
+	}
+
+	err = nh.Nc.Publish(msg.Reply, resp)
+	if err != nil {
+		log.Println("NATS: Error publishing response to schemas request: ", err)
+	}
+}
+
+func (nh *NatsHandler) handleNodeHistory(msg *natsgo.Msg) {
+	resp := &pb.PointsRequest{}
+	var query data.HistoryQuery
+	var err error
+	var points data.Points
+	var nodeID string
+
+	chunks := strings.Split(msg.Subject, ".")
+	if len(chunks) < 3 {
+		resp.Error = fmt.Sprintf("Error in message subject: %v", msg.Subject)
+		goto handleNodeHistoryDone
+	}
+
+	query, err = data.PbDecodeHistoryQuery(msg.Data)
+	if err != nil {
+		resp.Error = fmt.Sprintf("Error decoding history request params: %v", err)
+		goto handleNodeHistoryDone
+	}
+
+	nodeID = chunks[1]
+
+	if nodeID == "root" {
+		nodeID = nh.db.rootNodeID()
+	}
+
+	points, err = nh.db.history(nodeID, query)
+
+	if err != nil {
+		resp.Error = fmt.Sprintf("NATS: Error getting history for node %v: %v\n", nodeID, err)
+		goto handleNodeHistoryDone
+	}
+
+handleNodeHistoryDone:
+	resp.Points, err = points.ToPbPoints()
+	if err != nil {
+		resp.Error = fmt.Sprintf("Error pb encoding points: %v", err)
+	}
+
+	data, err := proto.Marshal(resp)
+	if err != nil {
+		resp.Error = fmt.Sprintf("Error encoding data: %v", err)
+	}
+
+	err = nh.Nc.Publish(msg.Reply, data)
+
+	if err != nil {
+		log.Println("NATS: Error publishing response to node history request: ", err)
+	}
+}
+
+func (nh *NatsHandler) handleNodeEvent(msg *natsgo.Msg) {
+	chunks := strings.Split(msg.Subject, ".")
+	if len(chunks) < 3 {
+		nh.reply(msg.Reply, errors.New("error decoding node event subject"))
+		return
+	}
+
+	event, err := data.PbDecodeEvent(msg.Data)
+	if err != nil {
+		log.Println("Error decoding Pb event: ", err)
+		nh.reply(msg.Reply, err)
+		return
+	}
+
+	event.NodeID = chunks[1]
+
+	if event.NodeID == "root" {
+		event.NodeID = nh.db.rootNodeID()
+	}
+
+	_, err = nh.db.eventWrite(event)
+	if err != nil {
+		log.Printf("Error writing event for nodeID (%v): %v", event.NodeID, err)
+	}
+
+	nh.reply(msg.Reply, err)
+}
+
+func (nh *NatsHandler) handleNodeEvents(msg *natsgo.Msg) {
+	var query data.EventQuery
+	var err error
+	var events []data.Event
+	var nodeID string
+	var resp []byte
+
+	chunks := strings.Split(msg.Subject, ".")
+	if len(chunks) < 3 {
+		err = fmt.Errorf("Error in message subject: %v", msg.Subject)
+		goto handleNodeEventsDone
+	}
+
+	query, err = data.PbDecodeEventQuery(msg.Data)
+	if err != nil {
+		err = fmt.Errorf("Error decoding event request params: %v", err)
+		goto handleNodeEventsDone
+	}
+
+	nodeID = chunks[1]
+
+	if nodeID == "root" {
+		nodeID = nh.db.rootNodeID()
+	}
+
+	events, err = nh.db.events(nodeID, query)
+	if err != nil {
+		err = fmt.Errorf("NATS: Error getting events for node %v: %v", nodeID, err)
+	}
+
+handleNodeEventsDone:
+	resp, err = data.EventsToPb(events, err)
+	if err != nil {
+		// reply with the error so the requester does not wait for a timeout
+		resp, err = data.EventsToPb(nil, fmt.Errorf("Error encoding events: %v", err))
+		if err != nil {
+			log.Println("Error encoding events error: ", err)
+			return
+		}
+	}
+
+	err = nh.Nc.Publish(msg.Reply, resp)
+	if err != nil {
+		log.Println("NATS: Error publishing response to node events request: ", err)
+	}
+}
+
+func (nh *NatsHandler) handleNodeAck(msg *natsgo.Msg) {
+	chunks := strings.Split(msg.Subject, ".")
+	if len(chunks) < 3 {
+		nh.reply(msg.Reply, errors.New("error decoding node ack subject"))
+		return
+	}
+
+	ack, err := data.PbDecodeAlarmAck(msg.Data)
+	if err != nil {
+		log.Println("Error decoding Pb alarm ack: ", err)
+		nh.reply(msg.Reply, err)
+		return
+	}
+
+	nh.reply(msg.Reply, nh.alarmAck(chunks[1], ack))
+}
+
 func (nh *NatsHandler) handleNotification(msg *natsgo.Msg) {
 	chunks := strings.Split(msg.Subject, ".")
 	if len(chunks) < 2 {
@@ -438,12 +970,28 @@ func (nh *NatsHandler) handleNotification(msg *natsgo.Msg) {
 
 	if node.Type == data.NodeTypeUser {
 		// if we notify a user node, we only want to message this node, and not walk up the tree
-		nodeEdge := node.ToNodeEdge(data.Edge{Up: not.Parent})
+		edge := data.Edge{Up: not.Parent}
+
+		// the edge to the parent may have notification preferences
+		edges, err := nh.db.edgeUp(nodeID)
+		if err != nil {
+			log.Println("Error getting upstream nodes: ", err)
+		}
+
+		for _, e := range edges {
+			if e.Up == not.Parent {
+				edge = *e
+			}
+		}
+
+		nodeEdge := node.ToNodeEdge(edge)
 		userNodes = append(userNodes, nodeEdge)
 	} else {
 		findUsers(nodeID)
 	}
 
+	now := time.Now()
+
 	for _, userNode := range userNodes {
 		user, err := data.NodeToUser(userNode.ToNode())
 
@@ -452,16 +1000,26 @@ func (nh *NatsHandler) handleNotification(msg *natsgo.Msg) {
 			continue
 		}
 
+		// preferences are applied before messages are created
+		prefs := data.NodeEdgeToNotifyPrefs(userNode)
+
+		if !notifyAllowed(prefs, not.Severity, now) {
+			continue
+		}
+
 		if user.Email != "" || user.Phone != "" {
 			msg := data.Message{
 				ID:             uuid.New().String(),
 				UserID:         user.ID,
 				ParentID:       userNode.Parent,
-				NotificationID: nodeID,
+				NotificationID: not.ID,
 				Email:          user.Email,
 				Phone:          user.Phone,
 				Subject:        not.Subject,
 				Message:        not.Message,
+				HTML:           not.HTML,
+				Channels:       prefs.Channels,
+				RuleID:         not.RuleID,
 			}
 
 			data, err := msg.ToPb()
@@ -547,17 +1105,7 @@ func (nh *NatsHandler) handleMessage(natsMsg *natsgo.Msg) {
 			continue
 		}
 
-		if svc.Service == data.PointValueTwilio &&
-			message.Phone != "" {
-			twilio := msg.NewTwilio(svc.SID, svc.AuthToken, svc.From)
-
-			err := twilio.SendSMS(message.Phone, message.Message)
-
-			if err != nil {
-				log.Printf("Error sending SMS to: %v: %v\n",
-					message.Phone, err)
-			}
-		}
+		nh.queueDelivery(svc, message)
 	}
 }
 
@@ -577,66 +1125,170 @@ func (nh *NatsHandler) reply(subject string, err error) {
 	nh.Nc.Publish(subject, []byte(reply))
 }
 
-func (nh *NatsHandler) processRuleNode(ruleNode data.NodeEdge, sourceNodeID string, points []data.Point) error {
-	conditionNodes, err := nh.db.nodeDescendents(ruleNode.ID, data.NodeTypeCondition,
-		false, false)
+// processRuleNode runs points through a rule. Points that the rule does not
+// watch are ignored. Trigger points are sent by the rule scheduler to update
+// conditions and actions that change over time.
+func (nh *NatsHandler) processRuleNode(key ruleKey, sourceNodeID string, points []data.Point) error {
+	rule, err := nh.rule(key)
 	if err != nil {
 		return err
 	}
 
-	actionNodes, err := nh.db.nodeDescendents(ruleNode.ID, data.NodeTypeAction,
-		false, false)
-	if err != nil {
-		return err
+	trigger := false
+	for _, p := range points {
+		if p.Type == data.PointTypeTrigger {
+			trigger = true
+			break
+		}
 	}
 
-	actionInactiveNodes, err := nh.db.nodeDescendents(ruleNode.ID,
-		data.NodeTypeActionInactive,
-		false, false)
-	if err != nil {
-		return err
+	if !trigger && !rule.Watches(sourceNodeID, points) {
+		return nil
 	}
 
-	rule, err := data.NodeToRule(ruleNode, conditionNodes, actionNodes, actionInactiveNodes)
+	now := time.Now()
 
-	if err != nil {
-		return err
+	rr := newRuleRun(nh.Nc, rule.Trace)
+
+	nh.runRule(rr, rule, sourceNodeID, points, trigger, now)
+
+	nh.rules.schedule(key, ruleNextRun(rule, sourceNodeID, points, trigger, now),
+		trigger)
+
+	if rr.eval != nil {
+		nh.publishTrace(rr.eval)
 	}
 
-	active, changed, err := ruleProcessPoints(nh.Nc, rule, sourceNodeID, points)
+	return nil
+}
+
+// runRule updates the conditions and state of a rule, and runs its actions.
+// The evaluation is recorded if rr traces the rule.
+func (nh *NatsHandler) runRule(rr *ruleRun, rule *data.Rule, sourceNodeID string, points data.Points, trigger bool, now time.Time) {
+	if trigger {
+		nh.ruleLastUpdates(rr, rule.Conditions, rule.Groups, rule.Parent)
+		nh.ruleCalendars(rule.Conditions, rule.Groups)
+	}
+
+	nh.ruleInputs(rr, rule.Conditions, rule.Groups, now)
+	nh.ruleWindows(rr, rule.Conditions, rule.Groups, sourceNodeID, points, now)
+
+	active, changed, err := ruleProcessPoints(rr, rule, sourceNodeID, points, now)
+
+	defer func() {
+		rr.finish(rule, sourceNodeID, points, changed, now)
+	}()
 
 	if err != nil {
 		log.Println("Error processing rule point: ", err)
 	}
 
-	if active && changed {
-		err := nh.ruleRunActions(nh.Nc, rule, rule.Actions, sourceNodeID)
+	if changed {
+		rule.Active = active
+		rule.ActiveTime = now
+	}
+
+	alarmState := rule.AlarmState
+	if changed {
+		alarmState = data.AlarmTransition(alarmState, active)
+	}
+
+	// shelve expiry is only handled by the scheduler so that actions that
+	// were held back while shelved only run once. Actions of a rule that
+	// is still active run as if the rule had just gone active.
+	fire := changed
+	if trigger && rule.AlarmState == data.PointValueAlarmShelved &&
+		!now.Before(rule.ShelveUntil) {
+		alarmState = data.AlarmTransition("", rule.Active)
+		if rule.Active {
+			rule.ActiveTime = now
+			fire = true
+
+			rr.sendPoint(rule.ID, data.Point{
+				Type:  data.PointTypeActive,
+				Time:  now,
+				Value: data.BoolToFloat(true),
+				Text:  rule.TriggerNode,
+			})
+		}
+	}
+
+	if alarmState != rule.AlarmState {
+		rr.sendPoint(rule.ID, data.Point{
+			Type: data.PointTypeAlarmState,
+			Time: now,
+			Text: alarmState,
+		})
+		rule.AlarmState = alarmState
+	}
+
+	// shelved alarms do not run any actions, and acknowledged alarms do
+	// not repeat or escalate
+	switch rule.AlarmState {
+	case data.PointValueAlarmShelved, data.PointValueAlarmActiveAcked:
+		return
+	}
+
+	triggerPoint := rule.TriggerPoint(sourceNodeID, points)
+
+	if rule.Active {
+		nh.ruleRunDueActions(rr, rule, sourceNodeID, triggerPoint, fire, now)
+	}
+
+	if !rule.Active && changed {
+		rr.actions(rule.ActionsInactive, true, sourceNodeID)
+
+		if rr.dryRun {
+			return
+		}
+
+		err := nh.ruleRunActions(nh.Nc, rule, rule.ActionsInactive, sourceNodeID,
+			triggerPoint)
 		if err != nil {
 			log.Println("Error running rule actions: ", err)
 		}
 	}
+}
+
+// conditionGroups returns the condition groups under a rule or condition
+// group, including nested groups
+func (nh *NatsHandler) conditionGroups(parentID string) ([]data.ConditionGroup, error) {
+	groupNodes, err := nh.db.nodeDescendents(parentID, data.NodeTypeConditionGroup,
+		false, false)
+	if err != nil {
+		return nil, err
+	}
 
-	if !active && changed {
-		err := nh.ruleRunActions(nh.Nc, rule, rule.ActionsInactive, sourceNodeID)
+	var ret []data.ConditionGroup
+
+	for _, groupNode := range groupNodes {
+		conditionNodes, err := nh.db.nodeDescendents(groupNode.ID, data.NodeTypeCondition,
+			false, false)
 		if err != nil {
-			log.Println("Error running rule actions: ", err)
+			return nil, err
+		}
+
+		group := data.NodeToConditionGroup(groupNode, conditionNodes)
+
+		group.Groups, err = nh.conditionGroups(groupNode.ID)
+		if err != nil {
+			return nil, err
 		}
+
+		ret = append(ret, group)
 	}
 
-	return nil
+	return ret, nil
 }
 
-func (nh *NatsHandler) processPointsUpstream(currentNodeID, nodeID, nodeDesc string, points data.Points) error {
+func (nh *NatsHandler) processPointsUpstream(currentNodeID, nodeID, nodeDesc string, points data.Points, done map[ruleKey]bool) error {
 	// at this point, the point update has already been written to the DB
 
-	// get children and process any rules
-	ruleNodes, err := nh.db.nodeDescendents(currentNodeID, data.NodeTypeRule, false, false)
-	if err != nil {
-		return err
-	}
+	// process any rules under this node
+	for _, key := range nh.rules.watching(nh.rules.childRules(currentNodeID), points) {
+		done[key] = true
 
-	for _, ruleNode := range ruleNodes {
-		err := nh.processRuleNode(ruleNode, nodeID, points)
+		err := nh.processRuleNode(key, nodeID, points)
 		if err != nil {
 			return err
 		}
@@ -670,7 +1322,7 @@ func (nh *NatsHandler) processPointsUpstream(currentNodeID, nodeID, nodeDesc str
 
 	for _, edge := range edges {
 
-		err = nh.processPointsUpstream(edge.Up, nodeID, nodeDesc, points)
+		err = nh.processPointsUpstream(edge.Up, nodeID, nodeDesc, points, done)
 		if err != nil {
 			log.Println("Rules -- error processing upstream node: ", err)
 		}
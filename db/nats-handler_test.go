@@ -0,0 +1,55 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/simpleiot/simpleiot/data"
+)
+
+func TestValidateNodePointsRequired(t *testing.T) {
+	db, err := NewDb(StoreTypeMemory, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nh := NewNatsHandler(db, "", "")
+
+	upstream := data.Point{Type: data.PointTypeNodeType, Text: data.NodeTypeUpstream}
+	uri := data.Point{Type: data.PointTypeURI, Text: "nats://localhost:4222"}
+
+	_, err = db.nodePoints("root", data.Points{{Type: data.PointTypeNodeType,
+		Text: data.NodeTypeDevice}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.nodePoints("up", data.Points{upstream, uri})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		id     string
+		points data.Points
+		valid  bool
+	}{
+		{"new node without required point", "new", data.Points{upstream}, false},
+		{"new node", "new", data.Points{upstream, uri}, true},
+		{"required point already set", "up",
+			data.Points{{Type: data.PointTypeDescription, Text: "cloud"}}, true},
+		{"required point cleared", "up",
+			data.Points{{Type: data.PointTypeURI, Text: ""}}, false},
+		{"node type without schema", "new",
+			data.Points{{Type: data.PointTypeNodeType, Text: "custom"}}, true},
+	}
+
+	for _, test := range tests {
+		err := nh.validateNodePoints(test.id, test.points)
+		if test.valid && err != nil {
+			t.Errorf("%v: expected valid, got: %v", test.name, err)
+		} else if !test.valid && err == nil {
+			t.Errorf("%v: expected error", test.name)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package db
+
+import (
+	"log"
+	"time"
+
+	"github.com/simpleiot/simpleiot/data"
+)
+
+// notifyAllowed returns true if the preferences of a user allow a
+// notification of a severity to be sent at a time. If the quiet hours are
+// invalid, the error is logged and they are ignored so that notifications
+// are not lost.
+func notifyAllowed(prefs data.NotifyPrefs, severity string, now time.Time) bool {
+	if prefs.OptOut {
+		return false
+	}
+
+	level := data.SeverityLevel(severity)
+
+	if prefs.MinSeverity != "" && level < data.SeverityLevel(prefs.MinSeverity) {
+		return false
+	}
+
+	if prefs.QuietStart == "" || prefs.QuietEnd == "" {
+		return true
+	}
+
+	quietSeverity := prefs.QuietSeverity
+	if quietSeverity == "" {
+		quietSeverity = data.PointValueCritical
+	}
+
+	if level >= data.SeverityLevel(quietSeverity) {
+		return true
+	}
+
+	s := newSchedule(prefs.QuietStart, prefs.QuietEnd, nil)
+	s.timezone = prefs.Timezone
+
+	quiet, err := s.activeForTime(now)
+	if err != nil {
+		log.Println("Error in user quiet hours: ", err)
+		return true
+	}
+
+	return !quiet
+}
@@ -0,0 +1,54 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/simpleiot/simpleiot/data"
+)
+
+func TestNotifyAllowed(t *testing.T) {
+	chicago, err := time.LoadLocation("America/Chicago")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	night := time.Date(2021, time.September, 1, 3, 0, 0, 0, chicago)
+	day := time.Date(2021, time.September, 1, 12, 0, 0, 0, chicago)
+
+	onCall := data.NotifyPrefs{
+		MinSeverity: data.PointValueWarning,
+		QuietStart:  "22:00",
+		QuietEnd:    "7:00",
+		Timezone:    "America/Chicago",
+	}
+
+	tests := []struct {
+		desc     string
+		prefs    data.NotifyPrefs
+		severity string
+		t        time.Time
+		exp      bool
+	}{
+		{"no prefs", data.NotifyPrefs{}, data.PointValueInfo, night, true},
+		{"opt out", data.NotifyPrefs{OptOut: true}, data.PointValueCritical, day, false},
+		{"below min", onCall, data.PointValueInfo, day, false},
+		{"blank is warning", onCall, "", day, true},
+		{"quiet hours", onCall, data.PointValueWarning, night, false},
+		{"critical in quiet hours", onCall, data.PointValueCritical, night, true},
+		{"quiet severity", data.NotifyPrefs{QuietStart: "22:00", QuietEnd: "7:00",
+			Timezone: "America/Chicago", QuietSeverity: data.PointValueWarning},
+			data.PointValueWarning, night, true},
+		// quiet hours are in the user's timezone, 3:00 in Chicago is 8:00 UTC
+		{"quiet hours UTC", data.NotifyPrefs{QuietStart: "22:00", QuietEnd: "7:00"},
+			data.PointValueWarning, night, true},
+		{"invalid quiet hours", data.NotifyPrefs{QuietStart: "late", QuietEnd: "7:00"},
+			data.PointValueWarning, night, true},
+	}
+
+	for _, test := range tests {
+		if notifyAllowed(test.prefs, test.severity, test.t) != test.exp {
+			t.Errorf("%v: expected %v", test.desc, test.exp)
+		}
+	}
+}
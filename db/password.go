@@ -0,0 +1,184 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/genjidb/genji"
+	"github.com/genjidb/genji/document"
+	"github.com/genjidb/genji/types"
+	"github.com/simpleiot/simpleiot/data"
+)
+
+// defaultAdminEmail and defaultAdminPass are the credentials of the admin
+// user that is created with a new database
+const (
+	defaultAdminEmail = "admin@admin.com"
+	defaultAdminPass  = "admin"
+)
+
+// hashPasswords replaces the pass points of a user node with bcrypt hashes,
+// so that passwords are never stored or recorded in history as plain text.
+// Setting the password clears passChange, unless it is set in the same
+// points. Pass points of other nodes, like SMTP message services, are not
+// changed as the service needs the password. Passwords synchronized from
+// another instance (points with an origin) are already hashed, and do not
+// clear passChange as the passChange point is synchronized with them.
+func (nh *NatsHandler) hashPasswords(nodeID string, points data.Points) (data.Points, error) {
+	var passPoints []int
+	typ := ""
+	passChangeSet := false
+	local := false
+
+	for i, p := range points {
+		switch p.Type {
+		case data.PointTypePass:
+			passPoints = append(passPoints, i)
+			if p.Origin == "" {
+				local = true
+			}
+		case data.PointTypeNodeType:
+			typ = p.Text
+		case data.PointTypePassChange:
+			passChangeSet = true
+		}
+	}
+
+	if len(passPoints) == 0 {
+		return points, nil
+	}
+
+	var user data.User
+
+	node, err := nh.db.node(nodeID)
+	if err == nil {
+		user = node.ToUser()
+		if typ == "" {
+			typ = node.Type
+		}
+	}
+
+	if typ != data.NodeTypeUser {
+		return points, nil
+	}
+
+	var last time.Time
+
+	for _, i := range passPoints {
+		p := &points[i]
+
+		if p.Time.After(last) {
+			last = p.Time
+		}
+
+		if data.PasswordHashed(p.Text) {
+			continue
+		}
+
+		if p.Text == "" {
+			return nil, errors.New("password can't be blank")
+		}
+
+		if user.PassChange && data.CheckPassword(user.Pass, p.Text) {
+			return nil, errors.New("new password must be different")
+		}
+
+		p.Text, err = data.HashPassword(p.Text)
+		if err != nil {
+			return nil, fmt.Errorf("Error hashing password: %w", err)
+		}
+	}
+
+	if user.PassChange && !passChangeSet && local {
+		points = append(points, data.Point{
+			Type:  data.PointTypePassChange,
+			Time:  last,
+			Value: 0,
+		})
+	}
+
+	return points, nil
+}
+
+// migratePasswords hashes the passwords of users that are stored as plain
+// text, and removes passwords from point history. The default admin user
+// must change their password if it was not changed.
+func (gen *Db) migratePasswords() error {
+	return gen.store.Update(func(tx *genji.Tx) error {
+		var nodes []data.Node
+
+		res, err := tx.Query(`select * from nodes where type = ?`, data.NodeTypeUser)
+		if err != nil {
+			return err
+		}
+		defer res.Close()
+
+		err = res.Iterate(func(d types.Document) error {
+			var node data.Node
+			err := document.StructScan(d, &node)
+			if err != nil {
+				return err
+			}
+
+			nodes = append(nodes, node)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		count := 0
+
+		for _, node := range nodes {
+			user := node.ToUser()
+			changed := false
+
+			for i, p := range node.Points {
+				if p.Type != data.PointTypePass || p.Text == "" ||
+					data.PasswordHashed(p.Text) {
+					continue
+				}
+
+				node.Points[i].Text, err = data.HashPassword(p.Text)
+				if err != nil {
+					return fmt.Errorf("Error hashing password: %w", err)
+				}
+
+				changed = true
+			}
+
+			if !changed {
+				continue
+			}
+
+			if user.Email == defaultAdminEmail && user.Pass == defaultAdminPass {
+				node.Points.ProcessPoint(data.Point{
+					Type:  data.PointTypePassChange,
+					Time:  time.Now(),
+					Value: 1,
+				})
+			}
+
+			err := tx.Exec(`update nodes set points = ? where id = ?`,
+				node.Points, node.ID)
+			if err != nil {
+				return fmt.Errorf("Error updating user %v: %w", node.ID, err)
+			}
+
+			count++
+		}
+
+		if count > 0 {
+			log.Printf("Hashed the passwords of %v users\n", count)
+		}
+
+		err = tx.Exec(`delete from history where type = ?`, data.PointTypePass)
+		if err != nil {
+			return fmt.Errorf("Error removing passwords from history: %w", err)
+		}
+
+		return nil
+	})
+}
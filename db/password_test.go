@@ -0,0 +1,274 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	natsgo "github.com/nats-io/nats.go"
+	"github.com/simpleiot/simpleiot/data"
+	"github.com/simpleiot/simpleiot/nats"
+)
+
+func TestMigratePasswords(t *testing.T) {
+	db, err := NewDb(StoreTypeMemory, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+
+	users := map[string]data.User{
+		"admin": {Email: defaultAdminEmail, Pass: defaultAdminPass},
+		"bob":   {Email: "bob@example.com", Pass: "secret"},
+	}
+
+	for id, u := range users {
+		_, err := db.nodePoints(id, u.ToPoints())
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// passwords were recorded in history by earlier versions
+	err = db.store.Exec(`insert into history values ?`, newHistoryPoint("bob",
+		data.Point{Type: data.PointTypePass, Time: now, Text: "secret"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.migratePasswords()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for id, u := range users {
+		node, err := db.node(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		user := node.ToUser()
+
+		if !data.PasswordHashed(user.Pass) {
+			t.Errorf("%v: password not hashed", id)
+		}
+
+		if user.PassChange != (id == "admin") {
+			t.Errorf("%v: wrong passChange: %v", id, user.PassChange)
+		}
+
+		checked, err := db.UserCheck(u.Email, u.Pass)
+		if err != nil || checked == nil || checked.ID != id {
+			t.Errorf("%v: login failed: %v", id, err)
+		}
+
+		checked, err = db.UserCheck(u.Email, user.Pass)
+		if err != nil || checked != nil {
+			t.Errorf("%v: login with the hash should fail: %v", id, err)
+		}
+	}
+
+	history, err := db.history("bob", data.HistoryQuery{Start: now.Add(-time.Minute),
+		End: now.Add(time.Minute)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(history) != 0 {
+		t.Errorf("password not removed from history: %+v", history)
+	}
+
+	// hashes are not hashed again
+	hash, _ := db.node("bob")
+
+	err = db.migratePasswords()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	node, _ := db.node("bob")
+
+	if node.ToUser().Pass != hash.ToUser().Pass {
+		t.Error("password was hashed twice")
+	}
+}
+
+func TestHashPasswords(t *testing.T) {
+	db, err := NewDb(StoreTypeMemory, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nh := &NatsHandler{db: db}
+
+	hash, _ := data.HashPassword(defaultAdminPass)
+	admin := data.User{Email: defaultAdminEmail, Pass: hash, PassChange: true}
+
+	_, err = db.nodePoints("admin", admin.ToPoints())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.nodePoints("smtp", data.Points{
+		{Type: data.PointTypeNodeType, Text: data.NodeTypeMsgService},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// other nodes keep the password, as services need it
+	points, err := nh.hashPasswords("smtp", data.Points{
+		{Type: data.PointTypePass, Text: "smtp secret"},
+	})
+	if err != nil || points[0].Text != "smtp secret" {
+		t.Errorf("SMTP password changed: %+v, %v", points, err)
+	}
+
+	_, err = nh.hashPasswords("admin", data.Points{
+		{Type: data.PointTypePass, Text: defaultAdminPass},
+	})
+	if err == nil {
+		t.Error("expected error for unchanged password")
+	}
+
+	_, err = nh.hashPasswords("admin", data.Points{
+		{Type: data.PointTypePass, Text: ""},
+	})
+	if err == nil {
+		t.Error("expected error for blank password")
+	}
+
+	points, err = nh.hashPasswords("admin", data.Points{
+		{Type: data.PointTypePass, Time: time.Now(), Text: "new secret"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(points) != 2 || !data.CheckPassword(points[0].Text, "new secret") {
+		t.Fatalf("password not hashed: %+v", points)
+	}
+
+	if points[1].Type != data.PointTypePassChange || points[1].Value != 0 {
+		t.Errorf("passChange not cleared: %+v", points[1])
+	}
+
+	// new users are hashed, and keep passChange if it is set
+	points, err = nh.hashPasswords("new", data.Points{
+		{Type: data.PointTypeNodeType, Text: data.NodeTypeUser},
+		{Type: data.PointTypePass, Text: "pass"},
+		{Type: data.PointTypePassChange, Value: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(points) != 3 || !data.CheckPassword(points[1].Text, "pass") {
+		t.Errorf("new user password not hashed: %+v", points)
+	}
+}
+
+func TestSyncUser(t *testing.T) {
+	cloud := newTestInstance(t)
+	edge := newTestInstance(t)
+
+	user := data.User{Email: "bob@example.com", Pass: "secret"}
+	userID := edge.newNode(t, data.NodeTypeUser, user.ToPoints())
+
+	svcID := edge.newNode(t, data.NodeTypeMsgService, data.Points{
+		{Type: data.PointTypeService, Text: data.PointValueSMTP},
+		{Type: data.PointTypePass, Text: "smtp secret"},
+	})
+
+	edge.upstream(t, cloud)
+
+	login := func(pass string) bool {
+		return eventually(func() bool {
+			u, err := cloud.db.UserCheck(user.Email, pass)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			return u != nil
+		})
+	}
+
+	if !login("secret") {
+		t.Fatal("synced user can't log in")
+	}
+
+	svcPass := func() bool {
+		svc, err := cloud.db.node(svcID)
+		if err != nil {
+			return false
+		}
+
+		pass, _ := svc.Points.Text("", data.PointTypePass, 0)
+		return pass == "smtp secret"
+	}
+
+	if !eventually(svcPass) {
+		t.Error("message service password not synced")
+	}
+
+	// nodes are only synced again if the hashes differ
+	for _, id := range []string{userID, svcID} {
+		synced := eventually(func() bool {
+			nodeEdge, err := nats.GetNodeSync(edge.nc, id, edge.root)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			nodeCloud, err := nats.GetNodeSync(cloud.nc, id, edge.root)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			return string(nodeEdge.Hash) == string(nodeCloud.Hash)
+		})
+
+		if !synced {
+			t.Errorf("hash of %v differs after sync", id)
+		}
+	}
+
+	// clients do not get the password
+	n, err := nats.GetNode(cloud.nc, userID, "skip")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := n.Points.Find("", data.PointTypePass, 0); ok {
+		t.Error("password returned to client")
+	}
+
+	// nor do sync requests without the auth token
+	req := natsgo.NewMsg(nats.SubjectNodeSync(userID))
+	req.Data = []byte("skip")
+	req.Header.Set("Authorization", "wrong token")
+
+	resp, err := cloud.nc.RequestMsg(req, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err = data.PbDecodeNodeRequest(resp.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := n.Points.Find("", data.PointTypePass, 0); ok {
+		t.Error("password returned to sync request without auth token")
+	}
+
+	// password changes are synced
+	err = nats.SendNodePoint(edge.nc, userID, data.Point{Type: data.PointTypePass,
+		Text: "new secret"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !login("new secret") {
+		t.Error("changed password not synced")
+	}
+}
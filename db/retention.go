@@ -0,0 +1,397 @@
+package db
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/genjidb/genji"
+	"github.com/genjidb/genji/document"
+	genjierrors "github.com/genjidb/genji/errors"
+	"github.com/genjidb/genji/types"
+	"github.com/simpleiot/simpleiot/data"
+)
+
+// historyPolicy is a retention policy applied to a single node. exclude
+// lists point types that are handled by a more specific policy on the
+// same node.
+type historyPolicy struct {
+	nodeID    string
+	retention *data.Retention
+	exclude   []string
+}
+
+// where returns a where clause and args that select the history points
+// this policy applies to.
+func (hp *historyPolicy) where(rollup time.Duration) (string, []interface{}) {
+	clause := []string{"nodeid = ?", "rollup = ?"}
+	args := []interface{}{hp.nodeID, int64(rollup)}
+
+	if hp.retention.PointType != "" {
+		clause = append(clause, "type = ?")
+		args = append(args, hp.retention.PointType)
+	}
+
+	for _, t := range hp.exclude {
+		clause = append(clause, "type != ?")
+		args = append(args, t)
+	}
+
+	return strings.Join(clause, " and "), args
+}
+
+// historyPolicies finds all retention nodes and returns the policies that
+// apply to each node in the tree. If several retention nodes apply to the
+// same node and point type, the one closest to the node wins.
+func (gen *Db) historyPolicies() ([]*historyPolicy, error) {
+	type parentRetention struct {
+		parent    string
+		distRoot  int
+		retention *data.Retention
+	}
+
+	var prs []parentRetention
+
+	err := gen.store.View(func(tx *genji.Tx) error {
+		res, err := tx.Query(`select * from nodes where type = ?`, data.NodeTypeRetention)
+		if err != nil {
+			return err
+		}
+
+		defer res.Close()
+
+		var nodes []data.Node
+
+		err = res.Iterate(func(d types.Document) error {
+			var node data.Node
+			err := document.StructScan(d, &node)
+			if err != nil {
+				return err
+			}
+
+			nodes = append(nodes, node)
+			return nil
+		})
+
+		if err != nil {
+			return err
+		}
+
+		for _, node := range nodes {
+			edges, err := txEdgeUp(tx, node.ID, false)
+			if err != nil {
+				return err
+			}
+
+			for _, e := range edges {
+				r, err := data.NodeToRetention(node.ToNodeEdge(*e))
+				if err != nil {
+					return err
+				}
+
+				prs = append(prs, parentRetention{parent: e.Up, retention: r})
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range prs {
+		prs[i].distRoot, err = gen.minDistToRoot(prs[i].parent)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// apply policies closest to root first so that policies further down
+	// the tree override them
+	sort.SliceStable(prs, func(i, j int) bool {
+		return prs[i].distRoot < prs[j].distRoot
+	})
+
+	type policyKey struct {
+		nodeID    string
+		pointType string
+	}
+
+	policies := make(map[policyKey]*historyPolicy)
+	var keys []policyKey
+
+	for _, pr := range prs {
+		ids := []string{pr.parent}
+
+		desc, err := gen.nodeDescendents(pr.parent, "", true, false)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, d := range desc {
+			if d.Type == data.NodeTypeRetention {
+				continue
+			}
+			ids = append(ids, d.ID)
+		}
+
+		for _, id := range ids {
+			k := policyKey{id, pr.retention.PointType}
+			if _, ok := policies[k]; !ok {
+				keys = append(keys, k)
+			}
+			policies[k] = &historyPolicy{nodeID: id, retention: pr.retention}
+		}
+	}
+
+	ret := make([]*historyPolicy, 0, len(keys))
+
+	for _, k := range keys {
+		p := policies[k]
+		if k.pointType == "" {
+			for _, k2 := range keys {
+				if k2.nodeID == k.nodeID && k2.pointType != "" {
+					p.exclude = append(p.exclude, k2.pointType)
+				}
+			}
+		}
+		ret = append(ret, p)
+	}
+
+	return ret, nil
+}
+
+// historyTime returns an aggregate (min or max) of the time field for
+// history points matching the where clause. ok is false if there are no
+// matching points.
+func (gen *Db) historyTime(agg, where string, args []interface{}) (t int64, ok bool, err error) {
+	doc, err := gen.store.QueryDocument(
+		fmt.Sprintf(`select %v(time) as t from history where %v`, agg, where), args...)
+	if err != nil {
+		if err == genjierrors.ErrDocumentNotFound {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+
+	v, err := doc.GetByField("t")
+	if err != nil {
+		return 0, false, err
+	}
+
+	switch v.Type() {
+	case types.IntegerValue:
+		return v.V().(int64), true, nil
+	case types.DoubleValue:
+		return int64(v.V().(float64)), true, nil
+	}
+
+	return 0, false, nil
+}
+
+// rollupRecord is stored in the rollups table and records the end of the
+// last period rolled up for a policy tier as Unix nanoseconds
+type rollupRecord struct {
+	ID   string
+	Time int64
+}
+
+// rollupID returns the ID of the rollup record for a policy tier
+func (hp *historyPolicy) rollupID(period time.Duration) string {
+	return fmt.Sprintf("%v:%v:%v", hp.nodeID, hp.retention.PointType, int64(period))
+}
+
+// rollupTime returns the end of the last period rolled up for a policy
+// tier. ok is false if the tier has not been rolled up yet.
+func (gen *Db) rollupTime(id string) (t time.Time, ok bool, err error) {
+	doc, err := gen.store.QueryDocument(`select * from rollups where id = ?`, id)
+	if err != nil {
+		if err == genjierrors.ErrDocumentNotFound {
+			return time.Time{}, false, nil
+		}
+		return time.Time{}, false, err
+	}
+
+	var r rollupRecord
+	err = document.StructScan(doc, &r)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	return time.Unix(0, r.Time), true, nil
+}
+
+// historyRollup downsamples raw points for a policy tier. Rollups start
+// after the last rollup for the tier and only include complete periods.
+// Periods are processed one at a time, and the last rollup time is
+// committed with each period, so that large histories are not loaded into
+// memory and compaction resumes where it left off if interrupted.
+func (gen *Db) historyRollup(hp *historyPolicy, tier data.RetentionTier, now time.Time) error {
+	id := hp.rollupID(tier.Period)
+	rawWhere, rawArgs := hp.where(0)
+
+	rawQueryArgs := func(args ...interface{}) []interface{} {
+		return append(append([]interface{}{}, rawArgs...), args...)
+	}
+
+	from, ok, err := gen.rollupTime(id)
+	if err != nil {
+		return fmt.Errorf("Error getting last rollup time: %w", err)
+	}
+
+	if !ok {
+		// databases from earlier versions only have the rollup points
+		tierWhere, tierArgs := hp.where(tier.Period)
+		last, ok, err := gen.historyTime("max", tierWhere, tierArgs)
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			from = time.Unix(0, last).Add(tier.Period)
+		} else {
+			first, ok, err := gen.historyTime("min", rawWhere, rawArgs)
+			if err != nil {
+				return err
+			}
+
+			if !ok {
+				// no history for this node
+				return nil
+			}
+
+			from = time.Unix(0, first).Truncate(tier.Period)
+		}
+	}
+
+	to := now.Truncate(tier.Period)
+
+	for from.Before(to) {
+		// skip periods without any history
+		first, ok, err := gen.historyTime("min", rawWhere+` and time >= ?`,
+			rawQueryArgs(from.UnixNano()))
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			return nil
+		}
+
+		start := time.Unix(0, first).Truncate(tier.Period)
+		if start.Before(from) {
+			start = from
+		}
+
+		if !start.Before(to) {
+			return nil
+		}
+
+		end := start.Add(tier.Period)
+
+		raw, err := gen.historyQuery(`select * from history where `+rawWhere+
+			` and time >= ? and time < ?`,
+			rawQueryArgs(start.UnixNano(), end.UnixNano())...)
+		if err != nil {
+			return err
+		}
+
+		rollups := data.Rollup(raw, tier.Period)
+
+		err = gen.store.Update(func(tx *genji.Tx) error {
+			for _, p := range rollups {
+				rp := newHistoryPoint(hp.nodeID, p)
+				rp.Rollup = int64(tier.Period)
+				err := tx.Exec(`insert into history values ?`, rp)
+				if err != nil {
+					return fmt.Errorf("Error inserting rollup point: %w", err)
+				}
+			}
+
+			err := tx.Exec(`insert into rollups values ? on conflict do replace`,
+				rollupRecord{ID: id, Time: end.UnixNano()})
+			if err != nil {
+				return fmt.Errorf("Error updating last rollup time: %w", err)
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			return err
+		}
+
+		from = end
+	}
+
+	return nil
+}
+
+// historyExpire deletes history points older than the retention time
+func (gen *Db) historyExpire(hp *historyPolicy, rollup, retention time.Duration, now time.Time) error {
+	if retention <= 0 {
+		return nil
+	}
+
+	where, args := hp.where(rollup)
+
+	return gen.store.Exec(`delete from history where `+where+` and time < ?`,
+		append(args, now.Add(-retention).UnixNano())...)
+}
+
+// historyCompact applies all retention policies to the history and events
+// tables.
+// Each node is processed in separate short transactions so that point
+// writes are not blocked while compacting.
+func (gen *Db) historyCompact(now time.Time) error {
+	policies, err := gen.historyPolicies()
+	if err != nil {
+		return fmt.Errorf("Error getting retention policies: %w", err)
+	}
+
+	for _, hp := range policies {
+		for _, tier := range hp.retention.Tiers {
+			err := gen.historyRollup(hp, tier, now)
+			if err != nil {
+				return fmt.Errorf("Error rolling up history for node %v: %w",
+					hp.nodeID, err)
+			}
+
+			err = gen.historyExpire(hp, tier.Period, tier.Retention, now)
+			if err != nil {
+				return fmt.Errorf("Error expiring rollups for node %v: %w",
+					hp.nodeID, err)
+			}
+		}
+
+		err := gen.historyExpire(hp, 0, hp.retention.Raw, now)
+		if err != nil {
+			return fmt.Errorf("Error expiring history for node %v: %w",
+				hp.nodeID, err)
+		}
+
+		if hp.retention.PointType == "" {
+			err := gen.eventExpire(hp.nodeID, hp.retention.Events, now)
+			if err != nil {
+				return fmt.Errorf("Error expiring events for node %v: %w",
+					hp.nodeID, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// runHistoryCompaction periodically compacts the history table
+func (gen *Db) runHistoryCompaction(period time.Duration) {
+	for {
+		err := gen.historyCompact(time.Now())
+		if err != nil {
+			log.Println("Error compacting history: ", err)
+		}
+		time.Sleep(period)
+	}
+}
@@ -0,0 +1,106 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/simpleiot/simpleiot/data"
+)
+
+func TestHistoryCompact(t *testing.T) {
+	db, err := NewDb(StoreTypeMemory, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newNode := func(id, parent, typ string, points data.Points) {
+		points = append(points, data.Point{Type: data.PointTypeNodeType, Text: typ})
+		_, err := db.nodePoints(id, points)
+		if err != nil {
+			t.Fatal("Error creating node: ", err)
+		}
+
+		if parent != "" {
+			_, err := db.edgePoints(id, parent,
+				data.Points{{Type: data.PointTypeTombstone, Value: 0}})
+			if err != nil {
+				t.Fatal("Error creating edge: ", err)
+			}
+		}
+	}
+
+	newNode("root", "", data.NodeTypeDevice, nil)
+	newNode("dev", "root", data.NodeTypeDevice, nil)
+	newNode("other", "root", data.NodeTypeDevice, nil)
+	newNode("ret", "dev", data.NodeTypeRetention, data.Points{
+		{Type: data.PointTypeRawRetention, Value: 1},
+		{Type: data.PointTypeRollupPeriod, Value: 60},
+	})
+
+	start := time.Date(2021, time.September, 1, 0, 0, 0, 0, time.UTC)
+	now := start.Add(48 * time.Hour)
+
+	var points data.Points
+	for i := 0; i < 48*6; i++ {
+		points = append(points, data.Point{
+			Type:  data.PointTypeValue,
+			Time:  start.Add(time.Duration(i) * 10 * time.Minute),
+			Value: float64(i),
+		})
+	}
+
+	for _, id := range []string{"dev", "other"} {
+		err = db.historyWrite(id, points)
+		if err != nil {
+			t.Fatal("Error writing history: ", err)
+		}
+	}
+
+	// compaction resumes after the last rollup, and running twice must not
+	// duplicate rollups
+	for _, n := range []time.Time{start.Add(90 * time.Minute), now, now} {
+		err = db.historyCompact(n)
+		if err != nil {
+			t.Fatal("Error compacting history: ", err)
+		}
+	}
+
+	hp := &historyPolicy{nodeID: "dev", retention: &data.Retention{}}
+	last, ok, err := db.rollupTime(hp.rollupID(time.Hour))
+	if err != nil || !ok || !last.Equal(now) {
+		t.Errorf("wrong last rollup time: %v, %v, %v", last, ok, err)
+	}
+
+	raw, err := db.history("dev", data.HistoryQuery{Index: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(raw) != 24*6 {
+		t.Error("expected 144 raw points, got: ", len(raw))
+	}
+
+	rollups, err := db.history("dev", data.HistoryQuery{Index: -1, Rollup: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rollups) != 48 {
+		t.Fatal("expected 48 rollup points, got: ", len(rollups))
+	}
+
+	r := rollups[0]
+	if !r.Time.Equal(start) || r.Value != 2.5 || r.Min != 0 || r.Max != 5 ||
+		r.Duration != time.Hour {
+		t.Error("first rollup point is not correct: ", r)
+	}
+
+	other, err := db.history("other", data.HistoryQuery{Index: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(other) != len(points) {
+		t.Error("history for node without retention policy was modified")
+	}
+}
@@ -0,0 +1,553 @@
+package db
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/simpleiot/simpleiot/data"
+)
+
+// ruleRetryInterval is how long the rule scheduler waits before running a
+// rule again after an error
+const ruleRetryInterval = time.Minute
+
+// ruleKey identifies a rule under a parent, as a rule may have more than
+// one parent
+type ruleKey struct {
+	id     string
+	parent string
+}
+
+// ruleCache holds compiled rules so that rules do not need to be loaded from
+// the database for every point. A rule is compiled again when any of its
+// nodes (the rule, conditions, condition groups, actions, or calendars)
+// change, and all rules are reloaded when the node tree changes. Rules are
+// indexed by their parent, by the nodes their conditions reference, and by
+// the point types their conditions watch, so that points only need to be
+// processed by the rules that watch them. The cache also tracks when each
+// rule must be run next by the rule scheduler. Variables are loaded with the
+// rules, and are indexed by their input nodes.
+type ruleCache struct {
+	lock sync.Mutex
+	// version is incremented when rules are invalidated, so that rules
+	// compiled from stale nodes are not stored
+	version    int
+	treeChange int
+	treeLoaded int
+	// compiled rules, nil if a rule must be compiled
+	rules    map[ruleKey]*data.Rule
+	children map[string][]ruleKey
+	members  map[string]map[ruleKey]bool
+	watchers map[string]map[ruleKey]bool
+	next     map[ruleKey]time.Time
+	wake     chan struct{}
+	// point types watched by conditions, a blank type matches any point
+	types map[string]map[ruleKey]bool
+	// variables by ID, and the IDs of the variables that use a node as an
+	// input
+	variables map[string]*data.Variable
+	inputs    map[string]map[string]bool
+}
+
+func newRuleCache() *ruleCache {
+	return &ruleCache{
+		// the tree is loaded the first time rules are used
+		treeChange: 1,
+		rules:      make(map[ruleKey]*data.Rule),
+		children:   make(map[string][]ruleKey),
+		members:    make(map[string]map[ruleKey]bool),
+		watchers:   make(map[string]map[ruleKey]bool),
+		types:      make(map[string]map[ruleKey]bool),
+		variables:  make(map[string]*data.Variable),
+		inputs:     make(map[string]map[string]bool),
+		next:       make(map[ruleKey]time.Time),
+		wake:       make(chan struct{}, 1),
+	}
+}
+
+// loaded returns true if the rules are loaded, and a value to pass to reset
+// if not
+func (rc *ruleCache) loaded() (bool, int) {
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+	return rc.treeLoaded == rc.treeChange, rc.treeChange
+}
+
+// reset replaces the rules and variables in the cache with the rule nodes
+// and variables in the tree, and schedules all rules to run now. treeChange
+// is the value returned by loaded before the nodes were read.
+func (rc *ruleCache) reset(treeChange int, nodes []data.NodeEdge, variables []*data.Variable, now time.Time) {
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+
+	rc.version++
+	rc.treeLoaded = treeChange
+	rc.rules = make(map[ruleKey]*data.Rule)
+	rc.children = make(map[string][]ruleKey)
+	rc.members = make(map[string]map[ruleKey]bool)
+	rc.watchers = make(map[string]map[ruleKey]bool)
+	rc.types = make(map[string]map[ruleKey]bool)
+	rc.next = make(map[ruleKey]time.Time)
+	rc.variables = make(map[string]*data.Variable)
+	rc.inputs = make(map[string]map[string]bool)
+
+	for _, n := range nodes {
+		key := ruleKey{n.ID, n.Parent}
+		if _, ok := rc.rules[key]; ok {
+			continue
+		}
+		rc.rules[key] = nil
+		rc.children[n.Parent] = append(rc.children[n.Parent], key)
+		rc.next[key] = now
+	}
+
+	for _, v := range variables {
+		rc.putVariableLocked(v)
+	}
+
+	rc.signal()
+}
+
+// treeChanged causes the rules to be reloaded
+func (rc *ruleCache) treeChanged() {
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+	rc.treeChange++
+	rc.version++
+	rc.signal()
+}
+
+// invalidate causes rules that a node is part of to be compiled again and
+// run by the scheduler
+func (rc *ruleCache) invalidate(nodeID string, now time.Time) {
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+
+	keys, ok := rc.members[nodeID]
+	if !ok {
+		return
+	}
+
+	rc.version++
+	delete(rc.members, nodeID)
+
+	for key := range keys {
+		if _, ok := rc.rules[key]; ok {
+			rc.rules[key] = nil
+			rc.next[key] = now
+		}
+	}
+
+	rc.signal()
+}
+
+// update applies points written to a node to the compiled rules the node is
+// part of. State points written by the rule engine (see data.RuleStatePoint)
+// are applied to the compiled rules, and the rules are only compiled again
+// if any other points changed.
+func (rc *ruleCache) update(nodeID string, points data.Points, now time.Time) {
+	for _, p := range points {
+		if !data.RuleStatePoint(p.Type) {
+			rc.invalidate(nodeID, now)
+			return
+		}
+	}
+
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+
+	keys, ok := rc.members[nodeID]
+	if !ok {
+		return
+	}
+
+	// rules compiled before the points were written have stale state
+	rc.version++
+
+	for key := range keys {
+		if r := rc.rules[key]; r != nil {
+			r.ProcessStatePoints(nodeID, points)
+		}
+	}
+}
+
+// get returns a copy of a compiled rule. If the rule is not compiled, ok is
+// false, and version must be passed to put when the rule is compiled.
+func (rc *ruleCache) get(key ruleKey) (rule *data.Rule, version int, ok bool) {
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+
+	r := rc.rules[key]
+	if r == nil {
+		return nil, rc.version, false
+	}
+
+	return r.Copy(), rc.version, true
+}
+
+// put stores a compiled rule unless rules have been invalidated since
+// version was returned by get
+func (rc *ruleCache) put(key ruleKey, r *data.Rule, version int) {
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+
+	if version != rc.version {
+		return
+	}
+
+	if _, ok := rc.rules[key]; !ok {
+		return
+	}
+
+	rc.rules[key] = r
+
+	add := func(index map[string]map[ruleKey]bool, id string) {
+		if id == "" {
+			return
+		}
+		if index[id] == nil {
+			index[id] = make(map[ruleKey]bool)
+		}
+		index[id][key] = true
+	}
+
+	add(rc.members, r.ID)
+
+	for _, a := range r.Actions {
+		add(rc.members, a.ID)
+	}
+
+	for _, a := range r.ActionsInactive {
+		add(rc.members, a.ID)
+	}
+
+	var addGroups func(groups []data.ConditionGroup)
+	addGroups = func(groups []data.ConditionGroup) {
+		for _, g := range groups {
+			add(rc.members, g.ID)
+			addGroups(g.Groups)
+		}
+	}
+
+	addGroups(r.Groups)
+
+	for _, c := range r.AllConditions() {
+		add(rc.members, c.ID)
+		add(rc.members, c.Calendar)
+		add(rc.watchers, c.NodeID)
+
+		switch c.ConditionType {
+		case data.PointValuePointValue, data.PointValueWindow,
+			data.PointValueNoUpdate:
+			if rc.types[c.PointType] == nil {
+				rc.types[c.PointType] = make(map[ruleKey]bool)
+			}
+			rc.types[c.PointType][key] = true
+		}
+	}
+}
+
+// childRules returns the rules under a node
+func (rc *ruleCache) childRules(parentID string) []ruleKey {
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+	return append([]ruleKey(nil), rc.children[parentID]...)
+}
+
+// watcherRules returns the rules with conditions that reference a node
+func (rc *ruleCache) watcherRules(nodeID string) []ruleKey {
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+
+	var ret []ruleKey
+	for key := range rc.watchers[nodeID] {
+		ret = append(ret, key)
+	}
+
+	return ret
+}
+
+// watching returns the rules in keys that may watch one of the points.
+// Rules that are not compiled yet are always returned.
+func (rc *ruleCache) watching(keys []ruleKey, points data.Points) []ruleKey {
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+
+	var ret []ruleKey
+	for _, key := range keys {
+		if rc.rules[key] == nil || rc.types[""][key] {
+			ret = append(ret, key)
+			continue
+		}
+
+		for _, p := range points {
+			if rc.types[p.Type][key] {
+				ret = append(ret, key)
+				break
+			}
+		}
+	}
+
+	return ret
+}
+
+// variable returns a variable from the cache
+func (rc *ruleCache) variable(id string) (*data.Variable, bool) {
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+	v, ok := rc.variables[id]
+	return v, ok
+}
+
+// inputVariables returns the variables that use a node as an input
+func (rc *ruleCache) inputVariables(nodeID string) []*data.Variable {
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+
+	var ret []*data.Variable
+	for id := range rc.inputs[nodeID] {
+		ret = append(ret, rc.variables[id])
+	}
+
+	return ret
+}
+
+// putVariable stores a variable that has changed. Variables are not
+// modified once they are stored.
+func (rc *ruleCache) putVariable(v *data.Variable) {
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+	rc.putVariableLocked(v)
+}
+
+// putVariableLocked stores a variable. Must be called with the lock held.
+func (rc *ruleCache) putVariableLocked(v *data.Variable) {
+	if old, ok := rc.variables[v.ID]; ok {
+		for _, in := range old.Inputs {
+			delete(rc.inputs[in.NodeID], v.ID)
+		}
+	}
+
+	rc.variables[v.ID] = v
+
+	for _, in := range v.Inputs {
+		if rc.inputs[in.NodeID] == nil {
+			rc.inputs[in.NodeID] = make(map[string]bool)
+		}
+		rc.inputs[in.NodeID][v.ID] = true
+	}
+}
+
+// schedule sets when a rule must be run next by the scheduler. If replace is
+// false, the time is only changed if t is earlier. A zero time with replace
+// set removes the timer.
+func (rc *ruleCache) schedule(key ruleKey, t time.Time, replace bool) {
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+
+	if _, ok := rc.rules[key]; !ok {
+		return
+	}
+
+	if t.IsZero() {
+		if replace {
+			delete(rc.next, key)
+		}
+		return
+	}
+
+	current, ok := rc.next[key]
+	if ok && !replace && !t.Before(current) {
+		return
+	}
+
+	rc.next[key] = t
+
+	if !ok || t.Before(current) {
+		rc.signal()
+	}
+}
+
+// due returns the rules that must be run at time now, and removes their
+// timers
+func (rc *ruleCache) due(now time.Time) []ruleKey {
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+
+	var ret []ruleKey
+	for key, t := range rc.next {
+		if !t.After(now) {
+			ret = append(ret, key)
+			delete(rc.next, key)
+		}
+	}
+
+	return ret
+}
+
+// nextTime returns when the next rule must run, or a zero time if no rules
+// are scheduled
+func (rc *ruleCache) nextTime() time.Time {
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+
+	var ret time.Time
+	for _, t := range rc.next {
+		if ret.IsZero() || t.Before(ret) {
+			ret = t
+		}
+	}
+
+	return ret
+}
+
+// signal wakes up the scheduler. Must be called with the lock held.
+func (rc *ruleCache) signal() {
+	select {
+	case rc.wake <- struct{}{}:
+	default:
+	}
+}
+
+// ruleLoad loads the rules in the node tree if the tree has changed
+func (nh *NatsHandler) ruleLoad() error {
+	loaded, treeChange := nh.rules.loaded()
+	if loaded {
+		return nil
+	}
+
+	nodes, err := nh.db.nodeDescendents(nh.db.rootNodeID(), data.NodeTypeRule,
+		true, false)
+	if err != nil {
+		return err
+	}
+
+	variables, err := nh.db.variables()
+	if err != nil {
+		return err
+	}
+
+	nh.rules.reset(treeChange, nodes, variables, time.Now())
+
+	return nil
+}
+
+// rule returns a copy of a rule from the cache, compiling the rule if
+// needed. The copy can be modified while the rule is processed.
+func (nh *NatsHandler) rule(key ruleKey) (*data.Rule, error) {
+	r, version, ok := nh.rules.get(key)
+	if ok {
+		return r, nil
+	}
+
+	r, err := nh.compileRule(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nh.rules.put(key, r, version)
+
+	return r.Copy(), nil
+}
+
+// compileRule loads a rule and its conditions, condition groups, and
+// actions from the database
+func (nh *NatsHandler) compileRule(key ruleKey) (*data.Rule, error) {
+	node, err := nh.db.node(key.id)
+	if err != nil {
+		return nil, err
+	}
+
+	ruleNode := data.NodeEdge{
+		ID:     node.ID,
+		Type:   node.Type,
+		Parent: key.parent,
+		Points: node.Points,
+	}
+
+	conditionNodes, err := nh.db.nodeDescendents(key.id, data.NodeTypeCondition,
+		false, false)
+	if err != nil {
+		return nil, err
+	}
+
+	actionNodes, err := nh.db.nodeDescendents(key.id, data.NodeTypeAction,
+		false, false)
+	if err != nil {
+		return nil, err
+	}
+
+	actionInactiveNodes, err := nh.db.nodeDescendents(key.id,
+		data.NodeTypeActionInactive,
+		false, false)
+	if err != nil {
+		return nil, err
+	}
+
+	rule, err := data.NodeToRule(ruleNode, conditionNodes, actionNodes, actionInactiveNodes)
+	if err != nil {
+		return nil, err
+	}
+
+	rule.Groups, err = nh.conditionGroups(key.id)
+	if err != nil {
+		return nil, err
+	}
+
+	return rule, nil
+}
+
+// runRuleScheduler runs rules when their timers expire. Rules are run with
+// a trigger point, which updates conditions that change over time.
+func (nh *NatsHandler) runRuleScheduler() {
+	timer := time.NewTimer(0)
+
+	for {
+		select {
+		case <-timer.C:
+		case <-nh.rules.wake:
+		}
+
+		wait := time.Hour
+
+		// variables are updated as points are written, so must not be
+		// loaded at the same time
+		nh.nodeUpdateLock.Lock()
+		err := nh.ruleLoad()
+		nh.nodeUpdateLock.Unlock()
+		if err != nil {
+			log.Println("Error loading rules: ", err)
+			wait = ruleRetryInterval
+		}
+
+		now := time.Now()
+
+		for _, key := range nh.rules.due(now) {
+			p := data.Point{Time: now, Type: data.PointTypeTrigger}
+			// rules are also run as points are written, which is
+			// serialized by the node update lock
+			nh.nodeUpdateLock.Lock()
+			err := nh.processRuleNode(key, "", []data.Point{p})
+			nh.nodeUpdateLock.Unlock()
+			if err != nil {
+				log.Println("Error running rule: ", err)
+				nh.rules.schedule(key, now.Add(ruleRetryInterval), false)
+			}
+		}
+
+		next := nh.rules.nextTime()
+		if !next.IsZero() && time.Until(next) < wait {
+			wait = time.Until(next)
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+
+		timer.Reset(wait)
+	}
+}
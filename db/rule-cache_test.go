@@ -0,0 +1,123 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/simpleiot/simpleiot/data"
+)
+
+func TestRuleCache(t *testing.T) {
+	rc := newRuleCache()
+	now := time.Date(2021, time.September, 1, 0, 0, 0, 0, time.UTC)
+
+	loaded, treeChange := rc.loaded()
+	if loaded {
+		t.Fatal("rules should not be loaded initially")
+	}
+
+	rc.reset(treeChange, []data.NodeEdge{
+		{ID: "r1", Parent: "dev1"},
+		{ID: "r1", Parent: "dev2"},
+	}, nil, now)
+
+	if loaded, _ := rc.loaded(); !loaded {
+		t.Fatal("rules should be loaded")
+	}
+
+	if len(rc.childRules("dev1")) != 1 || len(rc.childRules("dev2")) != 1 {
+		t.Fatal("rules not indexed by parent")
+	}
+
+	key := ruleKey{"r1", "dev1"}
+
+	// all rules run after a reset
+	if len(rc.due(now)) != 2 {
+		t.Fatal("all rules should be due after a reset")
+	}
+
+	_, version, ok := rc.get(key)
+	if ok {
+		t.Fatal("rule should not be compiled")
+	}
+
+	rule := &data.Rule{
+		ID: "r1",
+		Conditions: []data.Condition{
+			{ID: "c1", NodeID: "tank", PointIndex: -1,
+				ConditionType: data.PointValuePointValue, PointType: "level"},
+		},
+	}
+
+	rc.put(key, rule, version)
+
+	r, _, ok := rc.get(key)
+	if !ok || r.ID != "r1" {
+		t.Fatal("rule not cached")
+	}
+
+	if w := rc.watcherRules("tank"); len(w) != 1 || w[0] != key {
+		t.Fatal("rule not indexed by watched node: ", w)
+	}
+
+	// rules that are not compiled may watch any point
+	keys := []ruleKey{key, {"r1", "dev2"}}
+
+	if w := rc.watching(keys, data.Points{{Type: "temp"}}); len(w) != 1 || w[0] != keys[1] {
+		t.Fatal("rule should not watch point type: ", w)
+	}
+
+	if w := rc.watching(keys, data.Points{{Type: "temp"}, {Type: "level"}}); len(w) != 2 {
+		t.Fatal("rule not indexed by watched point type: ", w)
+	}
+
+	// earlier times replace later times unless replace is set
+	rc.schedule(key, now.Add(time.Minute), false)
+	rc.schedule(key, now.Add(2*time.Minute), false)
+	if next := rc.nextTime(); !next.Equal(now.Add(time.Minute)) {
+		t.Error("wrong next time: ", next)
+	}
+
+	rc.schedule(key, now.Add(2*time.Minute), true)
+	if len(rc.due(now.Add(time.Minute))) != 0 {
+		t.Error("rule should not be due")
+	}
+
+	if len(rc.due(now.Add(2*time.Minute))) != 1 {
+		t.Error("rule should be due")
+	}
+
+	// state written by the rule engine is applied to the compiled rule
+	rc.update("c1", data.Points{{Type: data.PointTypeActive, Value: 1}}, now)
+
+	r, _, ok = rc.get(key)
+	if !ok || !r.Conditions[0].Active {
+		t.Fatal("condition state not applied to compiled rule: ", r)
+	}
+
+	if len(rc.due(now.Add(2*time.Minute))) != 0 {
+		t.Error("state changes should not run the rule")
+	}
+
+	// editing a condition compiles the rule again and runs it
+	rc.update("c1", data.Points{{Type: data.PointTypeValue, Value: 10}}, now)
+
+	if _, _, ok := rc.get(key); ok {
+		t.Error("rule should be invalidated")
+	}
+
+	if len(rc.due(now)) != 1 {
+		t.Error("invalidated rule should be due")
+	}
+
+	// rules compiled before a change are not stored
+	rc.put(key, rule, version)
+	if _, _, ok := rc.get(key); ok {
+		t.Error("stale rule should not be stored")
+	}
+
+	rc.treeChanged()
+	if loaded, _ := rc.loaded(); loaded {
+		t.Error("rules should be reloaded after a tree change")
+	}
+}
@@ -0,0 +1,279 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	natsgo "github.com/nats-io/nats.go"
+	"github.com/simpleiot/simpleiot/data"
+	"github.com/simpleiot/simpleiot/nats"
+)
+
+// ruleRun holds the state of one evaluation of a rule. In a dry run, no
+// points are sent and no actions are run, and the hypothetical points of
+// nodeID are used in place of the points stored in the database. If eval is
+// set, the evaluation is recorded in it.
+type ruleRun struct {
+	nc         *natsgo.Conn
+	dryRun     bool
+	nodeID     string
+	points     data.Points
+	eval       *data.RuleEval
+	conditions map[string]data.ConditionEval
+}
+
+func newRuleRun(nc *natsgo.Conn, trace bool) *ruleRun {
+	rr := &ruleRun{nc: nc}
+
+	if trace {
+		rr.eval = &data.RuleEval{}
+		rr.conditions = make(map[string]data.ConditionEval)
+	}
+
+	return rr
+}
+
+// sendPoint sends a point unless this is a dry run
+func (rr *ruleRun) sendPoint(nodeID string, p data.Point) {
+	if rr.dryRun {
+		return
+	}
+
+	err := nats.SendNodePoint(rr.nc, nodeID, p, false)
+	if err != nil {
+		log.Println("Rule error sending point: ", err)
+	}
+}
+
+// dryRunPoint returns the latest hypothetical point of a node in a dry run
+// that is selected by match
+func (rr *ruleRun) dryRunPoint(nodeID string, match func(p data.Point) bool) (data.Point, bool) {
+	var ret data.Point
+	found := false
+
+	if !rr.dryRun || nodeID != rr.nodeID {
+		return ret, false
+	}
+
+	for _, p := range rr.points {
+		if match(p) && (!found || !p.Time.Before(ret.Time)) {
+			ret = p
+			found = true
+		}
+	}
+
+	return ret, found
+}
+
+// condition records the evaluation of a condition. c is the condition after
+// it is debounced, met is the result of the evaluation, and p is the value
+// the condition was evaluated against.
+func (rr *ruleRun) condition(c data.Condition, met bool, p data.Point) {
+	if rr.eval == nil {
+		return
+	}
+
+	rr.conditions[c.ID] = data.ConditionEval{
+		Evaluated: true,
+		Met:       met,
+		Point:     p,
+	}
+}
+
+// conditionError records an error evaluating a condition
+func (rr *ruleRun) conditionError(c data.Condition, err error) {
+	if rr.eval == nil {
+		return
+	}
+
+	ce := rr.conditions[c.ID]
+	ce.Evaluated = true
+	ce.Error = err.Error()
+	rr.conditions[c.ID] = ce
+}
+
+// actions records actions that run (or would run in a dry run)
+func (rr *ruleRun) actions(actions []data.Action, inactive bool, triggerNode string) {
+	if rr.eval == nil {
+		return
+	}
+
+	for _, a := range actions {
+		rr.eval.Actions = append(rr.eval.Actions, data.ActionEval{
+			ID:          a.ID,
+			Description: a.Description,
+			Action:      a.Action,
+			Inactive:    inactive,
+			TriggerNode: triggerNode,
+		})
+	}
+}
+
+// finish fills in the state of the rule, its conditions, and its condition
+// groups after the rule is evaluated
+func (rr *ruleRun) finish(r *data.Rule, nodeID string, points data.Points, changed bool, now time.Time) {
+	if rr.eval == nil {
+		return
+	}
+
+	e := rr.eval
+	e.RuleID = r.ID
+	e.Parent = r.Parent
+	e.Description = r.Description
+	e.Time = now
+	e.DryRun = rr.dryRun
+	e.NodeID = nodeID
+	e.Points = points
+	e.Active = r.Active
+	e.Changed = changed
+	e.AlarmState = r.AlarmState
+
+	addConditions := func(conditions []data.Condition, groupID string) {
+		for _, c := range conditions {
+			ce := rr.conditions[c.ID]
+			ce.ID = c.ID
+			ce.GroupID = groupID
+			ce.Description = c.Description
+			ce.ConditionType = c.ConditionType
+			ce.Active = c.Active
+			ce.Pending = c.Pending
+			ce.InputStatus = c.InputStatus
+			e.Conditions = append(e.Conditions, ce)
+		}
+	}
+
+	var addGroups func(groups []data.ConditionGroup, parentID string)
+	addGroups = func(groups []data.ConditionGroup, parentID string) {
+		for _, g := range groups {
+			e.Groups = append(e.Groups, data.GroupEval{
+				ID:          g.ID,
+				ParentID:    parentID,
+				Description: g.Description,
+				Logic:       g.Logic,
+				Active:      g.Active,
+			})
+
+			addConditions(g.Conditions, g.ID)
+			addGroups(g.Groups, g.ID)
+		}
+	}
+
+	addConditions(r.Conditions, "")
+	addGroups(r.Groups, "")
+}
+
+// ruleEval evaluates a rule without side effects. The rule is evaluated as
+// if the hypothetical points in the request had arrived, and the rule
+// scheduler had run the rule.
+func (nh *NatsHandler) ruleEval(id string, req data.RuleEvalRequest) (data.RuleEval, error) {
+	node, err := nh.db.node(id)
+	if err != nil {
+		return data.RuleEval{}, err
+	}
+
+	if node.Type != data.NodeTypeRule {
+		return data.RuleEval{}, errors.New("node is not a rule")
+	}
+
+	parent := req.Parent
+	if parent == "" {
+		edges, err := nh.db.edgeUp(id)
+		if err != nil {
+			return data.RuleEval{}, err
+		}
+
+		if len(edges) > 0 {
+			parent = edges[0].Up
+		}
+	}
+
+	if parent == "" {
+		return data.RuleEval{}, errors.New("rule does not have a parent")
+	}
+
+	rule, err := nh.rule(ruleKey{id, parent})
+	if err != nil {
+		return data.RuleEval{}, err
+	}
+
+	now := time.Now()
+
+	nodeID := req.NodeID
+	if nodeID == "" {
+		nodeID = parent
+	}
+
+	var points data.Points
+	for _, p := range req.Points {
+		if p.Time.IsZero() {
+			p.Time = now
+		}
+		points = append(points, p)
+	}
+
+	rr := newRuleRun(nh.Nc, true)
+	rr.dryRun = true
+	rr.nodeID = nodeID
+	rr.points = points
+
+	points = append(points, data.Point{Time: now, Type: data.PointTypeTrigger})
+
+	nh.runRule(rr, rule, nodeID, points, true, now)
+
+	return *rr.eval, nil
+}
+
+func (nh *NatsHandler) handleNodeEval(msg *natsgo.Msg) {
+	var eval data.RuleEval
+	var req data.RuleEvalRequest
+	var err error
+	var resp []byte
+
+	chunks := strings.Split(msg.Subject, ".")
+	if len(chunks) < 3 {
+		err = fmt.Errorf("Error in message subject: %v", msg.Subject)
+		goto handleNodeEvalDone
+	}
+
+	req, err = data.PbDecodeRuleEvalRequest(msg.Data)
+	if err != nil {
+		err = fmt.Errorf("Error decoding rule eval request: %v", err)
+		goto handleNodeEvalDone
+	}
+
+	eval, err = nh.ruleEval(chunks[1], req)
+
+handleNodeEvalDone:
+	resp, err = data.RuleEvalToPb(eval, err)
+	if err != nil {
+		// reply with the error so the requester does not wait for a timeout
+		resp, err = data.RuleEvalToPb(data.RuleEval{},
+			fmt.Errorf("Error encoding rule evaluation: %v", err))
+		if err != nil {
+			log.Println("Error encoding rule evaluation error: ", err)
+			return
+		}
+	}
+
+	err = nh.Nc.Publish(msg.Reply, resp)
+	if err != nil {
+		log.Println("NATS: Error publishing response to rule eval request: ", err)
+	}
+}
+
+// publishTrace publishes the evaluation of a rule with trace enabled
+func (nh *NatsHandler) publishTrace(eval *data.RuleEval) {
+	d, err := data.RuleEvalToPb(*eval, nil)
+	if err != nil {
+		log.Println("Error encoding rule trace: ", err)
+		return
+	}
+
+	err = nh.Nc.Publish(nats.SubjectNodeTrace(eval.RuleID), d)
+	if err != nil {
+		log.Println("Error publishing rule trace: ", err)
+	}
+}
@@ -0,0 +1,98 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/simpleiot/simpleiot/data"
+)
+
+func TestRuleRunDryRun(t *testing.T) {
+	now := time.Date(2021, time.September, 1, 0, 0, 0, 0, time.UTC)
+
+	r := &data.Rule{
+		ID:     "r1",
+		Parent: "tank",
+		Conditions: []data.Condition{
+			{ID: "c1", ConditionType: data.PointValuePointValue,
+				PointType: data.PointTypeValue, PointIndex: -1,
+				PointValueType: data.PointValueNumber,
+				Operator:       data.PointValueLessThan, PointValue: 20},
+		},
+		Groups: []data.ConditionGroup{
+			{ID: "g1", Conditions: []data.Condition{
+				{ID: "c2", ConditionType: data.PointValuePointValue,
+					PointType: "temp", PointIndex: -1,
+					PointValueType: data.PointValueNumber,
+					Operator:       data.PointValueGreaterThan, PointValue: 50},
+			}},
+		},
+	}
+
+	// a dry run does not send points, so no NATS connection is needed
+	rr := newRuleRun(nil, true)
+	rr.dryRun = true
+
+	points := data.Points{{Type: data.PointTypeValue, Value: 12, Time: now}}
+
+	active, changed, err := ruleProcessPoints(rr, r, "tank", points, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if active || changed {
+		t.Error("rule should not go active as the group is not active")
+	}
+
+	rr.finish(r, "tank", points, changed, now)
+
+	e := rr.eval
+
+	if len(e.Conditions) != 2 || len(e.Groups) != 1 {
+		t.Fatalf("wrong evaluation: %+v", e)
+	}
+
+	c1 := e.Conditions[0]
+	if c1.ID != "c1" || !c1.Evaluated || !c1.Met || !c1.Active ||
+		c1.Point.Value != 12 {
+		t.Errorf("wrong condition evaluation: %+v", c1)
+	}
+
+	c2 := e.Conditions[1]
+	if c2.ID != "c2" || c2.GroupID != "g1" || c2.Evaluated || c2.Active {
+		t.Errorf("wrong condition evaluation: %+v", c2)
+	}
+
+	if e.Groups[0].ID != "g1" || e.Groups[0].Active {
+		t.Errorf("wrong group evaluation: %+v", e.Groups[0])
+	}
+}
+
+func TestRuleRunDryRunPoint(t *testing.T) {
+	now := time.Date(2021, time.September, 1, 0, 0, 0, 0, time.UTC)
+
+	rr := newRuleRun(nil, false)
+
+	match := func(p data.Point) bool { return p.Type == "on" }
+
+	rr.nodeID = "pump"
+	rr.points = data.Points{
+		{Type: "on", Value: 1, Time: now},
+		{Type: "on", Value: 0, Time: now.Add(time.Second)},
+	}
+
+	if _, ok := rr.dryRunPoint("pump", match); ok {
+		t.Error("hypothetical points are only used in a dry run")
+	}
+
+	rr.dryRun = true
+
+	p, ok := rr.dryRunPoint("pump", match)
+	if !ok || p.Value != 0 {
+		t.Error("expected latest hypothetical point, got: ", p)
+	}
+
+	if _, ok := rr.dryRunPoint("tank", match); ok {
+		t.Error("hypothetical points are only for one node")
+	}
+}
@@ -1,9 +1,12 @@
 package db
 
 import (
+	"encoding/json"
+	"fmt"
 	"log"
 	"os"
 	"os/exec"
+	"sort"
 	"strconv"
 	"time"
 
@@ -11,132 +14,646 @@ import (
 	"github.com/google/uuid"
 	natsgo "github.com/nats-io/nats.go"
 	"github.com/simpleiot/simpleiot/data"
+	"github.com/simpleiot/simpleiot/msg"
 	"github.com/simpleiot/simpleiot/nats"
 )
 
-// ruleProcessPoints runs points through a rules conditions and and updates condition
-// and rule active status. Returns true if point was processed and active is true.
+// ruleProcessPoints runs points through a rules conditions and condition
+// groups and updates condition, group, and rule active status. Returns true if
+// point was processed and active is true.
 // Currently, this function only processes the first point that matches -- this should
 // handle all current uses.
-func ruleProcessPoints(nc *natsgo.Conn, r *data.Rule, nodeID string, points data.Points) (bool, bool, error) {
+func ruleProcessPoints(rr *ruleRun, r *data.Rule, nodeID string, points data.Points, now time.Time) (bool, bool, error) {
+	pointsProcessed := ruleProcessConditions(rr, r.Conditions, nodeID, points, now)
+
+	if ruleProcessGroups(rr, r.Groups, nodeID, points, now) {
+		pointsProcessed = true
+	}
+
+	if pointsProcessed {
+		active := data.LogicActive(r.Logic, r.Conditions, r.Groups)
+
+		changed := false
+
+		if active != r.Active {
+			p := data.Point{
+				Type:  data.PointTypeActive,
+				Time:  now,
+				Value: data.BoolToFloat(active),
+			}
+
+			if active {
+				p.Text = nodeID
+			}
+
+			rr.sendPoint(r.ID, p)
+			changed = true
+		}
+
+		return active, changed, nil
+	}
+
+	return false, false, nil
+}
+
+// ruleProcessGroups runs points through the conditions in condition groups
+// and updates the group active status. Nested groups are processed first.
+// Returns true if any points were processed.
+func ruleProcessGroups(rr *ruleRun, groups []data.ConditionGroup, nodeID string, points data.Points, now time.Time) bool {
+	pointsProcessed := false
+
+	for i := range groups {
+		g := &groups[i]
+
+		processed := ruleProcessConditions(rr, g.Conditions, nodeID, points, now)
+
+		if ruleProcessGroups(rr, g.Groups, nodeID, points, now) {
+			processed = true
+		}
+
+		if !processed {
+			continue
+		}
+
+		pointsProcessed = true
+
+		active := data.LogicActive(g.Logic, g.Conditions, g.Groups)
+
+		if active != g.Active {
+			p := data.Point{
+				Type:  data.PointTypeActive,
+				Time:  now,
+				Value: data.BoolToFloat(active),
+			}
+
+			rr.sendPoint(g.ID, p)
+
+			g.Active = active
+		}
+	}
+
+	return pointsProcessed
+}
+
+// ruleProcessConditions runs points through conditions and updates the
+// condition active status. Point value conditions that reference a node are
+// evaluated against the current point stored in that node every time the
+// rule runs, so a rule over multiple nodes always sees their combined state.
+// These conditions are not met if the input is missing or stale. Trigger
+// points check if other pending conditions have been met long enough to
+// change state. Returns true if any points were processed.
+func ruleProcessConditions(rr *ruleRun, conditions []data.Condition, nodeID string, points data.Points, now time.Time) bool {
 	pointsProcessed := false
 
+	for i, c := range conditions {
+		if c.ConditionType != data.PointValuePointValue || c.NodeID == "" {
+			continue
+		}
+
+		pointsProcessed = true
+
+		active := false
+		var input data.Point
+
+		if c.InputStatus == data.PointValueInputOK {
+			input = *c.Input
+
+			var err error
+			active, err = c.PointActive(input)
+			if err != nil {
+				log.Printf("Rule condition %v error: %v", c.ID, err)
+			}
+		}
+
+		conditions[i] = ruleUpdateCondition(rr, c, active, input, now)
+	}
+
 	for _, p := range points {
-		for i, c := range r.Conditions {
+		for i, c := range conditions {
 			var active bool
+			// value the condition is evaluated against
+			value := p
 
-			switch c.ConditionType {
-			case data.PointValuePointValue:
-				if c.NodeID != "" && c.NodeID != nodeID {
-					continue
-				}
-
-				if c.PointID != "" && c.PointID != p.ID {
-					continue
-				}
+			switch {
+			case c.ConditionType == data.PointValuePointValue && c.NodeID != "":
+				// evaluated above
+				continue
 
-				if c.PointType != "" && c.PointType != p.Type {
+			case c.ConditionType == data.PointValuePointValue &&
+				p.Type == data.PointTypeTrigger:
+				if !c.Pending {
 					continue
 				}
+				pointsProcessed = true
+				active = !c.Active
 
-				if c.PointIndex != -1 && c.PointIndex != int(p.Index) {
+			case c.ConditionType == data.PointValuePointValue:
+				if !c.PointMatch(nodeID, p) {
 					continue
 				}
 
 				// conditions match, so check value
-				switch c.PointValueType {
-				case data.PointValueNumber:
-					pointsProcessed = true
-					switch c.Operator {
-					case data.PointValueGreaterThan:
-						active = p.Value > c.PointValue
-					case data.PointValueLessThan:
-						active = p.Value < c.PointValue
-					case data.PointValueEqual:
-						active = p.Value == c.PointValue
-					case data.PointValueNotEqual:
-						active = p.Value != c.PointValue
-					}
-				case data.PointValueText:
-					pointsProcessed = true
-					switch c.Operator {
-					case data.PointValueEqual:
-					case data.PointValueNotEqual:
-					case data.PointValueContains:
-					}
-				case data.PointValueOnOff:
-					pointsProcessed = true
-					condValue := c.PointValue != 0
-					pointValue := p.Value != 0
-					active = condValue == pointValue
+				var err error
+				active, err = c.PointActive(p)
+				if err != nil {
+					log.Printf("Rule condition %v error: %v", c.ID, err)
+					rr.conditionError(c, err)
+					continue
 				}
-			case data.PointValueSchedule:
+				pointsProcessed = true
+			case c.ConditionType == data.PointValueSchedule:
 				if p.Type != data.PointTypeTrigger {
 					continue
 				}
 				pointsProcessed = true
-				sched := newSchedule(c.StartTime, c.EndTime, c.Weekdays)
-
 				var err error
-				active, err = sched.activeForTime(p.Time)
+				active, err = conditionSchedule(c).activeForTime(p.Time)
 				if err != nil {
 					log.Println("Error parsing schedule time: ", err)
+					rr.conditionError(c, err)
+					continue
+				}
+			case c.ConditionType == data.PointValueWindow:
+				if p.Type != data.PointTypeTrigger && !c.PointMatch(nodeID, p) {
+					continue
+				}
+
+				if !c.WindowValid {
+					continue
+				}
+				pointsProcessed = true
+				active = c.NumberActive(c.WindowValue)
+				value = data.Point{Time: now, Value: c.WindowValue}
+			case c.ConditionType == data.PointValueNoUpdate:
+				if p.Type != data.PointTypeTrigger {
 					continue
 				}
+				pointsProcessed = true
+				active = c.NoUpdateActive(p.Time)
+				value = data.Point{Time: c.LastUpdate}
 			}
 
-			if active != c.Active {
-				// update condition
-				p := data.Point{
-					Type:  data.PointTypeActive,
-					Time:  time.Now(),
-					Value: data.BoolToFloat(active),
+			conditions[i] = ruleUpdateCondition(rr, c, active, value, now)
+		}
+	}
+
+	return pointsProcessed
+}
+
+// conditionSchedule returns the schedule of a schedule condition
+func conditionSchedule(c data.Condition) *schedule {
+	sched := newSchedule(c.StartTime, c.EndTime, c.Weekdays)
+	sched.timezone = c.Timezone
+	sched.dates = c.Dates
+	sched.excludeDates = c.ExcludeDates
+	sched.calendar = c.CalendarDates
+	sched.calendarOnly = c.Calendar != "" && c.CalendarMode == data.PointValueOnly
+	return sched
+}
+
+// ruleMinInterval is the minimum time between runs of a rule by the rule
+// scheduler
+const ruleMinInterval = time.Second
+
+// ruleNextRun returns when the rule scheduler must run a rule next, after the
+// rule has processed points at time now. This is the earliest time that a
+// schedule, pending condition, no update condition, stale input, window
+// statistic, action delay or repeat, or alarm shelve could change the rule.
+// Schedules and no update conditions are only loaded when the rule is
+// triggered by the scheduler, so they are ignored otherwise. A zero time is
+// returned if the rule does not need to run.
+func ruleNextRun(r *data.Rule, nodeID string, points data.Points, trigger bool, now time.Time) time.Time {
+	var ret time.Time
+
+	next := func(t time.Time) {
+		if !t.IsZero() && (ret.IsZero() || t.Before(ret)) {
+			ret = t
+		}
+	}
+
+	minutes := func(m float64) time.Duration {
+		return time.Duration(m * float64(time.Minute))
+	}
+
+	for _, c := range r.AllConditions() {
+		next(c.PendingEnd())
+
+		switch c.ConditionType {
+		case data.PointValueSchedule:
+			if !trigger {
+				continue
+			}
+
+			t, err := conditionSchedule(c).nextEdge(now)
+			if err != nil {
+				continue
+			}
+
+			next(t)
+
+		case data.PointValueNoUpdate:
+			if c.Active {
+				// a new point makes the condition inactive
+				for _, p := range points {
+					if !trigger && c.Watches(nodeID, p) {
+						next(now)
+					}
 				}
+			} else if trigger && !c.Pending {
+				next(c.LastUpdate.Add(minutes(c.NoUpdateTime)))
+			}
 
-				err := nats.SendNodePoint(nc, c.ID, p, false)
-				if err != nil {
-					log.Println("Rule error sending point: ", err)
+		case data.PointValuePointValue:
+			if c.NodeID != "" && c.StaleTime > 0 && c.Input != nil &&
+				c.InputStatus == data.PointValueInputOK {
+				next(c.Input.Time.Add(minutes(c.StaleTime)))
+			}
+
+		case data.PointValueWindow:
+			// statistics change as points leave the window. Only
+			// conditions that watch a node are updated by the
+			// scheduler.
+			if c.NodeID != "" {
+				interval := minutes(c.Window) / 60
+				if interval < 5*time.Second {
+					interval = 5 * time.Second
+				}
+				next(now.Add(interval))
+			}
+		}
+	}
+
+	switch r.AlarmState {
+	case data.PointValueAlarmShelved:
+		next(r.ShelveUntil)
+	case data.PointValueAlarmActiveAcked:
+	default:
+		if r.Active {
+			for _, a := range r.Actions {
+				// actions without a delay only run when the rule
+				// goes active
+				if a.RunCountSince(r.ActiveTime) == 0 && a.Delay <= 0 {
+					continue
 				}
 
-				r.Conditions[i].Active = active
+				next(a.NextRun(r.ActiveTime))
 			}
 		}
 	}
 
-	if pointsProcessed {
-		allActive := true
+	if !ret.IsZero() && ret.Before(now.Add(ruleMinInterval)) {
+		ret = now.Add(ruleMinInterval)
+	}
+
+	return ret
+}
+
+// ruleUpdateCondition debounces a condition that has been evaluated as met
+// (or not), and sends the pending and active points of the condition if they
+// changed. value is the point the condition was evaluated against. Returns
+// the updated condition.
+func ruleUpdateCondition(rr *ruleRun, c data.Condition, met bool, value data.Point, now time.Time) data.Condition {
+	cNew := c.Debounce(met, now)
+
+	rr.condition(cNew, met, value)
+
+	if cNew.Pending != c.Pending {
+		// persist pending state so that timers survive a restart
+		p := data.Point{
+			Type:  data.PointTypePending,
+			Time:  now,
+			Value: data.BoolToFloat(cNew.Pending),
+		}
+
+		rr.sendPoint(c.ID, p)
+	}
+
+	if cNew.Active != c.Active {
+		// update condition
+		p := data.Point{
+			Type:  data.PointTypeActive,
+			Time:  now,
+			Value: data.BoolToFloat(cNew.Active),
+		}
 
-		for _, c := range r.Conditions {
-			if !c.Active {
-				allActive = false
+		rr.sendPoint(c.ID, p)
+	}
+
+	return cNew
+}
+
+// ruleInputs fills in the current input point of point value conditions
+// that reference a node, and sends an inputStatus point to the condition when
+// the input goes missing or stale, or recovers. In a dry run, hypothetical
+// points replace the stored points.
+func (nh *NatsHandler) ruleInputs(rr *ruleRun, conditions []data.Condition, groups []data.ConditionGroup, now time.Time) {
+	for i, c := range conditions {
+		if c.ConditionType != data.PointValuePointValue || c.NodeID == "" {
+			continue
+		}
+
+		var input *data.Point
+
+		node, err := nh.db.node(c.NodeID)
+		if err == nil {
+			for _, p := range node.Points {
+				if c.PointMatch(c.NodeID, p) {
+					p := p
+					input = &p
+					break
+				}
+			}
+		}
+
+		p, ok := rr.dryRunPoint(c.NodeID, func(p data.Point) bool {
+			return c.PointMatch(c.NodeID, p)
+		})
+		if ok {
+			input = &p
+		}
+
+		status := c.InputState(input, now)
+
+		if status != c.InputStatus {
+			if status != data.PointValueInputOK {
+				log.Printf("Rule condition %v input is %v", c.ID, status)
+			}
+
+			rr.sendPoint(c.ID, data.Point{
+				Type: data.PointTypeInputStatus,
+				Time: now,
+				Text: status,
+			})
+		}
+
+		conditions[i].Input = input
+		conditions[i].InputStatus = status
+	}
+
+	for _, g := range groups {
+		nh.ruleInputs(rr, g.Conditions, g.Groups, now)
+	}
+}
+
+// ruleWindows fills in the window statistic of window conditions that match
+// any of the points, from the point history. Trigger points update conditions
+// that watch a specific node, as the statistics change over time even if no
+// new points arrive. In a dry run, hypothetical points are added to the
+// history.
+func (nh *NatsHandler) ruleWindows(rr *ruleRun, conditions []data.Condition, groups []data.ConditionGroup, nodeID string, points data.Points, now time.Time) {
+	for i, c := range conditions {
+		if c.ConditionType != data.PointValueWindow {
+			continue
+		}
+
+		windowNodeID := ""
+
+		for _, p := range points {
+			if p.Type == data.PointTypeTrigger {
+				windowNodeID = c.NodeID
+			} else if c.PointMatch(nodeID, p) {
+				windowNodeID = nodeID
+			}
+
+			if windowNodeID != "" {
 				break
 			}
 		}
 
-		changed := false
+		if windowNodeID == "" {
+			continue
+		}
 
-		if allActive != r.Active {
-			p := data.Point{
-				Type:  data.PointTypeActive,
-				Time:  time.Now(),
-				Value: data.BoolToFloat(allActive),
+		history, err := nh.db.history(windowNodeID, data.HistoryQuery{
+			ID:    c.PointID,
+			Type:  c.PointType,
+			Index: c.PointIndex,
+			Start: now.Add(-time.Duration(c.Window * float64(time.Minute))),
+		})
+		if err != nil {
+			log.Printf("Rule condition %v error getting history: %v", c.ID, err)
+			continue
+		}
+
+		if rr.dryRun && rr.nodeID == windowNodeID {
+			for _, p := range rr.points {
+				if c.PointMatch(windowNodeID, p) {
+					history = append(history, p)
+				}
 			}
+			sort.Sort(history)
+		}
 
-			err := nats.SendNodePoint(nc, r.ID, p, false)
-			if err != nil {
-				log.Println("Rule error sending point: ", err)
+		conditions[i].WindowValue, conditions[i].WindowValid = c.WindowStats(history)
+	}
+
+	for _, g := range groups {
+		nh.ruleWindows(rr, g.Conditions, g.Groups, nodeID, points, now)
+	}
+}
+
+// ruleLastUpdates fills in the last update time of all no update conditions
+// in a rule. Conditions without a node ID watch the node the rule is
+// attached to. If the watched node can't be read, the condition is treated
+// as not updated. In a dry run, hypothetical points count as updates.
+func (nh *NatsHandler) ruleLastUpdates(rr *ruleRun, conditions []data.Condition, groups []data.ConditionGroup, parentID string) {
+	for i, c := range conditions {
+		if c.ConditionType != data.PointValueNoUpdate {
+			continue
+		}
+
+		nodeID := c.NodeID
+		if nodeID == "" {
+			nodeID = parentID
+		}
+
+		var err error
+		conditions[i].LastUpdate, err = nh.lastUpdate(nodeID, c.PointType,
+			c.PointIndex, c.Descendants)
+		if err != nil {
+			log.Printf("Rule condition %v error getting last update: %v", c.ID, err)
+		}
+
+		p, ok := rr.dryRunPoint(nodeID, func(p data.Point) bool {
+			return (c.PointType == "" && p.Type != data.PointTypeSysState ||
+				p.Type == c.PointType) &&
+				(c.PointIndex == -1 || int(p.Index) == c.PointIndex)
+		})
+		if ok && p.Time.After(conditions[i].LastUpdate) {
+			conditions[i].LastUpdate = p.Time
+		}
+	}
+
+	for _, g := range groups {
+		nh.ruleLastUpdates(rr, g.Conditions, g.Groups, parentID)
+	}
+}
+
+// ruleCalendars fills in the dates of the calendar nodes referenced by
+// schedule conditions
+func (nh *NatsHandler) ruleCalendars(conditions []data.Condition, groups []data.ConditionGroup) {
+	for i, c := range conditions {
+		if c.ConditionType != data.PointValueSchedule || c.Calendar == "" {
+			continue
+		}
+
+		node, err := nh.db.node(c.Calendar)
+		if err != nil {
+			log.Printf("Rule condition %v error getting calendar: %v", c.ID, err)
+			continue
+		}
+
+		for _, p := range node.Points {
+			if p.Type == data.PointTypeDate && p.Text != "" {
+				conditions[i].CalendarDates = append(conditions[i].CalendarDates, p.Text)
 			}
-			changed = true
 		}
+	}
 
-		return allActive, changed, nil
+	for _, g := range groups {
+		nh.ruleCalendars(g.Conditions, g.Groups)
 	}
+}
 
-	return false, false, nil
+// lastUpdate returns the time of the latest point of a type and index in a
+// node, and optionally all of its descendants. Set typ to "" to look at all
+// points, and index to -1 for all indexes. sysState points are ignored
+// when looking at all points as these are generated locally. Rules are not
+// searched so that rule state changes do not count as updates.
+func (nh *NatsHandler) lastUpdate(nodeID, typ string, index int, descendants bool) (time.Time, error) {
+	var ret time.Time
+
+	node, err := nh.db.node(nodeID)
+	if err != nil {
+		return ret, err
+	}
+
+	for _, p := range node.Points {
+		if typ == "" && p.Type == data.PointTypeSysState ||
+			typ != "" && p.Type != typ ||
+			index != -1 && int(p.Index) != index {
+			continue
+		}
+
+		if p.Time.After(ret) {
+			ret = p.Time
+		}
+	}
+
+	if !descendants {
+		return ret, nil
+	}
+
+	children, err := nh.db.nodeDescendents(nodeID, "", false, false)
+	if err != nil {
+		return ret, err
+	}
+
+	for _, c := range children {
+		if c.Type == data.NodeTypeRule {
+			continue
+		}
+
+		t, err := nh.lastUpdate(c.ID, typ, index, true)
+		if err != nil {
+			return ret, err
+		}
+
+		if t.After(ret) {
+			ret = t
+		}
+	}
+
+	return ret, nil
+}
+
+// ruleRunDueActions runs the actions of an active rule that are due (see
+// data.Action.Due), and records each run in the runCount point of the action
+// so that repeat and escalation timers survive a restart. Actions without a
+// delay that have not run yet only run when the rule goes active, so rules
+// that were active before an upgrade do not fire again. In a dry run, due
+// actions are only recorded.
+func (nh *NatsHandler) ruleRunDueActions(rr *ruleRun, r *data.Rule, triggerNode string, triggerPoint data.Point, changed bool, now time.Time) {
+	// rules are run by the scheduler and as points arrive, so runs are also
+	// tracked in memory as the runCount points may not be written yet
+	nh.actionLock.Lock()
+	defer nh.actionLock.Unlock()
+
+	for i, a := range r.Actions {
+		if p, ok := nh.actionRuns[a.ID]; ok && p.Time.After(a.LastRun) {
+			r.Actions[i].RunCount = int(p.Value)
+			r.Actions[i].LastRun = p.Time
+			r.Actions[i].LastTriggerNode = p.Text
+		}
+	}
+
+	// node that triggered the rule when it went active
+	lastTriggerNode := r.TriggerNode
+	for _, a := range r.Actions {
+		if a.RunCountSince(r.ActiveTime) > 0 && a.LastTriggerNode != "" {
+			lastTriggerNode = a.LastTriggerNode
+			break
+		}
+	}
+
+	for i, a := range r.Actions {
+		runCount := a.RunCountSince(r.ActiveTime)
+
+		if runCount == 0 && a.Delay <= 0 && !changed {
+			continue
+		}
+
+		if !a.Due(r.ActiveTime, now) {
+			continue
+		}
+
+		actionTriggerNode, actionTriggerPoint := triggerNode, triggerPoint
+
+		// repeats and escalations report the node that triggered the rule,
+		// not the node of the point that is currently being processed
+		if (!changed || actionTriggerNode == "") && lastTriggerNode != "" {
+			node, err := nh.db.node(lastTriggerNode)
+			if err == nil {
+				actionTriggerNode = lastTriggerNode
+				actionTriggerPoint = r.TriggerPoint(node.ID, node.Points)
+			}
+		}
+
+		rr.actions([]data.Action{a}, false, actionTriggerNode)
+
+		if rr.dryRun {
+			continue
+		}
+
+		err := nh.ruleRunActions(nh.Nc, r, []data.Action{a}, actionTriggerNode,
+			actionTriggerPoint)
+		if err != nil {
+			log.Println("Error running rule actions: ", err)
+		}
+
+		p := data.Point{
+			Type:  data.PointTypeRunCount,
+			Time:  now,
+			Value: float64(runCount + 1),
+			Text:  actionTriggerNode,
+		}
+
+		nh.actionRuns[a.ID] = p
+		r.Actions[i].RunCount = runCount + 1
+		r.Actions[i].LastRun = now
+		r.Actions[i].LastTriggerNode = actionTriggerNode
+
+		err = nats.SendNodePoint(nh.Nc, a.ID, p, false)
+		if err != nil {
+			log.Println("Rule error sending point: ", err)
+		}
+	}
 }
 
-// ruleRunActions runs rule actions
-func (nh *NatsHandler) ruleRunActions(nc *natsgo.Conn, r *data.Rule, actions []data.Action, triggerNode string) error {
+// ruleRunActions runs rule actions. triggerNode and triggerPoint are the node
+// and point that caused the rule to change state. triggerNode is blank for
+// rules fired by a timer.
+func (nh *NatsHandler) ruleRunActions(nc *natsgo.Conn, r *data.Rule, actions []data.Action, triggerNode string, triggerPoint data.Point) error {
 	for _, a := range actions {
 		switch a.Action {
 		case data.PointValueActionSetValue:
@@ -154,30 +671,14 @@ func (nh *NatsHandler) ruleRunActions(nc *natsgo.Conn, r *data.Rule, actions []d
 				log.Println("Error sending rule action point: ", err)
 			}
 		case data.PointValueActionNotify:
-			// get node that fired the rule
-			triggerNode, err := nh.db.node(triggerNode)
-			if err != nil {
-				return err
-			}
-
-			triggerNodeDesc := triggerNode.Desc()
-
-			n := data.Notification{
-				ID:         uuid.New().String(),
-				SourceNode: a.NodeID,
-				Message:    r.Description + " fired at " + triggerNodeDesc,
-			}
-
-			d, err := n.ToPb()
-
+			err := nh.ruleNotify(r, a, triggerNode, triggerPoint)
 			if err != nil {
 				return err
 			}
-
-			err = nh.Nc.Publish("node."+r.ID+".not", d)
-
+		case data.PointValueActionWebhook:
+			err := nh.ruleWebhook(r, a, triggerNode, triggerPoint)
 			if err != nil {
-				return err
+				log.Println("Rule action webhook error: ", err)
 			}
 		case data.PointValueActionPlayAudio:
 			f, err := os.Open(a.PointFilePath)
@@ -199,8 +700,10 @@ func (nh *NatsHandler) ruleRunActions(nc *natsgo.Conn, r *data.Rule, actions []d
 			channelNum := strconv.Itoa(a.PointChannel)
 			sampleRate := strconv.Itoa(format.SampleRate)
 
+			device, filePath := a.PointDevice, a.PointFilePath
+
 			go func() {
-				stderr, err := exec.Command("speaker-test", "-D"+a.PointDevice, "-twav", "-w"+a.PointFilePath, "-c5", "-s"+channelNum, "-r"+sampleRate).CombinedOutput()
+				stderr, err := exec.Command("speaker-test", "-D"+device, "-twav", "-w"+filePath, "-c5", "-s"+channelNum, "-r"+sampleRate).CombinedOutput()
 				if err != nil {
 					log.Println("Play audio error: ", err)
 					log.Printf("Audio stderr: %s\n", stderr)
@@ -212,3 +715,254 @@ func (nh *NatsHandler) ruleRunActions(nc *natsgo.Conn, r *data.Rule, actions []d
 	}
 	return nil
 }
+
+// ruleTemplateData loads the nodes that are available to action templates
+func (nh *NatsHandler) ruleTemplateData(r *data.Rule, triggerNodeID string, triggerPoint data.Point) (notifyTemplateData, error) {
+	var triggerNode, ruleNode, parent *data.Node
+	var err error
+
+	// rules fired by a timer do not have a trigger node
+	if triggerNodeID != "" {
+		triggerNode, err = nh.db.node(triggerNodeID)
+		if err != nil {
+			return notifyTemplateData{}, err
+		}
+	}
+
+	ruleNode, err = nh.db.node(r.ID)
+	if err != nil {
+		return notifyTemplateData{}, err
+	}
+
+	if r.Parent != "" {
+		parent, err = nh.db.node(r.Parent)
+		if err != nil {
+			return notifyTemplateData{}, err
+		}
+	}
+
+	return newNotifyTemplateData(triggerNode, triggerPoint, ruleNode, parent), nil
+}
+
+// ruleNotify sends the notification for a notify action. If the action has
+// subject, message, or HTML templates, they are rendered with the trigger
+// node and point, the rule, and the node the rule is attached to. If a
+// template fails to render, the error is logged and the default message is
+// sent (without HTML) so that the notification is not lost.
+func (nh *NatsHandler) ruleNotify(r *data.Rule, a data.Action, triggerNodeID string, triggerPoint data.Point) error {
+	ntd, err := nh.ruleTemplateData(r, triggerNodeID, triggerPoint)
+	if err != nil {
+		return err
+	}
+
+	message := r.Description + " fired"
+
+	if ntd.Description != "" {
+		message += " at " + ntd.Description
+	}
+
+	var subject string
+
+	render := func(tmpl string, def string) string {
+		if tmpl == "" {
+			return def
+		}
+
+		ret, err := renderNotifyTemplate(tmpl, ntd)
+		if err != nil {
+			log.Printf("Rule action %v template error: %v", a.ID, err)
+			return def
+		}
+
+		return ret
+	}
+
+	subject = render(a.SubjectTemplate, subject)
+	message = render(a.Template, message)
+
+	var html string
+
+	if a.HTMLTemplate != "" {
+		html, err = renderNotifyHTMLTemplate(a.HTMLTemplate, ntd)
+		if err != nil {
+			log.Printf("Rule action %v HTML template error: %v", a.ID, err)
+			html = ""
+		}
+	}
+
+	n := data.Notification{
+		ID:         uuid.New().String(),
+		SourceNode: a.NodeID,
+		Subject:    subject,
+		Message:    message,
+		HTML:       html,
+		Severity:   r.Severity,
+		RuleID:     r.ID,
+	}
+
+	// by default, users in the rule's groups and their parent groups are
+	// notified. The action node ID may select a user, or a group whose users
+	// are notified.
+	targets := map[string]string{r.ID: ""}
+
+	if a.NodeID != "" {
+		targets, err = nh.notifyTargets(a.NodeID)
+		if err != nil {
+			return err
+		}
+	}
+
+	for id, parent := range targets {
+		n.Parent = parent
+
+		d, err := n.ToPb()
+
+		if err != nil {
+			return err
+		}
+
+		err = nh.Nc.Publish("node."+id+".not", d)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// notifyTargets returns the nodes a notification for a node should be sent
+// to, mapped to the parent of the node. Users are notified directly, only the
+// users that are direct children of a group are notified, and notifications
+// for other nodes are sent to the users above the node.
+func (nh *NatsHandler) notifyTargets(nodeID string) (map[string]string, error) {
+	ret := make(map[string]string)
+
+	node, err := nh.db.node(nodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch node.Type {
+	case data.NodeTypeUser:
+		edges, err := nh.db.edgeUp(nodeID)
+		if err != nil {
+			return nil, err
+		}
+
+		parent := ""
+		if len(edges) > 0 {
+			parent = edges[0].Up
+		}
+
+		ret[nodeID] = parent
+
+	case data.NodeTypeGroup:
+		users, err := nh.db.nodeDescendents(nodeID, data.NodeTypeUser, false, false)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, u := range users {
+			ret[u.ID] = nodeID
+		}
+
+	default:
+		ret[nodeID] = ""
+	}
+
+	return ret, nil
+}
+
+// webhookMaxBackoff is the maximum delay between webhook retries
+var webhookMaxBackoff = time.Minute
+
+// webhookBody is sent by webhook actions that do not have a template
+type webhookBody struct {
+	RuleID string     `json:"ruleId"`
+	Rule   string     `json:"rule"`
+	Active bool       `json:"active"`
+	NodeID string     `json:"nodeId"`
+	Node   string     `json:"node"`
+	Point  data.Point `json:"point"`
+}
+
+// ruleWebhook sends the request for a webhook action. The body is rendered
+// from the action template, or is a JSON summary of the rule if the template
+// is blank. Requests that fail without a response or with a 5xx status are
+// retried with a backoff. The request is sent in the background, and the
+// result is recorded in the statusCode, attempts, and error points of the
+// action.
+func (nh *NatsHandler) ruleWebhook(r *data.Rule, a data.Action, triggerNodeID string, triggerPoint data.Point) error {
+	if a.URI == "" {
+		return fmt.Errorf("webhook action %v does not have a URI", a.ID)
+	}
+
+	ntd, err := nh.ruleTemplateData(r, triggerNodeID, triggerPoint)
+	if err != nil {
+		return err
+	}
+
+	var body []byte
+
+	if a.Template != "" {
+		b, err := renderNotifyTemplate(a.Template, ntd)
+		if err != nil {
+			return fmt.Errorf("webhook action %v template error: %w", a.ID, err)
+		}
+		body = []byte(b)
+	} else {
+		body, err = json.Marshal(webhookBody{
+			RuleID: r.ID,
+			Rule:   r.Description,
+			Active: r.Active,
+			NodeID: ntd.ID,
+			Node:   ntd.Description,
+			Point:  triggerPoint,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	timeout := 10 * time.Second
+	if a.Timeout > 0 {
+		timeout = time.Duration(a.Timeout * float64(time.Second))
+	}
+
+	wh := msg.NewWebhook(a.URI, a.Method, a.Headers, timeout)
+
+	go func() {
+		var status, attempts int
+		var err error
+
+		for {
+			attempts++
+			status, err = wh.Send(body)
+			if err == nil || attempts > a.Retries ||
+				(status != 0 && status < 500) {
+				break
+			}
+
+			time.Sleep(nats.ExpBackoff(attempts-1, webhookMaxBackoff))
+		}
+
+		errText := ""
+		if err != nil {
+			log.Printf("Rule action %v webhook error: %v", a.ID, err)
+			errText = err.Error()
+		}
+
+		now := time.Now()
+
+		err = nats.SendNodePoints(nh.Nc, a.ID, data.Points{
+			{Type: data.PointTypeStatusCode, Time: now, Value: float64(status)},
+			{Type: data.PointTypeAttempts, Time: now, Value: float64(attempts)},
+			{Type: data.PointTypeError, Time: now, Text: errText},
+		}, false)
+		if err != nil {
+			log.Println("Rule error sending point: ", err)
+		}
+	}()
+
+	return nil
+}
@@ -4,14 +4,28 @@ import (
 	"fmt"
 	"log"
 	"regexp"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 )
 
+// schedule is active between startTime and endTime (HH:MM) in the timezone
+// (IANA name, UTC if blank). A time range that wraps past midnight belongs to
+// the day it starts on, and is filtered by weekday and date on that day.
+// If dates are specified, the schedule is only active on those dates.
+// Dates are YYYY-MM-DD, or an inclusive YYYY-MM-DD/YYYY-MM-DD range.
+// Dates in calendar are excluded, or if calendarOnly is set, the schedule
+// is only active on calendar dates.
 type schedule struct {
-	startTime string
-	endTime   string
-	weekdays  []time.Weekday
+	startTime    string
+	endTime      string
+	weekdays     []time.Weekday
+	timezone     string
+	dates        []string
+	excludeDates []string
+	calendar     []string
+	calendarOnly bool
 }
 
 func newSchedule(start, end string, weekdays []time.Weekday) *schedule {
@@ -22,48 +36,63 @@ func newSchedule(start, end string, weekdays []time.Weekday) *schedule {
 	}
 }
 
-func (s *schedule) activeForTime(t time.Time) (bool, error) {
-	tUTC := t.UTC()
+func (s *schedule) location() (*time.Location, error) {
+	if s.timezone == "" {
+		return time.UTC, nil
+	}
+
+	loc, err := time.LoadLocation(s.timezone)
+	if err != nil {
+		return nil, fmt.Errorf("TimeRange: invalid timezone: %v", err)
+	}
+
+	return loc, nil
+}
 
-	// parse out hour/minute
-	matches := reHourMin.FindStringSubmatch(s.startTime)
+// parseHourMin parses a HH:MM time
+func parseHourMin(s string) (int, int, error) {
+	matches := reHourMin.FindStringSubmatch(s)
 	if len(matches) < 3 {
-		return false, fmt.Errorf("TimeRange: invalid start: %v ", s.startTime)
+		return 0, 0, fmt.Errorf("TimeRange: invalid time: %v ", s)
 	}
 
-	startHour, err := strconv.Atoi(matches[1])
+	hour, err := strconv.Atoi(matches[1])
 	if err != nil {
-		return false, fmt.Errorf("TimeRange: error parsing start hour: %v", matches[1])
+		return 0, 0, fmt.Errorf("TimeRange: error parsing hour: %v", matches[1])
 	}
 
-	startMin, err := strconv.Atoi(matches[2])
+	min, err := strconv.Atoi(matches[2])
 	if err != nil {
-		return false, fmt.Errorf("TimeRange: error parsing start hour: %v", matches[1])
+		return 0, 0, fmt.Errorf("TimeRange: error parsing minute: %v", matches[2])
 	}
 
-	matches = reHourMin.FindStringSubmatch(s.endTime)
-	if len(matches) < 3 {
-		return false, fmt.Errorf("TimeRange: invalid end: %v ", s.endTime)
+	return hour, min, nil
+}
+
+func (s *schedule) activeForTime(t time.Time) (bool, error) {
+	loc, err := s.location()
+	if err != nil {
+		return false, err
 	}
 
-	endHour, err := strconv.Atoi(matches[1])
+	tLocal := t.In(loc)
 
+	startHour, startMin, err := parseHourMin(s.startTime)
 	if err != nil {
-		return false, fmt.Errorf("TimeRange: error parsing end hour: %v", matches[1])
+		return false, err
 	}
 
-	endMin, err := strconv.Atoi(matches[2])
-
+	endHour, endMin, err := parseHourMin(s.endTime)
 	if err != nil {
-		return false, fmt.Errorf("TimeRange: error parsing end hour: %v", matches[1])
+		return false, err
 	}
 
-	y := tUTC.Year()
-	m := tUTC.Month()
-	d := tUTC.Day()
+	y := tLocal.Year()
+	m := tLocal.Month()
+	d := tLocal.Day()
 
-	start := time.Date(y, m, d, startHour, startMin, 0, 0, time.UTC)
-	end := time.Date(y, m, d, endHour, endMin, 0, 0, time.UTC)
+	start := time.Date(y, m, d, startHour, startMin, 0, 0, loc)
+	end := time.Date(y, m, d, endHour, endMin, 0, 0, loc)
 
 	timeRanges := timeRanges{
 		{start, end},
@@ -80,6 +109,35 @@ func (s *schedule) activeForTime(t time.Time) (bool, error) {
 
 	timeRanges.filterWeekdays(s.weekdays)
 
+	dates, err := parseDateRanges(s.dates)
+	if err != nil {
+		return false, err
+	}
+
+	timeRanges.filterDates(dates)
+
+	excludeDates, err := parseDateRanges(s.excludeDates)
+	if err != nil {
+		return false, err
+	}
+
+	timeRanges.excludeDates(excludeDates)
+
+	calendar, err := parseDateRanges(s.calendar)
+	if err != nil {
+		return false, err
+	}
+
+	if s.calendarOnly {
+		if len(calendar) <= 0 {
+			return false, nil
+		}
+
+		timeRanges.filterDates(calendar)
+	} else {
+		timeRanges.excludeDates(calendar)
+	}
+
 	if timeRanges.in(t) {
 		return true, nil
 	}
@@ -87,8 +145,67 @@ func (s *schedule) activeForTime(t time.Time) (bool, error) {
 	return false, nil
 }
 
+// nextEdge returns the next time after t that the schedule changes state.
+// The state can only change at the start or end time of a day, so these
+// times are checked for the next week. If the state does not change in this
+// time, for example because the schedule is limited to dates that are
+// further out, a time a day from t is returned so that the schedule is
+// checked again.
+func (s *schedule) nextEdge(t time.Time) (time.Time, error) {
+	active, err := s.activeForTime(t)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	loc, err := s.location()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	startHour, startMin, err := parseHourMin(s.startTime)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	endHour, endMin, err := parseHourMin(s.endTime)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	tLocal := t.In(loc)
+	y, m, d := tLocal.Date()
+
+	var edges []time.Time
+	for i := 0; i <= 8; i++ {
+		edges = append(edges,
+			time.Date(y, m, d+i, startHour, startMin, 0, 0, loc),
+			time.Date(y, m, d+i, endHour, endMin, 0, 0, loc))
+	}
+
+	sort.Slice(edges, func(i, j int) bool {
+		return edges[i].Before(edges[j])
+	})
+
+	for _, e := range edges {
+		if !e.After(t) {
+			continue
+		}
+
+		a, err := s.activeForTime(e)
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		if a != active {
+			return e, nil
+		}
+	}
+
+	return t.Add(24 * time.Hour), nil
+}
+
 var reHourMin = regexp.MustCompile(`(\d{1,2}):(\d\d)`)
-var reDate = regexp.MustCompile(`(\d{4})-(\d{1,2})-(\d{1,2})`)
+var reDate = regexp.MustCompile(`^(\d{4})-(\d{1,2})-(\d{1,2})$`)
 
 type timeRange struct {
 	start time.Time
@@ -150,27 +267,112 @@ func (trs *timeRanges) filterWeekdays(weekdays []time.Weekday) {
 	*trs = trsNew
 }
 
-// FilterDates removes time ranges that do not have the same date as the provided list of times
-func (trs *timeRanges) FilterDates(dates []time.Time) {
+// filterDates removes time ranges that do not start on one of the dates
+func (trs *timeRanges) filterDates(dates []dateRange) {
 	if len(dates) <= 0 {
 		return
 	}
 
 	trsNew := (*trs)[:0]
 	for _, tr := range *trs {
-		dateFound := false
-		for _, date := range dates {
-			if date.Year() == tr.start.Year() &&
-				date.Month() == tr.start.Month() &&
-				date.Day() == tr.start.Day() {
-				dateFound = true
-				break
-			}
+		if dateRanges(dates).in(tr.start) {
+			trsNew = append(trsNew, tr)
 		}
-		if dateFound {
+	}
+
+	*trs = trsNew
+}
+
+// excludeDates removes time ranges that start on one of the dates
+func (trs *timeRanges) excludeDates(dates []dateRange) {
+	if len(dates) <= 0 {
+		return
+	}
+
+	trsNew := (*trs)[:0]
+	for _, tr := range *trs {
+		if !dateRanges(dates).in(tr.start) {
 			trsNew = append(trsNew, tr)
 		}
 	}
 
 	*trs = trsNew
 }
+
+// dateRange is an inclusive range of dates. start and end are midnight UTC
+// on the dates so that dates can be compared independent of timezone.
+type dateRange struct {
+	start time.Time
+	end   time.Time
+}
+
+// in returns true if the date of t (in the location of t) is in the range
+func (dr dateRange) in(t time.Time) bool {
+	date := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return !date.Before(dr.start) && !date.After(dr.end)
+}
+
+type dateRanges []dateRange
+
+// in returns true if the date of t is in any of the date ranges
+func (drs dateRanges) in(t time.Time) bool {
+	for _, dr := range drs {
+		if dr.in(t) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func parseDate(s string) (time.Time, error) {
+	matches := reDate.FindStringSubmatch(strings.TrimSpace(s))
+	if len(matches) < 4 {
+		return time.Time{}, fmt.Errorf("TimeRange: invalid date: %v", s)
+	}
+
+	y, _ := strconv.Atoi(matches[1])
+	m, _ := strconv.Atoi(matches[2])
+	d, _ := strconv.Atoi(matches[3])
+
+	if m < 1 || m > 12 || d < 1 || d > 31 {
+		return time.Time{}, fmt.Errorf("TimeRange: invalid date: %v", s)
+	}
+
+	return time.Date(y, time.Month(m), d, 0, 0, 0, 0, time.UTC), nil
+}
+
+// parseDateRanges parses dates in the form YYYY-MM-DD or
+// YYYY-MM-DD/YYYY-MM-DD. Blank dates are ignored.
+func parseDateRanges(dates []string) ([]dateRange, error) {
+	var ret []dateRange
+
+	for _, s := range dates {
+		if strings.TrimSpace(s) == "" {
+			continue
+		}
+
+		parts := strings.SplitN(s, "/", 2)
+
+		start, err := parseDate(parts[0])
+		if err != nil {
+			return nil, err
+		}
+
+		end := start
+		if len(parts) > 1 {
+			end, err = parseDate(parts[1])
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if end.Before(start) {
+			return nil, fmt.Errorf("TimeRange: date range ends before it starts: %v", s)
+		}
+
+		ret = append(ret, dateRange{start, end})
+	}
+
+	return ret, nil
+}
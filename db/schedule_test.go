@@ -75,3 +75,154 @@ func TestScheduleWrapDayWeekday(t *testing.T) {
 
 	tests.run(t, sched)
 }
+
+func TestScheduleTimezone(t *testing.T) {
+	sched := newSchedule("6:00", "7:00", []time.Weekday{})
+	sched.timezone = "America/Chicago"
+
+	// DST starts on 2021-03-14 in the US
+	tests := testTable{
+		{time.Date(2021, time.March, 13, 12, 30, 0, 0, time.UTC), true},
+		{time.Date(2021, time.March, 13, 11, 30, 0, 0, time.UTC), false},
+		{time.Date(2021, time.March, 15, 11, 30, 0, 0, time.UTC), true},
+		{time.Date(2021, time.March, 15, 12, 30, 0, 0, time.UTC), false},
+	}
+
+	tests.run(t, sched)
+
+	sched.timezone = "Nowhere/Bogus"
+	_, err := sched.activeForTime(time.Now())
+	if err == nil {
+		t.Error("expected error for invalid timezone")
+	}
+}
+
+func TestScheduleWeekdayTimezone(t *testing.T) {
+	sched := newSchedule("20:00", "23:00", []time.Weekday{1})
+	sched.timezone = "America/Chicago"
+
+	// 2021-08-09 is a Monday. 21:00 local is 02:00 UTC on Tuesday.
+	tests := testTable{
+		{time.Date(2021, time.August, 10, 2, 0, 0, 0, time.UTC), true},
+		{time.Date(2021, time.August, 9, 2, 0, 0, 0, time.UTC), false},
+	}
+
+	tests.run(t, sched)
+}
+
+func TestScheduleDates(t *testing.T) {
+	sched := newSchedule("20:00", "2:00", []time.Weekday{})
+	sched.timezone = "America/Chicago"
+	sched.dates = []string{"2021-12-24/2021-12-26", "2021-12-31"}
+	sched.excludeDates = []string{"2021-12-25"}
+
+	tests := testTable{
+		// 2021-12-24 21:00 local
+		{time.Date(2021, time.December, 25, 3, 0, 0, 0, time.UTC), true},
+		// 2021-12-26 01:00 local, range started on excluded date
+		{time.Date(2021, time.December, 26, 7, 0, 0, 0, time.UTC), false},
+		// 2021-12-27 01:00 local, range started on 2021-12-26
+		{time.Date(2021, time.December, 27, 7, 0, 0, 0, time.UTC), true},
+		// 2021-12-27 21:00 local
+		{time.Date(2021, time.December, 28, 3, 0, 0, 0, time.UTC), false},
+		// 2021-12-31 21:00 local
+		{time.Date(2022, time.January, 1, 3, 0, 0, 0, time.UTC), true},
+	}
+
+	tests.run(t, sched)
+}
+
+func TestScheduleCalendar(t *testing.T) {
+	sched := newSchedule("6:00", "18:00", []time.Weekday{})
+	sched.calendar = []string{"2021-12-25", "2022-01-01"}
+
+	tests := testTable{
+		{time.Date(2021, time.December, 24, 12, 0, 0, 0, time.UTC), true},
+		{time.Date(2021, time.December, 25, 12, 0, 0, 0, time.UTC), false},
+	}
+
+	tests.run(t, sched)
+
+	sched.calendarOnly = true
+
+	tests = testTable{
+		{time.Date(2021, time.December, 24, 12, 0, 0, 0, time.UTC), false},
+		{time.Date(2021, time.December, 25, 12, 0, 0, 0, time.UTC), true},
+	}
+
+	tests.run(t, sched)
+
+	// a schedule limited to an empty calendar is never active
+	sched.calendar = nil
+
+	tests = testTable{
+		{time.Date(2021, time.December, 25, 12, 0, 0, 0, time.UTC), false},
+	}
+
+	tests.run(t, sched)
+}
+
+func TestParseDateRanges(t *testing.T) {
+	drs, err := parseDateRanges([]string{"2021-12-24", " 2021-12-30 / 2022-01-02 ", ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(drs) != 2 {
+		t.Fatal("expected 2 date ranges, got: ", len(drs))
+	}
+
+	if !dateRanges(drs).in(time.Date(2022, time.January, 2, 23, 0, 0, 0, time.UTC)) {
+		t.Error("expected last day of range to be included")
+	}
+
+	for _, bad := range []string{"2021-13-01", "12/25", "2021-12-25/2021-12-24"} {
+		_, err := parseDateRanges([]string{bad})
+		if err == nil {
+			t.Error("expected error for: ", bad)
+		}
+	}
+}
+
+func TestScheduleNextEdge(t *testing.T) {
+	sched := newSchedule("20:00", "2:00", []time.Weekday{1})
+
+	// 2021-08-09 is a Monday
+	tests := []struct {
+		t   time.Time
+		exp time.Time
+	}{
+		{time.Date(2021, time.August, 9, 12, 0, 0, 0, time.UTC),
+			time.Date(2021, time.August, 9, 20, 0, 0, 0, time.UTC)},
+		{time.Date(2021, time.August, 9, 20, 0, 0, 0, time.UTC),
+			time.Date(2021, time.August, 10, 2, 0, 0, 0, time.UTC)},
+		{time.Date(2021, time.August, 10, 3, 0, 0, 0, time.UTC),
+			time.Date(2021, time.August, 16, 20, 0, 0, 0, time.UTC)},
+	}
+
+	for _, test := range tests {
+		next, err := sched.nextEdge(test.t)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !next.Equal(test.exp) {
+			t.Errorf("for %v expected %v, got %v", test.t, test.exp, next)
+		}
+	}
+
+	// DST starts on 2021-03-14 in the US, so 6:00 local moves from 12:00
+	// to 11:00 UTC
+	sched = newSchedule("6:00", "7:00", []time.Weekday{})
+	sched.timezone = "America/Chicago"
+
+	next, err := sched.nextEdge(time.Date(2021, time.March, 13, 13, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := time.Date(2021, time.March, 14, 11, 0, 0, 0, time.UTC)
+	if !next.Equal(exp) {
+		t.Errorf("expected %v, got %v", exp, next)
+	}
+}
@@ -0,0 +1,510 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	natsgo "github.com/nats-io/nats.go"
+	"github.com/simpleiot/simpleiot/data"
+	"github.com/simpleiot/simpleiot/msg"
+	"github.com/simpleiot/simpleiot/nats"
+)
+
+// smsPollInterval is how often message services that receive SMS by polling,
+// like cellular modems, are checked for new messages
+const smsPollInterval = 10 * time.Second
+
+// smsHelp is the reply to the HELP command and unknown commands
+const smsHelp = "Commands: ACK [alarm], STATUS <device>, SET <node> <value>"
+
+// phoneDigits returns the digits in a phone number
+func phoneDigits(phone string) string {
+	var ret strings.Builder
+
+	for _, c := range phone {
+		if c >= '0' && c <= '9' {
+			ret.WriteRune(c)
+		}
+	}
+
+	return ret.String()
+}
+
+// phoneMatch returns true if two phone numbers are the same. Formatting is
+// ignored. If only one of the numbers has a country code (starts with +),
+// they match if the last 10 digits are the same.
+func phoneMatch(a, b string) bool {
+	international := strings.HasPrefix(strings.TrimSpace(a), "+") &&
+		strings.HasPrefix(strings.TrimSpace(b), "+")
+
+	a = phoneDigits(a)
+	b = phoneDigits(b)
+
+	if a == "" || b == "" {
+		return false
+	}
+
+	if a == b {
+		return true
+	}
+
+	if international || len(a) < 10 || len(b) < 10 {
+		return false
+	}
+
+	return a[len(a)-10:] == b[len(b)-10:]
+}
+
+// smsUser returns the user with a phone number. Users whose number is the
+// same take precedence over users whose number only matches without the
+// country code. Senders that match more than one user are rejected, as the
+// command could otherwise run as the wrong user.
+func (nh *NatsHandler) smsUser(phone string) (data.User, error) {
+	nodes, err := nh.db.nodeDescendents(nh.db.rootNodeID(), data.NodeTypeUser, true, false)
+	if err != nil {
+		return data.User{}, err
+	}
+
+	var exact, partial []data.User
+	found := make(map[string]bool)
+
+	for _, n := range nodes {
+		// users with more than one parent are returned once per parent
+		if found[n.ID] {
+			continue
+		}
+
+		user, err := data.NodeToUser(n.ToNode())
+		if err != nil {
+			continue
+		}
+
+		if !phoneMatch(user.Phone, phone) {
+			continue
+		}
+
+		found[n.ID] = true
+
+		if phoneDigits(user.Phone) == phoneDigits(phone) {
+			exact = append(exact, user)
+		} else {
+			partial = append(partial, user)
+		}
+	}
+
+	matches := exact
+	if len(matches) <= 0 {
+		matches = partial
+	}
+
+	switch len(matches) {
+	case 0:
+		return data.User{}, fmt.Errorf("unknown SMS sender: %v", phone)
+	case 1:
+		return matches[0], nil
+	}
+
+	return data.User{}, fmt.Errorf("SMS sender %v matches more than one user", phone)
+}
+
+// smsFindNode finds a node the user has access to by ID or description.
+// Descriptions are not case sensitive. If typ is set, only nodes of that
+// type are matched.
+func (nh *NatsHandler) smsFindNode(userID, name, typ string) (data.NodeEdge, error) {
+	nodes, err := nh.db.NodesForUser(userID)
+	if err != nil {
+		return data.NodeEdge{}, err
+	}
+
+	var found []data.NodeEdge
+
+	for _, n := range nodes {
+		if typ != "" && n.Type != typ {
+			continue
+		}
+
+		if tombstone, _ := n.IsTombstone(); tombstone {
+			continue
+		}
+
+		if n.ID == name {
+			return n, nil
+		}
+
+		if !strings.EqualFold(n.Desc(), name) {
+			continue
+		}
+
+		// nodes with more than one parent are returned once per parent
+		dup := false
+		for _, f := range found {
+			if f.ID == n.ID {
+				dup = true
+			}
+		}
+
+		if !dup {
+			found = append(found, n)
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		return data.NodeEdge{}, fmt.Errorf("%v not found", name)
+	case 1:
+		return found[0], nil
+	}
+
+	return data.NodeEdge{}, fmt.Errorf("more than one node is named %v", name)
+}
+
+// smsValue formats the value point of a node with its units
+func smsValue(n data.NodeEdge) (string, bool) {
+	v, ok := n.Points.Value("", data.PointTypeValue, 0)
+	if !ok {
+		return "", false
+	}
+
+	ret := strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+
+	if units, _ := n.Points.Text("", data.PointTypeUnits, 0); units != "" {
+		ret += " " + units
+	}
+
+	return ret, true
+}
+
+// smsAck acknowledges the alarm of a rule. If name is blank, the rule of the
+// last notification sent to the user is acknowledged.
+func (nh *NatsHandler) smsAck(userID, name string) (string, error) {
+	ruleID := ""
+
+	if name == "" {
+		deliveries, err := nh.db.deliveries(data.DeliveryQuery{UserID: userID})
+		if err != nil {
+			return "", err
+		}
+
+		for i := len(deliveries) - 1; i >= 0; i-- {
+			if deliveries[i].Message.RuleID != "" {
+				ruleID = deliveries[i].Message.RuleID
+				break
+			}
+		}
+
+		if ruleID == "" {
+			return "", errors.New("no alarm to acknowledge")
+		}
+	} else {
+		rule, err := nh.smsFindNode(userID, name, data.NodeTypeRule)
+		if err != nil {
+			return "", err
+		}
+
+		ruleID = rule.ID
+	}
+
+	err := nh.alarmAck(ruleID, data.AlarmAck{UserID: userID, Action: data.AlarmActionAck})
+	if err != nil {
+		return "", err
+	}
+
+	desc := ruleID
+	rule, err := nh.db.node(ruleID)
+	if err == nil {
+		desc = rule.Desc()
+	}
+
+	return desc + " acknowledged", nil
+}
+
+// smsStatus returns the state and value of a node, and the values of its
+// children, for example the IO of a Modbus device
+func (nh *NatsHandler) smsStatus(userID, name string) (string, error) {
+	n, err := nh.smsFindNode(userID, name, "")
+	if err != nil {
+		return "", err
+	}
+
+	ret := n.Desc()
+
+	if state, _ := n.Points.Text("", data.PointTypeSysState, 0); state != "" {
+		ret += " (" + state + ")"
+	}
+
+	var values []string
+
+	if v, ok := smsValue(n); ok {
+		values = append(values, v)
+	}
+
+	children, err := nh.db.nodeDescendents(n.ID, "", false, false)
+	if err != nil {
+		return "", err
+	}
+
+	for _, c := range children {
+		if v, ok := smsValue(c); ok {
+			values = append(values, c.Desc()+" "+v)
+		}
+	}
+
+	if len(values) > 0 {
+		ret += ": " + strings.Join(values, ", ")
+	}
+
+	return ret, nil
+}
+
+// smsSet sets the value of a node. Nodes that are written to by a client,
+// like Modbus IO, are set through the valueSet point, otherwise the value
+// point is set. Values may be numbers, or on/off.
+func (nh *NatsHandler) smsSet(userID, name, value string) (string, error) {
+	n, err := nh.smsFindNode(userID, name, "")
+	if err != nil {
+		return "", err
+	}
+
+	var v float64
+
+	switch strings.ToLower(value) {
+	case "on", "true":
+		v = 1
+	case "off", "false":
+		v = 0
+	default:
+		v, err = strconv.ParseFloat(value, 64)
+		if err != nil {
+			return "", fmt.Errorf("invalid value: %v", value)
+		}
+	}
+
+	if readOnly, _ := n.Points.ValueBool("", data.PointTypeReadOnly, 0); readOnly {
+		return "", fmt.Errorf("%v is read only", n.Desc())
+	}
+
+	typ := ""
+
+	schema, ok := data.GetNodeSchema(n.Type)
+	if ok {
+		for _, t := range []string{data.PointTypeValueSet, data.PointTypeValue} {
+			if _, ok := schema.Point(t); ok {
+				typ = t
+				break
+			}
+		}
+	}
+
+	if typ == "" {
+		return "", fmt.Errorf("%v can't be set", n.Desc())
+	}
+
+	err = nats.SendNodePoint(nh.Nc, n.ID, data.Point{
+		Type:  typ,
+		Time:  time.Now(),
+		Value: v,
+	}, true)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%v set to %v", n.Desc(), value), nil
+}
+
+// runSMSCommand runs a command from a user and returns the reply
+func (nh *NatsHandler) runSMSCommand(userID, body string) (string, error) {
+	fields := strings.Fields(body)
+	if len(fields) == 0 {
+		return smsHelp, nil
+	}
+
+	args := fields[1:]
+
+	switch strings.ToUpper(fields[0]) {
+	case data.SMSCommandAck:
+		return nh.smsAck(userID, strings.Join(args, " "))
+	case data.SMSCommandStatus:
+		if len(args) < 1 {
+			return "", errors.New("usage: STATUS <device>")
+		}
+		return nh.smsStatus(userID, strings.Join(args, " "))
+	case data.SMSCommandSet:
+		if len(args) < 2 {
+			return "", errors.New("usage: SET <node> <value>")
+		}
+		return nh.smsSet(userID, strings.Join(args[:len(args)-1], " "),
+			args[len(args)-1])
+	case data.SMSCommandHelp:
+		return smsHelp, nil
+	}
+
+	return "", fmt.Errorf("unknown command %v. %v", fields[0], smsHelp)
+}
+
+// smsCommand runs a command received by a message service. The sender is
+// looked up by phone number, and the reply is sent back to them by SMS
+// through the same service. Commands from unknown senders are not answered.
+// The result is published on the command subject of the user.
+func (nh *NatsHandler) smsCommand(cmd data.SMSCommand) data.SMSCommand {
+	if cmd.Time.IsZero() {
+		cmd.Time = time.Now()
+	}
+
+	node, err := nh.db.node(cmd.ServiceID)
+	if err != nil {
+		cmd.Error = fmt.Sprintf("msg service %v not found: %v", cmd.ServiceID, err)
+		return cmd
+	}
+
+	svc, err := data.NodeToMsgService(*node)
+	if err != nil {
+		cmd.Error = err.Error()
+		return cmd
+	}
+
+	user, err := nh.smsUser(cmd.From)
+	if err != nil {
+		log.Println("Error running SMS command: ", err)
+		cmd.Error = err.Error()
+		return cmd
+	}
+
+	cmd.UserID = user.ID
+
+	reply, err := nh.runSMSCommand(user.ID, cmd.Body)
+	if err != nil {
+		cmd.Error = err.Error()
+		reply = "Error: " + err.Error()
+	}
+
+	cmd.Reply = reply
+
+	nh.queueDelivery(svc, data.Message{
+		ID:       uuid.New().String(),
+		UserID:   user.ID,
+		Phone:    cmd.From,
+		Message:  reply,
+		Channels: []string{data.PointValueSMS},
+	})
+
+	d, err := cmd.ToPb()
+	if err != nil {
+		// publish the error so subscribers know a command was run
+		cmd.Error = fmt.Sprintf("Error encoding SMS command: %v", err)
+		d, err = data.SMSCommand{UserID: user.ID, Error: cmd.Error}.ToPb()
+		if err != nil {
+			log.Println("Error encoding SMS command error: ", err)
+			return cmd
+		}
+	}
+
+	err = nh.Nc.Publish(nats.SubjectNodeCommand(user.ID), d)
+	if err != nil {
+		log.Println("Error publishing SMS command: ", err)
+	}
+
+	return cmd
+}
+
+// receiveSMS runs the commands received by message services that are polled
+// for inbound messages
+func (nh *NatsHandler) receiveSMS() error {
+	nodes, err := nh.db.nodeDescendents(nh.db.rootNodeID(), data.NodeTypeMsgService,
+		true, false)
+	if err != nil {
+		return err
+	}
+
+	done := make(map[string]bool)
+
+	for _, n := range nodes {
+		if done[n.ID] {
+			continue
+		}
+
+		done[n.ID] = true
+
+		if tombstone, _ := n.IsTombstone(); tombstone {
+			continue
+		}
+
+		svc, err := data.NodeToMsgService(n.ToNode())
+		if err != nil {
+			continue
+		}
+
+		service, err := nh.msgService(svc)
+		if err != nil {
+			continue
+		}
+
+		receiver, ok := service.(msg.Receiver)
+		if !ok {
+			continue
+		}
+
+		cmds, err := receiver.Receive()
+		if err != nil {
+			log.Printf("Error receiving SMS from %v: %v\n", n.Desc(), err)
+		}
+
+		for _, cmd := range cmds {
+			cmd.ServiceID = svc.ID
+			cmd = nh.smsCommand(cmd)
+			if cmd.Error != "" {
+				log.Printf("SMS command from %v: %v\n", cmd.From, cmd.Error)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (nh *NatsHandler) handleNodeSMS(msg *natsgo.Msg) {
+	var cmd data.SMSCommand
+	var err error
+	var resp []byte
+
+	chunks := strings.Split(msg.Subject, ".")
+	if len(chunks) < 3 {
+		err = fmt.Errorf("Error in message subject: %v", msg.Subject)
+		goto handleNodeSMSDone
+	}
+
+	cmd, err = data.PbDecodeSMSCommand(msg.Data)
+	if err != nil {
+		err = fmt.Errorf("Error decoding SMS command: %v", err)
+		goto handleNodeSMSDone
+	}
+
+	cmd.ServiceID = chunks[1]
+	cmd = nh.smsCommand(cmd)
+
+handleNodeSMSDone:
+	if err != nil {
+		cmd.Error = err.Error()
+	}
+
+	resp, err = cmd.ToPb()
+	if err != nil {
+		// reply with the error so the requester does not wait for a timeout
+		resp, err = data.SMSCommand{
+			Error: fmt.Sprintf("Error encoding SMS command: %v", err),
+		}.ToPb()
+		if err != nil {
+			log.Println("Error encoding SMS command error: ", err)
+			return
+		}
+	}
+
+	err = nh.Nc.Publish(msg.Reply, resp)
+	if err != nil {
+		log.Println("NATS: Error publishing response to SMS command: ", err)
+	}
+}
@@ -0,0 +1,93 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/simpleiot/simpleiot/data"
+)
+
+func TestPhoneMatch(t *testing.T) {
+	tests := []struct {
+		a, b string
+		exp  bool
+	}{
+		{"+15555550100", "+15555550100", true},
+		{"+15555550100", "(555) 555-0100", true},
+		{"+1 555 555 0100", "555.555.0100", true},
+		{"+15555550100", "+15555550101", false},
+		{"15555550100", "(555) 555-0100", true},
+		{"+445555550100", "+15555550100", false},
+		{"0100", "+15555550100", false},
+		{"", "", false},
+	}
+
+	for _, test := range tests {
+		if phoneMatch(test.a, test.b) != test.exp {
+			t.Errorf("%v, %v: expected %v", test.a, test.b, test.exp)
+		}
+	}
+}
+
+func TestSMSUser(t *testing.T) {
+	db, err := NewDb(StoreTypeMemory, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nh := &NatsHandler{db: db}
+
+	_, err = db.nodePoints("root", data.Points{
+		{Type: data.PointTypeNodeType, Text: data.NodeTypeDevice},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	users := map[string]string{
+		"bob":   "+15555550100",
+		"alice": "(555) 555-0100",
+		"carol": "555.555.0101",
+		"dave":  "(555) 555-0101",
+	}
+
+	for id, phone := range users {
+		user := data.User{Email: id + "@example.com", Phone: phone}
+		_, err := db.nodePoints(id, user.ToPoints())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = db.edgePoints(id, db.rootNodeID(),
+			data.Points{{Type: data.PointTypeTombstone, Value: 0}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		phone string
+		exp   string
+	}{
+		// exact matches take precedence
+		{"+15555550100", "bob"},
+		{"5555550100", "alice"},
+		{"+445555550100", "alice"},
+		// carol and dave only differ by formatting
+		{"+15555550101", ""},
+		{"+15555550102", ""},
+	}
+
+	for _, test := range tests {
+		user, err := nh.smsUser(test.phone)
+		if test.exp == "" {
+			if err == nil {
+				t.Errorf("%v: expected error, got %v", test.phone, user.ID)
+			}
+			continue
+		}
+
+		if err != nil || user.ID != test.exp {
+			t.Errorf("%v: expected %v, got %v, %v", test.phone, test.exp, user.ID, err)
+		}
+	}
+}
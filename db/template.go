@@ -0,0 +1,113 @@
+package db
+
+import (
+	"bytes"
+	"encoding/json"
+	htmltemplate "html/template"
+	"text/template"
+
+	"github.com/simpleiot/simpleiot/data"
+)
+
+// nodeTemplateData makes the points of a node easy to reference by type or
+// ID in a template
+type nodeTemplateData struct {
+	ID          string
+	Description string
+	Ios         map[string]float64
+	Texts       map[string]string
+}
+
+func newNodeTemplateData(node *data.Node) nodeTemplateData {
+	ret := nodeTemplateData{
+		Ios:   make(map[string]float64),
+		Texts: make(map[string]string),
+	}
+
+	if node == nil {
+		return ret
+	}
+
+	ret.ID = node.ID
+	ret.Description = node.Desc()
+
+	for _, io := range node.Points {
+		if io.Type != "" {
+			ret.Ios[io.Type] = io.Value
+			ret.Texts[io.Type] = io.Text
+		}
+		if io.ID != "" {
+			ret.Ios[io.ID] = io.Value
+			ret.Texts[io.ID] = io.Text
+		}
+	}
+
+	return ret
+}
+
+// notifyTemplateData is passed to notification templates. The fields of the
+// node that triggered the rule are available at the top level.
+type notifyTemplateData struct {
+	nodeTemplateData
+	Point  data.Point
+	Rule   nodeTemplateData
+	Parent nodeTemplateData
+}
+
+func newNotifyTemplateData(triggerNode *data.Node, triggerPoint data.Point,
+	rule, parent *data.Node) notifyTemplateData {
+	return notifyTemplateData{
+		nodeTemplateData: newNodeTemplateData(triggerNode),
+		Point:            triggerPoint,
+		Rule:             newNodeTemplateData(rule),
+		Parent:           newNodeTemplateData(parent),
+	}
+}
+
+// templateFuncs are available in notification templates. json encodes a
+// value as JSON, which is useful for building webhook bodies.
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		d, err := json.Marshal(v)
+		return string(d), err
+	},
+}
+
+// renderNotifyTemplate renders a notification template
+func renderNotifyTemplate(msgTemplate string, ntd notifyTemplateData) (string, error) {
+	buf := new(bytes.Buffer)
+
+	tmpl, err := template.New("msg").Funcs(templateFuncs).Parse(msgTemplate)
+
+	if err != nil {
+		return "", err
+	}
+
+	err = tmpl.Execute(buf, ntd)
+
+	if err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// renderNotifyHTMLTemplate renders a notification HTML email template. Values
+// from the template data are escaped for HTML.
+func renderNotifyHTMLTemplate(htmlTemplate string, ntd notifyTemplateData) (string, error) {
+	buf := new(bytes.Buffer)
+
+	tmpl, err := htmltemplate.New("html").Funcs(htmltemplate.FuncMap(templateFuncs)).Parse(htmlTemplate)
+
+	if err != nil {
+		return "", err
+	}
+
+	err = tmpl.Execute(buf, ntd)
+
+	if err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
@@ -0,0 +1,132 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/simpleiot/simpleiot/data"
+)
+
+func TestNotifyTemplate(t *testing.T) {
+	device := data.Node{
+		ID: "1234",
+		Points: []data.Point{
+			{
+				Type: data.PointTypeDescription,
+				Text: "My Node",
+			},
+			{
+				Type:  "tankLevel",
+				ID:    "",
+				Value: 12.523423423,
+			},
+			{
+				Type:  "current",
+				ID:    "c0",
+				Value: 1.52323,
+			},
+		},
+	}
+
+	res, err := renderNotifyTemplate(`Alarm from {{.Description}}, tank level is {{printf "%.2f" (index .Ios "tankLevel")}}.`,
+		newNotifyTemplateData(&device, data.Point{}, nil, nil))
+
+	if err != nil {
+		t.Error("render failed: ", err)
+	}
+
+	if res != "Alarm from My Node, tank level is 12.52." {
+		t.Error("rendered text is not correct: ", res)
+	}
+}
+
+func TestNotifyTemplateRule(t *testing.T) {
+	tank := data.Node{
+		ID: "tank3",
+		Points: []data.Point{
+			{Type: data.PointTypeDescription, Text: "Tank 3"},
+			{Type: data.PointTypeValue, Value: 12},
+		},
+	}
+
+	rule := data.Node{
+		ID: "rule",
+		Points: []data.Point{
+			{Type: data.PointTypeDescription, Text: "low"},
+		},
+	}
+
+	parent := data.Node{
+		ID: "site",
+		Points: []data.Point{
+			{Type: data.PointTypeDescription, Text: "North site"},
+			{Type: "address", Text: "123 Main"},
+		},
+	}
+
+	res, err := renderNotifyTemplate(
+		`{{.Parent.Description}} ({{index .Parent.Texts "address"}}): {{.Description}} level {{.Point.Value}}% ({{.Rule.Description}})`,
+		newNotifyTemplateData(&tank, data.Point{Type: data.PointTypeValue, Value: 12},
+			&rule, &parent))
+
+	if err != nil {
+		t.Fatal("render failed: ", err)
+	}
+
+	exp := "North site (123 Main): Tank 3 level 12% (low)"
+	if res != exp {
+		t.Errorf("expected %v, got %v", exp, res)
+	}
+
+	// rules fired by a timer do not have a trigger node
+	res, err = renderNotifyTemplate(`{{.Rule.Description}} fired{{with .Description}} at {{.}}{{end}}`,
+		newNotifyTemplateData(nil, data.Point{}, &rule, nil))
+
+	if err != nil {
+		t.Fatal("render failed: ", err)
+	}
+
+	if res != "low fired" {
+		t.Error("rendered text is not correct: ", res)
+	}
+
+	_, err = renderNotifyTemplate(`{{.Bogus}}`,
+		newNotifyTemplateData(&tank, data.Point{}, &rule, &parent))
+	if err == nil {
+		t.Error("expected error for invalid field")
+	}
+
+	res, err = renderNotifyTemplate(`{"node": {{json .Description}}, "value": {{.Point.Value}}}`,
+		newNotifyTemplateData(&data.Node{Points: data.Points{
+			{Type: data.PointTypeDescription, Text: `Tank "3"`}}},
+			data.Point{Value: 12}, nil, nil))
+
+	if err != nil {
+		t.Fatal("render failed: ", err)
+	}
+
+	exp = `{"node": "Tank \"3\"", "value": 12}`
+	if res != exp {
+		t.Errorf("expected %v, got %v", exp, res)
+	}
+}
+
+func TestNotifyHTMLTemplate(t *testing.T) {
+	tank := data.Node{
+		ID: "tank3",
+		Points: []data.Point{
+			{Type: data.PointTypeDescription, Text: "Tank <3>"},
+		},
+	}
+
+	res, err := renderNotifyHTMLTemplate(`<p>{{.Description}} level is <b>{{.Point.Value}}%</b></p>`,
+		newNotifyTemplateData(&tank, data.Point{Value: 12}, nil, nil))
+
+	if err != nil {
+		t.Fatal("render failed: ", err)
+	}
+
+	exp := "<p>Tank &lt;3&gt; level is <b>12%</b></p>"
+	if res != exp {
+		t.Errorf("expected %v, got %v", exp, res)
+	}
+}
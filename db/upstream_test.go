@@ -0,0 +1,173 @@
+package db
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nats-io/nats-server/v2/server"
+	natsgo "github.com/nats-io/nats.go"
+	"github.com/simpleiot/simpleiot/data"
+	"github.com/simpleiot/simpleiot/nats"
+	"github.com/simpleiot/simpleiot/node"
+)
+
+// testAuthToken is the NATS auth token of test instances
+const testAuthToken = "test token"
+
+// testInstance is a SIOT instance with its own NATS server and database
+type testInstance struct {
+	db   *Db
+	nc   *natsgo.Conn
+	url  string
+	root string
+}
+
+func newTestInstance(t *testing.T) testInstance {
+	ns, err := server.NewServer(&server.Options{Port: -1,
+		Authorization: testAuthToken})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go ns.Start()
+	if !ns.ReadyForConnections(5 * time.Second) {
+		t.Fatal("NATS server not ready")
+	}
+
+	db, err := NewDb(StoreTypeMemory, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nc, err := NewNatsHandler(db, testAuthToken, ns.ClientURL()).Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = node.NewManger(nc).Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root, err := nats.GetNode(nc, "root", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return testInstance{db, nc, ns.ClientURL(), root.ID}
+}
+
+// newNode creates a node of type typ under the root node of the instance
+func (ti testInstance) newNode(t *testing.T, typ string, points data.Points) string {
+	id := uuid.New().String()
+	points = append(points, data.Point{Type: data.PointTypeNodeType, Text: typ})
+
+	err := nats.SendNodePoints(ti.nc, id, points, true)
+	if err != nil {
+		t.Fatal("Error creating node: ", err)
+	}
+
+	err = nats.SendEdgePoint(ti.nc, id, ti.root,
+		data.Point{Type: data.PointTypeTombstone}, true)
+	if err != nil {
+		t.Fatal("Error creating edge: ", err)
+	}
+
+	return id
+}
+
+// upstream synchronizes the instance with the up instance
+func (ti testInstance) upstream(t *testing.T, up testInstance) {
+	_, err := node.NewUpstream(ti.nc, data.NodeEdge{
+		ID:   uuid.New().String(),
+		Type: data.NodeTypeUpstream,
+		Points: data.Points{
+			{Type: data.PointTypeURI, Text: up.url},
+			{Type: data.PointTypeAuthToken, Text: testAuthToken},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// eventually waits for nodes to be synced in the background
+func eventually(cond func() bool) bool {
+	for start := time.Now(); time.Since(start) < 10*time.Second; time.Sleep(50 * time.Millisecond) {
+		if cond() {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestUpstreamChain(t *testing.T) {
+	cloud := newTestInstance(t)
+	mid := newTestInstance(t)
+	edge := newTestInstance(t)
+
+	id := edge.newNode(t, data.NodeTypeDevice, data.Points{
+		{Type: data.PointTypeDescription, Text: "sensor"},
+	})
+
+	mid.upstream(t, cloud)
+	edge.upstream(t, mid)
+
+	value := func(ti testInstance, v float64) func() bool {
+		return func() bool {
+			n, err := ti.db.node(id)
+			if err != nil {
+				return false
+			}
+
+			got, ok := n.Points.Value("", data.PointTypeValue, 0)
+			return ok && got == v
+		}
+	}
+
+	err := nats.SendNodePoint(edge.nc, id, data.Point{Type: data.PointTypeValue,
+		Value: 1}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !eventually(value(cloud, 1)) {
+		t.Fatal("node not synced through the chain")
+	}
+
+	// count how often each instance stores points for the node
+	var counts [3]int32
+	for i, ti := range []testInstance{cloud, mid, edge} {
+		i := i
+		_, err := ti.nc.Subscribe(nats.SubjectNodeStored(id), func(msg *natsgo.Msg) {
+			atomic.AddInt32(&counts[i], 1)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = nats.SendNodePoint(edge.nc, id, data.Point{Type: data.PointTypeValue,
+		Value: 2}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ti := range []testInstance{cloud, mid} {
+		if !eventually(value(ti, 2)) {
+			t.Fatal("point not synced through the chain")
+		}
+	}
+
+	// points that were already applied must not be forwarded again
+	time.Sleep(time.Second)
+
+	for i, name := range []string{"cloud", "mid", "edge"} {
+		if c := atomic.LoadInt32(&counts[i]); c != 1 {
+			t.Errorf("%v stored the point %v times", name, c)
+		}
+	}
+}
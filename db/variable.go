@@ -0,0 +1,248 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/genjidb/genji"
+	"github.com/genjidb/genji/document"
+	"github.com/genjidb/genji/types"
+	"github.com/simpleiot/simpleiot/data"
+	"github.com/simpleiot/simpleiot/nats"
+)
+
+// variables returns all variable nodes that have not been deleted
+func (gen *Db) variables() ([]*data.Variable, error) {
+	var ret []*data.Variable
+
+	err := gen.store.View(func(tx *genji.Tx) error {
+		res, err := tx.Query(`select * from nodes where type = ?`, data.NodeTypeVariable)
+		if err != nil {
+			return err
+		}
+
+		defer res.Close()
+
+		var nodes []data.Node
+
+		err = res.Iterate(func(d types.Document) error {
+			var node data.Node
+			err := document.StructScan(d, &node)
+			if err != nil {
+				return err
+			}
+
+			nodes = append(nodes, node)
+			return nil
+		})
+
+		if err != nil {
+			return err
+		}
+
+		for _, node := range nodes {
+			edges, err := txEdgeUp(tx, node.ID, false)
+			if err != nil {
+				return err
+			}
+
+			if len(edges) <= 0 {
+				// variable has been deleted
+				continue
+			}
+
+			v, err := data.NodeToVariable(node.ToNodeEdge(*edges[0]))
+			if err != nil {
+				return err
+			}
+
+			ret = append(ret, v)
+		}
+
+		return nil
+	})
+
+	return ret, err
+}
+
+// variable returns a variable node
+func (gen *Db) variable(id string) (*data.Variable, error) {
+	node, err := gen.node(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return data.NodeToVariable(data.NodeEdge{
+		ID:     node.ID,
+		Type:   node.Type,
+		Points: node.Points,
+	})
+}
+
+// processVariables recomputes variables that use any of the points written
+// to a node as an input, or whose configuration was changed by the points.
+// New values are published as value points on the variable nodes, so
+// variables that use other variables as inputs are updated in turn.
+func (nh *NatsHandler) processVariables(nodeID string, points data.Points) error {
+	err := nh.ruleLoad()
+	if err != nil {
+		return err
+	}
+
+	changed := false
+	for _, p := range points {
+		// value and error points are written by updateVariable
+		if p.Type != data.PointTypeValue && p.Type != data.PointTypeError {
+			changed = true
+			break
+		}
+	}
+
+	if _, ok := nh.rules.variable(nodeID); ok && changed {
+		v, err := nh.db.variable(nodeID)
+		if err != nil {
+			return err
+		}
+
+		nh.rules.putVariable(v)
+
+		err = nh.updateVariable(v)
+		if err != nil {
+			log.Printf("Error updating variable %v: %v", v.ID, err)
+		}
+	}
+
+	for _, v := range nh.rules.inputVariables(nodeID) {
+		for _, p := range points {
+			if v.IsInput(nodeID, p) {
+				err := nh.updateVariable(v)
+				if err != nil {
+					log.Printf("Error updating variable %v: %v", v.ID, err)
+				}
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateVariable checks that the inputs of a variable node do not
+// include the variable itself, or a variable that depends on it
+func (nh *NatsHandler) validateVariable(nodeID string, points data.Points) error {
+	v, err := data.NodeToVariable(data.NodeEdge{
+		ID:     nodeID,
+		Points: nh.pointsAfter(nodeID, points),
+	})
+	if err != nil {
+		return err
+	}
+
+	err = nh.ruleLoad()
+	if err != nil {
+		return err
+	}
+
+	visited := make(map[string]bool)
+	var inputs []string
+
+	for _, in := range v.Inputs {
+		if in.NodeID == nodeID {
+			return fmt.Errorf("variable input %v can't be the variable itself", in.Name)
+		}
+		inputs = append(inputs, in.NodeID)
+	}
+
+	for len(inputs) > 0 {
+		id := inputs[len(inputs)-1]
+		inputs = inputs[:len(inputs)-1]
+
+		if id == nodeID {
+			return errors.New("variable inputs can't depend on the variable")
+		}
+
+		if visited[id] {
+			continue
+		}
+		visited[id] = true
+
+		if in, ok := nh.rules.variable(id); ok {
+			for _, i := range in.Inputs {
+				inputs = append(inputs, i.NodeID)
+			}
+		}
+	}
+
+	return nil
+}
+
+// updateVariable computes a variable from the current input values and
+// publishes the result if it changed. Variables that can't be computed, such
+// as when an input does not exist yet, keep their value and record the
+// reason in the error point of the variable node, which is cleared once the
+// variable is computed again.
+func (nh *NatsHandler) updateVariable(v *data.Variable) error {
+	node, err := nh.db.node(v.ID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var points data.Points
+
+	value, evalErr := nh.evalVariable(v)
+
+	errText := ""
+	if evalErr != nil {
+		errText = evalErr.Error()
+	}
+
+	if current, _ := node.Points.Text("", data.PointTypeError, 0); current != errText {
+		points = append(points, data.Point{
+			Time: now,
+			Type: data.PointTypeError,
+			Text: errText,
+		})
+	}
+
+	if evalErr == nil {
+		current, ok := node.Points.Value("", data.PointTypeValue, 0)
+		if !ok || value != current {
+			points = append(points, data.Point{
+				Time:  now,
+				Type:  data.PointTypeValue,
+				Value: value,
+			})
+		}
+	}
+
+	if len(points) <= 0 {
+		return nil
+	}
+
+	return nats.SendNodePoints(nh.Nc, v.ID, points, false)
+}
+
+// evalVariable computes a variable from the current input values
+func (nh *NatsHandler) evalVariable(v *data.Variable) (float64, error) {
+	values := make(map[string]float64)
+
+	for _, in := range v.Inputs {
+		node, err := nh.db.node(in.NodeID)
+		if err != nil {
+			return 0, fmt.Errorf("input %v: node %v not found", in.Name, in.NodeID)
+		}
+
+		value, ok := node.Points.Value("", in.PointType, in.PointIndex)
+		if !ok {
+			return 0, fmt.Errorf("input %v: node %v does not have a %v point with index %v",
+				in.Name, in.NodeID, in.PointType, in.PointIndex)
+		}
+
+		values[in.Name] = value
+	}
+
+	return v.Eval(values)
+}
@@ -0,0 +1,114 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/simpleiot/simpleiot/data"
+	"github.com/simpleiot/simpleiot/nats"
+)
+
+func TestValidateVariable(t *testing.T) {
+	db, err := NewDb(StoreTypeMemory, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nh := NewNatsHandler(db, "", "")
+
+	input := func(index int, id string) data.Points {
+		return data.Points{
+			{Type: data.PointTypeInputName, Index: index, Text: "in"},
+			{Type: data.PointTypeID, Index: index, Text: id},
+			{Type: data.PointTypePointType, Index: index, Text: data.PointTypeValue},
+		}
+	}
+
+	newNode := func(id, parent, typ string, points data.Points) {
+		points = append(points, data.Point{Type: data.PointTypeNodeType, Text: typ})
+		_, err := db.nodePoints(id, points)
+		if err != nil {
+			t.Fatal("Error creating node: ", err)
+		}
+
+		_, err = db.edgePoints(id, parent,
+			data.Points{{Type: data.PointTypeTombstone, Value: 0}})
+		if err != nil {
+			t.Fatal("Error creating edge: ", err)
+		}
+	}
+
+	newNode("root", "", data.NodeTypeDevice, nil)
+	newNode("io", "root", data.NodeTypeDevice, nil)
+	newNode("a", "root", data.NodeTypeVariable, input(0, "io"))
+	newNode("b", "root", data.NodeTypeVariable, input(0, "a"))
+	newNode("c", "root", data.NodeTypeVariable, input(0, "b"))
+
+	tests := []struct {
+		name   string
+		id     string
+		points data.Points
+		valid  bool
+	}{
+		{"other input", "a", input(1, "io"), true},
+		{"new variable", "d", input(0, "c"), true},
+		{"self", "a", input(0, "a"), false},
+		{"new self", "d", input(0, "d"), false},
+		{"cycle", "a", input(0, "c"), false},
+		{"added input cycle", "a", input(1, "b"), false},
+		{"description", "a", data.Points{{Type: data.PointTypeDescription, Text: "x"}}, true},
+	}
+
+	for _, test := range tests {
+		err := nh.validateVariable(test.id, test.points)
+		if test.valid && err != nil {
+			t.Errorf("%v: unexpected error: %v", test.name, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("%v: expected error", test.name)
+		}
+	}
+}
+
+func TestUpdateVariable(t *testing.T) {
+	ti := newTestInstance(t)
+
+	io := ti.newNode(t, data.NodeTypeDevice, data.Points{
+		{Type: data.PointTypeDescription, Text: "tank"},
+	})
+
+	v := ti.newNode(t, data.NodeTypeVariable, data.Points{
+		{Type: data.PointTypeExpression, Text: "level * 2"},
+		{Type: data.PointTypeInputName, Text: "level"},
+		{Type: data.PointTypeID, Text: io},
+		{Type: data.PointTypePointType, Text: data.PointTypeValue},
+	})
+
+	state := func(value float64, errText string) func() bool {
+		return func() bool {
+			n, err := ti.db.node(v)
+			if err != nil {
+				return false
+			}
+
+			got, _ := n.Points.Value("", data.PointTypeValue, 0)
+			gotErr, _ := n.Points.Text("", data.PointTypeError, 0)
+			return got == value && gotErr == errText
+		}
+	}
+
+	// the input does not have a level yet
+	if !eventually(state(0, "input level: node "+io+
+		" does not have a value point with index 0")) {
+		t.Fatal("missing input not recorded")
+	}
+
+	err := nats.SendNodePoint(ti.nc, io, data.Point{Type: data.PointTypeValue,
+		Value: 3}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !eventually(state(6, "")) {
+		t.Fatal("variable not computed")
+	}
+}
@@ -0,0 +1,27 @@
+/*
+Package expr implements a small expression language that is used to compute
+values from node points, such as the volume of a tank from its level, or the
+total power from three phase currents.
+
+Expressions operate on float64 values. Boolean operators treat any non-zero
+value as true and return 1 for true and 0 for false. The following are
+supported, from lowest to highest precedence:
+
+	c ? a : b          conditional
+	||                 logical or
+	&&                 logical and
+	== != < <= > >=    comparison
+	+ -                addition, subtraction
+	* / %              multiplication, division, remainder
+	- !                negation, logical not
+
+Parentheses may be used for grouping, and true/false are the constants 1
+and 0. The following functions are available:
+
+	min(a, b, ...)  max(a, b, ...)  abs(x)  sqrt(x)  pow(x, y)
+	round(x)  floor(x)  ceil(x)  if(c, a, b)
+
+Any other identifier is a variable whose value is supplied when the
+expression is evaluated.
+*/
+package expr
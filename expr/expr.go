@@ -0,0 +1,611 @@
+package expr
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Expr is a parsed expression that can be evaluated many times
+type Expr struct {
+	src  string
+	root node
+}
+
+// Parse parses an expression
+func Parse(src string) (*Expr, error) {
+	p := &parser{src: src}
+
+	err := p.next()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.tok.kind == tokEOF {
+		return nil, errors.New("empty expression")
+	}
+
+	root, err := p.parseConditional()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.tok.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q at position %v", p.tok.text, p.tok.pos)
+	}
+
+	return &Expr{src: src, root: root}, nil
+}
+
+// Eval parses and evaluates an expression
+func Eval(src string, vars map[string]float64) (float64, error) {
+	e, err := Parse(src)
+	if err != nil {
+		return 0, err
+	}
+
+	return e.Eval(vars)
+}
+
+// Eval evaluates the expression with the given variable values
+func (e *Expr) Eval(vars map[string]float64) (float64, error) {
+	return e.root.eval(vars)
+}
+
+// Vars returns the sorted names of the variables used in the expression
+func (e *Expr) Vars() []string {
+	names := make(map[string]bool)
+	e.root.vars(names)
+
+	ret := make([]string, 0, len(names))
+	for n := range names {
+		ret = append(ret, n)
+	}
+
+	sort.Strings(ret)
+
+	return ret
+}
+
+func (e *Expr) String() string {
+	return e.src
+}
+
+// node is an element in the parsed expression tree
+type node interface {
+	eval(vars map[string]float64) (float64, error)
+	vars(names map[string]bool)
+}
+
+type numNode float64
+
+func (n numNode) eval(vars map[string]float64) (float64, error) {
+	return float64(n), nil
+}
+
+func (n numNode) vars(names map[string]bool) {}
+
+type varNode string
+
+func (n varNode) eval(vars map[string]float64) (float64, error) {
+	v, ok := vars[string(n)]
+	if !ok {
+		return 0, fmt.Errorf("unknown variable: %v", string(n))
+	}
+	return v, nil
+}
+
+func (n varNode) vars(names map[string]bool) {
+	names[string(n)] = true
+}
+
+type unaryNode struct {
+	op string
+	x  node
+}
+
+func (n *unaryNode) eval(vars map[string]float64) (float64, error) {
+	x, err := n.x.eval(vars)
+	if err != nil {
+		return 0, err
+	}
+
+	if n.op == "!" {
+		return boolToFloat(x == 0), nil
+	}
+
+	return -x, nil
+}
+
+func (n *unaryNode) vars(names map[string]bool) {
+	n.x.vars(names)
+}
+
+type binaryNode struct {
+	op   string
+	x, y node
+}
+
+func (n *binaryNode) eval(vars map[string]float64) (float64, error) {
+	x, err := n.x.eval(vars)
+	if err != nil {
+		return 0, err
+	}
+
+	// logical operators short circuit
+	switch n.op {
+	case "&&":
+		if x == 0 {
+			return 0, nil
+		}
+	case "||":
+		if x != 0 {
+			return 1, nil
+		}
+	}
+
+	y, err := n.y.eval(vars)
+	if err != nil {
+		return 0, err
+	}
+
+	switch n.op {
+	case "+":
+		return x + y, nil
+	case "-":
+		return x - y, nil
+	case "*":
+		return x * y, nil
+	case "/":
+		if y == 0 {
+			return 0, errors.New("division by zero")
+		}
+		return x / y, nil
+	case "%":
+		if y == 0 {
+			return 0, errors.New("division by zero")
+		}
+		return math.Mod(x, y), nil
+	case "==":
+		return boolToFloat(x == y), nil
+	case "!=":
+		return boolToFloat(x != y), nil
+	case "<":
+		return boolToFloat(x < y), nil
+	case "<=":
+		return boolToFloat(x <= y), nil
+	case ">":
+		return boolToFloat(x > y), nil
+	case ">=":
+		return boolToFloat(x >= y), nil
+	case "&&", "||":
+		return boolToFloat(y != 0), nil
+	}
+
+	return 0, fmt.Errorf("unknown operator: %v", n.op)
+}
+
+func (n *binaryNode) vars(names map[string]bool) {
+	n.x.vars(names)
+	n.y.vars(names)
+}
+
+type condNode struct {
+	c, a, b node
+}
+
+func (n *condNode) eval(vars map[string]float64) (float64, error) {
+	c, err := n.c.eval(vars)
+	if err != nil {
+		return 0, err
+	}
+
+	if c != 0 {
+		return n.a.eval(vars)
+	}
+
+	return n.b.eval(vars)
+}
+
+func (n *condNode) vars(names map[string]bool) {
+	n.c.vars(names)
+	n.a.vars(names)
+	n.b.vars(names)
+}
+
+type callNode struct {
+	fn   string
+	args []node
+}
+
+func (n *callNode) eval(vars map[string]float64) (float64, error) {
+	if n.fn == "if" {
+		return (&condNode{n.args[0], n.args[1], n.args[2]}).eval(vars)
+	}
+
+	args := make([]float64, len(n.args))
+	for i, a := range n.args {
+		var err error
+		args[i], err = a.eval(vars)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	switch n.fn {
+	case "min":
+		ret := args[0]
+		for _, a := range args[1:] {
+			ret = math.Min(ret, a)
+		}
+		return ret, nil
+	case "max":
+		ret := args[0]
+		for _, a := range args[1:] {
+			ret = math.Max(ret, a)
+		}
+		return ret, nil
+	case "abs":
+		return math.Abs(args[0]), nil
+	case "sqrt":
+		return math.Sqrt(args[0]), nil
+	case "pow":
+		return math.Pow(args[0], args[1]), nil
+	case "round":
+		return math.Round(args[0]), nil
+	case "floor":
+		return math.Floor(args[0]), nil
+	case "ceil":
+		return math.Ceil(args[0]), nil
+	}
+
+	return 0, fmt.Errorf("unknown function: %v", n.fn)
+}
+
+func (n *callNode) vars(names map[string]bool) {
+	for _, a := range n.args {
+		a.vars(names)
+	}
+}
+
+// functions lists the number of arguments for each function. -1 means one
+// or more arguments.
+var functions = map[string]int{
+	"min":   -1,
+	"max":   -1,
+	"abs":   1,
+	"sqrt":  1,
+	"pow":   2,
+	"round": 1,
+	"floor": 1,
+	"ceil":  1,
+	"if":    3,
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokNum
+	tokIdent
+	tokOp
+)
+
+type token struct {
+	kind tokKind
+	text string
+	num  float64
+	pos  int
+}
+
+// parser is a recursive descent parser with one token of lookahead
+type parser struct {
+	src string
+	pos int
+	tok token
+}
+
+// operators lists two character operators before one character operators
+// so that the longest match wins
+var operators = []string{"==", "!=", "<=", ">=", "&&", "||",
+	"+", "-", "*", "/", "%", "<", ">", "!", "(", ")", ",", "?", ":"}
+
+func (p *parser) next() error {
+	for p.pos < len(p.src) && unicode.IsSpace(rune(p.src[p.pos])) {
+		p.pos++
+	}
+
+	start := p.pos
+
+	if p.pos >= len(p.src) {
+		p.tok = token{kind: tokEOF, pos: start}
+		return nil
+	}
+
+	c := p.src[p.pos]
+
+	switch {
+	case c >= '0' && c <= '9' || c == '.':
+		for p.pos < len(p.src) && (isDigit(p.src[p.pos]) || p.src[p.pos] == '.') {
+			p.pos++
+		}
+
+		// exponent
+		if p.pos < len(p.src) && (p.src[p.pos] == 'e' || p.src[p.pos] == 'E') {
+			p.pos++
+			if p.pos < len(p.src) && (p.src[p.pos] == '+' || p.src[p.pos] == '-') {
+				p.pos++
+			}
+			for p.pos < len(p.src) && isDigit(p.src[p.pos]) {
+				p.pos++
+			}
+		}
+
+		text := p.src[start:p.pos]
+		v, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q at position %v", text, start)
+		}
+
+		p.tok = token{kind: tokNum, text: text, num: v, pos: start}
+		return nil
+
+	case isIdentStart(c):
+		for p.pos < len(p.src) && (isIdentStart(p.src[p.pos]) || isDigit(p.src[p.pos])) {
+			p.pos++
+		}
+
+		p.tok = token{kind: tokIdent, text: p.src[start:p.pos], pos: start}
+		return nil
+	}
+
+	for _, op := range operators {
+		if strings.HasPrefix(p.src[p.pos:], op) {
+			p.pos += len(op)
+			p.tok = token{kind: tokOp, text: op, pos: start}
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unexpected character %q at position %v", c, start)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// accept consumes the current token if it is the given operator
+func (p *parser) accept(op string) (bool, error) {
+	if p.tok.kind != tokOp || p.tok.text != op {
+		return false, nil
+	}
+
+	return true, p.next()
+}
+
+func (p *parser) expect(op string) error {
+	ok, err := p.accept(op)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		if p.tok.kind == tokEOF {
+			return fmt.Errorf("expected %q at end of expression", op)
+		}
+		return fmt.Errorf("expected %q at position %v, got %q", op, p.tok.pos, p.tok.text)
+	}
+
+	return nil
+}
+
+func (p *parser) parseConditional() (node, error) {
+	c, err := p.parseBinary(0)
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := p.accept("?")
+	if err != nil || !ok {
+		return c, err
+	}
+
+	a, err := p.parseConditional()
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.expect(":")
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := p.parseConditional()
+	if err != nil {
+		return nil, err
+	}
+
+	return &condNode{c, a, b}, nil
+}
+
+// precedence lists binary operators from lowest to highest precedence
+var precedence = [][]string{
+	{"||"},
+	{"&&"},
+	{"==", "!=", "<", "<=", ">", ">="},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (p *parser) parseBinary(level int) (node, error) {
+	if level >= len(precedence) {
+		return p.parseUnary()
+	}
+
+	x, err := p.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		op := ""
+		if p.tok.kind == tokOp {
+			for _, o := range precedence[level] {
+				if p.tok.text == o {
+					op = o
+				}
+			}
+		}
+
+		if op == "" {
+			return x, nil
+		}
+
+		err := p.next()
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := p.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+
+		x = &binaryNode{op, x, y}
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	for _, op := range []string{"-", "!", "+"} {
+		ok, err := p.accept(op)
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			x, err := p.parseUnary()
+			if err != nil {
+				return nil, err
+			}
+
+			if op == "+" {
+				return x, nil
+			}
+
+			return &unaryNode{op, x}, nil
+		}
+	}
+
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	tok := p.tok
+
+	switch tok.kind {
+	case tokNum:
+		return numNode(tok.num), p.next()
+
+	case tokIdent:
+		err := p.next()
+		if err != nil {
+			return nil, err
+		}
+
+		switch tok.text {
+		case "true":
+			return numNode(1), nil
+		case "false":
+			return numNode(0), nil
+		}
+
+		ok, err := p.accept("(")
+		if err != nil {
+			return nil, err
+		}
+
+		if !ok {
+			return varNode(tok.text), nil
+		}
+
+		return p.parseCall(tok)
+
+	case tokOp:
+		if tok.text == "(" {
+			err := p.next()
+			if err != nil {
+				return nil, err
+			}
+
+			x, err := p.parseConditional()
+			if err != nil {
+				return nil, err
+			}
+
+			return x, p.expect(")")
+		}
+
+	case tokEOF:
+		return nil, errors.New("unexpected end of expression")
+	}
+
+	return nil, fmt.Errorf("unexpected %q at position %v", tok.text, tok.pos)
+}
+
+// parseCall parses function arguments after the opening parenthesis
+func (p *parser) parseCall(fn token) (node, error) {
+	argCount, ok := functions[fn.text]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %q at position %v", fn.text, fn.pos)
+	}
+
+	var args []node
+
+	closed, err := p.accept(")")
+	if err != nil {
+		return nil, err
+	}
+
+	for !closed {
+		arg, err := p.parseConditional()
+		if err != nil {
+			return nil, err
+		}
+
+		args = append(args, arg)
+
+		closed, err = p.accept(")")
+		if err != nil {
+			return nil, err
+		}
+
+		if !closed {
+			err := p.expect(",")
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if argCount < 0 && len(args) < 1 ||
+		argCount >= 0 && len(args) != argCount {
+		return nil, fmt.Errorf("wrong number of arguments to %v: %v", fn.text, len(args))
+	}
+
+	return &callNode{fn.text, args}, nil
+}
@@ -0,0 +1,112 @@
+package expr
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEval(t *testing.T) {
+	vars := map[string]float64{
+		"level": 2,
+		"ia":    10,
+		"ib":    12,
+		"ic":    11,
+		"on":    1,
+	}
+
+	tests := []struct {
+		expr string
+		exp  float64
+	}{
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"10 - 4 - 3", 3},
+		{"12 / 4 / 3", 1},
+		{"7 % 4", 3},
+		{"-level + 5", 3},
+		{"--level", 2},
+		{"1.5e2", 150},
+		{".5 * 4", 2},
+		{"level * 3.14 * 0.5 * 0.5", 1.57},
+		{"(ia + ib + ic) * 120", 3960},
+		{"min(ia, ib, ic)", 10},
+		{"max(ia, ib, ic)", 12},
+		{"abs(-3)", 3},
+		{"sqrt(16)", 4},
+		{"pow(2, 10)", 1024},
+		{"round(2.5)", 3},
+		{"floor(2.7)", 2},
+		{"ceil(2.1)", 3},
+		{"level > 1", 1},
+		{"level >= 3", 0},
+		{"level == 2 && on", 1},
+		{"level != 2 || !on", 0},
+		{"!0", 1},
+		{"true && false", 0},
+		{"level > 1 ? 100 : 200", 100},
+		{"level > 5 ? 100 : level > 1 ? 50 : 0", 50},
+		{"if(on, ia, ib)", 10},
+		{"1 < 2 == 1", 1},
+	}
+
+	for _, test := range tests {
+		v, err := Eval(test.expr, vars)
+		if err != nil {
+			t.Errorf("%v: error: %v", test.expr, err)
+			continue
+		}
+
+		if v != test.exp {
+			t.Errorf("%v: expected %v, got %v", test.expr, test.exp, v)
+		}
+	}
+}
+
+func TestEvalShortCircuit(t *testing.T) {
+	// the unknown variable is not evaluated
+	tests := []string{"0 && x", "1 || x", "1 ? 1 : x", "if(0, x, 1)"}
+
+	for _, test := range tests {
+		_, err := Eval(test, nil)
+		if err != nil {
+			t.Errorf("%v: error: %v", test, err)
+		}
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"1 +",
+		"(1 + 2",
+		"1 2",
+		"1 ? 2",
+		"foo(1)",
+		"min()",
+		"pow(1)",
+		"1 $ 2",
+		"x + 1",
+		"1 / 0",
+		"1 % 0",
+	}
+
+	for _, test := range tests {
+		_, err := Eval(test, nil)
+		if err == nil {
+			t.Errorf("%v: expected error", test)
+		}
+	}
+}
+
+func TestVars(t *testing.T) {
+	e, err := Parse("max(ib, ia) * level > 3 ? ia : c")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []string{"c", "ia", "ib", "level"}
+
+	if !reflect.DeepEqual(e.Vars(), exp) {
+		t.Errorf("expected %v, got %v", exp, e.Vars())
+	}
+}
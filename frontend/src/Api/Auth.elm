@@ -8,7 +8,7 @@ module Api.Auth exposing
 import Api.Data exposing (Data)
 import Http
 import Json.Decode as Decode
-import Json.Decode.Pipeline exposing (required)
+import Json.Decode.Pipeline exposing (optional, required)
 import Url.Builder
 
 
@@ -21,12 +21,13 @@ type alias Cred =
 type alias Auth =
     { token : String
     , email : String
+    , passChange : Bool
     }
 
 
 empty : Auth
 empty =
-    Auth "" ""
+    Auth "" "" False
 
 
 decodeResponse : Decode.Decoder Auth
@@ -34,6 +35,7 @@ decodeResponse =
     Decode.succeed Auth
         |> required "token" Decode.string
         |> required "email" Decode.string
+        |> optional "passChange" Decode.bool False
 
 
 login :
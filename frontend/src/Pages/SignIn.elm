@@ -102,8 +102,12 @@ update msg model =
             let
                 error =
                     case auth of
-                        Api.Data.Success _ ->
-                            Nothing
+                        Api.Data.Success a ->
+                            if a.passChange then
+                                Just "Please change your password: expand your user and set Pass"
+
+                            else
+                                Nothing
 
                         Api.Data.Failure _ ->
                             Just "Login Failure"
@@ -127,7 +131,7 @@ save model shared =
         | auth =
             case Api.Data.toMaybe model.auth of
                 Just auth ->
-                    Just { email = model.email, token = auth.token }
+                    Just { email = model.email, token = auth.token, passChange = auth.passChange }
 
                 Nothing ->
                     shared.auth
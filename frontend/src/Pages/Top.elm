@@ -137,7 +137,7 @@ defaultModel key =
         Time.utc
         (Time.millisToPosix 0)
         []
-        { email = "", token = "" }
+        { email = "", token = "", passChange = False }
         Nothing
         OpNone
         CopyMoveNone
@@ -0,0 +1,168 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.26.0
+// 	protoc        v3.15.7
+// source: alarm.proto
+
+package pb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type AlarmAck struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	UserId      string                 `protobuf:"bytes,1,opt,name=userId,proto3" json:"userId,omitempty"`
+	Action      string                 `protobuf:"bytes,2,opt,name=action,proto3" json:"action,omitempty"`
+	ShelveUntil *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=shelveUntil,proto3" json:"shelveUntil,omitempty"`
+}
+
+func (x *AlarmAck) Reset() {
+	*x = AlarmAck{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_alarm_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AlarmAck) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AlarmAck) ProtoMessage() {}
+
+func (x *AlarmAck) ProtoReflect() protoreflect.Message {
+	mi := &file_alarm_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AlarmAck.ProtoReflect.Descriptor instead.
+func (*AlarmAck) Descriptor() ([]byte, []int) {
+	return file_alarm_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *AlarmAck) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *AlarmAck) GetAction() string {
+	if x != nil {
+		return x.Action
+	}
+	return ""
+}
+
+func (x *AlarmAck) GetShelveUntil() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ShelveUntil
+	}
+	return nil
+}
+
+var File_alarm_proto protoreflect.FileDescriptor
+
+var file_alarm_proto_rawDesc = []byte{
+	0x0a, 0x0b, 0x61, 0x6c, 0x61, 0x72, 0x6d, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x02, 0x70,
+	0x62, 0x1a, 0x1f, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62,
+	0x75, 0x66, 0x2f, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x2e, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x22, 0x78, 0x0a, 0x08, 0x41, 0x6c, 0x61, 0x72, 0x6d, 0x41, 0x63, 0x6b, 0x12, 0x16,
+	0x0a, 0x06, 0x75, 0x73, 0x65, 0x72, 0x49, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06,
+	0x75, 0x73, 0x65, 0x72, 0x49, 0x64, 0x12, 0x16, 0x0a, 0x06, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x3c,
+	0x0a, 0x0b, 0x73, 0x68, 0x65, 0x6c, 0x76, 0x65, 0x55, 0x6e, 0x74, 0x69, 0x6c, 0x18, 0x03, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52,
+	0x0b, 0x73, 0x68, 0x65, 0x6c, 0x76, 0x65, 0x55, 0x6e, 0x74, 0x69, 0x6c, 0x42, 0x0d, 0x5a, 0x0b,
+	0x69, 0x6e, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x2f, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x33,
+}
+
+var (
+	file_alarm_proto_rawDescOnce sync.Once
+	file_alarm_proto_rawDescData = file_alarm_proto_rawDesc
+)
+
+func file_alarm_proto_rawDescGZIP() []byte {
+	file_alarm_proto_rawDescOnce.Do(func() {
+		file_alarm_proto_rawDescData = protoimpl.X.CompressGZIP(file_alarm_proto_rawDescData)
+	})
+	return file_alarm_proto_rawDescData
+}
+
+var file_alarm_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
+var file_alarm_proto_goTypes = []interface{}{
+	(*AlarmAck)(nil),              // 0: pb.AlarmAck
+	(*timestamppb.Timestamp)(nil), // 1: google.protobuf.Timestamp
+}
+var file_alarm_proto_depIdxs = []int32{
+	1, // 0: pb.AlarmAck.shelveUntil:type_name -> google.protobuf.Timestamp
+	1, // [1:1] is the sub-list for method output_type
+	1, // [1:1] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	1, // [1:1] is the sub-list for extension extendee
+	0, // [0:1] is the sub-list for field type_name
+}
+
+func init() { file_alarm_proto_init() }
+func file_alarm_proto_init() {
+	if File_alarm_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_alarm_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AlarmAck); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_alarm_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   1,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_alarm_proto_goTypes,
+		DependencyIndexes: file_alarm_proto_depIdxs,
+		MessageInfos:      file_alarm_proto_msgTypes,
+	}.Build()
+	File_alarm_proto = out.File
+	file_alarm_proto_rawDesc = nil
+	file_alarm_proto_goTypes = nil
+	file_alarm_proto_depIdxs = nil
+}
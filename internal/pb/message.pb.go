@@ -25,14 +25,17 @@ type Message struct {
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Id             string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	UserId         string `protobuf:"bytes,2,opt,name=userId,proto3" json:"userId,omitempty"`
-	NotificationId string `protobuf:"bytes,3,opt,name=notificationId,proto3" json:"notificationId,omitempty"`
-	Email          string `protobuf:"bytes,4,opt,name=email,proto3" json:"email,omitempty"`
-	Phone          string `protobuf:"bytes,5,opt,name=phone,proto3" json:"phone,omitempty"`
-	Subject        string `protobuf:"bytes,6,opt,name=subject,proto3" json:"subject,omitempty"`
-	Message        string `protobuf:"bytes,7,opt,name=message,proto3" json:"message,omitempty"`
-	ParentId       string `protobuf:"bytes,8,opt,name=parentId,proto3" json:"parentId,omitempty"`
+	Id             string   `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	UserId         string   `protobuf:"bytes,2,opt,name=userId,proto3" json:"userId,omitempty"`
+	NotificationId string   `protobuf:"bytes,3,opt,name=notificationId,proto3" json:"notificationId,omitempty"`
+	Email          string   `protobuf:"bytes,4,opt,name=email,proto3" json:"email,omitempty"`
+	Phone          string   `protobuf:"bytes,5,opt,name=phone,proto3" json:"phone,omitempty"`
+	Subject        string   `protobuf:"bytes,6,opt,name=subject,proto3" json:"subject,omitempty"`
+	Message        string   `protobuf:"bytes,7,opt,name=message,proto3" json:"message,omitempty"`
+	ParentId       string   `protobuf:"bytes,8,opt,name=parentId,proto3" json:"parentId,omitempty"`
+	Html           string   `protobuf:"bytes,9,opt,name=html,proto3" json:"html,omitempty"`
+	Channels       []string `protobuf:"bytes,10,rep,name=channels,proto3" json:"channels,omitempty"`
+	RuleId         string   `protobuf:"bytes,11,opt,name=ruleId,proto3" json:"ruleId,omitempty"`
 }
 
 func (x *Message) Reset() {
@@ -123,11 +126,32 @@ func (x *Message) GetParentId() string {
 	return ""
 }
 
+func (x *Message) GetHtml() string {
+	if x != nil {
+		return x.Html
+	}
+	return ""
+}
+
+func (x *Message) GetChannels() []string {
+	if x != nil {
+		return x.Channels
+	}
+	return nil
+}
+
+func (x *Message) GetRuleId() string {
+	if x != nil {
+		return x.RuleId
+	}
+	return ""
+}
+
 var File_message_proto protoreflect.FileDescriptor
 
 var file_message_proto_rawDesc = []byte{
 	0x0a, 0x0d, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12,
-	0x02, 0x70, 0x62, 0x22, 0xd5, 0x01, 0x0a, 0x07, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12,
+	0x02, 0x70, 0x62, 0x22, 0x9d, 0x02, 0x0a, 0x07, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12,
 	0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12,
 	0x16, 0x0a, 0x06, 0x75, 0x73, 0x65, 0x72, 0x49, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52,
 	0x06, 0x75, 0x73, 0x65, 0x72, 0x49, 0x64, 0x12, 0x26, 0x0a, 0x0e, 0x6e, 0x6f, 0x74, 0x69, 0x66,
@@ -140,9 +164,13 @@ var file_message_proto_rawDesc = []byte{
 	0x62, 0x6a, 0x65, 0x63, 0x74, 0x12, 0x18, 0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65,
 	0x18, 0x07, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12,
 	0x1a, 0x0a, 0x08, 0x70, 0x61, 0x72, 0x65, 0x6e, 0x74, 0x49, 0x64, 0x18, 0x08, 0x20, 0x01, 0x28,
-	0x09, 0x52, 0x08, 0x70, 0x61, 0x72, 0x65, 0x6e, 0x74, 0x49, 0x64, 0x42, 0x0d, 0x5a, 0x0b, 0x69,
-	0x6e, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x2f, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74,
-	0x6f, 0x33,
+	0x09, 0x52, 0x08, 0x70, 0x61, 0x72, 0x65, 0x6e, 0x74, 0x49, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x68,
+	0x74, 0x6d, 0x6c, 0x18, 0x09, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x68, 0x74, 0x6d, 0x6c, 0x12,
+	0x1a, 0x0a, 0x08, 0x63, 0x68, 0x61, 0x6e, 0x6e, 0x65, 0x6c, 0x73, 0x18, 0x0a, 0x20, 0x03, 0x28,
+	0x09, 0x52, 0x08, 0x63, 0x68, 0x61, 0x6e, 0x6e, 0x65, 0x6c, 0x73, 0x12, 0x16, 0x0a, 0x06, 0x72,
+	0x75, 0x6c, 0x65, 0x49, 0x64, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x72, 0x75, 0x6c,
+	0x65, 0x49, 0x64, 0x42, 0x0d, 0x5a, 0x0b, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x2f,
+	0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -0,0 +1,72 @@
+package msg
+
+import (
+	"github.com/simpleiot/simpleiot/data"
+	"github.com/simpleiot/simpleiot/network"
+)
+
+// DefaultModemPort is the AT command port of BG96 modems, which is used if a
+// modem message service does not have a port
+const DefaultModemPort = "/dev/ttyUSB2"
+
+// Modem sends and receives SMS through the AT command port of a cellular
+// modem. The port is shared with the network manager, so it is only opened
+// while sending or receiving.
+type Modem struct {
+	port string
+	pdu  bool
+}
+
+// NewModem creates a new modem message service. If pdu is set, SMS are sent
+// and received in PDU mode instead of text mode.
+func NewModem(port string, pdu bool) *Modem {
+	if port == "" {
+		port = DefaultModemPort
+	}
+
+	return &Modem{port: port, pdu: pdu}
+}
+
+// Address returns the phone number of the user if the message may be sent
+// by SMS
+func (m *Modem) Address(msg data.Message) string {
+	if !msg.ChannelAllowed(data.PointValueSMS) {
+		return ""
+	}
+
+	return msg.Phone
+}
+
+// Deliver sends a message as SMS
+func (m *Modem) Deliver(msg data.Message) error {
+	p, err := network.OpenCmdPort(m.port)
+	if err != nil {
+		return err
+	}
+	defer p.Close()
+
+	return p.SendSMS(msg.Phone, msg.Message, m.pdu)
+}
+
+// Receive returns the SMS received by the modem as commands. Messages are
+// deleted from the modem once they are read.
+func (m *Modem) Receive() ([]data.SMSCommand, error) {
+	p, err := network.OpenCmdPort(m.port)
+	if err != nil {
+		return nil, err
+	}
+	defer p.Close()
+
+	messages, err := p.ReadSMS(m.pdu)
+
+	var ret []data.SMSCommand
+	for _, sms := range messages {
+		ret = append(ret, data.SMSCommand{
+			From: sms.From,
+			Body: sms.Text,
+			Time: sms.Time,
+		})
+	}
+
+	return ret, err
+}
@@ -0,0 +1,118 @@
+package msg
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/simpleiot/simpleiot/data"
+)
+
+// Service is implemented by message service backends
+type Service interface {
+	// Address returns the address the service delivers a message to, or
+	// blank if the service can't deliver the message (for example, an SMS
+	// service and a user without a phone number).
+	Address(m data.Message) string
+	// Deliver sends a message to the address
+	Deliver(m data.Message) error
+}
+
+// Receiver is implemented by message services that are polled for inbound
+// messages, like a cellular modem. Services that are sent inbound messages,
+// like Twilio webhooks, don't implement it.
+type Receiver interface {
+	// Receive returns the messages received since the last call
+	Receive() ([]data.SMSCommand, error)
+}
+
+// defaultTimeout is used if a message service does not have a timeout
+const defaultTimeout = 30 * time.Second
+
+// NewService creates the message service backend that is selected by the
+// service point of a message service node
+func NewService(svc data.MsgService) (Service, error) {
+	timeout := defaultTimeout
+	if svc.Timeout > 0 {
+		timeout = time.Duration(svc.Timeout * float64(time.Second))
+	}
+
+	switch svc.Service {
+	case data.PointValueTwilio:
+		return NewTwilio(svc.SID, svc.AuthToken, svc.From), nil
+	case data.PointValueSMTP:
+		return NewSMTP(svc.Host, svc.Port, svc.TLSMode, svc.Username,
+			svc.Password, svc.From, svc.SubjectPrefix, timeout), nil
+	case data.PointValueWebhook:
+		if svc.URI == "" {
+			return nil, errors.New("webhook message service does not have a URI")
+		}
+		return NewWebhook(svc.URI, svc.Method, svc.Headers, timeout), nil
+	case data.PointValueModem:
+		return NewModem(svc.ModemPort, svc.SMSMode == data.PointValuePDU), nil
+	case data.PointValueMock:
+		return NewMock(), nil
+	}
+
+	return nil, fmt.Errorf("unknown message service: %v", svc.Service)
+}
+
+// Mock is a message service that records messages instead of sending them,
+// which is useful for testing. Messages are delivered to the email address
+// of the user, or the phone number if the user does not have an email
+// address or the message may not be sent by email.
+type Mock struct {
+	lock     sync.Mutex
+	messages []data.Message
+	err      error
+}
+
+// NewMock creates a new mock message service
+func NewMock() *Mock {
+	return &Mock{}
+}
+
+// Address returns the email address or phone number of the user, for the
+// channels the message may be sent on
+func (m *Mock) Address(msg data.Message) string {
+	if msg.Email != "" && msg.ChannelAllowed(data.PointValueEmail) {
+		return msg.Email
+	}
+
+	if msg.ChannelAllowed(data.PointValueSMS) {
+		return msg.Phone
+	}
+
+	return ""
+}
+
+// Deliver records a message, or returns the error set by SetError
+func (m *Mock) Deliver(msg data.Message) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.err != nil {
+		return m.err
+	}
+
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// Messages returns the messages that have been delivered
+func (m *Mock) Messages() []data.Message {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return append([]data.Message{}, m.messages...)
+}
+
+// SetError sets the error returned by Deliver. Set to nil for messages to
+// be delivered again.
+func (m *Mock) SetError(err error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.err = err
+}
@@ -0,0 +1,99 @@
+package msg
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/simpleiot/simpleiot/data"
+)
+
+func TestNewService(t *testing.T) {
+	m := data.Message{Email: "bob@example.com", Phone: "+15555550100"}
+
+	tests := []struct {
+		svc     data.MsgService
+		address string
+	}{
+		{data.MsgService{Service: data.PointValueTwilio}, m.Phone},
+		{data.MsgService{Service: data.PointValueSMTP, Host: "localhost"}, m.Email},
+		{data.MsgService{Service: data.PointValueWebhook, URI: "http://localhost/msg"},
+			"http://localhost/msg"},
+		{data.MsgService{Service: data.PointValueModem}, m.Phone},
+		{data.MsgService{Service: data.PointValueMock}, m.Email},
+	}
+
+	for _, test := range tests {
+		s, err := NewService(test.svc)
+		if err != nil {
+			t.Fatalf("%v: %v", test.svc.Service, err)
+		}
+
+		if a := s.Address(m); a != test.address {
+			t.Errorf("%v: wrong address: %v", test.svc.Service, a)
+		}
+	}
+
+	if s, _ := NewService(data.MsgService{Service: data.PointValueTwilio}); s.Address(data.Message{Email: "bob@example.com"}) != "" {
+		t.Error("SMS service should not deliver to users without a phone")
+	}
+
+	// channels limit the services a message is sent by
+	m.Channels = []string{data.PointValueSMS}
+
+	for _, test := range tests {
+		s, _ := NewService(test.svc)
+		a := s.Address(m)
+
+		switch test.svc.Service {
+		case data.PointValueTwilio, data.PointValueModem, data.PointValueMock:
+			if a != m.Phone {
+				t.Errorf("%v: wrong SMS only address: %v", test.svc.Service, a)
+			}
+		default:
+			if a != "" {
+				t.Errorf("%v: should not send SMS only message: %v", test.svc.Service, a)
+			}
+		}
+	}
+
+	// only services that are polled for inbound messages are receivers
+	for _, test := range tests {
+		s, _ := NewService(test.svc)
+		_, ok := s.(Receiver)
+
+		if ok != (test.svc.Service == data.PointValueModem) {
+			t.Errorf("%v: wrong receiver: %v", test.svc.Service, ok)
+		}
+	}
+
+	_, err := NewService(data.MsgService{Service: data.PointValueWebhook})
+	if err == nil {
+		t.Error("expected error for webhook without URI")
+	}
+
+	_, err = NewService(data.MsgService{Service: "pigeon"})
+	if err == nil {
+		t.Error("expected error for unknown service")
+	}
+}
+
+func TestMock(t *testing.T) {
+	m := NewMock()
+
+	err := m.Deliver(data.Message{Phone: "+15555550100", Message: "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.SetError(errors.New("service down"))
+
+	err = m.Deliver(data.Message{Phone: "+15555550100", Message: "lost"})
+	if err == nil {
+		t.Error("expected error")
+	}
+
+	msgs := m.Messages()
+	if len(msgs) != 1 || msgs[0].Message != "hi" {
+		t.Errorf("wrong messages: %+v", msgs)
+	}
+}
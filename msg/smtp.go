@@ -0,0 +1,259 @@
+package msg
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/simpleiot/simpleiot/data"
+)
+
+// SMTP TLS modes
+const (
+	SMTPTLSNone  = "none"
+	SMTPStartTLS = "starttls"
+	SMTPTLS      = "tls"
+)
+
+// SMTP can be used to send email through an SMTP server
+type SMTP struct {
+	host          string
+	port          int
+	tlsMode       string
+	username      string
+	password      string
+	from          string
+	subjectPrefix string
+	timeout       time.Duration
+}
+
+// NewSMTP creates a new SMTP client. tlsMode defaults to starttls if blank,
+// and port defaults to the standard port for the TLS mode if 0 (25 for none,
+// 587 for starttls, and 465 for tls). Authentication is only used if
+// username is set.
+func NewSMTP(host string, port int, tlsMode, username, password, from,
+	subjectPrefix string, timeout time.Duration) *SMTP {
+	if tlsMode == "" {
+		tlsMode = SMTPStartTLS
+	}
+
+	if port == 0 {
+		switch tlsMode {
+		case SMTPTLSNone:
+			port = 25
+		case SMTPTLS:
+			port = 465
+		default:
+			port = 587
+		}
+	}
+
+	return &SMTP{
+		host:          host,
+		port:          port,
+		tlsMode:       tlsMode,
+		username:      username,
+		password:      password,
+		from:          from,
+		subjectPrefix: subjectPrefix,
+		timeout:       timeout,
+	}
+}
+
+// Address returns the email address of the user if the message may be sent
+// by email
+func (s *SMTP) Address(m data.Message) string {
+	if !m.ChannelAllowed(data.PointValueEmail) {
+		return ""
+	}
+
+	return m.Email
+}
+
+// Deliver sends a message as email
+func (s *SMTP) Deliver(m data.Message) error {
+	return s.SendEmail(m.Email, m.Subject, m.Message, m.HTML)
+}
+
+// SendEmail sends an email. If html is not blank, the email is sent as a
+// multipart message with plain text and HTML versions of the message.
+func (s *SMTP) SendEmail(to, subject, text, html string) error {
+	if s.host == "" {
+		return errors.New("SMTP host not set")
+	}
+
+	from, err := mail.ParseAddress(s.from)
+	if err != nil {
+		return fmt.Errorf("invalid from address: %w", err)
+	}
+
+	toAddr, err := mail.ParseAddress(to)
+	if err != nil {
+		return fmt.Errorf("invalid to address: %w", err)
+	}
+
+	body, err := s.message(from, toAddr, subject, text, html)
+	if err != nil {
+		return err
+	}
+
+	addr := net.JoinHostPort(s.host, strconv.Itoa(s.port))
+	dialer := &net.Dialer{Timeout: s.timeout}
+
+	var conn net.Conn
+
+	if s.tlsMode == SMTPTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr,
+			&tls.Config{ServerName: s.host})
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if s.timeout > 0 {
+		conn.SetDeadline(time.Now().Add(s.timeout))
+	}
+
+	c, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	defer c.Close()
+
+	if s.tlsMode == SMTPStartTLS {
+		err = c.StartTLS(&tls.Config{ServerName: s.host})
+		if err != nil {
+			return err
+		}
+	}
+
+	if s.username != "" {
+		err = c.Auth(smtp.PlainAuth("", s.username, s.password, s.host))
+		if err != nil {
+			return err
+		}
+	}
+
+	err = c.Mail(from.Address)
+	if err != nil {
+		return err
+	}
+
+	err = c.Rcpt(toAddr.Address)
+	if err != nil {
+		return err
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(body)
+	if err != nil {
+		return err
+	}
+
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
+
+// message builds the headers and body of an email
+func (s *SMTP) message(from, to *mail.Address, subject, text, html string) ([]byte, error) {
+	buf := new(bytes.Buffer)
+
+	if s.subjectPrefix != "" {
+		subject = s.subjectPrefix + " " + subject
+	}
+
+	header := func(key, value string) {
+		fmt.Fprintf(buf, "%v: %v\r\n", key, value)
+	}
+
+	header("From", from.String())
+	header("To", to.String())
+	header("Subject", mime.QEncoding.Encode("utf-8", subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("Message-ID", fmt.Sprintf("<%v@%v>", uuid.New().String(), s.host))
+	header("MIME-Version", "1.0")
+
+	if html == "" {
+		header("Content-Type", "text/plain; charset=utf-8")
+		header("Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+
+		err := writeQuotedPrintable(buf, text)
+		if err != nil {
+			return nil, err
+		}
+
+		return buf.Bytes(), nil
+	}
+
+	mw := multipart.NewWriter(buf)
+
+	header("Content-Type", "multipart/alternative; boundary="+mw.Boundary())
+	buf.WriteString("\r\n")
+
+	parts := []struct {
+		contentType string
+		body        string
+	}{
+		{"text/plain; charset=utf-8", text},
+		{"text/html; charset=utf-8", html},
+	}
+
+	for _, p := range parts {
+		pw, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {p.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		err = writeQuotedPrintable(pw, p.body)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := mw.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func writeQuotedPrintable(w io.Writer, text string) error {
+	qp := quotedprintable.NewWriter(w)
+
+	_, err := qp.Write([]byte(text))
+	if err != nil {
+		return err
+	}
+
+	return qp.Close()
+}
@@ -0,0 +1,242 @@
+package msg
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testMail struct {
+	auth string
+	from string
+	to   string
+	data string
+}
+
+// smtpStandIn is a minimal SMTP server that records the mail it receives.
+// Mail to addresses starting with "reject" is rejected.
+type smtpStandIn struct {
+	ln    net.Listener
+	mails chan testMail
+}
+
+func newSMTPStandIn(t *testing.T) *smtpStandIn {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &smtpStandIn{ln: ln, mails: make(chan testMail, 10)}
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+
+	return s
+}
+
+func (s *smtpStandIn) port() int {
+	return s.ln.Addr().(*net.TCPAddr).Port
+}
+
+func (s *smtpStandIn) serve(conn net.Conn) {
+	tp := textproto.NewConn(conn)
+	defer tp.Close()
+
+	var m testMail
+
+	tp.PrintfLine("220 localhost stand-in")
+
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+
+		cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+
+		switch cmd {
+		case "EHLO", "HELO":
+			tp.PrintfLine("250-localhost")
+			tp.PrintfLine("250 AUTH PLAIN")
+		case "AUTH":
+			fields := strings.Fields(line)
+			d, _ := base64.StdEncoding.DecodeString(fields[len(fields)-1])
+			m.auth = strings.ReplaceAll(string(d), "\x00", ":")
+			tp.PrintfLine("235 ok")
+		case "MAIL":
+			m.from = line
+			tp.PrintfLine("250 ok")
+		case "RCPT":
+			if strings.Contains(line, "<reject") {
+				tp.PrintfLine("550 no such user")
+				continue
+			}
+			m.to = line
+			tp.PrintfLine("250 ok")
+		case "DATA":
+			tp.PrintfLine("354 go ahead")
+			d, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			m.data = string(d)
+			s.mails <- m
+			tp.PrintfLine("250 ok")
+		case "QUIT":
+			tp.PrintfLine("221 bye")
+			return
+		default:
+			tp.PrintfLine("250 ok")
+		}
+	}
+}
+
+func (s *smtpStandIn) mail(t *testing.T) testMail {
+	select {
+	case m := <-s.mails:
+		return m
+	case <-time.After(time.Second):
+		t.Fatal("no mail received")
+	}
+
+	return testMail{}
+}
+
+func TestSMTPPlain(t *testing.T) {
+	srv := newSMTPStandIn(t)
+	defer srv.ln.Close()
+
+	s := NewSMTP("127.0.0.1", srv.port(), SMTPTLSNone, "siot", "secret",
+		"Simple IoT <alerts@example.com>", "[SIOT]", time.Second)
+
+	err := s.SendEmail("bob@example.com", "Tank low", "Tank 3 is at 12%\nCheck it", "")
+	if err != nil {
+		t.Fatal("send failed: ", err)
+	}
+
+	m := srv.mail(t)
+
+	if m.auth != ":siot:secret" {
+		t.Error("wrong auth: ", m.auth)
+	}
+
+	if m.from != "MAIL FROM:<alerts@example.com>" || m.to != "RCPT TO:<bob@example.com>" {
+		t.Errorf("wrong envelope: %v, %v", m.from, m.to)
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(m.data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subject, _ := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if subject != "[SIOT] Tank low" {
+		t.Error("wrong subject: ", subject)
+	}
+
+	if !strings.HasPrefix(msg.Header.Get("Content-Type"), "text/plain") {
+		t.Error("wrong content type: ", msg.Header.Get("Content-Type"))
+	}
+
+	// the stand-in reads the data with line endings converted to \n
+	body, _ := ioutil.ReadAll(quotedprintable.NewReader(msg.Body))
+	if strings.TrimSpace(string(body)) != "Tank 3 is at 12%\nCheck it" {
+		t.Errorf("wrong body: %q", body)
+	}
+}
+
+func TestSMTPMultipart(t *testing.T) {
+	srv := newSMTPStandIn(t)
+	defer srv.ln.Close()
+
+	s := NewSMTP("127.0.0.1", srv.port(), SMTPTLSNone, "", "",
+		"alerts@example.com", "", time.Second)
+
+	err := s.SendEmail("bob@example.com", "Tank low", "Tank 3 is low",
+		"<p>Tank 3 is <b>low</b></p>")
+	if err != nil {
+		t.Fatal("send failed: ", err)
+	}
+
+	m := srv.mail(t)
+
+	if m.auth != "" {
+		t.Error("auth should not be used without a username")
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(m.data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("wrong content type: %v, %v", mediaType, err)
+	}
+
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+
+	exp := []struct {
+		contentType string
+		body        string
+	}{
+		{"text/plain; charset=utf-8", "Tank 3 is low"},
+		{"text/html; charset=utf-8", "<p>Tank 3 is <b>low</b></p>"},
+	}
+
+	for _, e := range exp {
+		p, err := mr.NextPart()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the multipart reader decodes quoted-printable parts
+		body, _ := ioutil.ReadAll(p)
+
+		if p.Header.Get("Content-Type") != e.contentType || string(body) != e.body {
+			t.Errorf("wrong part: %v, %q", p.Header.Get("Content-Type"), body)
+		}
+	}
+}
+
+func TestSMTPErrors(t *testing.T) {
+	srv := newSMTPStandIn(t)
+	defer srv.ln.Close()
+
+	s := NewSMTP("127.0.0.1", srv.port(), SMTPTLSNone, "", "",
+		"alerts@example.com", "", time.Second)
+
+	err := s.SendEmail("reject@example.com", "Tank low", "Tank 3 is low", "")
+	if err == nil || !strings.Contains(err.Error(), "no such user") {
+		t.Error("expected rejected recipient error, got: ", err)
+	}
+
+	err = s.SendEmail("not an address", "Tank low", "Tank 3 is low", "")
+	if err == nil {
+		t.Error("expected invalid address error")
+	}
+
+	// the stand-in does not support STARTTLS
+	s = NewSMTP("127.0.0.1", srv.port(), SMTPStartTLS, "", "",
+		"alerts@example.com", "", time.Second)
+
+	err = s.SendEmail("bob@example.com", "Tank low", "Tank 3 is low", "")
+	if err == nil {
+		t.Error("expected STARTTLS error")
+	}
+}
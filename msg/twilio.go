@@ -1,14 +1,18 @@
 package msg
 
 import (
+	"crypto/hmac"
 	"errors"
+	"net/url"
 
 	"github.com/kevinburke/twilio-go"
+	"github.com/simpleiot/simpleiot/data"
 )
 
 // Twilio can be used to send messages through Twilio
 type Twilio struct {
 	twilioClient *twilio.Client
+	authToken    string
 	smsFrom      string
 }
 
@@ -16,10 +20,26 @@ type Twilio struct {
 func NewTwilio(twilioSid, twilioAuth, smsFrom string) *Twilio {
 	return &Twilio{
 		twilioClient: twilio.NewClient(twilioSid, twilioAuth, nil),
+		authToken:    twilioAuth,
 		smsFrom:      smsFrom,
 	}
 }
 
+// Address returns the phone number of the user if the message may be sent
+// by SMS
+func (m *Twilio) Address(msg data.Message) string {
+	if !msg.ChannelAllowed(data.PointValueSMS) {
+		return ""
+	}
+
+	return msg.Phone
+}
+
+// Deliver sends a message as SMS
+func (m *Twilio) Deliver(msg data.Message) error {
+	return m.SendSMS(msg.Phone, msg.Message)
+}
+
 // SendSMS sends a sms message
 func (m *Twilio) SendSMS(to, msg string) error {
 	if m.twilioClient == nil {
@@ -37,3 +57,21 @@ func (m *Twilio) SendSMS(to, msg string) error {
 
 	return nil
 }
+
+// VerifySignature checks the X-Twilio-Signature header of a webhook request
+// from Twilio, for example an inbound SMS. URL is the full URL Twilio
+// requested (including the query string), and params are the POST
+// parameters.
+func (m *Twilio) VerifySignature(URL string, params url.Values, signature string) error {
+	if m.authToken == "" {
+		return errors.New("Twilio auth token not set")
+	}
+
+	expected := twilio.GetExpectedTwilioSignature("", m.authToken, URL, params)
+
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return errors.New("invalid Twilio signature")
+	}
+
+	return nil
+}
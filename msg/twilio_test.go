@@ -0,0 +1,38 @@
+package msg
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestTwilioVerifySignature(t *testing.T) {
+	// example request from the Twilio security documentation
+	URL := "https://mycompany.com/myapp.php?foo=1&bar=2"
+	params := url.Values{
+		"CallSid": {"CA1234567890ABCDE"},
+		"Caller":  {"+12349013030"},
+		"Digits":  {"1234"},
+		"From":    {"+12349013030"},
+		"To":      {"+18005551212"},
+	}
+	signature := "0/KCTR6DLpKmkAf8muzZqo1nDgQ="
+
+	m := NewTwilio("AC123", "12345", "+15555550100")
+
+	err := m.VerifySignature(URL, params, signature)
+	if err != nil {
+		t.Error("valid signature rejected: ", err)
+	}
+
+	params.Set("Digits", "4321")
+
+	err = m.VerifySignature(URL, params, signature)
+	if err == nil {
+		t.Error("signature of modified request accepted")
+	}
+
+	err = NewTwilio("AC123", "", "").VerifySignature(URL, params, signature)
+	if err == nil {
+		t.Error("signature accepted without auth token")
+	}
+}
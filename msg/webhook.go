@@ -0,0 +1,89 @@
+package msg
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/simpleiot/simpleiot/data"
+)
+
+// Webhook can be used to send data to a HTTP endpoint
+type Webhook struct {
+	url     string
+	method  string
+	headers map[string]string
+	client  *http.Client
+}
+
+// NewWebhook creates a new webhook. method defaults to POST if blank.
+func NewWebhook(url, method string, headers map[string]string, timeout time.Duration) *Webhook {
+	if method == "" {
+		method = http.MethodPost
+	}
+
+	return &Webhook{
+		url:     url,
+		method:  method,
+		headers: headers,
+		client:  &http.Client{Timeout: timeout},
+	}
+}
+
+// Address returns the webhook URL as every message that may be sent by
+// webhook is sent to it
+func (w *Webhook) Address(m data.Message) string {
+	if !m.ChannelAllowed(data.PointValueWebhook) {
+		return ""
+	}
+
+	return w.url
+}
+
+// Deliver sends a message as JSON to the webhook URL
+func (w *Webhook) Deliver(m data.Message) error {
+	body, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Send(body)
+	return err
+}
+
+// Send sends a JSON body to the webhook URL and returns the HTTP status code.
+// A status code outside of the 2xx range is returned as an error. The status
+// code is 0 if the request did not get a response.
+func (w *Webhook) Send(body []byte) (int, error) {
+	req, err := http.NewRequest(w.method, w.url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	for k, v := range w.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+
+	defer resp.Body.Close()
+
+	// read the body so the connection can be reused
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("webhook returned status: %v",
+			resp.Status)
+	}
+
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,101 @@
+package msg
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/simpleiot/simpleiot/data"
+)
+
+func TestWebhook(t *testing.T) {
+	var method, auth, contentType, body string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		auth = r.Header.Get("Authorization")
+		contentType = r.Header.Get("Content-Type")
+		b, _ := ioutil.ReadAll(r.Body)
+		body = string(b)
+	}))
+	defer srv.Close()
+
+	wh := NewWebhook(srv.URL, "", map[string]string{"Authorization": "Bearer 123"},
+		time.Second)
+
+	status, err := wh.Send([]byte(`{"a":1}`))
+	if err != nil {
+		t.Fatal("send failed: ", err)
+	}
+
+	if status != http.StatusOK {
+		t.Error("wrong status: ", status)
+	}
+
+	if method != http.MethodPost || auth != "Bearer 123" ||
+		contentType != "application/json" || body != `{"a":1}` {
+		t.Errorf("wrong request: %v %v %v %v", method, auth, contentType, body)
+	}
+
+	wh = NewWebhook(srv.URL, http.MethodPut, nil, time.Second)
+
+	_, err = wh.Send(nil)
+	if err != nil {
+		t.Fatal("send failed: ", err)
+	}
+
+	if method != http.MethodPut {
+		t.Error("wrong method: ", method)
+	}
+}
+
+func TestWebhookErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(200 * time.Millisecond)
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	status, err := NewWebhook(srv.URL, "", nil, time.Second).Send(nil)
+	if err == nil || status != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 error, got %v %v", status, err)
+	}
+
+	status, err = NewWebhook(srv.URL+"/slow", "", nil, 50*time.Millisecond).Send(nil)
+	if err == nil || status != 0 {
+		t.Errorf("expected timeout error, got %v %v", status, err)
+	}
+}
+
+func TestWebhookDeliver(t *testing.T) {
+	var m data.Message
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(b, &m)
+	}))
+	defer srv.Close()
+
+	s, err := NewService(data.MsgService{Service: data.PointValueWebhook, URI: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := data.Message{ID: "m1", UserID: "u1", Subject: "Tank low", Message: "Tank 3 is low"}
+
+	err = s.Deliver(exp)
+	if err != nil {
+		t.Fatal("deliver failed: ", err)
+	}
+
+	if !reflect.DeepEqual(m, exp) {
+		t.Errorf("wrong message, exp %+v, got %+v", exp, m)
+	}
+}
@@ -0,0 +1,30 @@
+package nats
+
+import (
+	"errors"
+	"time"
+
+	natsgo "github.com/nats-io/nats.go"
+	"github.com/simpleiot/simpleiot/data"
+)
+
+// SendAlarmAck acknowledges, shelves, or unshelves the alarm of a rule node.
+// An error is returned if the alarm is not in a state where the action is
+// allowed.
+func SendAlarmAck(nc *natsgo.Conn, nodeID string, ack data.AlarmAck) error {
+	ackData, err := ack.ToPb()
+	if err != nil {
+		return err
+	}
+
+	msg, err := nc.Request(SubjectNodeAck(nodeID), ackData, time.Second)
+	if err != nil {
+		return err
+	}
+
+	if len(msg.Data) > 0 {
+		return errors.New(string(msg.Data))
+	}
+
+	return nil
+}
@@ -26,6 +26,10 @@ func Dump(nc *natsgo.Conn, msg *natsgo.Msg) error {
 func String(nc *natsgo.Conn, msg *natsgo.Msg) (string, error) {
 	ret := ""
 
+	if msg.Subject == SubjectSchemas() {
+		return "get node schemas\n", nil
+	}
+
 	chunks := strings.Split(msg.Subject, ".")
 
 	if len(chunks) < 2 {
@@ -60,7 +64,7 @@ func String(nc *natsgo.Conn, msg *natsgo.Msg) (string, error) {
 			ret += fmt.Sprintf("NODE: %v (%v) (%v)\n", description, node.Type, node.ID)
 
 			switch chunks[2] {
-			case "points":
+			case "points", "stored":
 				_, points, err := DecodeNodePointsMsg(msg)
 				if err != nil {
 					return "", err
@@ -84,6 +88,36 @@ func String(nc *natsgo.Conn, msg *natsgo.Msg) (string, error) {
 				ret += fmt.Sprintf("    - Message: %+v\n", message)
 			case "children":
 				ret += "   get children\n"
+			case "sync":
+				ret += "   get node for sync\n"
+			case "syncChildren":
+				ret += "   get children for sync\n"
+			case "history":
+				ret += "   get history\n"
+			case "event":
+				event, err := data.PbDecodeEvent(msg.Data)
+				if err != nil {
+					return "", err
+				}
+				ret += fmt.Sprintf("    - Event: %+v\n", event)
+			case "events":
+				ret += "   get events\n"
+			case "eval":
+				ret += "   evaluate rule\n"
+			case "deliveries":
+				ret += "   get message deliveries\n"
+			case "trace":
+				eval, err := data.PbDecodeRuleEval(msg.Data)
+				if err != nil {
+					return "", err
+				}
+				ret += fmt.Sprintf("    - Rule trace: %+v\n", eval)
+			case "sms", "command":
+				cmd, err := data.PbDecodeSMSCommand(msg.Data)
+				if err != nil {
+					return "", err
+				}
+				ret += fmt.Sprintf("    - SMS command: %+v\n", cmd)
 			default:
 				log.Println("unknown node op: ", chunks[2])
 			}
@@ -113,8 +147,8 @@ func String(nc *natsgo.Conn, msg *natsgo.Msg) (string, error) {
 			return "", fmt.Errorf("invalid message, does not start with node: %v", msg.Subject)
 		}
 
-		if chunks[3] != "points" {
-			return "", fmt.Errorf("invalid message, does not end with points: %v", msg.Subject)
+		if chunks[3] != "points" && chunks[3] != "stored" {
+			return "", fmt.Errorf("invalid message, does not end with points or stored: %v", msg.Subject)
 		}
 
 		nodeID := chunks[1]
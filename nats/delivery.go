@@ -0,0 +1,25 @@
+package nats
+
+import (
+	"time"
+
+	natsgo "github.com/nats-io/nats.go"
+	"github.com/simpleiot/simpleiot/data"
+)
+
+// GetDeliveries fetches the message deliveries to a user node over NATS. If
+// id is "root", deliveries to all users are fetched. The query may select the
+// deliveries of a notification.
+func GetDeliveries(nc *natsgo.Conn, id string, query data.DeliveryQuery) ([]data.Delivery, error) {
+	reqData, err := query.ToPb()
+	if err != nil {
+		return nil, err
+	}
+
+	msg, err := nc.Request(SubjectNodeDeliveries(id), reqData, time.Second*20)
+	if err != nil {
+		return nil, err
+	}
+
+	return data.PbDecodeDeliveriesRequest(msg.Data)
+}
@@ -0,0 +1,64 @@
+package nats
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	natsgo "github.com/nats-io/nats.go"
+	"github.com/simpleiot/simpleiot/data"
+)
+
+// SendEvent publishes an event against a node. If the event ID or time is
+// not set, they are assigned so the event is recorded consistently by every
+// instance that receives it.
+func SendEvent(nc *natsgo.Conn, nodeID string, event data.Event, ack bool) error {
+	if event.ID == "" {
+		event.ID = uuid.New().String()
+	}
+
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	event.NodeID = nodeID
+
+	eventData, err := event.PbEncode()
+	if err != nil {
+		return err
+	}
+
+	subject := SubjectNodeEvent(nodeID)
+
+	if ack {
+		msg, err := nc.Request(subject, eventData, time.Second)
+		if err != nil {
+			return err
+		}
+
+		if len(msg.Data) > 0 {
+			return errors.New(string(msg.Data))
+		}
+
+		return nil
+	}
+
+	return nc.Publish(subject, eventData)
+}
+
+// GetEvents fetches events for a node and all of its descendants from the
+// event log over NATS. If id is "root", events for the entire tree are
+// fetched.
+func GetEvents(nc *natsgo.Conn, id string, query data.EventQuery) ([]data.Event, error) {
+	reqData, err := query.ToPb()
+	if err != nil {
+		return nil, err
+	}
+
+	msg, err := nc.Request(SubjectNodeEvents(id), reqData, time.Second*20)
+	if err != nil {
+		return nil, err
+	}
+
+	return data.PbDecodeEventsRequest(msg.Data)
+}
@@ -0,0 +1,24 @@
+package nats
+
+import (
+	"time"
+
+	natsgo "github.com/nats-io/nats.go"
+	"github.com/simpleiot/simpleiot/data"
+)
+
+// GetNodeHistory fetches points for a node from the local history store
+// over NATS. If id is "root", history for the root node is fetched.
+func GetNodeHistory(nc *natsgo.Conn, id string, query data.HistoryQuery) (data.Points, error) {
+	reqData, err := query.ToPb()
+	if err != nil {
+		return nil, err
+	}
+
+	msg, err := nc.Request(SubjectNodeHistory(id), reqData, time.Second*20)
+	if err != nil {
+		return nil, err
+	}
+
+	return data.PbDecodePointsRequest(msg.Data)
+}
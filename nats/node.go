@@ -13,12 +13,25 @@ import (
 // GetNode over NATS. If id is "root", the root node is fetched.
 // If parent is set to "skip", the edge details are not included
 // and the hash is calculated without the edge points.
-// returns data.ErrDocumentNotFound if node is not found.
+// Points that must not be shown to clients, like password hashes, are
+// redacted. returns data.ErrDocumentNotFound if node is not found.
 func GetNode(nc *natsgo.Conn, id, parent string) (data.NodeEdge, error) {
+	return getNode(nc, "node."+id, parent, false)
+}
+
+// GetNodeSync gets a node like GetNode, but does not redact any points. It
+// is used to synchronize nodes between instances. The auth token of the
+// connection is sent with the request, and points are still redacted if
+// it does not match the auth token of the instance.
+func GetNodeSync(nc *natsgo.Conn, id, parent string) (data.NodeEdge, error) {
+	return getNode(nc, SubjectNodeSync(id), parent, true)
+}
+
+func getNode(nc *natsgo.Conn, subject, parent string, sync bool) (data.NodeEdge, error) {
 	if parent == "" {
 		parent = "none"
 	}
-	nodeMsg, err := nc.Request("node."+id, []byte(parent), time.Second*20)
+	nodeMsg, err := request(nc, subject, []byte(parent), sync)
 	if err != nil {
 		return data.NodeEdge{}, err
 	}
@@ -35,8 +48,19 @@ func GetNode(nc *natsgo.Conn, id, parent string) (data.NodeEdge, error) {
 // GetNodeChildren over NATS (immediate children only, not recursive)
 // deleted nodes are skipped unless includeDel is set to true. typ
 // can be used to limit nodes to a particular type, otherwise, all nodes
-// are returned.
+// are returned. Points are redacted like GetNode.
 func GetNodeChildren(nc *natsgo.Conn, id, typ string, includeDel bool) ([]data.NodeEdge, error) {
+	return getNodeChildren(nc, "node."+id+".children", typ, includeDel, false)
+}
+
+// GetNodeChildrenSync gets the children of a node like GetNodeChildren, but
+// does not redact any points. It is used to synchronize nodes between
+// instances and is authorized like GetNodeSync.
+func GetNodeChildrenSync(nc *natsgo.Conn, id, typ string, includeDel bool) ([]data.NodeEdge, error) {
+	return getNodeChildren(nc, SubjectNodeSyncChildren(id), typ, includeDel, true)
+}
+
+func getNodeChildren(nc *natsgo.Conn, subject, typ string, includeDel, sync bool) ([]data.NodeEdge, error) {
 	reqData, err := proto.Marshal(&pb.NatsRequest{IncludeDel: includeDel,
 		Type: typ})
 
@@ -44,7 +68,7 @@ func GetNodeChildren(nc *natsgo.Conn, id, typ string, includeDel bool) ([]data.N
 		return nil, err
 	}
 
-	nodeMsg, err := nc.Request("node."+id+".children", reqData, time.Second*20)
+	nodeMsg, err := request(nc, subject, reqData, sync)
 	if err != nil {
 		return nil, err
 	}
@@ -57,7 +81,22 @@ func GetNodeChildren(nc *natsgo.Conn, id, typ string, includeDel bool) ([]data.N
 	return nodes, nil
 }
 
-// SendNode is used to recursively send a node and children over nats
+// request sends a node request. Sync requests carry the auth token of the
+// connection in the Authorization header.
+func request(nc *natsgo.Conn, subject string, reqData []byte, sync bool) (*natsgo.Msg, error) {
+	if !sync {
+		return nc.Request(subject, reqData, time.Second*20)
+	}
+
+	msg := natsgo.NewMsg(subject)
+	msg.Data = reqData
+	msg.Header.Set("Authorization", nc.Opts.Token)
+
+	return nc.RequestMsg(msg, time.Second*20)
+}
+
+// SendNode is used to recursively send a node and children over nats. The
+// node must be read with GetNodeSync so that no points are redacted.
 func SendNode(src, dest *natsgo.Conn, node data.NodeEdge) error {
 	points := node.Points
 
@@ -83,7 +122,7 @@ func SendNode(src, dest *natsgo.Conn, node data.NodeEdge) error {
 	}
 
 	// process child nodes
-	childNodes, err := GetNodeChildren(src, node.ID, "", false)
+	childNodes, err := GetNodeChildrenSync(src, node.ID, "", false)
 	if err != nil {
 		return fmt.Errorf("Error getting node children: %v", err)
 	}
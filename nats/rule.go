@@ -0,0 +1,24 @@
+package nats
+
+import (
+	"time"
+
+	natsgo "github.com/nats-io/nats.go"
+	"github.com/simpleiot/simpleiot/data"
+)
+
+// EvalRule evaluates a rule node without side effects (a dry run), with
+// optional hypothetical points. No points are sent and no actions are run.
+func EvalRule(nc *natsgo.Conn, nodeID string, req data.RuleEvalRequest) (data.RuleEval, error) {
+	reqData, err := req.ToPb()
+	if err != nil {
+		return data.RuleEval{}, err
+	}
+
+	msg, err := nc.Request(SubjectNodeEval(nodeID), reqData, time.Second*20)
+	if err != nil {
+		return data.RuleEval{}, err
+	}
+
+	return data.PbDecodeRuleEval(msg.Data)
+}
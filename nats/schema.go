@@ -0,0 +1,18 @@
+package nats
+
+import (
+	"time"
+
+	natsgo "github.com/nats-io/nats.go"
+	"github.com/simpleiot/simpleiot/data"
+)
+
+// GetNodeSchemas fetches the schemas for all node types over NATS
+func GetNodeSchemas(nc *natsgo.Conn) ([]data.NodeSchema, error) {
+	msg, err := nc.Request(SubjectSchemas(), nil, time.Second*20)
+	if err != nil {
+		return nil, err
+	}
+
+	return data.PbDecodeNodeSchemasRequest(msg.Data)
+}
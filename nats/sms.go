@@ -0,0 +1,26 @@
+package nats
+
+import (
+	"time"
+
+	natsgo "github.com/nats-io/nats.go"
+	"github.com/simpleiot/simpleiot/data"
+)
+
+// SendSMSCommand runs a command received by SMS through a message service
+// node. The returned command has the user that sent it, and the reply that
+// is sent back to the user. Error is set in the returned command if the
+// sender is unknown or the command failed.
+func SendSMSCommand(nc *natsgo.Conn, serviceID string, cmd data.SMSCommand) (data.SMSCommand, error) {
+	cmdData, err := cmd.ToPb()
+	if err != nil {
+		return data.SMSCommand{}, err
+	}
+
+	msg, err := nc.Request(SubjectNodeSMS(serviceID), cmdData, time.Second*20)
+	if err != nil {
+		return data.SMSCommand{}, err
+	}
+
+	return data.PbDecodeSMSCommand(msg.Data)
+}
@@ -23,3 +23,99 @@ func SubjectNodeAllPoints() string {
 func SubjectEdgeAllPoints() string {
 	return "node.*.*.points"
 }
+
+// SubjectNodeStored constructs a NATS subject for publishing node points
+// after they have been stored
+func SubjectNodeStored(nodeID string) string {
+	return fmt.Sprintf("node.%v.stored", nodeID)
+}
+
+// SubjectEdgeStored constructs a NATS subject for publishing edge points
+// after they have been stored
+func SubjectEdgeStored(nodeID, parentID string) string {
+	return fmt.Sprintf("node.%v.%v.stored", nodeID, parentID)
+}
+
+// SubjectNodeAllStored provides subject for stored points for any node
+func SubjectNodeAllStored() string {
+	return "node.*.stored"
+}
+
+// SubjectEdgeAllStored provides subject for stored edge points for any node
+func SubjectEdgeAllStored() string {
+	return "node.*.*.stored"
+}
+
+// SubjectNodeSync constructs a NATS subject for requesting a node with all of
+// its points when synchronizing nodes between instances
+func SubjectNodeSync(nodeID string) string {
+	return fmt.Sprintf("node.%v.sync", nodeID)
+}
+
+// SubjectNodeSyncChildren constructs a NATS subject for requesting the
+// children of a node with all of their points when synchronizing nodes
+// between instances
+func SubjectNodeSyncChildren(nodeID string) string {
+	return fmt.Sprintf("node.%v.syncChildren", nodeID)
+}
+
+// SubjectNodeHistory constructs a NATS subject for node history requests
+func SubjectNodeHistory(nodeID string) string {
+	return fmt.Sprintf("node.%v.history", nodeID)
+}
+
+// SubjectNodeEvent constructs a NATS subject for publishing node events
+func SubjectNodeEvent(nodeID string) string {
+	return fmt.Sprintf("node.%v.event", nodeID)
+}
+
+// SubjectNodeAllEvents provides subject for events published against any node
+func SubjectNodeAllEvents() string {
+	return "node.*.event"
+}
+
+// SubjectNodeEvents constructs a NATS subject for node event log requests
+func SubjectNodeEvents(nodeID string) string {
+	return fmt.Sprintf("node.%v.events", nodeID)
+}
+
+// SubjectNodeAck constructs a NATS subject for acknowledging or shelving
+// the alarm of a rule node
+func SubjectNodeAck(nodeID string) string {
+	return fmt.Sprintf("node.%v.ack", nodeID)
+}
+
+// SubjectNodeEval constructs a NATS subject for dry run evaluation requests
+// of a rule node
+func SubjectNodeEval(nodeID string) string {
+	return fmt.Sprintf("node.%v.eval", nodeID)
+}
+
+// SubjectNodeTrace constructs a NATS subject for publishing the evaluation
+// trace of a rule node
+func SubjectNodeTrace(nodeID string) string {
+	return fmt.Sprintf("node.%v.trace", nodeID)
+}
+
+// SubjectNodeDeliveries constructs a NATS subject for message delivery
+// requests for a user node
+func SubjectNodeDeliveries(nodeID string) string {
+	return fmt.Sprintf("node.%v.deliveries", nodeID)
+}
+
+// SubjectNodeSMS constructs a NATS subject for SMS commands received by a
+// message service node
+func SubjectNodeSMS(nodeID string) string {
+	return fmt.Sprintf("node.%v.sms", nodeID)
+}
+
+// SubjectNodeCommand constructs a NATS subject for publishing the result of
+// SMS commands sent by a user node
+func SubjectNodeCommand(nodeID string) string {
+	return fmt.Sprintf("node.%v.command", nodeID)
+}
+
+// SubjectSchemas provides the subject for node schema requests
+func SubjectSchemas() string {
+	return "schemas"
+}
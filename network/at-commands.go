@@ -37,6 +37,11 @@ func RsrpValueToDb(value int) float64 {
 // Cmd send a command to modem and read response
 // retry 3 times. Port should be a RespReadWriter.
 func Cmd(port io.ReadWriter, cmd string) (string, error) {
+	return cmdRead(port, cmd, 100)
+}
+
+// cmdRead sends a command and reads a response of up to size bytes
+func cmdRead(port io.ReadWriter, cmd string, size int) (string, error) {
 	var err error
 
 	for try := 0; try < 3; try++ {
@@ -44,7 +49,7 @@ func Cmd(port io.ReadWriter, cmd string) (string, error) {
 			log.Println("Modem Tx: ", cmd)
 		}
 
-		readString := make([]byte, 100)
+		readString := make([]byte, size)
 
 		_, err = port.Write([]byte(cmd + "\r"))
 		if err != nil {
@@ -0,0 +1,89 @@
+package network
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/jacobsa/go-serial/serial"
+	"github.com/simpleiot/simpleiot/respreader"
+)
+
+// CmdPort is a modem AT command port that is shared by the network manager
+// and other users of the modem, like SMS. The port must be locked while
+// running commands, so that commands and responses are not interleaved.
+type CmdPort struct {
+	sync.Mutex
+	io.ReadWriter
+	name   string
+	closer io.Closer
+	refs   int
+}
+
+var cmdPortsLock sync.Mutex
+var cmdPorts = make(map[string]*CmdPort)
+
+// openSerial opens a modem serial port. It is replaced in tests with a
+// scripted fake port.
+var openSerial = func(name string) (io.ReadWriteCloser, error) {
+	options := serial.OpenOptions{
+		PortName:          name,
+		BaudRate:          115200,
+		DataBits:          8,
+		StopBits:          1,
+		MinimumReadSize:   1,
+		RTSCTSFlowControl: true,
+	}
+
+	port, err := serial.Open(options)
+	if err != nil {
+		return nil, err
+	}
+
+	return respreader.NewReadWriteCloser(port, 10*time.Second,
+		50*time.Millisecond), nil
+}
+
+// OpenCmdPort opens a modem AT command port, or returns the port if it is
+// already open. Every call must be matched by a call to Close.
+func OpenCmdPort(name string) (*CmdPort, error) {
+	cmdPortsLock.Lock()
+	defer cmdPortsLock.Unlock()
+
+	p, ok := cmdPorts[name]
+	if ok {
+		p.refs++
+		return p, nil
+	}
+
+	port, err := openSerial(name)
+	if err != nil {
+		return nil, err
+	}
+
+	p = &CmdPort{ReadWriter: port, name: name, closer: port, refs: 1}
+	cmdPorts[name] = p
+
+	return p, nil
+}
+
+// Close releases the port. The serial port is closed once it is no longer
+// used.
+func (p *CmdPort) Close() error {
+	cmdPortsLock.Lock()
+	defer cmdPortsLock.Unlock()
+
+	if p.refs <= 0 {
+		// already closed
+		return nil
+	}
+
+	p.refs--
+	if p.refs > 0 {
+		return nil
+	}
+
+	delete(cmdPorts, p.name)
+
+	return p.closer.Close()
+}
@@ -5,17 +5,14 @@ package network
 import (
 	"errors"
 	"fmt"
-	"io"
 	"log"
 	"os/exec"
 	"strings"
 	"time"
 
 	nmea "github.com/adrianmo/go-nmea"
-	"github.com/jacobsa/go-serial/serial"
 	"github.com/simpleiot/simpleiot/data"
 	"github.com/simpleiot/simpleiot/file"
-	"github.com/simpleiot/simpleiot/respreader"
 )
 
 // APNVerizon is the APN to use on VZ network
@@ -30,13 +27,14 @@ const APNHologram = "hologram"
 // Modem is an interface that always reports detected/connected
 type Modem struct {
 	iface      string
-	atCmdPort  io.ReadWriteCloser
+	atCmdPort  *CmdPort
 	lastPPPRun time.Time
 	config     ModemConfig
 	enabled    bool
 }
 
-// ModemConfig describes the configuration for a modem
+// ModemConfig describes the configuration for a modem. The AT command port
+// is shared with other users of the modem, like a modem message service.
 type ModemConfig struct {
 	ChatScript    string
 	AtCmdPortName string
@@ -67,23 +65,12 @@ func (m *Modem) openCmdPort() error {
 		return errors.New("open failed, modem not detected")
 	}
 
-	options := serial.OpenOptions{
-		PortName:          m.config.AtCmdPortName,
-		BaudRate:          115200,
-		DataBits:          8,
-		StopBits:          1,
-		MinimumReadSize:   1,
-		RTSCTSFlowControl: true,
-	}
-
-	port, err := serial.Open(options)
-
+	port, err := OpenCmdPort(m.config.AtCmdPortName)
 	if err != nil {
 		return err
 	}
 
-	m.atCmdPort = respreader.NewReadWriteCloser(port, 10*time.Second,
-		50*time.Millisecond)
+	m.atCmdPort = port
 
 	return nil
 }
@@ -129,6 +116,9 @@ func (m *Modem) Configure() (InterfaceConfig, error) {
 		return ret, err
 	}
 
+	m.atCmdPort.Lock()
+	defer m.atCmdPort.Unlock()
+
 	// disable echo as it messes up the respreader in that it
 	// echos the command, which is not part of the response
 
@@ -235,9 +225,11 @@ func (m *Modem) Connect() error {
 		return err
 	}
 
+	m.atCmdPort.Lock()
 	mode, err := CmdBg96GetScanMode(m.atCmdPort)
 
 	if err != nil {
+		m.atCmdPort.Unlock()
 		return err
 	}
 
@@ -247,11 +239,13 @@ func (m *Modem) Connect() error {
 		log.Println("Setting BG96 scan mode")
 		err := CmdBg96ForceLTE(m.atCmdPort)
 		if err != nil {
+			m.atCmdPort.Unlock()
 			return err
 		}
 	}
 
 	service, _, _, _, err := CmdQcsq(m.atCmdPort)
+	m.atCmdPort.Unlock()
 	if err != nil {
 		return err
 	}
@@ -283,6 +277,9 @@ func (m *Modem) GetStatus() (InterfaceStatus, error) {
 		return InterfaceStatus{}, err
 	}
 
+	m.atCmdPort.Lock()
+	defer m.atCmdPort.Unlock()
+
 	var retError error
 	ip, _ := GetIP(m.iface)
 
@@ -338,6 +335,9 @@ func (m *Modem) Enable(en bool) error {
 		return err
 	}
 
+	m.atCmdPort.Lock()
+	defer m.atCmdPort.Unlock()
+
 	if en {
 		err = CmdFunFull(m.atCmdPort)
 	} else {
@@ -361,7 +361,9 @@ func (m *Modem) GetLocation() (data.GpsPos, error) {
 		return data.GpsPos{}, err
 	}
 
+	m.atCmdPort.Lock()
 	line, err := CmdGGA(m.atCmdPort)
+	m.atCmdPort.Unlock()
 
 	if err != nil {
 		return data.GpsPos{}, err
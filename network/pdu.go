@@ -0,0 +1,454 @@
+package network
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// gsm7 is the GSM 03.38 default alphabet
+var gsm7 = []rune("@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞ\x1bÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?" +
+	"¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà")
+
+// gsm7Ext is the GSM 03.38 extension table, which is selected by the escape
+// character (0x1b)
+var gsm7Ext = map[byte]rune{
+	0x0a: '\f',
+	0x14: '^',
+	0x28: '{',
+	0x29: '}',
+	0x2f: '\\',
+	0x3c: '[',
+	0x3d: '~',
+	0x3e: ']',
+	0x40: '|',
+	0x65: '€',
+}
+
+// data coding schemes
+const (
+	dcsGSM7 = 0x00
+	dcs8Bit = 0x04
+	dcsUCS2 = 0x08
+)
+
+// maximum length of a single SMS in septets (GSM 7 bit) or UTF-16 code
+// units (UCS2)
+const (
+	smsMaxGSM7 = 160
+	smsMaxUCS2 = 70
+)
+
+// gsm7Encode converts text to septets. False is returned if the text can't
+// be encoded with the GSM 7 bit alphabet.
+func gsm7Encode(text string) ([]byte, bool) {
+	var ret []byte
+
+	for _, r := range text {
+		found := false
+
+		for i, g := range gsm7 {
+			if r == g && r != 0x1b {
+				ret = append(ret, byte(i))
+				found = true
+				break
+			}
+		}
+
+		if found {
+			continue
+		}
+
+		for c, g := range gsm7Ext {
+			if r == g {
+				ret = append(ret, 0x1b, c)
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return nil, false
+		}
+	}
+
+	return ret, true
+}
+
+// gsm7Decode converts septets to text
+func gsm7Decode(septets []byte) string {
+	var ret strings.Builder
+
+	for i := 0; i < len(septets); i++ {
+		s := septets[i] & 0x7f
+
+		if s == 0x1b && i+1 < len(septets) {
+			i++
+			if r, ok := gsm7Ext[septets[i]]; ok {
+				ret.WriteRune(r)
+			} else {
+				ret.WriteRune(' ')
+			}
+			continue
+		}
+
+		ret.WriteRune(gsm7[s])
+	}
+
+	return ret.String()
+}
+
+// packSeptets packs 7 bit characters into octets
+func packSeptets(septets []byte) []byte {
+	var ret []byte
+	var acc uint
+	bits := 0
+
+	for _, s := range septets {
+		acc |= uint(s&0x7f) << bits
+		bits += 7
+
+		for bits >= 8 {
+			ret = append(ret, byte(acc))
+			acc >>= 8
+			bits -= 8
+		}
+	}
+
+	if bits > 0 {
+		ret = append(ret, byte(acc))
+	}
+
+	return ret
+}
+
+// unpackSeptets unpacks count 7 bit characters from octets
+func unpackSeptets(octets []byte, count int) []byte {
+	ret := make([]byte, 0, count)
+
+	for i := 0; i < count; i++ {
+		bit := i * 7
+		byteIndex := bit / 8
+		shift := uint(bit % 8)
+
+		if byteIndex >= len(octets) {
+			break
+		}
+
+		v := uint(octets[byteIndex]) >> shift
+		if shift > 1 && byteIndex+1 < len(octets) {
+			v |= uint(octets[byteIndex+1]) << (8 - shift)
+		}
+
+		ret = append(ret, byte(v&0x7f))
+	}
+
+	return ret
+}
+
+// encodeSemiOctets encodes decimal digits as swapped nibbles, padded with F
+func encodeSemiOctets(digits string) []byte {
+	if len(digits)%2 != 0 {
+		digits += "F"
+	}
+
+	ret := make([]byte, len(digits)/2)
+
+	for i := 0; i < len(digits); i += 2 {
+		lo := hexNibble(digits[i])
+		hi := hexNibble(digits[i+1])
+		ret[i/2] = hi<<4 | lo
+	}
+
+	return ret
+}
+
+func hexNibble(c byte) byte {
+	if c >= '0' && c <= '9' {
+		return c - '0'
+	}
+
+	return 0x0f
+}
+
+// decodeSemiOctets decodes swapped nibble decimal digits, ignoring padding
+func decodeSemiOctets(octets []byte, digits int) string {
+	var ret strings.Builder
+
+	for i := 0; i < digits && i/2 < len(octets); i++ {
+		b := octets[i/2]
+		if i%2 == 1 {
+			b >>= 4
+		}
+		b &= 0x0f
+
+		if b > 9 {
+			break
+		}
+
+		ret.WriteByte('0' + b)
+	}
+
+	return ret.String()
+}
+
+// semiOctet decodes a single swapped nibble number, like the fields of a
+// timestamp
+func semiOctet(b byte) int {
+	return int(b&0x0f)*10 + int(b>>4)
+}
+
+// splitSMS splits text into parts that fit in a single SMS
+func splitSMS(text string) []string {
+	var parts []string
+	var part []rune
+	size := 0
+
+	max := smsMaxGSM7
+	_, gsm := gsm7Encode(text)
+	if !gsm {
+		max = smsMaxUCS2
+	}
+
+	for _, r := range text {
+		var l int
+		if gsm {
+			septets, _ := gsm7Encode(string(r))
+			l = len(septets)
+		} else {
+			l = len(utf16.Encode([]rune{r}))
+		}
+
+		if size+l > max {
+			parts = append(parts, string(part))
+			part = nil
+			size = 0
+		}
+
+		part = append(part, r)
+		size += l
+	}
+
+	if len(part) > 0 || len(parts) == 0 {
+		parts = append(parts, string(part))
+	}
+
+	return parts
+}
+
+// EncodeSMSPDU encodes a SMS-SUBMIT PDU for AT+CMGS in PDU mode. The SMS
+// center of the SIM is used. Text is encoded with the GSM 7 bit alphabet if
+// possible, otherwise UCS2, and must fit in a single SMS. The PDU is returned
+// as hex, with the length in octets that is passed to AT+CMGS (which does not
+// include the SMS center).
+func EncodeSMSPDU(to, text string) (string, int, error) {
+	number := strings.TrimSpace(to)
+	addrType := byte(0x81)
+
+	if strings.HasPrefix(number, "+") {
+		addrType = 0x91
+		number = number[1:]
+	}
+
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, number)
+
+	if digits == "" {
+		return "", 0, fmt.Errorf("invalid phone number: %v", to)
+	}
+
+	var dcs byte
+	var udl int
+	var ud []byte
+
+	septets, ok := gsm7Encode(text)
+	if ok {
+		if len(septets) > smsMaxGSM7 {
+			return "", 0, errors.New("text is too long for a SMS")
+		}
+		dcs = dcsGSM7
+		udl = len(septets)
+		ud = packSeptets(septets)
+	} else {
+		units := utf16.Encode([]rune(text))
+		if len(units) > smsMaxUCS2 {
+			return "", 0, errors.New("text is too long for a SMS")
+		}
+		dcs = dcsUCS2
+		for _, u := range units {
+			ud = append(ud, byte(u>>8), byte(u))
+		}
+		udl = len(ud)
+	}
+
+	pdu := []byte{
+		0x00,              // SMS center from SIM
+		0x11,              // SMS-SUBMIT, relative validity period
+		0x00,              // message reference set by modem
+		byte(len(digits)), // address length in digits
+		addrType,
+	}
+
+	pdu = append(pdu, encodeSemiOctets(digits)...)
+	pdu = append(pdu,
+		0x00, // protocol ID
+		dcs,
+		0xaa, // validity period of 4 days
+		byte(udl),
+	)
+	pdu = append(pdu, ud...)
+
+	return strings.ToUpper(hex.EncodeToString(pdu)), len(pdu) - 1, nil
+}
+
+// DecodeSMSPDU decodes a received SMS-DELIVER PDU, as listed by AT+CMGL in
+// PDU mode. The user data header of concatenated messages is skipped, and
+// the parts are returned as separate messages.
+func DecodeSMSPDU(pduHex string) (SMS, error) {
+	pdu, err := hex.DecodeString(strings.TrimSpace(pduHex))
+	if err != nil {
+		return SMS{}, fmt.Errorf("invalid PDU: %w", err)
+	}
+
+	errShort := errors.New("PDU is too short")
+
+	i := 0
+	next := func(n int) ([]byte, error) {
+		if i+n > len(pdu) {
+			return nil, errShort
+		}
+		ret := pdu[i : i+n]
+		i += n
+		return ret, nil
+	}
+
+	b, err := next(1)
+	if err != nil {
+		return SMS{}, err
+	}
+
+	// skip SMS center
+	if _, err := next(int(b[0])); err != nil {
+		return SMS{}, err
+	}
+
+	b, err = next(3)
+	if err != nil {
+		return SMS{}, err
+	}
+
+	firstOctet := b[0]
+	if firstOctet&0x03 != 0 {
+		return SMS{}, errors.New("PDU is not SMS-DELIVER")
+	}
+
+	addrDigits := int(b[1])
+	addrType := b[2]
+
+	addr, err := next((addrDigits + 1) / 2)
+	if err != nil {
+		return SMS{}, err
+	}
+
+	var ret SMS
+
+	switch addrType & 0x70 {
+	case 0x50:
+		// alphanumeric sender
+		ret.From = gsm7Decode(unpackSeptets(addr, addrDigits*4/7))
+	case 0x10:
+		ret.From = "+" + decodeSemiOctets(addr, addrDigits)
+	default:
+		ret.From = decodeSemiOctets(addr, addrDigits)
+	}
+
+	b, err = next(2)
+	if err != nil {
+		return SMS{}, err
+	}
+
+	dcs := b[1]
+
+	scts, err := next(7)
+	if err != nil {
+		return SMS{}, err
+	}
+
+	quarters := int(scts[6]&0x07)*10 + int(scts[6]>>4)
+	if scts[6]&0x08 != 0 {
+		quarters = -quarters
+	}
+
+	ret.Time = time.Date(2000+semiOctet(scts[0]), time.Month(semiOctet(scts[1])),
+		semiOctet(scts[2]), semiOctet(scts[3]), semiOctet(scts[4]),
+		semiOctet(scts[5]), 0, time.FixedZone("", quarters*15*60))
+
+	b, err = next(1)
+	if err != nil {
+		return SMS{}, err
+	}
+
+	udl := int(b[0])
+	ud := pdu[i:]
+
+	udhLen := 0
+	if firstOctet&0x40 != 0 && len(ud) > 0 {
+		udhLen = int(ud[0]) + 1
+	}
+
+	alphabet := byte(dcsGSM7)
+
+	switch {
+	case dcs&0xc0 == 0x00:
+		alphabet = dcs & 0x0c
+	case dcs&0xf0 == 0xe0:
+		alphabet = dcsUCS2
+	case dcs&0xf0 == 0xf0:
+		alphabet = dcs & 0x04
+	}
+
+	switch alphabet {
+	case dcsUCS2, dcs8Bit:
+		if udl > len(ud) {
+			return SMS{}, errShort
+		}
+
+		if udhLen > udl {
+			return SMS{}, errors.New("invalid user data header")
+		}
+
+		ud = ud[udhLen:udl]
+
+		if alphabet == dcs8Bit {
+			ret.Text = string(ud)
+			break
+		}
+
+		units := make([]uint16, len(ud)/2)
+		for j := range units {
+			units[j] = uint16(ud[2*j])<<8 | uint16(ud[2*j+1])
+		}
+
+		ret.Text = string(utf16.Decode(units))
+
+	default:
+		septets := unpackSeptets(ud, udl)
+
+		// the header is padded to a septet boundary
+		skip := (udhLen*8 + 6) / 7
+		if skip > len(septets) {
+			return SMS{}, errors.New("invalid user data header")
+		}
+
+		ret.Text = gsm7Decode(septets[skip:])
+	}
+
+	return ret, nil
+}
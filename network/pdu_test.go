@@ -0,0 +1,128 @@
+package network
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGSM7Alphabet(t *testing.T) {
+	if len(gsm7) != 128 {
+		t.Fatal("GSM 7 bit alphabet has wrong length: ", len(gsm7))
+	}
+
+	text := "Tank [3] is at 5€ {low}"
+
+	septets, ok := gsm7Encode(text)
+	if !ok {
+		t.Fatal("text should be GSM 7 bit")
+	}
+
+	packed := packSeptets(septets)
+
+	if d := gsm7Decode(unpackSeptets(packed, len(septets))); d != text {
+		t.Errorf("round trip failed: %q", d)
+	}
+
+	if _, ok := gsm7Encode("Привет"); ok {
+		t.Error("cyrillic text is not GSM 7 bit")
+	}
+}
+
+func TestEncodeSMSPDU(t *testing.T) {
+	pdu, length, err := EncodeSMSPDU("+46708251358", "hellohello")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := "0011000B916407281553F80000AA0AE8329BFD4697D9EC37"
+
+	if pdu != exp || length != 23 {
+		t.Errorf("expected %v (23), got %v (%v)", exp, pdu, length)
+	}
+
+	pdu, length, err = EncodeSMSPDU("5551234", "Ж")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp = "0011000781551532F40008AA020416"
+
+	if pdu != exp || length != 14 {
+		t.Errorf("expected %v (14), got %v (%v)", exp, pdu, length)
+	}
+
+	_, _, err = EncodeSMSPDU("+15555550100", strings.Repeat("a", 161))
+	if err == nil {
+		t.Error("expected error for long text")
+	}
+
+	_, _, err = EncodeSMSPDU("bob", "hi")
+	if err == nil {
+		t.Error("expected error for invalid number")
+	}
+}
+
+func TestDecodeSMSPDU(t *testing.T) {
+	sms, err := DecodeSMSPDU(
+		"07917283010010F5040BC87238880900F10000993092516195800AE8329BFD4697D9EC37")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sms.From != "27838890001" || sms.Text != "hellohello" {
+		t.Errorf("wrong SMS: %+v", sms)
+	}
+
+	_, offset := sms.Time.Zone()
+	if sms.Time.Month() != time.March || sms.Time.Day() != 29 ||
+		sms.Time.Hour() != 15 || sms.Time.Second() != 59 || offset != 2*3600 {
+		t.Errorf("wrong time: %v", sms.Time)
+	}
+
+	// UCS2 with a negative time zone
+	sms, err = DecodeSMSPDU(
+		"00040B915155550501F000081290102100000A0800410043004B00E9")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := time.Date(2021, time.September, 1, 12, 0, 0, 0, time.FixedZone("", -5*3600))
+
+	if sms.From != "+15555550100" || sms.Text != "ACKé" || !sms.Time.Equal(exp) {
+		t.Errorf("wrong SMS: %+v", sms)
+	}
+
+	// part of a concatenated message, with a user data header
+	sms, err = DecodeSMSPDU(
+		"00440B915155550501F000001290102100000A0A05000301020182C325")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sms.Text != "ACK" {
+		t.Errorf("wrong concatenated SMS text: %q", sms.Text)
+	}
+
+	_, err = DecodeSMSPDU("00040B91")
+	if err == nil {
+		t.Error("expected error for short PDU")
+	}
+}
+
+func TestSplitSMS(t *testing.T) {
+	parts := splitSMS(strings.Repeat("a", 200))
+	if len(parts) != 2 || len(parts[0]) != 160 || len(parts[1]) != 40 {
+		t.Errorf("wrong GSM 7 bit parts: %v", len(parts))
+	}
+
+	parts = splitSMS(strings.Repeat("Ж", 100))
+	if len(parts) != 2 || len([]rune(parts[0])) != 70 {
+		t.Errorf("wrong UCS2 parts: %v", len(parts))
+	}
+
+	parts = splitSMS("")
+	if len(parts) != 1 {
+		t.Errorf("wrong parts for empty text: %v", len(parts))
+	}
+}
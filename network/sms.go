@@ -0,0 +1,296 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SMS is a SMS message received by a modem. Index is the location of the
+// message in the modem storage.
+type SMS struct {
+	Index int
+	From  string
+	Time  time.Time
+	Text  string
+}
+
+// ctrlZ ends the text of a SMS
+const ctrlZ = "\x1a"
+
+// CmdSMSFormat selects PDU or text mode for SMS commands (AT+CMGF)
+func CmdSMSFormat(port io.ReadWriter, pdu bool) error {
+	if pdu {
+		return CmdOK(port, "AT+CMGF=0")
+	}
+
+	return CmdOK(port, "AT+CMGF=1")
+}
+
+// cmdPrompt runs a command that prompts for data (like AT+CMGS), sends the
+// data terminated by Ctrl-Z, and returns the response. It is not retried as
+// the command may have been run.
+func cmdPrompt(port io.ReadWriter, cmd, data string) (string, error) {
+	if DebugAtCommands {
+		log.Println("Modem Tx: ", cmd)
+	}
+
+	_, err := port.Write([]byte(cmd + "\r"))
+	if err != nil {
+		return "", err
+	}
+
+	buf := make([]byte, 100)
+
+	n, err := port.Read(buf)
+	if err != nil {
+		return "", fmt.Errorf("no prompt for %v: %w", cmd, err)
+	}
+
+	prompt := string(buf[:n])
+
+	if !strings.Contains(prompt, ">") {
+		return "", fmt.Errorf("no prompt for %v: %v", cmd, strings.TrimSpace(prompt))
+	}
+
+	if DebugAtCommands {
+		log.Println("Modem Tx: ", data)
+	}
+
+	_, err = port.Write([]byte(data + ctrlZ))
+	if err != nil {
+		return "", err
+	}
+
+	n, err = port.Read(buf)
+	if err != nil {
+		return "", fmt.Errorf("no response to %v: %w", cmd, err)
+	}
+
+	resp := strings.TrimSpace(string(buf[:n]))
+
+	if DebugAtCommands {
+		log.Println("Modem Rx: ", resp)
+	}
+
+	return resp, nil
+}
+
+// +CMGS: 12
+// +CMS ERROR: 500
+var reCmgs = regexp.MustCompile(`\+CMGS:\s*(\d+)`)
+
+func checkRespCmgs(resp string) error {
+	if !reCmgs.MatchString(resp) {
+		return fmt.Errorf("Error sending SMS: %v", resp)
+	}
+
+	return checkRespOK(resp)
+}
+
+// CmdSendSMS sends a SMS in text mode (AT+CMGS)
+func CmdSendSMS(port io.ReadWriter, to, text string) error {
+	resp, err := cmdPrompt(port, "AT+CMGS=\""+to+"\"", text)
+	if err != nil {
+		return err
+	}
+
+	return checkRespCmgs(resp)
+}
+
+// CmdSendSMSPDU sends a SMS in PDU mode (AT+CMGS). The text must fit in a
+// single SMS.
+func CmdSendSMSPDU(port io.ReadWriter, to, text string) error {
+	pdu, length, err := EncodeSMSPDU(to, text)
+	if err != nil {
+		return err
+	}
+
+	resp, err := cmdPrompt(port, fmt.Sprintf("AT+CMGS=%v", length), pdu)
+	if err != nil {
+		return err
+	}
+
+	return checkRespCmgs(resp)
+}
+
+// +CMGL: 1,"REC UNREAD","+15555550100",,"21/09/01,12:00:00-20"
+var reCmglText = regexp.MustCompile(`\+CMGL:\s*(\d+),"([^"]*)","([^"]*)",[^,]*,"([^"]*)"`)
+
+// +CMGL: 1,0,,24
+var reCmglPDU = regexp.MustCompile(`\+CMGL:\s*(\d+),(\d+),[^,]*,(\d+)`)
+
+// parseSMSTime parses the time of a SMS in text mode, for example
+// 21/09/01,12:00:00-20. The time zone is in quarters of an hour.
+func parseSMSTime(s string) (time.Time, error) {
+	if len(s) < 17 {
+		return time.Time{}, fmt.Errorf("invalid SMS time: %v", s)
+	}
+
+	quarters := 0
+
+	if len(s) > 17 {
+		var err error
+		quarters, err = strconv.Atoi(s[17:])
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid SMS time zone: %v", s)
+		}
+	}
+
+	return time.ParseInLocation("06/01/02,15:04:05", s[:17],
+		time.FixedZone("", quarters*15*60))
+}
+
+// CmdListSMS lists the SMS received by the modem (AT+CMGL). Messages that
+// were sent or stored are not returned.
+func CmdListSMS(port io.ReadWriter, pdu bool) ([]SMS, error) {
+	cmd := `AT+CMGL="ALL"`
+	if pdu {
+		cmd = "AT+CMGL=4"
+	}
+
+	resp, err := cmdRead(port, cmd, 4096)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkRespOK(resp); err != nil {
+		return nil, fmt.Errorf("Error listing SMS: %v", resp)
+	}
+
+	var ret []SMS
+	var current *SMS
+	var body []string
+
+	done := func() {
+		if current != nil {
+			current.Text = strings.TrimSpace(strings.Join(body, "\n"))
+			ret = append(ret, *current)
+		}
+		current = nil
+		body = nil
+	}
+
+	lines := strings.Split(resp, "\n")
+
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], "\r")
+
+		if pdu {
+			matches := reCmglPDU.FindStringSubmatch(line)
+			if len(matches) < 4 || i+1 >= len(lines) {
+				continue
+			}
+
+			i++
+
+			// 0 is received unread, 1 is received read
+			if matches[2] != "0" && matches[2] != "1" {
+				continue
+			}
+
+			sms, err := DecodeSMSPDU(lines[i])
+			if err != nil {
+				log.Println("Error decoding SMS PDU: ", err)
+				continue
+			}
+
+			sms.Index, _ = strconv.Atoi(matches[1])
+			ret = append(ret, sms)
+			continue
+		}
+
+		matches := reCmglText.FindStringSubmatch(line)
+		if len(matches) >= 5 {
+			done()
+
+			if !strings.HasPrefix(matches[2], "REC") {
+				continue
+			}
+
+			index, _ := strconv.Atoi(matches[1])
+			t, err := parseSMSTime(matches[4])
+			if err != nil {
+				log.Println("Error parsing SMS time: ", err)
+			}
+
+			current = &SMS{Index: index, From: matches[3], Time: t}
+			continue
+		}
+
+		if strings.TrimSpace(line) == "OK" {
+			done()
+			break
+		}
+
+		if current != nil {
+			body = append(body, line)
+		}
+	}
+
+	done()
+
+	return ret, nil
+}
+
+// CmdDeleteSMS deletes a SMS from modem storage (AT+CMGD)
+func CmdDeleteSMS(port io.ReadWriter, index int) error {
+	return CmdOK(port, fmt.Sprintf("AT+CMGD=%v", index))
+}
+
+// SendSMS sends a SMS through the modem. Text that does not fit in a single
+// SMS is sent as multiple messages.
+func (p *CmdPort) SendSMS(to, text string, pdu bool) error {
+	p.Lock()
+	defer p.Unlock()
+
+	err := CmdSMSFormat(p, pdu)
+	if err != nil {
+		return err
+	}
+
+	for _, part := range splitSMS(text) {
+		if pdu {
+			err = CmdSendSMSPDU(p, to, part)
+		} else {
+			err = CmdSendSMS(p, to, part)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ReadSMS returns the SMS received by the modem, and deletes them from modem
+// storage
+func (p *CmdPort) ReadSMS(pdu bool) ([]SMS, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	err := CmdSMSFormat(p, pdu)
+	if err != nil {
+		return nil, err
+	}
+
+	messages, err := CmdListSMS(p, pdu)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, m := range messages {
+		err := CmdDeleteSMS(p, m.Index)
+		if err != nil {
+			return messages, errors.New("Error deleting SMS: " + err.Error())
+		}
+	}
+
+	return messages, nil
+}
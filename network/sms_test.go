@@ -0,0 +1,237 @@
+package network
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// exchange is a command sent to a modem and the response of the modem
+type exchange struct {
+	cmd  string
+	resp string
+}
+
+// fakeModem is a scripted serial port. Commands written to the port must
+// match the script, and the scripted responses are returned by Read.
+type fakeModem struct {
+	t      *testing.T
+	lock   sync.Mutex
+	script []exchange
+	buf    strings.Builder
+	resp   chan string
+	closed bool
+}
+
+func newFakeModem(t *testing.T, script []exchange) *fakeModem {
+	return &fakeModem{t: t, script: script, resp: make(chan string, 10)}
+}
+
+func (m *fakeModem) Write(b []byte) (int, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.buf.Write(b)
+	cmd := m.buf.String()
+
+	if !strings.HasSuffix(cmd, "\r") && !strings.HasSuffix(cmd, ctrlZ) {
+		return len(b), nil
+	}
+
+	m.buf.Reset()
+	cmd = strings.TrimRight(cmd, "\r"+ctrlZ)
+
+	if len(m.script) == 0 {
+		m.t.Errorf("unexpected command: %q", cmd)
+		return len(b), nil
+	}
+
+	e := m.script[0]
+	m.script = m.script[1:]
+
+	if cmd != e.cmd {
+		m.t.Errorf("expected command %q, got %q", e.cmd, cmd)
+	}
+
+	m.resp <- e.resp
+
+	return len(b), nil
+}
+
+func (m *fakeModem) Read(b []byte) (int, error) {
+	select {
+	case r := <-m.resp:
+		return copy(b, r), nil
+	case <-time.After(time.Second):
+		return 0, io.EOF
+	}
+}
+
+func (m *fakeModem) Close() error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.closed {
+		return errors.New("port already closed")
+	}
+
+	m.closed = true
+	return nil
+}
+
+// done checks that all the scripted commands were sent
+func (m *fakeModem) done() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	for _, e := range m.script {
+		m.t.Errorf("command not sent: %q", e.cmd)
+	}
+}
+
+func TestCmdSendSMS(t *testing.T) {
+	m := newFakeModem(t, []exchange{
+		{`AT+CMGS="+15555550100"`, "\r\n> "},
+		{"hello", "\r\n+CMGS: 12\r\n\r\nOK\r\n"},
+		{"AT+CMGS=23", "\r\n> "},
+		{"0011000B916407281553F80000AA0AE8329BFD4697D9EC37", "\r\n+CMGS: 13\r\n\r\nOK\r\n"},
+		{`AT+CMGS="+15555550100"`, "\r\n> "},
+		{"hello", "\r\n+CMS ERROR: 500\r\n"},
+	})
+
+	err := CmdSendSMS(m, "+15555550100", "hello")
+	if err != nil {
+		t.Error("text mode: ", err)
+	}
+
+	err = CmdSendSMSPDU(m, "+46708251358", "hellohello")
+	if err != nil {
+		t.Error("PDU mode: ", err)
+	}
+
+	err = CmdSendSMS(m, "+15555550100", "hello")
+	if err == nil {
+		t.Error("expected error")
+	}
+
+	m.done()
+}
+
+func TestCmdListSMS(t *testing.T) {
+	m := newFakeModem(t, []exchange{
+		{`AT+CMGL="ALL"`, "\r\n" +
+			`+CMGL: 1,"REC UNREAD","+15555550100",,"21/09/01,12:00:00-20"` + "\r\n" +
+			"STATUS pump\r\n" +
+			`+CMGL: 2,"STO UNSENT","+15555550100",,""` + "\r\n" +
+			"not received\r\n" +
+			`+CMGL: 3,"REC READ","+15555550101",,"21/09/01,12:05:00+00"` + "\r\n" +
+			"SET pump\r\non\r\n" +
+			"\r\nOK\r\n"},
+		{"AT+CMGL=4", "\r\n" +
+			"+CMGL: 0,1,,36\r\n" +
+			"07917283010010F5040BC87238880900F10000993092516195800AE8329BFD4697D9EC37\r\n" +
+			"+CMGL: 4,2,,23\r\n" +
+			"0011000B916407281553F80000AA0AE8329BFD4697D9EC37\r\n" +
+			"\r\nOK\r\n"},
+	})
+
+	messages, err := CmdListSMS(m, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(messages) != 2 {
+		t.Fatalf("expected 2 messages, got %+v", messages)
+	}
+
+	exp := time.Date(2021, time.September, 1, 12, 0, 0, 0, time.FixedZone("", -5*3600))
+
+	if messages[0].Index != 1 || messages[0].From != "+15555550100" ||
+		messages[0].Text != "STATUS pump" || !messages[0].Time.Equal(exp) {
+		t.Errorf("wrong message: %+v", messages[0])
+	}
+
+	if messages[1].Index != 3 || messages[1].Text != "SET pump\non" {
+		t.Errorf("wrong message: %+v", messages[1])
+	}
+
+	messages, err = CmdListSMS(m, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(messages) != 1 || messages[0].Index != 0 || messages[0].Text != "hellohello" {
+		t.Errorf("wrong PDU messages: %+v", messages)
+	}
+
+	m.done()
+}
+
+func TestCmdPortSMS(t *testing.T) {
+	m := newFakeModem(t, []exchange{
+		{"AT+CMGF=1", "\r\nOK\r\n"},
+		{`AT+CMGL="ALL"`, "\r\n" +
+			`+CMGL: 5,"REC UNREAD","+15555550100",,"21/09/01,12:00:00-20"` + "\r\n" +
+			"ACK\r\n" +
+			"\r\nOK\r\n"},
+		{"AT+CMGD=5", "\r\nOK\r\n"},
+		{"AT+CMGF=0", "\r\nOK\r\n"},
+		{"AT+CMGS=23", "\r\n> "},
+		{"0011000B916407281553F80000AA0AE8329BFD4697D9EC37", "\r\n+CMGS: 13\r\n\r\nOK\r\n"},
+	})
+
+	opens := 0
+
+	openSerialSave := openSerial
+	openSerial = func(name string) (io.ReadWriteCloser, error) {
+		opens++
+		return m, nil
+	}
+	defer func() { openSerial = openSerialSave }()
+
+	p1, err := OpenCmdPort("/dev/ttyTest")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p2, err := OpenCmdPort("/dev/ttyTest")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p1 != p2 || opens != 1 {
+		t.Fatal("port is not shared")
+	}
+
+	messages, err := p1.ReadSMS(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(messages) != 1 || messages[0].Text != "ACK" {
+		t.Errorf("wrong messages: %+v", messages)
+	}
+
+	err = p2.SendSMS("+46708251358", "hellohello", true)
+	if err != nil {
+		t.Error(err)
+	}
+
+	p1.Close()
+
+	if m.closed {
+		t.Error("port closed while still in use")
+	}
+
+	p2.Close()
+	p2.Close()
+
+	if !m.closed {
+		t.Error("port not closed")
+	}
+
+	m.done()
+}
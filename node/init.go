@@ -32,6 +32,9 @@ func Init(nc *natsgo.Conn) error {
 		LastName:  "user",
 		Email:     "admin@admin.com",
 		Pass:      "admin",
+		// the default password must be changed before the admin can make
+		// other changes
+		PassChange: true,
 	}
 
 	return nats.SendNodePoints(nc, admin.ID, admin.ToPoints(), true)
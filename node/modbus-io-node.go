@@ -1,8 +1,6 @@
 package node
 
 import (
-	"errors"
-
 	"github.com/simpleiot/simpleiot/data"
 )
 
@@ -33,45 +31,19 @@ func NewModbusIONode(busType string, node *data.NodeEdge) (*ModbusIONode, error)
 		nodeID: node.ID,
 	}
 
-	var ok bool
-
-	ret.id, ok = node.Points.ValueInt("", data.PointTypeID, 0)
-	if busType == data.PointValueClient && !ok {
-		if busType == data.PointValueServer {
-			return nil, errors.New("Must define modbus ID")
-		}
+	err := data.ValidateNode(data.NodeTypeModbusIO, node.Points)
+	if err != nil {
+		return nil, err
 	}
 
+	ret.id, _ = node.Points.ValueInt("", data.PointTypeID, 0)
 	ret.description, _ = node.Points.Text("", data.PointTypeDescription, 0)
-
-	ret.address, ok = node.Points.ValueInt("", data.PointTypeAddress, 0)
-	if !ok {
-		return nil, errors.New("Must define modbus address")
-	}
-
-	ret.modbusIOType, ok = node.Points.Text("", data.PointTypeModbusIOType, 0)
-	if !ok {
-		return nil, errors.New("Must define modbus IO type")
-	}
-
+	ret.address, _ = node.Points.ValueInt("", data.PointTypeAddress, 0)
+	ret.modbusIOType, _ = node.Points.Text("", data.PointTypeModbusIOType, 0)
 	ret.readOnly, _ = node.Points.ValueBool("", data.PointTypeReadOnly, 0)
-
-	if ret.modbusIOType == data.PointValueModbusInputRegister ||
-		ret.modbusIOType == data.PointValueModbusHoldingRegister {
-		ret.modbusDataType, ok = node.Points.Text("", data.PointTypeDataFormat, 0)
-		if !ok {
-			return nil, errors.New("Data format must be specified")
-		}
-		ret.scale, ok = node.Points.Value("", data.PointTypeScale, 0)
-		if !ok {
-			return nil, errors.New("Must define modbus scale")
-		}
-		ret.offset, ok = node.Points.Value("", data.PointTypeOffset, 0)
-		if !ok {
-			return nil, errors.New("Must define modbus offset")
-		}
-	}
-
+	ret.modbusDataType, _ = node.Points.Text("", data.PointTypeDataFormat, 0)
+	ret.scale, _ = node.Points.Value("", data.PointTypeScale, 0)
+	ret.offset, _ = node.Points.Value("", data.PointTypeOffset, 0)
 	ret.value, _ = node.Points.Value("", data.PointTypeValue, 0)
 	ret.valueSet, _ = node.Points.Value("", data.PointTypeValueSet, 0)
 	ret.errorCount, _ = node.Points.ValueInt("", data.PointTypeErrorCount, 0)
@@ -1,7 +1,6 @@
 package node
 
 import (
-	"errors"
 	"fmt"
 	"strconv"
 
@@ -33,59 +32,25 @@ func NewModbusNode(node data.NodeEdge) (*ModbusNode, error) {
 		nodeID: node.ID,
 	}
 
-	var ok bool
-
-	ret.busType, ok = node.Points.Text("", data.PointTypeClientServer, 0)
-	if !ok {
-		return nil, errors.New("Must define modbus client/server")
+	err := data.ValidateNode(data.NodeTypeModbus, node.Points)
+	if err != nil {
+		return nil, err
 	}
 
-	ret.protocol, ok = node.Points.Text("", data.PointTypeProtocol, 0)
-	if !ok {
-		return nil, errors.New("Must define modbus protocol")
-	}
+	ret.busType, _ = node.Points.Text("", data.PointTypeClientServer, 0)
+	ret.protocol, _ = node.Points.Text("", data.PointTypeProtocol, 0)
+	ret.portName, _ = node.Points.Text("", data.PointTypePort, 0)
+	ret.uri, _ = node.Points.Text("", data.PointTypeURI, 0)
+	ret.pollPeriod, _ = node.Points.ValueInt("", data.PointTypePollPeriod, 0)
 
 	if ret.protocol == data.PointValueRTU {
-		ret.portName, ok = node.Points.Text("", data.PointTypePort, 0)
-		if !ok {
-			return nil, errors.New("Must define modbus port name")
-		}
-
-		baud, ok := node.Points.Text("", data.PointTypeBaud, 0)
-		if !ok {
-			return nil, errors.New("Must define modbus baud")
-		}
-
-		var err error
+		baud, _ := node.Points.Text("", data.PointTypeBaud, 0)
 		ret.baud, err = strconv.Atoi(baud)
-
 		if err != nil {
 			return nil, fmt.Errorf("Invalid baud: %v", baud)
 		}
 	}
 
-	if ret.protocol == data.PointValueTCP {
-		switch ret.busType {
-		case data.PointValueClient:
-			ret.uri, ok = node.Points.Text("", data.PointTypeURI, 0)
-			if !ok {
-				return nil, errors.New("Must define modbus URI")
-			}
-		case data.PointValueServer:
-			ret.portName, ok = node.Points.Text("", data.PointTypePort, 0)
-			if !ok {
-				return nil, errors.New("Must define modbus port name")
-			}
-		default:
-			return nil, fmt.Errorf("Invalid bus type: %v", ret.busType)
-		}
-	}
-
-	ret.pollPeriod, ok = node.Points.ValueInt("", data.PointTypePollPeriod, 0)
-	if ret.busType == data.PointValueClient && !ok {
-		return nil, errors.New("Must define modbus polling period for client devices")
-	}
-
 	ret.debugLevel, _ = node.Points.ValueInt("", data.PointTypeDebug, 0)
 	ret.errorCount, _ = node.Points.ValueInt("", data.PointTypeErrorCount, 0)
 	ret.errorCountCRC, _ = node.Points.ValueInt("", data.PointTypeErrorCountCRC, 0)
@@ -95,11 +60,7 @@ func NewModbusNode(node data.NodeEdge) (*ModbusNode, error) {
 	ret.errorCountEOFReset, _ = node.Points.ValueBool("", data.PointTypeErrorCountEOFReset, 0)
 
 	if ret.busType == data.PointValueServer {
-		var ok bool
-		ret.id, ok = node.Points.ValueInt("", data.PointTypeID, 0)
-		if !ok {
-			return nil, errors.New("Must define modbus ID for server bus")
-		}
+		ret.id, _ = node.Points.ValueInt("", data.PointTypeID, 0)
 	}
 
 	return &ret, nil
@@ -1,10 +1,8 @@
 package node
 
 import (
-	"bytes"
 	"fmt"
 	"log"
-	"text/template"
 	"time"
 
 	"github.com/google/uuid"
@@ -12,6 +10,7 @@ import (
 
 	"github.com/simpleiot/simpleiot/data"
 	"github.com/simpleiot/simpleiot/nats"
+	"github.com/simpleiot/simpleiot/system"
 )
 
 // Manager is responsible for maintaining node state, running rules, etc
@@ -69,6 +68,9 @@ func (m *Manager) Init() error {
 			LastName:  "user",
 			Email:     "admin@admin.com",
 			Pass:      "admin",
+			// the default password must be changed before the admin can
+			// make other changes
+			PassChange: true,
 		}
 
 		points := admin.ToPoints()
@@ -91,9 +93,60 @@ func (m *Manager) Init() error {
 	m.modbusManager = NewModbusManager(m.nc, m.rootNodeID)
 	m.upstreamManager = NewUpstreamManager(m.nc, m.rootNodeID)
 
+	err = m.sendStartEvents()
+	if err != nil {
+		log.Println("Error sending start events: ", err)
+	}
+
 	return nil
 }
 
+// sendStartEvents records an app start event, and a system start event if
+// one has not already been recorded since the system booted. The boot ID of
+// the last system start is stored in the root node, as the boot time moves
+// when the system clock is set.
+func (m *Manager) sendStartEvents() error {
+	bootID, err := system.BootID()
+	if err == nil {
+		root, err := nats.GetNode(m.nc, m.rootNodeID, "skip")
+		if err != nil {
+			return fmt.Errorf("Error getting root node: %w", err)
+		}
+
+		if last, _ := root.Points.Text("", data.PointTypeBootID, 0); last != bootID {
+			bootTime, err := system.BootTime()
+			if err != nil {
+				bootTime = time.Now()
+			}
+
+			err = nats.SendEvent(m.nc, m.rootNodeID, data.Event{
+				Time:    bootTime,
+				Type:    data.EventTypeStartSystem,
+				Level:   data.EventLevelInfo,
+				Message: "system started",
+			}, true)
+			if err != nil {
+				return fmt.Errorf("Error sending system start event: %w", err)
+			}
+
+			err = nats.SendNodePoint(m.nc, m.rootNodeID, data.Point{
+				Time: time.Now(),
+				Type: data.PointTypeBootID,
+				Text: bootID,
+			}, true)
+			if err != nil {
+				return fmt.Errorf("Error recording boot ID: %w", err)
+			}
+		}
+	}
+
+	return nats.SendEvent(m.nc, m.rootNodeID, data.Event{
+		Type:    data.EventTypeStartApp,
+		Level:   data.EventLevelInfo,
+		Message: "app started",
+	}, true)
+}
+
 // Run manager
 func (m *Manager) Run() {
 	go func() {
@@ -111,76 +164,4 @@ func (m *Manager) Run() {
 	}()
 
 	select {}
-
-	/* the following code needs redone, so commenting out for now
-	for {
-		// TODO: this will not scale and needs to be made event driven
-		nodes, err := m.db.Nodes()
-		if err != nil {
-			log.Println("Error getting nodes: ", err)
-			time.Sleep(10 * time.Second)
-			continue
-		}
-
-		for _, node := range nodes {
-			// update node state
-			state, changed := node.GetState()
-			if changed {
-				p := data.Point{
-					Time: time.Now(),
-					Type: data.PointTypeSysState,
-					Text: state,
-				}
-
-				err := nats.SendNodePoint(m.nc, node.ID, p, false)
-				if err != nil {
-					log.Println("Error updating node state: ", err)
-				}
-			}
-		}
-
-		time.Sleep(30 * time.Second)
-	}
-	*/
-}
-
-type nodeTemplateData struct {
-	ID          string
-	Description string
-	Ios         map[string]float64
-}
-
-func renderNotifyTemplate(node *data.Node, msgTemplate string) (string, error) {
-	// build map of IO values so they are easy to reference by type or ID in template
-	dtd := nodeTemplateData{
-		ID:          node.ID,
-		Description: node.Desc(),
-		Ios:         make(map[string]float64),
-	}
-
-	for _, io := range node.Points {
-		if io.Type != "" {
-			dtd.Ios[io.Type] = io.Value
-		}
-		if io.ID != "" {
-			dtd.Ios[io.ID] = io.Value
-		}
-	}
-
-	buf := new(bytes.Buffer)
-
-	tmpl, err := template.New("msg").Parse(msgTemplate)
-
-	if err != nil {
-		return "", err
-	}
-
-	err = tmpl.Execute(buf, dtd)
-
-	if err != nil {
-		return "", err
-	}
-
-	return buf.String(), nil
-
 }
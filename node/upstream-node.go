@@ -1,8 +1,6 @@
 package node
 
 import (
-	"errors"
-
 	"github.com/simpleiot/simpleiot/data"
 )
 
@@ -16,7 +14,10 @@ type UpstreamNode struct {
 
 // NewUpstreamNode converts a node to UpstreamNode
 func NewUpstreamNode(node data.NodeEdge) (*UpstreamNode, error) {
-	var ok bool
+	err := data.ValidateNode(data.NodeTypeUpstream, node.Points)
+	if err != nil {
+		return nil, err
+	}
 
 	ret := &UpstreamNode{
 		ID: node.ID,
@@ -24,11 +25,7 @@ func NewUpstreamNode(node data.NodeEdge) (*UpstreamNode, error) {
 
 	ret.Description, _ = node.Points.Text("", data.PointTypeDescription, 0)
 	ret.AuthToken, _ = node.Points.Text("", data.PointTypeAuthToken, 0)
-
-	ret.URI, ok = node.Points.Text("", data.PointTypeURI, 0)
-	if !ok {
-		return nil, errors.New("URI must be specified for upstream connection")
-	}
+	ret.URI, _ = node.Points.Text("", data.PointTypeURI, 0)
 
 	return ret, nil
 }
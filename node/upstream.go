@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 	"time"
 
 	natsgo "github.com/nats-io/nats.go"
@@ -24,6 +25,10 @@ type Upstream struct {
 	subUpEdgePoints    map[string]*natsgo.Subscription
 	subLocalNodePoints *natsgo.Subscription
 	subLocalEdgePoints *natsgo.Subscription
+	subLocalEvents     *natsgo.Subscription
+	// origin IDs (root node IDs) of the local and upstream instances
+	origin   string
+	originUp string
 }
 
 // NewUpstream is used to create a new upstream connection
@@ -64,7 +69,22 @@ func NewUpstream(nc *natsgo.Conn, node data.NodeEdge) (*Upstream, error) {
 		return nil, fmt.Errorf("Error connection to upstream NATS: %v", err)
 	}
 
-	up.subLocalNodePoints, err = nc.Subscribe(nats.SubjectNodeAllPoints(), func(msg *natsgo.Msg) {
+	rootNode, err := nats.GetNode(nc, "root", "")
+	if err != nil {
+		return nil, err
+	}
+
+	rootNodeUp, err := nats.GetNode(up.ncUp, "root", "")
+	if err != nil {
+		return nil, fmt.Errorf("Error getting upstream root node: %v", err)
+	}
+
+	up.origin = rootNode.ID
+	up.originUp = rootNodeUp.ID
+
+	// points are forwarded after they are stored, so they carry the clock
+	// and origin they were stored with
+	up.subLocalNodePoints, err = nc.Subscribe(nats.SubjectNodeAllStored(), func(msg *natsgo.Msg) {
 		nodeID, points, err := nats.DecodeNodePointsMsg(msg)
 
 		if err != nil {
@@ -72,6 +92,11 @@ func NewUpstream(nc *natsgo.Conn, node data.NodeEdge) (*Upstream, error) {
 			return
 		}
 
+		points = skipOrigin(points, up.originUp)
+		if len(points) == 0 {
+			return
+		}
+
 		err = nats.SendNodePoints(up.ncUp, nodeID, points, false)
 
 		if err != nil {
@@ -79,7 +104,25 @@ func NewUpstream(nc *natsgo.Conn, node data.NodeEdge) (*Upstream, error) {
 		}
 	})
 
-	up.subLocalEdgePoints, err = nc.Subscribe(nats.SubjectEdgeAllPoints(), func(msg *natsgo.Msg) {
+	up.subLocalEvents, err = nc.Subscribe(nats.SubjectNodeAllEvents(), func(msg *natsgo.Msg) {
+		chunks := strings.Split(msg.Subject, ".")
+		if len(chunks) < 3 {
+			log.Println("Error in event subject: ", msg.Subject)
+			return
+		}
+
+		nodeID := chunks[1]
+		if nodeID == "root" {
+			nodeID = up.origin
+		}
+
+		err := up.ncUp.Publish(nats.SubjectNodeEvent(nodeID), msg.Data)
+		if err != nil {
+			log.Println("Error sending event to remote system: ", err)
+		}
+	})
+
+	up.subLocalEdgePoints, err = nc.Subscribe(nats.SubjectEdgeAllStored(), func(msg *natsgo.Msg) {
 		nodeID, parentID, points, err := nats.DecodeEdgePointsMsg(msg)
 
 		if err != nil {
@@ -87,6 +130,11 @@ func NewUpstream(nc *natsgo.Conn, node data.NodeEdge) (*Upstream, error) {
 			return
 		}
 
+		points = skipOrigin(points, up.originUp)
+		if len(points) == 0 {
+			return
+		}
+
 		err = nats.SendEdgePoints(up.ncUp, nodeID, parentID, points, false)
 
 		if err != nil {
@@ -110,12 +158,6 @@ func NewUpstream(nc *natsgo.Conn, node data.NodeEdge) (*Upstream, error) {
 		}
 	})
 
-	rootNode, err := nats.GetNode(nc, "root", "")
-
-	if err != nil {
-		return nil, err
-	}
-
 	var watchNode func(node data.NodeEdge) error
 
 	watchNode = func(node data.NodeEdge) error {
@@ -167,6 +209,19 @@ func NewUpstream(nc *natsgo.Conn, node data.NodeEdge) (*Upstream, error) {
 	return up, nil
 }
 
+// skipOrigin returns the points that did not come from the instance with
+// the given origin, so points received from an instance are not sent back
+// to it.
+func skipOrigin(points data.Points, origin string) data.Points {
+	var ret data.Points
+	for _, p := range points {
+		if p.Origin != origin {
+			ret = append(ret, p)
+		}
+	}
+	return ret
+}
+
 func (up *Upstream) addUpstreamSub(node data.NodeEdge) error {
 	err := up.addUpstreamNodeSub(node.ID)
 	if err != nil {
@@ -190,7 +245,7 @@ func (up *Upstream) addUpstreamNodeSub(nodeID string) error {
 	}
 
 	// create subscription
-	subject := nats.SubjectNodePoints(nodeID)
+	subject := nats.SubjectNodeStored(nodeID)
 	sub, err := up.ncUp.Subscribe(subject, func(msg *natsgo.Msg) {
 		nodeID, points, err := nats.DecodeNodePointsMsg(msg)
 
@@ -199,6 +254,11 @@ func (up *Upstream) addUpstreamNodeSub(nodeID string) error {
 			return
 		}
 
+		points = skipOrigin(points, up.origin)
+		if len(points) == 0 {
+			return
+		}
+
 		err = nats.SendNodePoints(up.nc, nodeID, points, false)
 
 		if err != nil {
@@ -231,7 +291,7 @@ func (up *Upstream) addUpstreamEdgeSub(nodeID, parentID string) error {
 	}
 
 	// create subscription
-	subject := nats.SubjectEdgePoints(nodeID, parentID)
+	subject := nats.SubjectEdgeStored(nodeID, parentID)
 	sub, err := up.ncUp.Subscribe(subject, func(msg *natsgo.Msg) {
 		nodeID, parentID, points, err := nats.DecodeEdgePointsMsg(msg)
 
@@ -240,6 +300,11 @@ func (up *Upstream) addUpstreamEdgeSub(nodeID, parentID string) error {
 			return
 		}
 
+		points = skipOrigin(points, up.origin)
+		if len(points) == 0 {
+			return
+		}
+
 		err = nats.SendEdgePoints(up.nc, nodeID, parentID, points, false)
 
 		if err != nil {
@@ -257,12 +322,12 @@ func (up *Upstream) addUpstreamEdgeSub(nodeID, parentID string) error {
 }
 
 func (up *Upstream) syncNode(id, parent string) error {
-	nodeLocal, err := nats.GetNode(up.nc, id, parent)
+	nodeLocal, err := nats.GetNodeSync(up.nc, id, parent)
 	if err != nil {
 		return fmt.Errorf("Error getting local node: %v", err)
 	}
 
-	nodeUp, upErr := nats.GetNode(up.ncUp, id, parent)
+	nodeUp, upErr := nats.GetNodeSync(up.ncUp, id, parent)
 	if upErr != nil {
 		if upErr != data.ErrDocumentNotFound {
 			return fmt.Errorf("Error getting upstream root node: %v", upErr)
@@ -297,13 +362,13 @@ func (up *Upstream) syncNode(id, parent string) error {
 				if p.IsMatch(pUp.ID, pUp.Type, pUp.Index) {
 					found = true
 					upstreamProcessed[i] = true
-					if p.Time.After(pUp.Time) {
+					if p.After(pUp) {
 						// need to send point upstream
 						err := nats.SendNodePoint(up.ncUp, nodeUp.ID, p, true)
 						if err != nil {
 							log.Println("Error syncing point upstream: ", err)
 						}
-					} else if p.Time.Before(pUp.Time) {
+					} else if pUp.After(p) {
 						// need to update point locally
 						err := nats.SendNodePoint(up.nc, nodeLocal.ID, pUp, true)
 						if err != nil {
@@ -338,13 +403,13 @@ func (up *Upstream) syncNode(id, parent string) error {
 				if p.IsMatch(pUp.ID, pUp.Type, pUp.Index) {
 					found = true
 					upstreamProcessed[i] = true
-					if p.Time.After(pUp.Time) {
+					if p.After(pUp) {
 						// need to send point upstream
 						err := nats.SendEdgePoint(up.ncUp, nodeUp.ID, nodeUp.Parent, p, true)
 						if err != nil {
 							log.Println("Error syncing point upstream: ", err)
 						}
-					} else if p.Time.Before(pUp.Time) {
+					} else if pUp.After(p) {
 						// need to update point locally
 						err := nats.SendEdgePoint(up.nc, nodeLocal.ID, nodeLocal.Parent, pUp, true)
 						if err != nil {
@@ -370,13 +435,13 @@ func (up *Upstream) syncNode(id, parent string) error {
 		}
 
 		// sync child nodes
-		children, err := nats.GetNodeChildren(up.nc, nodeLocal.ID, "", true)
+		children, err := nats.GetNodeChildrenSync(up.nc, nodeLocal.ID, "", true)
 		if err != nil {
 			return fmt.Errorf("Error getting local node children: %v", err)
 		}
 
 		// FIXME optimization we get the edges here and not the full child node
-		upChildren, err := nats.GetNodeChildren(up.ncUp, nodeUp.ID, "", true)
+		upChildren, err := nats.GetNodeChildrenSync(up.ncUp, nodeUp.ID, "", true)
 		if err != nil {
 			return fmt.Errorf("Error getting upstream node children: %v", err)
 		}
@@ -448,6 +513,13 @@ func (up *Upstream) Stop() {
 		}
 	}
 
+	if up.subLocalEvents != nil {
+		err := up.subLocalEvents.Unsubscribe()
+		if err != nil {
+			log.Println("Error unsubscribing events from local bus: ", err)
+		}
+	}
+
 	for _, sub := range up.subUpNodePoints {
 		err := sub.Unsubscribe()
 		if err != nil {
@@ -11,3 +11,13 @@ import (
 func SetTime(t time.Time) (err error) {
 	return errors.New("not implemented")
 }
+
+// BootTime returns the time the system was started
+func BootTime() (time.Time, error) {
+	return time.Time{}, errors.New("not implemented")
+}
+
+// BootID returns an ID that changes each time the system is started
+func BootID() (string, error) {
+	return "", errors.New("not implemented")
+}
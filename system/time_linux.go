@@ -3,8 +3,14 @@
 package system
 
 import (
+	"bufio"
+	"errors"
+	"io/ioutil"
 	"log"
+	"os"
 	"os/exec"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 )
@@ -27,3 +33,44 @@ func SetTime(t time.Time) (err error) {
 
 	return nil
 }
+
+// BootTime returns the time the system was started. This is read from
+// /proc/stat, and moves when the system clock is set, so use BootID to
+// tell if the system was restarted.
+func BootTime() (time.Time, error) {
+	f, err := os.Open("/proc/stat")
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == "btime" {
+			sec, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				return time.Time{}, err
+			}
+			return time.Unix(sec, 0), nil
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Time{}, errors.New("btime not found in /proc/stat")
+}
+
+// BootID returns a random ID that the kernel generates each time the system
+// is started
+func BootID() (string, error) {
+	id, err := ioutil.ReadFile("/proc/sys/kernel/random/boot_id")
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(id)), nil
+}